@@ -43,6 +43,7 @@ var plistTemplate = `
         <string>--log-handler=syslog</string>
         <string>run</string>
         <string>unattended</string>
+        <string>--resume</string>
     </array>
     <key>StartInterval</key>
     <integer>3600</integer>
@@ -12,13 +12,24 @@ import (
 func init() {
 	cmd := root.Command("list", "List results")
 	resultID := cmd.Arg("id", "the id of the result to list measurements for").Int64()
+	skipped := cmd.Flag("skipped", "list the test group runs skipped because of the run policies").Bool()
 	cmd.Action(func(_ *kingpin.ParseContext) error {
 		probeCLI, err := root.Init()
 		if err != nil {
 			log.WithError(err).Error("failed to initialize root context")
 			return err
 		}
-		if *resultID > 0 {
+		if *skipped {
+			runs, err := probeCLI.DB().ListSkippedRuns()
+			if err != nil {
+				log.WithError(err).Error("failed to list skipped runs")
+				return err
+			}
+			output.SectionTitle("Skipped runs")
+			for _, run := range runs {
+				output.SkippedRunItem(run)
+			}
+		} else if *resultID > 0 {
 			measurements, err := probeCLI.DB().ListMeasurements(*resultID)
 			if err != nil {
 				log.WithError(err).Error("failed to list measurements")
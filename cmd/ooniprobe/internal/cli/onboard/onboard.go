@@ -16,6 +16,9 @@ import (
 
 // Onboarding start the interactive onboarding procedure
 func Onboarding(config *config.Config) error {
+	if output.JSONOutput() {
+		return output.ErrInteractiveJSONOutput
+	}
 	output.SectionTitle("What is OONI Probe?")
 
 	fmt.Println()
@@ -8,12 +8,16 @@ import (
 	"github.com/alecthomas/kingpin"
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/output"
 	"github.com/ooni/probe-cli/v3/internal/database"
 	"github.com/upper/db/v4"
 )
 
 func deleteAll(d *database.Database, skipInteractive bool) error {
 	if !skipInteractive {
+		if output.JSONOutput() {
+			return output.ErrInteractiveJSONOutput
+		}
 		answer := ""
 		confirm := &survey.Select{
 			Message: "Are you sure you wish to delete ALL results",
@@ -74,6 +78,9 @@ func init() {
 			}
 			return err
 		}
+		if output.JSONOutput() {
+			return output.ErrInteractiveJSONOutput
+		}
 		answer := ""
 		confirm := &survey.Select{
 			Message: fmt.Sprintf("Are you sure you wish to delete the result #%d", *resultID),
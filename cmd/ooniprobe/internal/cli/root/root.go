@@ -3,10 +3,13 @@ package root
 import (
 	"github.com/alecthomas/kingpin"
 	"github.com/apex/log"
+	"github.com/fatih/color"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/log/handlers/batch"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/log/handlers/cli"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/log/handlers/jsonlog"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/log/handlers/syslog"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/output"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/utils"
 	"github.com/ooni/probe-cli/v3/internal/version"
 )
@@ -37,6 +40,9 @@ func init() {
 	logHandler := Cmd.Flag(
 		"log-handler", "Set the desired log handler (one of: batch, cli, syslog)",
 	).String()
+	outputFormat := Cmd.Flag(
+		"output", "Set the output format (one of: text, json)",
+	).Default("text").Enum("text", "json")
 
 	softwareName := Cmd.Flag(
 		"software-name", "Override application name",
@@ -57,6 +63,12 @@ func init() {
 		if *isBatch {
 			*logHandler = "batch"
 		}
+		if *outputFormat == "json" {
+			if *logHandler != "" {
+				log.Fatal("cannot specify --output json and --batch or --log-handler together")
+			}
+			*logHandler = "json"
+		}
 		switch *logHandler {
 		case "batch":
 			log.SetHandler(batch.Default)
@@ -64,6 +76,10 @@ func init() {
 			log.SetHandler(cli.Default)
 		case "syslog":
 			log.SetHandler(syslog.Default)
+		case "json":
+			color.NoColor = true // avoid escape sequences inside messages
+			log.SetHandler(jsonlog.Default)
+			output.SetJSONOutput(true)
 		default:
 			log.Fatalf("unknown --log-handler: %s", *logHandler)
 		}
@@ -85,7 +101,7 @@ func init() {
 			if err != nil {
 				return nil, err
 			}
-			if *isBatch {
+			if *isBatch || *outputFormat == "json" {
 				probe.SetIsBatch(true)
 			}
 
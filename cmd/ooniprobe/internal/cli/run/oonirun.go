@@ -0,0 +1,114 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/apex/log"
+	"github.com/fatih/color"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/nettests"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/output"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/oonirun"
+)
+
+// errNoNettests indicates that an OONI Run descriptor does not contain nettests.
+var errNoNettests = errors.New("oonirun: the descriptor does not contain any nettest")
+
+// loadOONIRunDescriptorFile loads an OONI Run v2 descriptor from a file.
+func loadOONIRunDescriptorFile(filename string) (*oonirun.V2Descriptor, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var desc oonirun.V2Descriptor
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// pullOONIRunDescriptor fetches the OONI Run v2 descriptor at the given URL
+// and asks the user for consent if it's new or it has changed since the
+// last time the user accepted to run it.
+func pullOONIRunDescriptor(probe *ooni.Probe, URL string, yes bool) (*oonirun.V2Descriptor, error) {
+	ctx := context.Background()
+	sess, err := probe.NewSession(ctx, model.RunTypeManual)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+	config := &oonirun.LinkConfig{
+		KVStore: sess.KeyValueStore(),
+		Session: sess,
+	}
+	desc, diff, err := oonirun.V2PullDescriptor(ctx, config, URL)
+	if err != nil {
+		return nil, err
+	}
+	if desc == nil {
+		return nil, oonirun.ErrNilDescriptor
+	}
+	if diff == "" {
+		return desc, nil
+	}
+	if !yes {
+		output.SectionTitle("OONI Run changes")
+		output.OONIRunDiff(URL, diff)
+		if probe.IsBatch() {
+			log.Warn("oonirun: to accept these changes, rerun adding `--yes` to the command line")
+			return nil, oonirun.ErrNeedToAcceptChanges
+		}
+		accept := false
+		prompt := &survey.Confirm{
+			Message: "Do you want to run the nettests in this OONI Run link?",
+			Default: false,
+		}
+		if err := survey.AskOne(prompt, &accept, nil); err != nil {
+			return nil, err
+		}
+		if !accept {
+			return nil, oonirun.ErrNeedToAcceptChanges
+		}
+	}
+	if err := oonirun.V2AcceptDescriptor(config, URL, desc); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// runOONIRun runs the OONI Run v2 link or descriptor file indicated
+// by link and stores the results into the database. The resume and
+// restart flags control what to do with an interrupted run.
+func runOONIRun(probe *ooni.Probe, link string, yes, resume, restart bool) error {
+	var (
+		desc *oonirun.V2Descriptor
+		err  error
+	)
+	if _, statErr := os.Stat(link); statErr == nil {
+		desc, err = loadOONIRunDescriptorFile(link)
+	} else {
+		desc, err = pullOONIRunDescriptor(probe, link, yes)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("cannot load OONI Run descriptor %s", link)
+		return err
+	}
+	group := nettests.NewOONIRunGroup(desc)
+	if len(group.Nettests) <= 0 {
+		return errNoNettests
+	}
+	log.Infof("Running %s tests", color.BlueString(group.Label))
+	return nettests.RunGroup(nettests.RunGroupConfig{
+		GroupName: nettests.OONIRunGroupName,
+		Probe:     probe,
+		Restart:   restart,
+		Resume:    resume,
+		RunType:   model.RunTypeManual,
+		Group:     &group,
+	})
+}
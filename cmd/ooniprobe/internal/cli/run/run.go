@@ -1,6 +1,9 @@
 package run
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/alecthomas/kingpin"
 	"github.com/apex/log"
 	"github.com/fatih/color"
@@ -14,6 +17,8 @@ import (
 func init() {
 	cmd := root.Command("run", "Run a test group or OONI Run link")
 	noCollector := cmd.Flag("no-collector", "Disable uploading measurements to a collector").Bool()
+	resume := cmd.Flag("resume", "Resume an interrupted run skipping the already measured inputs").Bool()
+	restart := cmd.Flag("restart", "Discard the progress of an interrupted run and start from scratch").Bool()
 
 	var probe *ooni.Probe
 	cmd.Action(func(_ *kingpin.ParseContext) error {
@@ -30,11 +35,19 @@ func init() {
 		if *noCollector {
 			probe.Config().Sharing.UploadResults = false
 		}
+		if *resume && *restart {
+			return errors.New("--resume and --restart are mutually exclusive")
+		}
 		return nil
 	})
 
 	functionalRun := func(runType model.RunType, pred func(name string, gr nettests.Group) bool) error {
-		for name, group := range nettests.All {
+		groups, err := nettests.AllGroups(probe.Config())
+		if err != nil {
+			log.WithError(err).Error("invalid custom test groups")
+			return err
+		}
+		for name, group := range groups {
 			if !pred(name, group) {
 				continue
 			}
@@ -42,6 +55,8 @@ func init() {
 			conf := nettests.RunGroupConfig{
 				GroupName: name,
 				Probe:     probe,
+				Restart:   *restart,
+				Resume:    *resume,
 				RunType:   runType,
 			}
 			if err := nettests.RunGroup(conf); err != nil {
@@ -69,6 +84,8 @@ func init() {
 			Probe:      probe,
 			InputFiles: *inputFile,
 			Inputs:     *input,
+			Restart:    *restart,
+			Resume:     *resume,
 			RunType:    model.RunTypeManual,
 		})
 	})
@@ -79,6 +96,25 @@ func init() {
 		cmd.Command(name, "").Action(genRunWithGroupName(name))
 	}
 
+	groupCmd := cmd.Command("group", "Run a custom test group defined in the config file")
+	groupName := groupCmd.Arg("name", "Name of the custom test group").Required().String()
+	groupCmd.Action(func(_ *kingpin.ParseContext) error {
+		if _, builtin := nettests.All[*groupName]; builtin {
+			return fmt.Errorf("%s is a built-in group: use 'ooniprobe run %s'", *groupName, *groupName)
+		}
+		if _, found := probe.Config().Nettests.CustomGroups[*groupName]; !found {
+			return fmt.Errorf("no custom test group named %s", *groupName)
+		}
+		return genRunWithGroupName(*groupName)(nil)
+	})
+
+	oonirunCmd := cmd.Command("oonirun", "Run an OONI Run v2 link or descriptor file")
+	oonirunLink := oonirunCmd.Arg("link", "URL or path of the OONI Run v2 descriptor").Required().String()
+	oonirunYes := oonirunCmd.Flag("yes", "Accept new or changed OONI Run links without asking").Short('y').Bool()
+	oonirunCmd.Action(func(_ *kingpin.ParseContext) error {
+		return runOONIRun(probe, *oonirunLink, *oonirunYes, *resume, *restart)
+	})
+
 	unattendedCmd := cmd.Command("unattended", "")
 	unattendedCmd.Action(func(_ *kingpin.ParseContext) error {
 		return functionalRun(model.RunTypeTimed, func(name string, gr nettests.Group) bool {
@@ -0,0 +1,106 @@
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/onboard"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/root"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/daemon"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// errNotLoopback indicates that the user wants to listen on a non-loopback
+// address without passing the --allow-remote flag.
+var errNotLoopback = errors.New("serve: refusing to listen on a non-loopback address without --allow-remote")
+
+// isLoopback returns whether address is a loopback endpoint.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newToken generates a new random API token.
+func newToken() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	runtimex.PanicOnError(err, "rand.Read failed")
+	return hex.EncodeToString(b)
+}
+
+func init() {
+	cmd := root.Command("serve", "Run as a daemon exposing a local HTTP/JSON API")
+	address := cmd.Flag("address", "Address where to listen").Default("127.0.0.1:8765").String()
+	allowRemote := cmd.Flag("allow-remote", "Allow listening on a non-loopback address").Bool()
+	metricsAddress := cmd.Flag("metrics-address", "Address where to serve Prometheus metrics (default: disabled)").String()
+	token := cmd.Flag("token", "Token for authenticating API requests (default: random)").
+		Envar("OONIPROBE_API_TOKEN").String()
+	cmd.Action(func(_ *kingpin.ParseContext) error {
+		if !*allowRemote && !isLoopback(*address) {
+			return errNotLoopback
+		}
+		if !*allowRemote && *metricsAddress != "" && !isLoopback(*metricsAddress) {
+			return errNotLoopback
+		}
+		probe, err := root.Init()
+		if err != nil {
+			log.Errorf("%s", err)
+			return err
+		}
+		if err := onboard.MaybeOnboarding(probe); err != nil {
+			log.WithError(err).Error("failed to perform onboarding")
+			return err
+		}
+		if *token == "" {
+			*token = newToken()
+			// Implementation note: we must not log the token because logs end up
+			// in log files and in the stream of events that clients can read.
+			fmt.Fprintf(os.Stderr, "API token: %s\n", *token)
+		}
+		logger := log.Log.(*log.Logger)
+		events := daemon.NewBroadcaster(logger.Handler)
+		logger.Handler = events
+		srvr := &http.Server{
+			Addr:    *address,
+			Handler: daemon.NewServer(probe, *token, events).Handler(),
+		}
+		if *metricsAddress != "" {
+			metrics, err := probemetrics.Serve(*metricsAddress, log.Log)
+			if err != nil {
+				return err
+			}
+			defer metrics.Close()
+		}
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			log.Info("caught a stop signal, shutting down cleanly")
+			probe.Terminate()
+			srvr.Shutdown(context.Background())
+		}()
+		log.Infof("listening at http://%s/api/v1", *address)
+		if err := srvr.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+}
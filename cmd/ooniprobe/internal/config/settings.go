@@ -13,4 +13,59 @@ type Nettests struct {
 	WebsitesMaxRuntime           int64    `json:"websites_max_runtime"`
 	WebsitesURLLimit             int64    `json:"websites_url_limit"`
 	WebsitesEnabledCategoryCodes []string `json:"websites_enabled_category_codes"`
+
+	// CustomGroups contains user-defined nettest groups indexed by
+	// the name used to run them (e.g., `ooniprobe run group dns`).
+	CustomGroups map[string]CustomGroup `json:"custom_groups,omitempty"`
+
+	// RunPolicies contains rules restricting which groups we
+	// run depending on the network we're connected to.
+	RunPolicies []RunPolicy `json:"run_policies,omitempty"`
+}
+
+// CustomGroup is a user-defined group of nettests
+type CustomGroup struct {
+	// Label is the human readable name of the group.
+	Label string `json:"label"`
+
+	// Nettests contains the nettests belonging to the group.
+	Nettests []CustomNettest `json:"nettests"`
+
+	// UnattendedOK indicates whether we can run this group
+	// when running in the background (i.e., `run unattended`).
+	UnattendedOK bool `json:"unattended_ok"`
+}
+
+// CustomNettest is a nettest inside a CustomGroup
+type CustomNettest struct {
+	// TestName is the name of the experiment to run (e.g., "dnscheck").
+	TestName string `json:"test_name"`
+
+	// Inputs optionally contains the inputs for the experiment.
+	Inputs []string `json:"inputs,omitempty"`
+
+	// Options optionally contains the experiment options.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// RunPolicy is a rule restricting what we run on matching networks
+type RunPolicy struct {
+	// ProbeASN is the ASN to match. Zero matches any ASN.
+	ProbeASN uint `json:"probe_asn,omitempty"`
+
+	// ProbeCC is the country code to match. Empty matches any country.
+	ProbeCC string `json:"probe_cc,omitempty"`
+
+	// SkipGroups contains the groups we must not run.
+	SkipGroups []string `json:"skip_groups,omitempty"`
+
+	// OnlyGroups, when not empty, contains the only groups we can run.
+	OnlyGroups []string `json:"only_groups,omitempty"`
+
+	// MaxDailyMB, when positive, is the maximum amount of data in
+	// MB we can send and receive per day on matching networks. We account
+	// the usage per ASN, when ProbeASN is set, otherwise per country, when
+	// ProbeCC is set, otherwise across all networks. We record each run
+	// we skip, which `ooniprobe list --skipped` shows.
+	MaxDailyMB float64 `json:"max_daily_mb,omitempty"`
 }
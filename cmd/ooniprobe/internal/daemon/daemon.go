@@ -0,0 +1,381 @@
+// Package daemon implements the local HTTP/JSON API that ooniprobe
+// exposes when running as a daemon (i.e., `ooniprobe serve`).
+//
+// All the endpoints require the `Authorization: Bearer <token>` header
+// (or the `token` query string parameter, which is useful for browsers
+// using EventSource). The API is the following:
+//
+// - GET /api/v1/status returns the daemon status;
+//
+// - POST /api/v1/run starts running a group (e.g., `{"group":"websites"}`);
+//
+// - POST /api/v1/stop interrupts the group that is running;
+//
+// - GET /api/v1/events streams events using server-sent events;
+//
+// - GET /api/v1/results lists the results;
+//
+// - GET /api/v1/results/<id> lists the measurements of a result;
+//
+// - GET /api/v1/measurements/<id> returns the JSON of a measurement.
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/nettests"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// ErrAlreadyRunning indicates that we're already running a group.
+var ErrAlreadyRunning = errors.New("daemon: already running a group")
+
+// ErrNotRunning indicates that we're not running any group.
+var ErrNotRunning = errors.New("daemon: not running any group")
+
+// Server is the ooniprobe daemon. The zero value is invalid; please,
+// use NewServer to construct a new instance.
+type Server struct {
+	events *Broadcaster
+	mu     sync.Mutex
+	probe  *ooni.Probe
+	group  string
+	token  string
+
+	// runGroup allows to mock nettests.RunGroup in tests.
+	runGroup func(config nettests.RunGroupConfig) error
+}
+
+// NewServer creates a new Server that runs nettests using the given probe,
+// authenticates requests using token and streams the events collected
+// by the given Broadcaster.
+func NewServer(probe *ooni.Probe, token string, events *Broadcaster) *Server {
+	return &Server{
+		events:   events,
+		probe:    probe,
+		token:    token,
+		runGroup: nettests.RunGroup,
+	}
+}
+
+// Handler returns the http.Handler implementing the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/status", s.handleStatus)
+	mux.HandleFunc("/api/v1/run", s.handleRun)
+	mux.HandleFunc("/api/v1/stop", s.handleStop)
+	mux.HandleFunc("/api/v1/events", s.handleEvents)
+	mux.HandleFunc("/api/v1/results", s.handleResults)
+	mux.HandleFunc("/api/v1/results/", s.handleResult)
+	mux.HandleFunc("/api/v1/measurements/", s.handleMeasurement)
+	return s.withAuth(mux)
+}
+
+// withAuth wraps the given handler and requires authentication.
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("daemon: invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Status is the response of GET /api/v1/status.
+type Status struct {
+	// Running indicates whether we're running a group.
+	Running bool `json:"running"`
+
+	// Group is the name of the group we're running, if any.
+	Group string `json:"group,omitempty"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	s.mu.Lock()
+	status := &Status{Running: s.group != "", Group: s.group}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// RunRequest is the request body of POST /api/v1/run.
+type RunRequest struct {
+	// Group is the name of the group to run.
+	Group string `json:"group"`
+
+	// Inputs optionally contains inputs for the group's nettests.
+	Inputs []string `json:"inputs,omitempty"`
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	groups, err := nettests.AllGroups(s.probe.Config())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if _, found := groups[req.Group]; !found {
+		writeError(w, http.StatusBadRequest, errors.New("daemon: no such group"))
+		return
+	}
+	if err := s.start(req); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, &Status{Running: true, Group: req.Group})
+}
+
+// start starts running the group in a background goroutine.
+func (s *Server) start(req RunRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.group != "" {
+		return ErrAlreadyRunning
+	}
+	s.group = req.Group
+	s.probe.ResetTerminated()
+	go func() {
+		err := s.runGroup(nettests.RunGroupConfig{
+			GroupName: req.Group,
+			Inputs:    req.Inputs,
+			Probe:     s.probe,
+			RunType:   model.RunTypeManual,
+		})
+		ev := &Event{
+			Type:    "run_finished",
+			Level:   log.InfoLevel.String(),
+			Message: "run finished",
+			Data:    map[string]any{"group": req.Group},
+			Time:    time.Now(),
+		}
+		if err != nil {
+			ev.Level = log.ErrorLevel.String()
+			ev.Data["error"] = err.Error()
+		}
+		s.mu.Lock()
+		s.group = ""
+		s.mu.Unlock()
+		s.events.Publish(ev)
+	}()
+	return nil
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	s.mu.Lock()
+	group := s.group
+	s.mu.Unlock()
+	if group == "" {
+		writeError(w, http.StatusConflict, ErrNotRunning)
+		return
+	}
+	s.probe.Terminate()
+	writeJSON(w, http.StatusOK, &Status{Running: true, Group: group})
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("daemon: streaming not supported"))
+		return
+	}
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue // e.g., a field that cannot be serialized
+			}
+			if _, err := w.Write([]byte("event: " + ev.Type + "\ndata: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Result is a result in the response of GET /api/v1/results.
+type Result struct {
+	ID                 int64     `json:"id"`
+	TestGroupName      string    `json:"test_group_name"`
+	StartTime          time.Time `json:"start_time"`
+	Runtime            float64   `json:"runtime"`
+	IsDone             bool      `json:"is_done"`
+	IsUploaded         bool      `json:"is_uploaded"`
+	DataUsageUp        float64   `json:"data_usage_up"`
+	DataUsageDown      float64   `json:"data_usage_down"`
+	NetworkName        string    `json:"network_name"`
+	ASN                uint      `json:"asn"`
+	NetworkCountryCode string    `json:"network_country_code"`
+	MeasurementCount   uint64    `json:"measurement_count"`
+	AnomalyCount       uint64    `json:"anomaly_count"`
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	done, incomplete, err := s.probe.DB().ListResults()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []*Result{}
+	for _, entry := range append(incomplete, done...) {
+		out = append(out, &Result{
+			ID:                 entry.DatabaseResult.ID,
+			TestGroupName:      entry.TestGroupName,
+			StartTime:          entry.StartTime,
+			Runtime:            entry.Runtime,
+			IsDone:             entry.IsDone,
+			IsUploaded:         entry.IsUploaded,
+			DataUsageUp:        entry.DataUsageUp,
+			DataUsageDown:      entry.DataUsageDown,
+			NetworkName:        entry.NetworkName,
+			ASN:                entry.ASN,
+			NetworkCountryCode: entry.DatabaseNetwork.CountryCode,
+			MeasurementCount:   entry.TotalCount,
+			AnomalyCount:       entry.AnomalyCount,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// Measurement is a measurement in the response of GET /api/v1/results/<id>.
+type Measurement struct {
+	ID               int64     `json:"id"`
+	TestName         string    `json:"test_name"`
+	StartTime        time.Time `json:"start_time"`
+	Runtime          float64   `json:"runtime"`
+	URL              string    `json:"url,omitempty"`
+	CategoryCode     string    `json:"category_code,omitempty"`
+	IsAnomaly        bool      `json:"is_anomaly"`
+	IsFailed         bool      `json:"is_failed"`
+	FailureMsg       string    `json:"failure_msg,omitempty"`
+	IsUploaded       bool      `json:"is_uploaded"`
+	IsUploadFailed   bool      `json:"is_upload_failed"`
+	UploadFailureMsg string    `json:"upload_failure_msg,omitempty"`
+	ReportID         string    `json:"report_id,omitempty"`
+	TestKeys         string    `json:"test_keys"`
+}
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	resultID, err := parseID(r.URL.Path, "/api/v1/results/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	measurements, err := s.probe.DB().ListMeasurements(resultID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []*Measurement{}
+	for _, entry := range measurements {
+		out = append(out, &Measurement{
+			ID:               entry.DatabaseMeasurement.ID,
+			TestName:         entry.TestName,
+			StartTime:        entry.DatabaseMeasurement.StartTime,
+			Runtime:          entry.DatabaseMeasurement.Runtime,
+			URL:              entry.URL.String,
+			CategoryCode:     entry.CategoryCode.String,
+			IsAnomaly:        entry.IsAnomaly.Bool,
+			IsFailed:         entry.IsFailed,
+			FailureMsg:       entry.FailureMsg.String,
+			IsUploaded:       entry.DatabaseMeasurement.IsUploaded,
+			IsUploadFailed:   entry.IsUploadFailed,
+			UploadFailureMsg: entry.UploadFailureMsg.String,
+			ReportID:         entry.ReportID.String,
+			TestKeys:         entry.TestKeys,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleMeasurement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("daemon: method not allowed"))
+		return
+	}
+	msmtID, err := parseID(r.URL.Path, "/api/v1/measurements/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	msmt, err := s.probe.DB().GetMeasurementJSON(msmtID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msmt)
+}
+
+// parseID parses the numeric ID following prefix in path.
+func parseID(path, prefix string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(path, prefix), 10, 64)
+}
+
+// Error is the body of an error response.
+type Error struct {
+	// Error is the error message.
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		code, data = http.StatusInternalServerError, []byte(`{"error":"cannot serialize response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/nettests"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+)
+
+func newOONIProbe(t *testing.T) *ooni.Probe {
+	homePath := t.TempDir()
+	configPath := filepath.Join(homePath, "config.json")
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "testing-config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	probe := ooni.NewProbe(configPath, homePath)
+	if err := probe.Init("ooniprobe-cli-tests", "3.0.0-alpha", ""); err != nil {
+		t.Fatal(err)
+	}
+	return probe
+}
+
+func doRequest(t *testing.T, handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServer(t *testing.T) {
+	const token = "xo"
+
+	t.Run("we reject requests without a valid token", func(t *testing.T) {
+		srvr := NewServer(newOONIProbe(t), token, NewBroadcaster(nil))
+		for _, tok := range []string{"", "invalid"} {
+			rr := doRequest(t, srvr.Handler(), "GET", "/api/v1/status", tok, "")
+			if rr.Code != http.StatusUnauthorized {
+				t.Fatal("unexpected status code", rr.Code)
+			}
+		}
+	})
+
+	t.Run("we accept the token as a query string parameter", func(t *testing.T) {
+		srvr := NewServer(newOONIProbe(t), token, NewBroadcaster(nil))
+		rr := doRequest(t, srvr.Handler(), "GET", "/api/v1/status?token="+token, "", "")
+		if rr.Code != http.StatusOK {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+	})
+
+	t.Run("we can run and stop a group", func(t *testing.T) {
+		events := NewBroadcaster(nil)
+		ch, unsubscribe := events.Subscribe()
+		defer unsubscribe()
+		srvr := NewServer(newOONIProbe(t), token, events)
+		stopped := make(chan bool)
+		srvr.runGroup = func(config nettests.RunGroupConfig) error {
+			for !config.Probe.IsTerminated() {
+				time.Sleep(10 * time.Millisecond)
+			}
+			close(stopped)
+			return errors.New("mocked error")
+		}
+		handler := srvr.Handler()
+		rr := doRequest(t, handler, "POST", "/api/v1/run", token, `{"group":"websites"}`)
+		if rr.Code != http.StatusAccepted {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+		rr = doRequest(t, handler, "POST", "/api/v1/run", token, `{"group":"im"}`)
+		if rr.Code != http.StatusConflict {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+		rr = doRequest(t, handler, "GET", "/api/v1/status", token, "")
+		var status Status
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if !status.Running || status.Group != "websites" {
+			t.Fatal("unexpected status", status)
+		}
+		rr = doRequest(t, handler, "POST", "/api/v1/stop", token, "")
+		if rr.Code != http.StatusOK {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+		<-stopped
+		ev := <-ch
+		if ev.Type != "run_finished" || ev.Data["error"] != "mocked error" {
+			t.Fatal("unexpected event", ev)
+		}
+		rr = doRequest(t, handler, "POST", "/api/v1/stop", token, "")
+		if rr.Code != http.StatusConflict {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+	})
+
+	t.Run("we cannot run a nonexistent group", func(t *testing.T) {
+		srvr := NewServer(newOONIProbe(t), token, NewBroadcaster(nil))
+		rr := doRequest(t, srvr.Handler(), "POST", "/api/v1/run", token, `{"group":"antani"}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+	})
+
+	t.Run("we can list results", func(t *testing.T) {
+		srvr := NewServer(newOONIProbe(t), token, NewBroadcaster(nil))
+		rr := doRequest(t, srvr.Handler(), "GET", "/api/v1/results", token, "")
+		if rr.Code != http.StatusOK {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+		if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+			t.Fatal("unexpected body", body)
+		}
+	})
+
+	t.Run("we fail with an invalid measurement ID", func(t *testing.T) {
+		srvr := NewServer(newOONIProbe(t), token, NewBroadcaster(nil))
+		rr := doRequest(t, srvr.Handler(), "GET", "/api/v1/measurements/antani", token, "")
+		if rr.Code != http.StatusBadRequest {
+			t.Fatal("unexpected status code", rr.Code)
+		}
+	})
+}
+
+func TestBroadcaster(t *testing.T) {
+	events := NewBroadcaster(nil)
+	ch, unsubscribe := events.Subscribe()
+	entry := log.NewEntry(log.Log.(*log.Logger))
+	entry.Fields = log.Fields{
+		"type":       "progress",
+		"percentage": 0.5,
+	}
+	entry.Level = log.InfoLevel
+	entry.Message = "processing input"
+	if err := events.HandleLog(entry); err != nil {
+		t.Fatal(err)
+	}
+	ev := <-ch
+	if ev.Type != "progress" || ev.Message != "processing input" || ev.Data["percentage"] != 0.5 {
+		t.Fatal("unexpected event", ev)
+	}
+	if _, found := ev.Data["type"]; found {
+		t.Fatal("the type should not be inside data")
+	}
+	unsubscribe()
+	events.Publish(&Event{}) // should not block or panic
+}
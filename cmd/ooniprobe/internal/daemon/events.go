@@ -0,0 +1,76 @@
+package daemon
+
+//
+// Broadcasting log events to API clients
+//
+
+import (
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/log/handlers/jsonlog"
+)
+
+// Event is an event emitted by ooniprobe and streamed to API clients. We
+// use the same schema used by `--output json` (see the jsonlog package).
+type Event = jsonlog.Event
+
+// eventsBufferSize is the buffer size of each subscriber's channel. When a
+// subscriber is too slow to drain its channel, we drop events.
+const eventsBufferSize = 256
+
+// Broadcaster is a log.Handler that forwards log entries to another
+// handler and broadcasts them as events to all the subscribers.
+type Broadcaster struct {
+	mu   sync.Mutex
+	next log.Handler
+	subs map[chan *Event]bool
+}
+
+var _ log.Handler = &Broadcaster{}
+
+// NewBroadcaster creates a new Broadcaster forwarding to next.
+func NewBroadcaster(next log.Handler) *Broadcaster {
+	return &Broadcaster{
+		next: next,
+		subs: make(map[chan *Event]bool),
+	}
+}
+
+// HandleLog implements log.Handler.
+func (b *Broadcaster) HandleLog(e *log.Entry) error {
+	ev := jsonlog.NewEvent(e)
+	b.Publish(ev)
+	if b.next == nil {
+		return nil
+	}
+	return b.next.HandleLog(e)
+}
+
+// Publish broadcasts the given event to all the subscribers.
+func (b *Broadcaster) Publish(ev *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			// the subscriber is too slow
+		}
+	}
+}
+
+// Subscribe returns a channel where we post events and a
+// function to unsubscribe when you're not interested anymore.
+func (b *Broadcaster) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, eventsBufferSize)
+	b.mu.Lock()
+	b.subs[ch] = true
+	b.mu.Unlock()
+	unsubscribe := func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+	return ch, unsubscribe
+}
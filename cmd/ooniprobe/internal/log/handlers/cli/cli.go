@@ -72,6 +72,19 @@ func logSectionTitle(w io.Writer, f log.Fields) error {
 	return nil
 }
 
+func logOONIRunDiff(w io.Writer, e *log.Entry) error {
+	diff := e.Fields.Get("diff").(string)
+	fmt.Fprintf(w, "%s:\n\n%s\n", e.Message, diff)
+	return nil
+}
+
+func logSkippedRunItem(w io.Writer, f log.Fields) error {
+	fmt.Fprintf(w, "#%d %s %s: %s\n", f.Get("id").(int64),
+		f.Get("time").(time.Time).Local().Format(time.RFC822),
+		bold.Sprint(f.Get("name").(string)), f.Get("reason").(string))
+	return nil
+}
+
 func logTable(w io.Writer, f log.Fields) error {
 	color := color.New(color.FgBlue)
 
@@ -134,6 +147,10 @@ func (h *Handler) TypedLog(t string, e *log.Entry) error {
 		return logResultSummary(h.Writer, e.Fields)
 	case "section_title":
 		return logSectionTitle(h.Writer, e.Fields)
+	case "oonirun_diff":
+		return logOONIRunDiff(h.Writer, e)
+	case "skipped_run_item":
+		return logSkippedRunItem(h.Writer, e.Fields)
 	default:
 		return h.DefaultLog(e)
 	}
@@ -0,0 +1,151 @@
+// Package jsonlog contains the log handler used by `--output json`.
+//
+// This handler emits on the standard output one JSON object per line
+// (i.e., JSONL) with the following schema:
+//
+//	{
+//	  "type": "<string>",
+//	  "level": "<string>",
+//	  "message": "<string>",
+//	  "data": {},
+//	  "time": "<RFC3339 time>"
+//	}
+//
+// where "level" is one of "debug", "info", "warn", "error", "fatal" and
+// "data" contains the fields of the event, which depend on its "type":
+//
+// - "result_item" (emitted by `list`) describes a result and contains
+// "id", "name", "start_time", "test_keys", "measurement_count",
+// "measurement_anomaly_count", "network_country_code", "network_name",
+// "asn", "runtime", "is_done", "is_uploaded", "data_usage_down",
+// "data_usage_up", "index" and "total_count";
+//
+// - "result_summary" (emitted by `list`) contains "total_tests",
+// "total_data_usage_up", "total_data_usage_down" and "total_networks";
+//
+// - "skipped_run_item" (emitted by `list --skipped`) describes a test group
+// run we skipped because of the run policies and contains "id", "name",
+// "time", "reason" and "network_id";
+//
+// - "measurement_item" (emitted by `list <id>`) describes a measurement
+// and contains "id", "test_name", "test_group_name", "start_time",
+// "test_keys", "network_country_code", "network_name", "asn", "runtime",
+// "url", "url_category_code", "url_country_code", "is_anomaly",
+// "is_uploaded", "is_upload_failed", "upload_failure_msg", "is_failed",
+// "failure_msg", "is_done", "report_file_path" and "measurement_file_path";
+//
+// - "measurement_summary" (emitted by `list <id>`) contains "total_runtime",
+// "total_count", "anomaly_count", "data_usage_down", "data_usage_up",
+// "asn", "network_country_code", "network_name" and "start_time";
+//
+// - "measurement_json" (emitted by `show`) contains the measurement
+// inside the "measurement_json" field;
+//
+// - "progress" (emitted by `run`) contains "key", "percentage" (between
+// 0 and 1) and "eta" (in seconds, negative if unknown);
+//
+// - "table" (emitted by `geoip`) contains "asn", "network_name",
+// "country_code" and "ip";
+//
+// - "version" (emitted by `version`) contains "version";
+//
+// - "section_title" contains "title";
+//
+// - "oonirun_diff" (emitted by `run oonirun`) contains "url" and "diff",
+// i.e., the changes of a new or changed OONI Run descriptor;
+//
+// - "engine" is a log message emitted by the measurement engine;
+//
+// - "error" is an error message (e.g., because a command failed) and "data"
+// contains the "error" field when there is an underlying error;
+//
+// - "log" is any other log message, with arbitrary "data" (e.g., `info`
+// emits the "Home" and "TempDir" messages with the "path" field).
+//
+// Because the standard output only contains these events, commands that
+// would otherwise interact with the user (e.g., `onboard` without `--yes`)
+// fail when using `--output json`.
+package jsonlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// Default handler outputting to stdout. See the comment in the
+// batch package for why we're emitting on the standard output.
+var Default = New(os.Stdout)
+
+// Event is an event emitted by this handler.
+type Event struct {
+	// Type is the event type (see the package documentation).
+	Type string `json:"type"`
+
+	// Level is the log level.
+	Level string `json:"level"`
+
+	// Message is the log message.
+	Message string `json:"message"`
+
+	// Data contains type-dependent data.
+	Data map[string]any `json:"data"`
+
+	// Time is the time when the event was emitted.
+	Time time.Time `json:"time"`
+}
+
+// NewEvent converts a log entry to an Event. We obtain the type from
+// the "type" field, which we remove from Data, and we convert errors to
+// strings, because they would otherwise be serialized as `{}`.
+func NewEvent(e *log.Entry) *Event {
+	ev := &Event{
+		Type:    "log",
+		Level:   e.Level.String(),
+		Message: e.Message,
+		Data:    make(map[string]any),
+		Time:    e.Timestamp,
+	}
+	if e.Level >= log.ErrorLevel {
+		ev.Type = "error"
+	}
+	for key, value := range e.Fields {
+		if key == "type" {
+			if s, ok := value.(string); ok {
+				ev.Type = s
+			}
+			continue
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		ev.Data[key] = value
+	}
+	return ev
+}
+
+// Handler implementation.
+type Handler struct {
+	enc *json.Encoder
+	mu  sync.Mutex
+}
+
+var _ log.Handler = &Handler{}
+
+// New handler.
+func New(w io.Writer) *Handler {
+	return &Handler{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.enc.Encode(NewEvent(e))
+}
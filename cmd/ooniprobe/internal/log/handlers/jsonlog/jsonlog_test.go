@@ -0,0 +1,100 @@
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+)
+
+func TestHandler(t *testing.T) {
+	newEntry := func(level log.Level, fields log.Fields) *log.Entry {
+		return &log.Entry{
+			Fields:    fields,
+			Level:     level,
+			Message:   "antani",
+			Timestamp: time.Now(),
+		}
+	}
+
+	t.Run("typed entries", func(t *testing.T) {
+		ev := NewEvent(newEntry(log.InfoLevel, log.Fields{
+			"type":       "progress",
+			"percentage": 0.5,
+		}))
+		if ev.Type != "progress" || ev.Level != "info" || ev.Message != "antani" {
+			t.Fatal("unexpected event", ev)
+		}
+		if _, found := ev.Data["type"]; found {
+			t.Fatal("type should not be inside data")
+		}
+		if ev.Data["percentage"] != 0.5 {
+			t.Fatal("unexpected data", ev.Data)
+		}
+	})
+
+	t.Run("untyped entries", func(t *testing.T) {
+		ev := NewEvent(newEntry(log.WarnLevel, log.Fields{}))
+		if ev.Type != "log" {
+			t.Fatal("unexpected type", ev.Type)
+		}
+	})
+
+	t.Run("error entries", func(t *testing.T) {
+		ev := NewEvent(newEntry(log.ErrorLevel, log.Fields{
+			"error": errors.New("mocked error"),
+		}))
+		if ev.Type != "error" || ev.Data["error"] != "mocked error" {
+			t.Fatal("unexpected event", ev)
+		}
+	})
+
+	t.Run("we emit JSONL", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		handler := New(buf)
+		entry := newEntry(log.InfoLevel, log.Fields{"type": "table", "asn": "AS30722"})
+		for i := 0; i < 2; i++ {
+			if err := handler.HandleLog(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if len(lines) != 2 {
+			t.Fatal("unexpected number of lines", len(lines))
+		}
+		var ev Event
+		if err := json.Unmarshal(lines[0], &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != "table" || ev.Data["asn"] != "AS30722" {
+			t.Fatal("unexpected event", ev)
+		}
+	})
+
+	t.Run("multiline data stays on a single line", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		handler := New(buf)
+		entry := newEntry(log.InfoLevel, log.Fields{
+			"type": "oonirun_diff",
+			"url":  "https://run.ooni.io/v2/1",
+			"diff": "--- old\n+++ new\n-a\n+b\n",
+		})
+		if err := handler.HandleLog(entry); err != nil {
+			t.Fatal(err)
+		}
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if len(lines) != 1 {
+			t.Fatal("unexpected number of lines", len(lines))
+		}
+		var ev Event
+		if err := json.Unmarshal(lines[0], &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != "oonirun_diff" || ev.Data["diff"] != "--- old\n+++ new\n-a\n+b\n" {
+			t.Fatal("unexpected event", ev)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package nettests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
+	engine "github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// Custom is a nettest defined by the user inside a custom group
+// in the configuration file rather than being hardcoded.
+type Custom struct {
+	// TestName is the name of the experiment to run.
+	TestName string
+
+	// Inputs contains the inputs configured for the experiment.
+	Inputs []string
+
+	// Options contains the options configured for the experiment. Because
+	// we decode them from JSON, numbers are float64, which the registry
+	// converts to the type of the corresponding option.
+	Options map[string]any
+}
+
+func (n Custom) lookupURLs(ctl *Controller, builder model.ExperimentBuilder) ([]string, error) {
+	inputloader := &engine.InputLoader{
+		CheckInConfig: &model.OOAPICheckInConfig{
+			// See the comment in web_connectivity.go
+			Charging: true,
+			OnWiFi:   true,
+			RunType:  ctl.RunType,
+			WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+				CategoryCodes: ctl.Probe.Config().Nettests.WebsitesEnabledCategoryCodes,
+			},
+		},
+		ExperimentName: n.TestName,
+		InputPolicy:    builder.InputPolicy(),
+		Session:        ctl.Session,
+		SourceFiles:    ctl.InputFiles,
+		StaticInputs:   append(append([]string{}, n.Inputs...), ctl.Inputs...),
+	}
+	testlist, err := inputloader.Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ctl.BuildAndSetInputIdxMap(testlist)
+}
+
+// Run starts the nettest.
+func (n Custom) Run(ctl *Controller) error {
+	builder, err := ctl.Session.NewExperimentBuilder(n.TestName)
+	if err != nil {
+		return err
+	}
+	if err := builder.SetOptionsAny(n.Options); err != nil {
+		return err
+	}
+	urls, err := n.lookupURLs(ctl, builder)
+	if err != nil {
+		return err
+	}
+	return ctl.Run(builder, urls)
+}
+
+// NewCustomGroup creates a Group from a user-defined config.CustomGroup.
+func NewCustomGroup(cg config.CustomGroup) Group {
+	group := Group{
+		Label:        cg.Label,
+		UnattendedOK: cg.UnattendedOK,
+	}
+	for _, cn := range cg.Nettests {
+		group.Nettests = append(group.Nettests, Custom{
+			TestName: cn.TestName,
+			Inputs:   cn.Inputs,
+			Options:  cn.Options,
+		})
+	}
+	return group
+}
+
+// maxGroupNameLength is the maximum length of test_group_name
+// in the results table of the database.
+const maxGroupNameLength = 16
+
+// AllGroups returns the built-in groups (see All) merged with the custom
+// groups declared in the given config. This function fails if a custom
+// group tries to override a built-in group or has no nettests.
+func AllGroups(c *config.Config) (map[string]Group, error) {
+	out := make(map[string]Group, len(All))
+	for name, group := range All {
+		out[name] = group
+	}
+	for name, cg := range c.Nettests.CustomGroups {
+		if _, found := All[name]; found {
+			return nil, fmt.Errorf("custom group %s overrides a built-in group", name)
+		}
+		if name == "" || len(name) > maxGroupNameLength {
+			return nil, fmt.Errorf("custom group name %q is empty or too long", name)
+		}
+		if len(cg.Nettests) <= 0 {
+			return nil, fmt.Errorf("custom group %s has no nettests", name)
+		}
+		group := NewCustomGroup(cg)
+		if group.Label == "" {
+			group.Label = name
+		}
+		out[name] = group
+	}
+	return out, nil
+}
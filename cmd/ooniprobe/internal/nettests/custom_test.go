@@ -0,0 +1,136 @@
+package nettests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
+	"github.com/ooni/probe-cli/v3/internal/oonirun"
+	"github.com/ooni/probe-cli/v3/internal/registry"
+)
+
+func TestAllGroups(t *testing.T) {
+	t.Run("without custom groups", func(t *testing.T) {
+		groups, err := AllGroups(&config.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != len(All) {
+			t.Fatal("unexpected number of groups")
+		}
+	})
+
+	t.Run("with a valid custom group", func(t *testing.T) {
+		// Note: we decode the config from JSON, such that the
+		// options contain float64 numbers like in production.
+		data := []byte(`{
+			"nettests": {
+				"custom_groups": {
+					"dns": {
+						"nettests": [{
+							"test_name": "dnscheck",
+							"inputs": ["https://dns.google/dns-query"]
+						}, {
+							"test_name": "dnsping",
+							"options": {"Repetitions": 3}
+						}],
+						"unattended_ok": true
+					}
+				}
+			}
+		}`)
+		c := &config.Config{}
+		if err := json.Unmarshal(data, c); err != nil {
+			t.Fatal(err)
+		}
+		groups, err := AllGroups(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group, found := groups["dns"]
+		if !found {
+			t.Fatal("custom group not found")
+		}
+		if group.Label != "dns" {
+			t.Fatal("expected the label to default to the name")
+		}
+		if !group.UnattendedOK {
+			t.Fatal("expected UnattendedOK to be true")
+		}
+		if len(group.Nettests) != 2 {
+			t.Fatal("unexpected number of nettests")
+		}
+		nt := group.Nettests[1].(Custom)
+		if nt.TestName != "dnsping" {
+			t.Fatal("unexpected nettest", nt)
+		}
+		// make sure the experiment accepts the options decoded from JSON
+		factory, err := registry.NewFactory(nt.TestName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := factory.SetOptionsAny(nt.Options); err != nil {
+			t.Fatal(err)
+		}
+		options, err := factory.Options()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := options["Repetitions"].Value; v != int64(3) {
+			t.Fatalf("unexpected Repetitions: %v (%T)", v, v)
+		}
+	})
+
+	t.Run("with a custom group overriding a built-in group", func(t *testing.T) {
+		c := &config.Config{}
+		c.Nettests.CustomGroups = map[string]config.CustomGroup{
+			"websites": {Nettests: []config.CustomNettest{{TestName: "dnscheck"}}},
+		}
+		if _, err := AllGroups(c); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+
+	t.Run("with a custom group without nettests", func(t *testing.T) {
+		c := &config.Config{}
+		c.Nettests.CustomGroups = map[string]config.CustomGroup{"dns": {}}
+		if _, err := AllGroups(c); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+
+	t.Run("with a custom group with a too long name", func(t *testing.T) {
+		c := &config.Config{}
+		c.Nettests.CustomGroups = map[string]config.CustomGroup{
+			"a-very-long-group-name": {Nettests: []config.CustomNettest{{TestName: "dnscheck"}}},
+		}
+		if _, err := AllGroups(c); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+}
+
+func TestNewOONIRunGroup(t *testing.T) {
+	desc := &oonirun.V2Descriptor{
+		Name: "DNS over HTTPS",
+		Nettests: []oonirun.V2Nettest{{
+			TestName: "dnscheck",
+			Inputs:   []string{"https://dns.google/dns-query"},
+		}, {
+			TestName: "", // should be skipped
+		}},
+	}
+	group := NewOONIRunGroup(desc)
+	if group.Label != "DNS over HTTPS" {
+		t.Fatal("unexpected label", group.Label)
+	}
+	if group.UnattendedOK {
+		t.Fatal("OONI Run groups should not run unattended")
+	}
+	if len(group.Nettests) != 1 {
+		t.Fatal("unexpected number of nettests")
+	}
+	if nt := group.Nettests[0].(Custom); nt.TestName != "dnscheck" || len(nt.Inputs) != 1 {
+		t.Fatal("unexpected nettest", nt)
+	}
+}
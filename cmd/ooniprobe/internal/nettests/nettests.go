@@ -53,10 +53,21 @@ type Controller struct {
 	// using the command line using the --input flag.
 	Inputs []string
 
+	// Restart indicates that we should discard the progress of
+	// an interrupted run and start from scratch.
+	Restart bool
+
+	// Resume indicates that we should resume an interrupted run
+	// and skip the inputs we have already measured.
+	Resume bool
+
 	// RunType contains the run_type hint for the CheckIn API. If
 	// not set, the underlying code defaults to model.RunTypeTimed.
 	RunType model.RunType
 
+	// testlist is the list set by BuildAndSetInputIdxMap.
+	testlist []model.OOAPIURLInfo
+
 	// numInputs is the total number of inputs
 	numInputs int
 
@@ -105,6 +116,7 @@ func (c *Controller) BuildAndSetInputIdxMap(testlist []model.OOAPIURLInfo) ([]st
 		urls = append(urls, url.URL)
 	}
 	c.inputIdxMap = urlIDMap
+	c.testlist = testlist
 	return urls, nil
 }
 
@@ -125,8 +137,12 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []string) error
 	// This will configure the controller as handler for the callbacks
 	// called by ooni/probe-engine/experiment.Experiment.
 	builder.SetCallbacks(model.ExperimentCallbacks(c))
-	c.numInputs = len(inputs)
 	exp := builder.NewExperiment()
+	checkpointer, inputs, err := c.newCheckpointer(exp.Name(), inputs)
+	if err != nil {
+		return err
+	}
+	c.numInputs = len(inputs)
 	defer func() {
 		c.res.DataUsageDown += exp.KibiBytesReceived()
 		c.res.DataUsageUp += exp.KibiBytesSent()
@@ -142,7 +158,7 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []string) error
 	log.Debug(color.RedString("status.started"))
 
 	if c.Probe.Config().Sharing.UploadResults {
-		if err := exp.OpenReportContext(context.Background()); err != nil {
+		if err := openReport(exp, checkpointer); err != nil {
 			log.Debugf(
 				"%s: %s", color.RedString("failure.report_create"), err.Error(),
 			)
@@ -168,9 +184,14 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []string) error
 	}
 	start := time.Now()
 	c.ntStartTime = start
+	completed := true
 	for idx, input := range inputs {
+		if checkpointer.Completed(idx) {
+			continue
+		}
 		if c.Probe.IsTerminated() {
 			log.Info("user requested us to terminate using Ctrl-C")
+			completed = false
 			break
 		}
 		if maxRuntime > 0 && time.Since(start) > maxRuntime {
@@ -239,6 +260,9 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []string) error
 		if err := db.Done(c.msmts[idx64]); err != nil {
 			return errors.Wrap(err, "failed to mark measurement as done")
 		}
+		if err := checkpointer.MarkCompleted(idx, reportID.String); err != nil {
+			return errors.Wrap(err, "failed to save the run progress")
+		}
 
 		// We're not sure whether it's enough to log the error or we should
 		// instead also mark the measurement as failed. Strictly speaking this
@@ -255,11 +279,73 @@ func (c *Controller) Run(builder model.ExperimentBuilder, inputs []string) error
 			return errors.Wrap(err, "failed to add test keys to summary")
 		}
 	}
+	if completed {
+		if err := checkpointer.Remove(); err != nil {
+			log.WithError(err).Warn("failed to remove the run progress")
+		}
+	}
 	db.UpdateUploadedStatus(c.res)
 	log.Debugf("status.end")
 	return nil
 }
 
+// openReport opens the report, reusing the report of the interrupted
+// run when we're resuming and such a report is still usable.
+func openReport(exp model.Experiment, checkpointer *engine.Checkpointer) error {
+	if reportID := checkpointer.ReportID(); reportID != "" {
+		err := exp.ReopenReportContext(context.Background(), reportID)
+		if err == nil {
+			log.Infof("checkpoint: resuming report %s", reportID)
+			return nil
+		}
+		log.Warnf("checkpoint: cannot resume report %s: %s", reportID, err.Error())
+	}
+	return exp.OpenReportContext(context.Background())
+}
+
+// newCheckpointer creates the engine.Checkpointer that keeps track of the
+// progress of measuring the given inputs with the given experiment and returns
+// the inputs to measure, which differ from the original ones when resuming.
+func (c *Controller) newCheckpointer(
+	experimentName string, inputs []string) (*engine.Checkpointer, []string, error) {
+	testlist := c.testlist
+	if len(testlist) != len(inputs) {
+		testlist = nil
+		for _, input := range inputs {
+			testlist = append(testlist, model.OOAPIURLInfo{URL: input})
+		}
+	}
+	// When the inputs come from the check-in API, they are different every
+	// time, so we resume using the inputs saved into the checkpoint.
+	userProvidedInput := len(c.Inputs) > 0 || len(c.InputFiles) > 0
+	checkpointer, err := engine.NewCheckpointer(&engine.CheckpointerConfig{
+		ExperimentName: experimentName,
+		Inputs:         testlist,
+		KVStore:        c.Session.KeyValueStore(),
+		Logger:         log.Log,
+		Name:           "ooniprobe." + c.res.TestGroupName + "." + experimentName,
+		Restart:        c.Restart,
+		Resume:         c.Resume,
+		ReuseInputs:    !userProvidedInput,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !checkpointer.Resumed() {
+		return checkpointer, inputs, nil
+	}
+	if c.inputIdxMap != nil {
+		// make sure we map each input to the correct URL in the database
+		inputs, err := c.BuildAndSetInputIdxMap(checkpointer.Inputs())
+		return checkpointer, inputs, err
+	}
+	inputs = nil
+	for _, entry := range checkpointer.Inputs() {
+		inputs = append(inputs, entry.URL)
+	}
+	return checkpointer, inputs, nil
+}
+
 // OnProgress should be called when a new progress event is available.
 func (c *Controller) OnProgress(perc float64, msg string) {
 	// when we have maxRuntime, honor it
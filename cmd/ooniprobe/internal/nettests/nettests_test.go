@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/ooni"
+	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
@@ -65,3 +66,53 @@ func TestRun(t *testing.T) {
 	ctl := NewController(nt, probe, res, sess)
 	nt.Run(ctl)
 }
+
+func TestControllerNewCheckpointer(t *testing.T) {
+	probe := newOONIProbe(t)
+	sess, err := probe.NewSession(context.Background(), model.RunTypeManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	checkpointer, err := engine.NewCheckpointer(&engine.CheckpointerConfig{
+		ExperimentName: "web_connectivity",
+		Inputs:         []model.OOAPIURLInfo{{URL: "https://a.org/"}, {URL: "https://b.org/"}},
+		KVStore:        sess.KeyValueStore(),
+		Logger:         model.DiscardLogger,
+		Name:           "ooniprobe.websites.web_connectivity",
+		ReuseInputs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpointer.MarkCompleted(0, ""); err != nil {
+		t.Fatal(err)
+	}
+	res := &model.DatabaseResult{TestGroupName: "websites"}
+
+	t.Run("we resume using the saved inputs", func(t *testing.T) {
+		ctl := NewController(WebConnectivity{}, probe, res, sess)
+		ctl.Resume = true
+		checkpointer, inputs, err := ctl.newCheckpointer("web_connectivity", []string{"https://c.org/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inputs) != 2 || inputs[1] != "https://b.org/" {
+			t.Fatal("unexpected inputs", inputs)
+		}
+		if !checkpointer.Completed(0) || checkpointer.Completed(1) {
+			t.Fatal("unexpected completed inputs")
+		}
+	})
+
+	t.Run("we do not resume unless asked to", func(t *testing.T) {
+		ctl := NewController(WebConnectivity{}, probe, res, sess)
+		checkpointer, inputs, err := ctl.newCheckpointer("web_connectivity", []string{"https://c.org/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inputs) != 1 || inputs[0] != "https://c.org/" || checkpointer.Completed(0) {
+			t.Fatal("unexpected inputs", inputs)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package nettests
+
+import (
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
+	"github.com/ooni/probe-cli/v3/internal/oonirun"
+)
+
+// OONIRunGroupName is the test_group_name we use for OONI Run v2 results.
+const OONIRunGroupName = "oonirun"
+
+// NewOONIRunGroup creates a Group running the nettests of an OONI Run
+// v2 descriptor. Nettests with an empty test name are skipped.
+func NewOONIRunGroup(desc *oonirun.V2Descriptor) Group {
+	cg := config.CustomGroup{
+		Label:        desc.Name,
+		UnattendedOK: false,
+	}
+	if cg.Label == "" {
+		cg.Label = "OONI Run"
+	}
+	for _, nettest := range desc.Nettests {
+		if nettest.TestName == "" {
+			continue
+		}
+		cg.Nettests = append(cg.Nettests, config.CustomNettest{
+			TestName: nettest.TestName,
+			Inputs:   nettest.Inputs,
+			Options:  nettest.Options,
+		})
+	}
+	return NewCustomGroup(cg)
+}
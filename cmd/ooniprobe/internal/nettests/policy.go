@@ -0,0 +1,109 @@
+package nettests
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// runPolicies evaluates the config.RunPolicy rules matching a network.
+type runPolicies struct {
+	// db is the database from which to read the data usage.
+	db model.ReadableDatabase
+
+	// loc is the location of the probe.
+	loc model.LocationProvider
+
+	// policies contains the policies matching the network.
+	policies []config.RunPolicy
+}
+
+// newRunPolicies returns the policies matching the given location.
+func newRunPolicies(
+	db model.ReadableDatabase, loc model.LocationProvider, policies []config.RunPolicy) *runPolicies {
+	rp := &runPolicies{db: db, loc: loc}
+	for _, policy := range policies {
+		if policy.ProbeASN != 0 && policy.ProbeASN != loc.ProbeASN() {
+			continue
+		}
+		if policy.ProbeCC != "" && !strings.EqualFold(policy.ProbeCC, loc.ProbeCC()) {
+			continue
+		}
+		rp.policies = append(rp.policies, policy)
+	}
+	return rp
+}
+
+// startOfDay returns the beginning of the current day in local time.
+func startOfDay() time.Time {
+	year, month, day := time.Now().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// kibiBytesPerMB converts MB to KiB, which is the unit we use
+// for accounting the data usage of results.
+const kibiBytesPerMB = 1000 * 1000 / 1024.0
+
+// Check returns a nonempty reason when we should not run the given group. The
+// pendingKiB argument is the data usage in KiB not yet written to the database
+// (i.e., what the bytecounter of the running experiments has accounted so far).
+func (rp *runPolicies) Check(groupName string, pendingKiB float64) (string, error) {
+	for _, policy := range rp.policies {
+		if containsString(policy.SkipGroups, groupName) {
+			return fmt.Sprintf("running %s is disabled on %s", groupName, rp.scope(policy)), nil
+		}
+		if len(policy.OnlyGroups) > 0 && !containsString(policy.OnlyGroups, groupName) {
+			return fmt.Sprintf("only %s can run on %s",
+				strings.Join(policy.OnlyGroups, ", "), rp.scope(policy)), nil
+		}
+		if policy.MaxDailyMB > 0 {
+			usage, err := rp.dataUsage(policy, startOfDay())
+			if err != nil {
+				return "", err
+			}
+			if usage+pendingKiB >= policy.MaxDailyMB*kibiBytesPerMB {
+				return fmt.Sprintf("daily data usage limit of %.1f MB reached on %s",
+					policy.MaxDailyMB, rp.scope(policy)), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// dataUsage returns the data usage since the given time aggregated by the
+// key the policy matches on: the ASN, the country code, or any network.
+func (rp *runPolicies) dataUsage(policy config.RunPolicy, since time.Time) (float64, error) {
+	switch {
+	case policy.ProbeASN != 0:
+		return rp.db.DataUsageByASN(policy.ProbeASN, since)
+	case policy.ProbeCC != "":
+		return rp.db.DataUsageByCC(policy.ProbeCC, since)
+	default:
+		return rp.db.DataUsage(since)
+	}
+}
+
+// scope returns a description of the networks the policy matches on.
+func (rp *runPolicies) scope(policy config.RunPolicy) string {
+	switch {
+	case policy.ProbeASN != 0:
+		return rp.loc.ProbeASNString()
+	case policy.ProbeCC != "":
+		return strings.ToUpper(policy.ProbeCC)
+	default:
+		return "any network"
+	}
+}
+
+// containsString returns whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package nettests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
+	"github.com/ooni/probe-cli/v3/internal/model/mocks"
+)
+
+func TestRunPolicies(t *testing.T) {
+	loc := &mocks.LocationProvider{
+		MockProbeASN: func() uint {
+			return 12345
+		},
+		MockProbeASNString: func() string {
+			return "AS12345"
+		},
+		MockProbeCC: func() string {
+			return "IT"
+		},
+	}
+	newDB := func(usage float64, err error) *mocks.Database {
+		return &mocks.Database{
+			MockDataUsageByASN: func(asn uint, since time.Time) (float64, error) {
+				if asn != 12345 {
+					t.Fatal("unexpected ASN", asn)
+				}
+				return usage, err
+			},
+			MockDataUsageByCC: func(cc string, since time.Time) (float64, error) {
+				t.Fatal("should not be called")
+				return 0, nil
+			},
+			MockDataUsage: func(since time.Time) (float64, error) {
+				return usage, err
+			},
+		}
+	}
+
+	t.Run("without policies", func(t *testing.T) {
+		rp := newRunPolicies(newDB(0, nil), loc, nil)
+		reason, err := rp.Check("performance", 0)
+		if err != nil || reason != "" {
+			t.Fatal("unexpected result", reason, err)
+		}
+	})
+
+	t.Run("with a policy for another network", func(t *testing.T) {
+		rp := newRunPolicies(newDB(0, nil), loc, []config.RunPolicy{{
+			ProbeASN:   30722,
+			SkipGroups: []string{"performance"},
+		}, {
+			ProbeCC:    "DE",
+			SkipGroups: []string{"performance"},
+		}})
+		reason, err := rp.Check("performance", 0)
+		if err != nil || reason != "" {
+			t.Fatal("unexpected result", reason, err)
+		}
+	})
+
+	t.Run("with SkipGroups", func(t *testing.T) {
+		rp := newRunPolicies(newDB(0, nil), loc, []config.RunPolicy{{
+			ProbeASN:   12345,
+			SkipGroups: []string{"performance"},
+		}})
+		if reason, _ := rp.Check("performance", 0); reason == "" {
+			t.Fatal("expected to skip performance")
+		}
+		if reason, _ := rp.Check("websites", 0); reason != "" {
+			t.Fatal("expected to run websites")
+		}
+	})
+
+	t.Run("with OnlyGroups", func(t *testing.T) {
+		rp := newRunPolicies(newDB(0, nil), loc, []config.RunPolicy{{
+			ProbeCC:    "it",
+			OnlyGroups: []string{"websites"},
+		}})
+		if reason, _ := rp.Check("performance", 0); reason == "" {
+			t.Fatal("expected to skip performance")
+		}
+		if reason, _ := rp.Check("websites", 0); reason != "" {
+			t.Fatal("expected to run websites")
+		}
+	})
+
+	t.Run("with MaxDailyMB", func(t *testing.T) {
+		policies := []config.RunPolicy{{ProbeASN: 12345, MaxDailyMB: 1}}
+		rp := newRunPolicies(newDB(500, nil), loc, policies)
+		if reason, _ := rp.Check("websites", 0); reason != "" {
+			t.Fatal("expected to run websites")
+		}
+		if reason, _ := rp.Check("websites", 500); reason == "" {
+			t.Fatal("expected to skip websites")
+		}
+	})
+
+	t.Run("with MaxDailyMB and a country code policy", func(t *testing.T) {
+		db := &mocks.Database{
+			MockDataUsageByCC: func(cc string, since time.Time) (float64, error) {
+				if cc != "it" {
+					t.Fatal("unexpected CC", cc)
+				}
+				return 1000, nil
+			},
+		}
+		policies := []config.RunPolicy{{ProbeCC: "it", MaxDailyMB: 1}}
+		rp := newRunPolicies(db, loc, policies)
+		reason, err := rp.Check("websites", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason != "daily data usage limit of 1.0 MB reached on IT" {
+			t.Fatal("unexpected reason", reason)
+		}
+	})
+
+	t.Run("when we cannot read the data usage", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		policies := []config.RunPolicy{{MaxDailyMB: 1}}
+		rp := newRunPolicies(newDB(0, expected), loc, policies)
+		if _, err := rp.Check("websites", 0); !errors.Is(err, expected) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
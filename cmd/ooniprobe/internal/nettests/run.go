@@ -12,13 +12,24 @@ import (
 	"github.com/pkg/errors"
 )
 
+// checkBackendTimeout is the maximum time we spend checking
+// whether a self-hosted backend is reachable.
+const checkBackendTimeout = 30 * time.Second
+
 // RunGroupConfig contains the settings for running a nettest group.
 type RunGroupConfig struct {
 	GroupName  string
 	InputFiles []string
 	Inputs     []string
 	Probe      *ooni.Probe
+	Restart    bool          // discard interrupted runs
+	Resume     bool          // resume interrupted runs
 	RunType    model.RunType // hint for check-in API
+
+	// Group optionally contains the group to run, in which case we do
+	// not look up GroupName among the known groups and we only use it
+	// as the test_group_name of the result (e.g., for OONI Run links).
+	Group *Group
 }
 
 const websitesURLLimitRemoved = `WARNING: CONFIGURATION CHANGE REQUIRED:
@@ -77,17 +88,31 @@ func RunGroup(config RunGroupConfig) error {
 		log.WithError(err).Error("Failed to create the network row")
 		return err
 	}
-	if err := sess.MaybeLookupBackends(); err != nil {
-		log.WithError(err).Warn("Failed to discover OONI backends")
+	group, err := lookupGroup(config)
+	if err != nil {
 		return err
 	}
+	log.Debugf("Running test group %s", group.Label)
 
-	group, ok := All[config.GroupName]
-	if !ok {
-		log.Errorf("No test group named %s", config.GroupName)
-		return errors.New("invalid test group name")
+	policies := newRunPolicies(db, sess, config.Probe.Config().Nettests.RunPolicies)
+	reason, err := policies.Check(config.GroupName, 0)
+	if err != nil {
+		log.WithError(err).Error("Failed to evaluate the run policies")
+		return err
+	}
+	if reason != "" {
+		log.Warnf("Skipping test group %s: %s", group.Label, reason)
+		_, err := db.CreateSkippedRun(config.GroupName, network.ID, reason)
+		return err
+	}
+
+	checkCtx, cancel := context.WithTimeout(context.Background(), checkBackendTimeout)
+	sess.CheckBackend(checkCtx) // only logs when using a self-hosted backend
+	cancel()
+	if err := sess.MaybeLookupBackends(); err != nil {
+		log.WithError(err).Warn("Failed to discover OONI backends")
+		return err
 	}
-	log.Debugf("Running test group %s", group.Label)
 
 	result, err := db.CreateResult(
 		config.Probe.Home(), config.GroupName, network.ID)
@@ -109,10 +134,26 @@ func RunGroup(config RunGroupConfig) error {
 				continue
 			}
 		}
+		if i > 0 {
+			reason, err := policies.Check(config.GroupName, result.DataUsageUp+result.DataUsageDown)
+			if err != nil {
+				log.WithError(err).Error("Failed to evaluate the run policies")
+				break
+			}
+			if reason != "" {
+				log.Warnf("Stopping test group %s early: %s", group.Label, reason)
+				if _, err := db.CreateSkippedRun(config.GroupName, network.ID, reason); err != nil {
+					log.WithError(err).Error("Failed to record the skipped run")
+				}
+				break
+			}
+		}
 		log.Debugf("Running test %T", nt)
 		ctl := NewController(nt, config.Probe, result, sess)
 		ctl.InputFiles = config.InputFiles
 		ctl.Inputs = config.Inputs
+		ctl.Restart = config.Restart
+		ctl.Resume = config.Resume
 		ctl.RunType = config.RunType
 		ctl.SetNettestIndex(i, len(group.Nettests))
 		if err = nt.Run(ctl); err != nil {
@@ -137,6 +178,24 @@ func RunGroup(config RunGroupConfig) error {
 	return nil
 }
 
+// lookupGroup returns the group to run for the given config.
+func lookupGroup(config RunGroupConfig) (Group, error) {
+	if config.Group != nil {
+		return *config.Group, nil
+	}
+	groups, err := AllGroups(config.Probe.Config())
+	if err != nil {
+		log.WithError(err).Error("Invalid custom test groups")
+		return Group{}, err
+	}
+	group, ok := groups[config.GroupName]
+	if !ok {
+		log.Errorf("No test group named %s", config.GroupName)
+		return Group{}, errors.New("invalid test group name")
+	}
+	return group, nil
+}
+
 // onlyBackground is the interface implements by nettests that we don't
 // want to run in manual mode because they take too much runtime
 //
@@ -13,6 +13,7 @@ import (
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/config"
 	"github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/utils"
+	"github.com/ooni/probe-cli/v3/internal/backendconfig"
 	"github.com/ooni/probe-cli/v3/internal/database"
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
@@ -110,6 +111,12 @@ func (p *Probe) Terminate() {
 	p.isTerminated.Add(1)
 }
 
+// ResetTerminated clears the terminated state, which is useful when we're
+// running as a daemon and want to run again after the user stopped a run.
+func (p *Probe) ResetTerminated() {
+	p.isTerminated.Store(0)
+}
+
 // ListenForSignals will listen for SIGINT and SIGTERM. When it receives those
 // signals it will set isTerminatedAtomicInt to non-zero, which will cleanly
 // shutdown the test logic.
@@ -232,7 +239,15 @@ func (p *Probe) NewSession(ctx context.Context, runType model.RunType) (*engine.
 	if runType == model.RunTypeTimed && softwareName == DefaultSoftwareName {
 		softwareName = DefaultSoftwareName + "-unattended"
 	}
+	backendConfig, err := backendconfig.LoadFromEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading the backend config")
+	}
+	// Implementation note: we don't set SubmitQueueDir because ooniprobe
+	// marks the measurements it fails to submit in its database and
+	// resubmits them using `ooniprobe upload`.
 	return engine.NewSession(ctx, engine.SessionConfig{
+		BackendConfig:   backendConfig,
 		KVStore:         kvstore,
 		Logger:          logger,
 		SoftwareName:    softwareName,
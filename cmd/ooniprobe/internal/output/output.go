@@ -2,6 +2,7 @@ package output
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -11,6 +12,25 @@ import (
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
+// jsonOutput indicates whether we're emitting JSONL on the standard output.
+var jsonOutput bool
+
+// SetJSONOutput configures whether we're emitting JSONL on the standard output
+// (i.e., `--output json`). In such a case, we MUST only write log events on the
+// standard output, so we route or refuse any other kind of output.
+func SetJSONOutput(value bool) {
+	jsonOutput = value
+}
+
+// JSONOutput returns whether we're emitting JSONL on the standard output.
+func JSONOutput() bool {
+	return jsonOutput
+}
+
+// ErrInteractiveJSONOutput indicates that we cannot interact with
+// the user because we're emitting JSONL on the standard output.
+var ErrInteractiveJSONOutput = errors.New("cannot interact with the user when using --output json")
+
 // MeasurementJSON prints the JSON of a measurement
 func MeasurementJSON(j map[string]interface{}) {
 	log.WithFields(log.Fields{
@@ -151,6 +171,18 @@ func ResultSummary(result ResultSummaryData) {
 	}).Info("result summary")
 }
 
+// SkippedRunItem emits a test group run we skipped because of the run policies
+func SkippedRunItem(run model.DatabaseSkippedRun) {
+	log.WithFields(log.Fields{
+		"type":       "skipped_run_item",
+		"id":         run.ID,
+		"name":       run.TestGroupName,
+		"time":       run.Time,
+		"reason":     run.Reason,
+		"network_id": run.NetworkID,
+	}).Infof("skipped %s: %s", run.TestGroupName, run.Reason)
+}
+
 // SectionTitle is the title of a section
 func SectionTitle(text string) {
 	log.WithFields(log.Fields{
@@ -159,20 +191,52 @@ func SectionTitle(text string) {
 	}).Info(text)
 }
 
+// OONIRunDiff emits the changes of a new or changed OONI Run descriptor
+func OONIRunDiff(URL string, diff string) {
+	log.WithFields(log.Fields{
+		"type": "oonirun_diff",
+		"url":  URL,
+		"diff": diff,
+	}).Infof("%s is new or has changed as follows", URL)
+}
+
+// Version emits the version of ooniprobe
+func Version(version string) {
+	if jsonOutput {
+		log.WithFields(log.Fields{
+			"type":    "version",
+			"version": version,
+		}).Info(version)
+		return
+	}
+	fmt.Println(version)
+}
+
 // Paragraph makes a word-wrapped paragraph out of text
 func Paragraph(text string) {
+	if jsonOutput {
+		log.Info(text)
+		return
+	}
 	const width = 80
 	fmt.Println(wordwrap.WrapString(text, width))
 }
 
 // Bullet is like paragraph but with a bullet point in front
 func Bullet(text string) {
+	if jsonOutput {
+		log.Info(text)
+		return
+	}
 	const width = 80
 	fmt.Printf("• %s\n", wordwrap.WrapString(text, width))
 }
 
 // PressAnyKeyToContinue blocks until the user presses any key
 func PressAnyKeyToContinue(text string) error {
+	if jsonOutput {
+		return ErrInteractiveJSONOutput
+	}
 	fmt.Print(text)
 	_, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
 	return err
@@ -10,6 +10,7 @@ import (
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/reset"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/rm"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/run"
+	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/serve"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/show"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/upload"
 	_ "github.com/ooni/probe-cli/v3/cmd/ooniprobe/internal/cli/version"
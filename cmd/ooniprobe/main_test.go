@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestMain allows us to run ooniprobe by executing the test binary
+// itself with the OONIPROBE_TEST_RUN_MAIN environment variable set.
+func TestMain(m *testing.M) {
+	if os.Getenv("OONIPROBE_TEST_RUN_MAIN") == "1" {
+		os.Args = append([]string{"ooniprobe"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runOONIProbe runs ooniprobe with the given arguments using
+// the given OONI_HOME and returns the standard output.
+func runOONIProbe(t *testing.T, home string, args ...string) []byte {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "OONIPROBE_TEST_RUN_MAIN=1", "OONI_HOME="+home)
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	// Note: we ignore the error because some commands are expected to
+	// fail and we only care about what they emit on the stdout
+	_ = cmd.Run()
+	return stdout.Bytes()
+}
+
+// checkJSONL ensures that each line of the given output is a JSON object.
+func checkJSONL(t *testing.T, output []byte) {
+	t.Helper()
+	if len(output) <= 0 {
+		t.Fatal("expected some output")
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var ev map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line is not JSON: %s: %s", err.Error(), scanner.Text())
+		}
+		if _, found := ev["type"]; !found {
+			t.Fatalf("line without type: %s", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		home := t.TempDir()
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "list"))
+	})
+
+	t.Run("version", func(t *testing.T) {
+		home := t.TempDir()
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "version"))
+	})
+
+	t.Run("run without informed consent", func(t *testing.T) {
+		home := t.TempDir()
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "run", "websites"))
+	})
+
+	t.Run("onboard without --yes", func(t *testing.T) {
+		home := t.TempDir()
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "onboard"))
+	})
+
+	t.Run("run", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skip test in short mode")
+		}
+		home := t.TempDir()
+		runOONIProbe(t, home, "--output", "json", "onboard", "--yes") // emits nothing
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "run", "websites",
+			"--no-collector", "--input", "https://www.example.com/"))
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "list"))
+		checkJSONL(t, runOONIProbe(t, home, "--output", "json", "list", "1"))
+	})
+}
@@ -32,6 +32,7 @@ require (
 	github.com/ooni/probe-assets v0.14.0
 	github.com/pborman/getopt/v2 v2.1.0
 	github.com/pion/stun v0.3.5
+	github.com/pion/turn/v2 v2.0.9
 	github.com/pkg/errors v0.9.1
 	github.com/rogpeppe/go-internal v1.9.0
 	github.com/rubenv/sql-migrate v1.3.0
@@ -41,6 +42,7 @@ require (
 	golang.org/x/crypto v0.5.0
 	golang.org/x/net v0.5.0
 	golang.org/x/sys v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -51,6 +53,7 @@ require (
 	github.com/Psiphon-Labs/quic-go v0.0.0-20221014165902-1b7c3975fcf3 // indirect
 	github.com/Psiphon-Labs/tls-tris v0.0.0-20210713133851-676a693d51ad // indirect
 	github.com/andybalholm/brotli v1.0.5-0.20220518190645-786ec621f618 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/mock v1.6.0 // indirect
 	github.com/google/pprof v0.0.0-20230111200839-76d1ae5aea2b // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -108,7 +111,6 @@ require (
 	github.com/pion/sdp/v3 v3.0.6 // indirect
 	github.com/pion/srtp/v2 v2.0.11 // indirect
 	github.com/pion/transport v0.14.1 // indirect
-	github.com/pion/turn/v2 v2.0.9 // indirect
 	github.com/pion/udp v0.1.2 // indirect
 	github.com/pion/webrtc/v3 v3.1.50 // indirect
 	github.com/prometheus/client_golang v1.14.0
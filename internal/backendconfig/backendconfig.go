@@ -0,0 +1,216 @@
+// Package backendconfig loads and validates the configuration for
+// using a self-hosted OONI backend (see model.BackendConfig).
+package backendconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// EnvVariable is the environment variable containing the path of
+// the JSON file with the backend configuration.
+const EnvVariable = "OONI_BACKEND_CONFIG"
+
+// ErrInvalidConfig indicates that the backend configuration is not valid.
+var ErrInvalidConfig = errors.New("backendconfig: invalid config")
+
+// Load loads the backend configuration from the given JSON file
+// and validates it using Validate.
+func Load(path string) (*model.BackendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config model.BackendConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := Validate(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// LoadFromEnv is like Load but reads the path from the EnvVariable
+// environment variable. When the variable is not set, this function
+// returns a nil config and a nil error.
+func LoadFromEnv() (*model.BackendConfig, error) {
+	path := os.Getenv(EnvVariable)
+	if path == "" {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Validate returns an error if the given config is not valid.
+func Validate(config *model.BackendConfig) error {
+	for _, svc := range config.ProbeServices {
+		if err := validateService(svc); err != nil {
+			return err
+		}
+	}
+	if config.CollectorURL != "" {
+		if err := validateHTTPURL(config.CollectorURL); err != nil {
+			return err
+		}
+	}
+	if config.CheckInURL != "" {
+		if err := validateHTTPURL(config.CheckInURL); err != nil {
+			return err
+		}
+	}
+	for name, services := range config.TestHelpers {
+		if len(services) <= 0 {
+			return fmt.Errorf("%w: no test helpers for %s", ErrInvalidConfig, name)
+		}
+	}
+	for _, resolver := range config.DNSResolvers {
+		if err := validateResolverURL(resolver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateService validates a probe services entry.
+func validateService(svc model.OOAPIService) error {
+	switch svc.Type {
+	case "https":
+		return validateHTTPURL(svc.Address)
+	case "cloudfront":
+		if svc.Front == "" {
+			return fmt.Errorf("%w: missing front for %s", ErrInvalidConfig, svc.Address)
+		}
+		return validateHTTPURL(svc.Address)
+	default:
+		return fmt.Errorf("%w: unsupported service type: %s", ErrInvalidConfig, svc.Type)
+	}
+}
+
+// validateHTTPURL ensures that the given string is an http or https URL.
+func validateHTTPURL(URL string) error {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: not an HTTP(S) URL: %s", ErrInvalidConfig, URL)
+	}
+	return nil
+}
+
+// validateResolverURL ensures that the given string is a resolver
+// URL we can use with sessionresolver.Resolver.
+func validateResolverURL(URL string) error {
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	switch parsed.Scheme {
+	case "https", "http3", "dot", "tcp", "udp":
+		if parsed.Host == "" {
+			return fmt.Errorf("%w: missing host in resolver URL: %s", ErrInvalidConfig, URL)
+		}
+		return nil
+	case "system":
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported resolver URL: %s", ErrInvalidConfig, URL)
+	}
+}
+
+// EndpointReport tells whether an endpoint is reachable.
+type EndpointReport struct {
+	// Kind is the kind of endpoint (e.g., "collector").
+	Kind string
+
+	// URL is the endpoint URL.
+	URL string
+
+	// Reachable indicates whether we could reach the endpoint.
+	Reachable bool
+
+	// Failure is the failure that occurred, if any.
+	Failure string
+}
+
+// Check checks whether the endpoints in the given config are reachable using
+// the given HTTP client. We consider an endpoint reachable when we receive an
+// HTTP response, regardless of its status code. We do not check the resolvers
+// because the client uses them to resolve all the other endpoints.
+func Check(ctx context.Context, config *model.BackendConfig, client model.HTTPClient) []*EndpointReport {
+	var out []*EndpointReport
+	for _, svc := range config.ProbeServices {
+		out = append(out, checkService(ctx, client, "probe_services", svc))
+	}
+	if config.CollectorURL != "" {
+		out = append(out, checkURL(ctx, client, "collector", config.CollectorURL, ""))
+	}
+	if config.CheckInURL != "" {
+		out = append(out, checkURL(ctx, client, "check_in", config.CheckInURL, ""))
+	}
+	var names []string
+	for name := range config.TestHelpers {
+		names = append(names, name)
+	}
+	sort.Strings(names) // deterministic order
+	for _, name := range names {
+		for _, svc := range config.TestHelpers[name] {
+			if report := checkService(ctx, client, "test_helpers."+name, svc); report != nil {
+				out = append(out, report)
+			}
+		}
+	}
+	return out
+}
+
+// checkService checks whether the given service is reachable. It returns
+// nil if we don't know how to check this kind of service.
+func checkService(
+	ctx context.Context, client model.HTTPClient, kind string, svc model.OOAPIService) *EndpointReport {
+	switch svc.Type {
+	case "cloudfront":
+		URL := &url.URL{Scheme: "https", Host: svc.Front}
+		parsed, err := url.Parse(svc.Address)
+		if err != nil {
+			return &EndpointReport{Kind: kind, URL: svc.Address, Failure: err.Error()}
+		}
+		report := checkURL(ctx, client, kind, URL.String(), parsed.Host)
+		report.URL = svc.Address
+		return report
+	case "https", "http":
+		return checkURL(ctx, client, kind, svc.Address, "")
+	default:
+		// Test helpers could use other schemes (e.g., tcp-echo), which we
+		// cannot check using HTTP, so we skip them rather than failing.
+		return nil
+	}
+}
+
+// checkURL checks whether the given URL is reachable. The host argument is
+// OPTIONAL and allows to override the Host header for domain fronting.
+func checkURL(ctx context.Context, client model.HTTPClient, kind, URL, host string) *EndpointReport {
+	report := &EndpointReport{Kind: kind, URL: URL}
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		report.Failure = err.Error()
+		return report
+	}
+	req.Host = host
+	resp, err := client.Do(req)
+	if err != nil {
+		report.Failure = err.Error()
+		return report
+	}
+	resp.Body.Close()
+	report.Reachable = true
+	return report
+}
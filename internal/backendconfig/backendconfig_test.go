@@ -0,0 +1,193 @@
+package backendconfig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("with a valid config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "backend.json")
+		data := []byte(`{
+			"probe_services": [{"address": "https://api.example.com", "type": "https"}],
+			"collector_url": "https://collector.example.com",
+			"test_helpers": {"web-connectivity": [{"address": "https://th.example.com", "type": "https"}]},
+			"dns_resolvers": ["https://dns.example.com/dns-query", "system:///"]
+		}`)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(config.ProbeServices) != 1 || config.CollectorURL != "https://collector.example.com" {
+			t.Fatal("unexpected config", config)
+		}
+		if len(config.TestHelpers["web-connectivity"]) != 1 || len(config.DNSResolvers) != 2 {
+			t.Fatal("unexpected config", config)
+		}
+	})
+
+	t.Run("with a nonexistent file", func(t *testing.T) {
+		config, err := Load(filepath.Join(t.TempDir(), "nonexistent.json"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatal("unexpected err", err)
+		}
+		if config != nil {
+			t.Fatal("expected nil config")
+		}
+	})
+
+	t.Run("with an invalid config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "backend.json")
+		if err := os.WriteFile(path, []byte(`{"collector_url": "ftp://x.org"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := Load(path)
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Fatal("unexpected err", err)
+		}
+		if config != nil {
+			t.Fatal("expected nil config")
+		}
+	})
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Run("without the environment variable", func(t *testing.T) {
+		t.Setenv(EnvVariable, "")
+		config, err := LoadFromEnv()
+		if err != nil || config != nil {
+			t.Fatal("expected nil config and nil error")
+		}
+	})
+
+	t.Run("with the environment variable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "backend.json")
+		if err := os.WriteFile(path, []byte(`{"check_in_url": "https://x.org"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(EnvVariable, path)
+		config, err := LoadFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.CheckInURL != "https://x.org" {
+			t.Fatal("unexpected config", config)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	var invalid = []struct {
+		name   string
+		config *model.BackendConfig
+	}{{
+		name: "unsupported service type",
+		config: &model.BackendConfig{
+			ProbeServices: []model.OOAPIService{{Address: "http://x.onion", Type: "onion"}},
+		},
+	}, {
+		name: "cloudfront without front",
+		config: &model.BackendConfig{
+			ProbeServices: []model.OOAPIService{{Address: "https://x.org", Type: "cloudfront"}},
+		},
+	}, {
+		name:   "invalid check-in URL",
+		config: &model.BackendConfig{CheckInURL: "https://"},
+	}, {
+		name:   "unparsable collector URL",
+		config: &model.BackendConfig{CollectorURL: "\t"},
+	}, {
+		name: "empty test helpers list",
+		config: &model.BackendConfig{
+			TestHelpers: map[string][]model.OOAPIService{"dnscheck": {}},
+		},
+	}, {
+		name:   "unsupported resolver",
+		config: &model.BackendConfig{DNSResolvers: []string{"ftp://8.8.8.8:53"}},
+	}, {
+		name:   "resolver without host",
+		config: &model.BackendConfig{DNSResolvers: []string{"https:///dns-query"}},
+	}, {
+		name:   "dot resolver without host",
+		config: &model.BackendConfig{DNSResolvers: []string{"dot://"}},
+	}}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Validate(tc.config); !errors.Is(err, ErrInvalidConfig) {
+				t.Fatal("unexpected err", err)
+			}
+		})
+	}
+
+	t.Run("with an empty config", func(t *testing.T) {
+		if err := Validate(&model.BackendConfig{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with all the resolvers supported by sessionresolver", func(t *testing.T) {
+		config := &model.BackendConfig{DNSResolvers: []string{
+			"https://dns.example.com/dns-query",
+			"http3://dns.example.com/dns-query",
+			"dot://8.8.8.8:853",
+			"tcp://8.8.8.8:53",
+			"udp://8.8.8.8:53",
+			"system:///",
+		}}
+		if err := Validate(config); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	var hosts []string
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		w.WriteHeader(404) // any response means reachable
+	}))
+	defer srvr.Close()
+	config := &model.BackendConfig{
+		ProbeServices: []model.OOAPIService{{Address: srvr.URL, Type: "https"}},
+		CollectorURL:  "http://127.0.0.1:1", // nothing listens here
+		CheckInURL:    srvr.URL,
+		TestHelpers: map[string][]model.OOAPIService{
+			"web-connectivity": {{Address: srvr.URL, Type: "https"}},
+			"tcp-echo":         {{Address: "37.218.241.38", Type: "legacy"}},
+		},
+	}
+	reports := Check(context.Background(), config, http.DefaultClient)
+	if len(reports) != 4 {
+		t.Fatal("unexpected number of reports", len(reports))
+	}
+	expect := []struct {
+		kind      string
+		reachable bool
+	}{
+		{"probe_services", true},
+		{"collector", false},
+		{"check_in", true},
+		{"test_helpers.web-connectivity", true},
+	}
+	for idx, e := range expect {
+		if reports[idx].Kind != e.kind || reports[idx].Reachable != e.reachable {
+			t.Fatal("unexpected report", idx, reports[idx])
+		}
+		if !e.reachable && reports[idx].Failure == "" {
+			t.Fatal("expected a failure", idx)
+		}
+	}
+	if len(hosts) != 3 {
+		t.Fatal("unexpected number of requests", len(hosts))
+	}
+}
@@ -0,0 +1,38 @@
+// Command fakebackend serves a fake OONI backend (see the internal/fakebackend
+// package) and writes the corresponding backend config, such that you can run
+// miniooni and ooniprobe without the real OONI backend. Note that this is not
+// fully offline, because the location lookup still needs network access. For
+// example:
+//
+//	go run ./internal/cmd/fakebackend -config /tmp/backend.json &
+//	OONI_BACKEND_CONFIG=/tmp/backend.json go run ./internal/cmd/miniooni example
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/fakebackend"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+func main() {
+	address := flag.String("address", "127.0.0.1:8080", "address where to listen")
+	configFile := flag.String("config", "backend.json", "where to write the backend config")
+	flag.Parse()
+	listener, err := net.Listen("tcp", *address)
+	runtimex.PanicOnError(err, "net.Listen failed")
+	backend := fakebackend.New()
+	config := backend.BackendConfig("http://" + listener.Addr().String())
+	data, err := json.MarshalIndent(config, "", "  ")
+	runtimex.PanicOnError(err, "json.MarshalIndent failed")
+	err = os.WriteFile(*configFile, data, 0600)
+	runtimex.PanicOnError(err, "os.WriteFile failed")
+	log.Infof("serving fake backend at %s; config written to %s", listener.Addr(), *configFile)
+	err = http.Serve(listener, backend)
+	runtimex.PanicOnError(err, "http.Serve failed")
+}
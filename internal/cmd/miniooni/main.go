@@ -12,11 +12,13 @@ import (
 	"time"
 
 	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/backendconfig"
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/humanize"
 	"github.com/ooni/probe-cli/v3/internal/legacy/assetsdir"
 	"github.com/ooni/probe-cli/v3/internal/logx"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
 	"github.com/ooni/probe-cli/v3/internal/registry"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -26,23 +28,30 @@ import (
 // Options contains the options you can set from the CLI.
 type Options struct {
 	Annotations         []string
+	BackendConfig       string
 	Emoji               bool
 	ExtraOptions        []string
 	HomeDir             string
 	Inputs              []string
 	InputFilePaths      []string
 	MaxRuntime          int64
+	MetricsAddress      string
 	NoJSON              bool
 	NoCollector         bool
+	Parallelism         int
 	ProbeServicesURL    string
 	Proxy               string
 	Random              bool
 	RepeatEvery         int64
 	ReportFile          string
+	Restart             bool
+	Resume              bool
 	SnowflakeRendezvous string
 	TorArgs             []string
 	TorBinary           string
+	TorBridgeLines      []string
 	Tunnel              string
+	TunnelAutoRestart   bool
 	Verbose             bool
 	Yes                 bool
 }
@@ -67,6 +76,13 @@ func main() {
 		"add KEY=VALUE annotation to the report (can be repeated multiple times)",
 	)
 
+	flags.StringVar(
+		&globalOptions.BackendConfig,
+		"backend-config",
+		"",
+		"path of the JSON config for a self-hosted backend (default: $"+backendconfig.EnvVariable+")",
+	)
+
 	flags.BoolVar(
 		&globalOptions.Emoji,
 		"emoji",
@@ -81,6 +97,13 @@ func main() {
 		"force specific home directory",
 	)
 
+	flags.StringVar(
+		&globalOptions.MetricsAddress,
+		"metrics-address",
+		"",
+		"serve Prometheus metrics at the given address (e.g., 127.0.0.1:9101)",
+	)
+
 	flags.BoolVarP(
 		&globalOptions.NoJSON,
 		"no-json",
@@ -126,6 +149,20 @@ func main() {
 		"set the output report file path (default: \"report.jsonl\")",
 	)
 
+	flags.BoolVar(
+		&globalOptions.Restart,
+		"restart",
+		false,
+		"discard the progress of an interrupted run and start from scratch",
+	)
+
+	flags.BoolVar(
+		&globalOptions.Resume,
+		"resume",
+		false,
+		"resume an interrupted run skipping the already measured inputs",
+	)
+
 	flags.StringVar(
 		&globalOptions.SnowflakeRendezvous,
 		"snowflake-rendezvous",
@@ -147,11 +184,26 @@ func main() {
 		"execute a specific tor binary",
 	)
 
+	flags.StringArrayVar(
+		&globalOptions.TorBridgeLines,
+		"tor-bridge",
+		[]string{},
+		"obfs4 bridge line for --tunnel=tor+obfs4 (may be specified multiple times)",
+	)
+
 	flags.StringVar(
 		&globalOptions.Tunnel,
 		"tunnel",
 		"",
-		"tunnel to use to communicate with the OONI backend (one of: psiphon, tor, torsf)",
+		"tunnel to use to communicate with the OONI backend (one of: psiphon, tor, torsf, tor+obfs4, "+
+			"http-connect://HOST:PORT and socks5://HOST:PORT, where HOST:PORT is the upstream proxy)",
+	)
+
+	flags.BoolVar(
+		&globalOptions.TunnelAutoRestart,
+		"tunnel-auto-restart",
+		false,
+		"restart the tunnel when it stops working",
 	)
 
 	flags.BoolVarP(
@@ -171,9 +223,12 @@ func main() {
 	)
 
 	rootCmd.MarkFlagsMutuallyExclusive("proxy", "tunnel")
+	rootCmd.MarkFlagsMutuallyExclusive("restart", "resume")
 
 	registerAllExperiments(rootCmd, &globalOptions)
 	registerOONIRun(rootCmd, &globalOptions)
+	registerSubmitQueue(rootCmd, &globalOptions)
+	registerResolvers(rootCmd, &globalOptions)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -256,6 +311,13 @@ func registerAllExperiments(rootCmd *cobra.Command, globalOptions *Options) {
 				"maximum runtime in seconds for the experiment (zero means infinite)",
 			)
 
+			flags.IntVar(
+				&globalOptions.Parallelism,
+				"parallelism",
+				0,
+				"number of inputs to measure concurrently (zero or one means sequentially)",
+			)
+
 			flags.BoolVar(
 				&globalOptions.Random,
 				"random",
@@ -286,7 +348,10 @@ func registerAllExperiments(rootCmd *cobra.Command, globalOptions *Options) {
 // integrate this function to either handle the panic of ignore it.
 func MainWithConfiguration(experimentName string, currentOptions *Options) {
 	runtimex.PanicOnError(engine.CheckEmbeddedPsiphonConfig(), "Invalid embedded psiphon config")
-	if currentOptions.Tunnel != "" {
+	switch {
+	case strings.Contains(currentOptions.Tunnel, "://"):
+		currentOptions.Proxy = currentOptions.Tunnel // tunnels using an upstream proxy
+	case currentOptions.Tunnel != "":
 		currentOptions.Proxy = fmt.Sprintf("%s:///", currentOptions.Tunnel)
 	}
 
@@ -300,6 +365,11 @@ func MainWithConfiguration(experimentName string, currentOptions *Options) {
 		currentOptions.ReportFile = "report.jsonl"
 	}
 	log.Log = logger
+	if currentOptions.MetricsAddress != "" {
+		srvr, err := probemetrics.Serve(currentOptions.MetricsAddress, logger)
+		runtimex.PanicOnError(err, "cannot serve metrics")
+		defer srvr.Close()
+	}
 	for {
 		mainSingleIteration(logger, experimentName, currentOptions)
 		if currentOptions.RepeatEvery <= 0 {
@@ -389,6 +459,12 @@ func documentationForOptions(name string, factory *registry.Factory) string {
 		fmt.Fprintf(&sb, "\n")
 		fmt.Fprintf(&sb, "  -O, --option %s=<%s>\n", name, info.Type)
 		fmt.Fprintf(&sb, "      %s\n", info.Doc)
+		if len(info.Enum) > 0 {
+			fmt.Fprintf(&sb, "      Allowed values: %s\n", strings.Join(info.Enum, ", "))
+		}
+		if info.Default != "" {
+			fmt.Fprintf(&sb, "      Default: %s\n", info.Default)
+		}
 	}
 	return sb.String()
 }
@@ -28,6 +28,8 @@ func ooniRunMain(ctx context.Context,
 		NoJSON:        currentOptions.NoJSON,
 		Random:        currentOptions.Random,
 		ReportFile:    currentOptions.ReportFile,
+		Restart:       currentOptions.Restart,
+		Resume:        currentOptions.Resume,
 		Session:       sess,
 	}
 	for _, URL := range currentOptions.Inputs {
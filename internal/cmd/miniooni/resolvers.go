@@ -0,0 +1,125 @@
+package main
+
+//
+// Inspecting and managing the session resolvers
+//
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/spf13/cobra"
+)
+
+// registerResolvers registers the resolvers subcommand
+func registerResolvers(rootCmd *cobra.Command, globalOptions *Options) {
+	subCmd := &cobra.Command{
+		Use:   "resolvers",
+		Short: "Inspects and manages the resolvers used to reach the OONI backend",
+	}
+	rootCmd.AddCommand(subCmd)
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists the resolvers with their scores and recent failures",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				for _, e := range sess.SessionResolverState() {
+					var flags []string
+					if e.Custom {
+						flags = append(flags, "custom")
+					}
+					if e.Pinned {
+						flags = append(flags, "pinned")
+					}
+					fmt.Printf("%s score=%.3f flags=%q\n", e.URL, e.Score, strings.Join(flags, ","))
+					for _, f := range e.RecentFailures {
+						fmt.Printf("    %s %s\n", f.Time.Format("2006-01-02 15:04:05 MST"), f.Failure)
+					}
+				}
+			})
+		},
+	})
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "add URL",
+		Short: "Adds a custom resolver (e.g., https://, http3://, dot://, tcp://, udp://)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				err := sess.AddSessionResolver(args[0])
+				runtimex.PanicOnError(err, "cannot add the resolver")
+			})
+		},
+	})
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "remove URL",
+		Short: "Removes a custom resolver",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				err := sess.RemoveSessionResolver(args[0])
+				runtimex.PanicOnError(err, "cannot remove the resolver")
+			})
+		},
+	})
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "reset [URL]",
+		Short: "Resets the score of a resolver or of all resolvers",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				var URL string
+				if len(args) > 0 {
+					URL = args[0]
+				}
+				err := sess.ResetSessionResolver(URL)
+				runtimex.PanicOnError(err, "cannot reset the resolver")
+			})
+		},
+	})
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "pin URL",
+		Short: "Only uses the given resolver to reach the OONI backend",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				err := sess.PinSessionResolver(args[0])
+				runtimex.PanicOnError(err, "cannot pin the resolver")
+			})
+		},
+	})
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "unpin",
+		Short: "Uses again all the resolvers to reach the OONI backend",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			withSessionForResolvers(globalOptions, func(sess *engine.Session) {
+				err := sess.UnpinSessionResolver()
+				runtimex.PanicOnError(err, "cannot unpin the resolver")
+			})
+		},
+	})
+}
+
+// withSessionForResolvers creates a session, calls fn, and closes the session. We
+// use a session because the list of resolvers depends on the backend config. We
+// only read and write the resolvers state, which does not depend on the proxy, so
+// we ignore --proxy and --tunnel to avoid starting a tunnel for nothing.
+func withSessionForResolvers(currentOptions *Options, fn func(sess *engine.Session)) {
+	options := *currentOptions
+	options.Proxy, options.Tunnel = "", ""
+	sess := newSessionOrPanic(
+		context.Background(), &options, miniooniDirOrPanic(&options), log.Log)
+	defer sess.Close()
+	fn(sess)
+}
@@ -7,24 +7,29 @@ package main
 import (
 	"context"
 
+	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/oonirun"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 )
 
 // runx runs the given experiment by name
-func runx(ctx context.Context, sess oonirun.Session, experimentName string,
+func runx(ctx context.Context, sess *engine.Session, experimentName string,
 	annotations map[string]string, extraOptions map[string]any, currentOptions *Options) {
 	desc := &oonirun.Experiment{
 		Annotations:    annotations,
 		ExtraOptions:   extraOptions,
 		Inputs:         currentOptions.Inputs,
 		InputFilePaths: currentOptions.InputFilePaths,
+		KVStore:        sess.KeyValueStore(),
 		MaxRuntime:     currentOptions.MaxRuntime,
 		Name:           experimentName,
 		NoCollector:    currentOptions.NoCollector,
 		NoJSON:         currentOptions.NoJSON,
+		Parallelism:    currentOptions.Parallelism,
 		Random:         currentOptions.Random,
 		ReportFile:     currentOptions.ReportFile,
+		Restart:        currentOptions.Restart,
+		Resume:         currentOptions.Resume,
 		Session:        sess,
 	}
 	err := desc.Run(ctx)
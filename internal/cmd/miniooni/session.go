@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/backendconfig"
 	"github.com/ooni/probe-cli/v3/internal/engine"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -42,8 +43,11 @@ func newSessionOrPanic(ctx context.Context, currentOptions *Options,
 		SnowflakeRendezvous: currentOptions.SnowflakeRendezvous,
 		SoftwareName:        softwareName,
 		SoftwareVersion:     softwareVersion,
+		SubmitQueueDir:      submitQueueDir(miniooniDir),
 		TorArgs:             currentOptions.TorArgs,
 		TorBinary:           currentOptions.TorBinary,
+		TorBridgeLines:      currentOptions.TorBridgeLines,
+		TunnelAutoRestart:   currentOptions.TunnelAutoRestart,
 		TunnelDir:           tunnelDir,
 	}
 	if currentOptions.ProbeServicesURL != "" {
@@ -53,6 +57,8 @@ func newSessionOrPanic(ctx context.Context, currentOptions *Options,
 		}}
 	}
 
+	config.BackendConfig = loadBackendConfigOrPanic(currentOptions)
+
 	sess, err := engine.NewSession(ctx, config)
 	runtimex.PanicOnError(err, "cannot create measurement session")
 
@@ -60,7 +66,24 @@ func newSessionOrPanic(ctx context.Context, currentOptions *Options,
 	return sess
 }
 
+// loadBackendConfigOrPanic loads the self-hosted backend config from the
+// --backend-config file or from the environment, if any, or panics on failure.
+func loadBackendConfigOrPanic(currentOptions *Options) *model.BackendConfig {
+	if currentOptions.BackendConfig != "" {
+		config, err := backendconfig.Load(currentOptions.BackendConfig)
+		runtimex.PanicOnError(err, "cannot load backend config")
+		return config
+	}
+	config, err := backendconfig.LoadFromEnv()
+	runtimex.PanicOnError(err, "cannot load backend config from the environment")
+	return config
+}
+
 func lookupBackendsOrPanic(ctx context.Context, sess *engine.Session) {
+	if sess.BackendConfig() != nil {
+		log.Info("Checking the self-hosted backend; please be patient...")
+		sess.CheckBackend(ctx)
+	}
 	log.Info("Looking up OONI backends; please be patient...")
 	err := sess.MaybeLookupBackendsContext(ctx)
 	runtimex.PanicOnError(err, "cannot lookup OONI backends")
@@ -0,0 +1,109 @@
+package main
+
+//
+// Inspecting and flushing the submission queue
+//
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/logx"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/submitqueue"
+	"github.com/spf13/cobra"
+)
+
+// submitQueueDir returns the directory containing the submission queue.
+func submitQueueDir(miniooniDir string) string {
+	return filepath.Join(miniooniDir, "submitqueue")
+}
+
+// registerSubmitQueue registers the submitqueue subcommand
+func registerSubmitQueue(rootCmd *cobra.Command, globalOptions *Options) {
+	subCmd := &cobra.Command{
+		Use:   "submitqueue",
+		Short: "Inspects and flushes the queue of measurements to submit",
+	}
+	rootCmd.AddCommand(subCmd)
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists the measurements in the queue",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			queue := newSubmitQueueOrPanic(globalOptions)
+			entries, err := queue.List()
+			runtimex.PanicOnError(err, "cannot list the submission queue")
+			for _, e := range entries {
+				fmt.Printf("%s %s %q attempts=%d next_attempt=%s last_failure=%q\n",
+					e.ID, e.TestName, e.Input, e.Attempts,
+					e.NextAttempt.Format("2006-01-02 15:04:05 MST"), e.LastFailure)
+			}
+		},
+	})
+
+	var force bool
+	flushCmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Submits the measurements in the queue",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			flushSubmitQueue(globalOptions, force)
+		},
+	}
+	flushCmd.Flags().BoolVar(
+		&force,
+		"force",
+		false,
+		"submit all measurements regardless of their retry backoff",
+	)
+	subCmd.AddCommand(flushCmd)
+
+	subCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Removes all the measurements from the queue",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			queue := newSubmitQueueOrPanic(globalOptions)
+			err := queue.Clear()
+			runtimex.PanicOnError(err, "cannot clear the submission queue")
+		},
+	})
+}
+
+// miniooniDirOrPanic returns the miniooni directory or panics on failure.
+func miniooniDirOrPanic(currentOptions *Options) string {
+	homeDir := gethomedir(currentOptions.HomeDir)
+	runtimex.Assert(homeDir != "", "home directory is empty")
+	miniooniDir := filepath.Join(homeDir, ".miniooni")
+	err := os.MkdirAll(miniooniDir, 0700)
+	runtimex.PanicOnError(err, "cannot create $HOME/.miniooni directory")
+	return miniooniDir
+}
+
+// newSubmitQueueOrPanic opens the submission queue or panics on failure.
+func newSubmitQueueOrPanic(currentOptions *Options) *submitqueue.Queue {
+	queue, err := submitqueue.New(submitQueueDir(miniooniDirOrPanic(currentOptions)), log.Log)
+	runtimex.PanicOnError(err, "cannot open the submission queue")
+	return queue
+}
+
+// flushSubmitQueue submits the queued measurements.
+func flushSubmitQueue(currentOptions *Options, force bool) {
+	logger := &log.Logger{Level: log.InfoLevel, Handler: logx.NewHandlerWithDefaultSettings()}
+	if currentOptions.Verbose {
+		logger.Level = log.DebugLevel
+	}
+	log.Log = logger
+	ctx := context.Background()
+	sess := newSessionOrPanic(ctx, currentOptions, miniooniDirOrPanic(currentOptions), logger)
+	defer sess.Close()
+	lookupBackendsOrPanic(ctx, sess)
+	result, err := sess.FlushSubmitQueue(ctx, force)
+	runtimex.PanicOnError(err, "cannot flush the submission queue")
+	log.Infof("submitted %d, failed %d, deferred %d", result.Submitted, result.Failed, result.Deferred)
+}
@@ -0,0 +1,75 @@
+// Command ndt7server implements a minimal ndt7 server.
+//
+// We use this server for offline testing of the ndt7 experiment
+// and in our lab. Run the experiment against this server using
+// `miniooni -O Server=ws://127.0.0.1:8080 ndt`.
+//
+// When you pass the -cert and -key flags, the server uses TLS and
+// you should use the wss:// scheme instead.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/experiment/ndt7"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+var (
+	endpoint   = flag.String("endpoint", "127.0.0.1:8080", "API endpoint")
+	maxRuntime = flag.Duration("max-runtime", 10*time.Second, "Maximum runtime of each subtest")
+	srvAddr    = make(chan string, 1) // with buffer
+	srvCancel  context.CancelFunc
+	srvCtx     context.Context
+)
+
+func init() {
+	srvCtx, srvCancel = context.WithCancel(context.Background())
+}
+
+func shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+func main() {
+	logmap := map[bool]log.Level{
+		true:  log.DebugLevel,
+		false: log.InfoLevel,
+	}
+	certFile := flag.String("cert", "", "OPTIONAL TLS certificate file")
+	keyFile := flag.String("key", "", "OPTIONAL TLS private key file")
+	debug := flag.Bool("debug", false, "Toggle debug mode")
+	flag.Parse()
+	log.SetLevel(logmap[*debug])
+	defer srvCancel()
+	listener, err := net.Listen("tcp", *endpoint)
+	runtimex.PanicOnError(err, "net.Listen failed")
+	srv := &http.Server{
+		Handler: &ndt7.Handler{
+			Logger:     log.Log,
+			MaxRuntime: *maxRuntime,
+		},
+	}
+	log.Infof("ndt7server: listening at %s", listener.Addr().String())
+	srvAddr <- listener.Addr().String()
+	go func() {
+		if *certFile != "" && *keyFile != "" {
+			err = srv.ServeTLS(listener, *certFile, *keyFile)
+		} else {
+			err = srv.Serve(listener)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			runtimex.PanicOnError(err, "srv.Serve failed")
+		}
+	}()
+	<-srvCtx.Done()
+	shutdown(srv)
+}
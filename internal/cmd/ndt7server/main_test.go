@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/experiment/ndt7"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestWorkingAsIntended(t *testing.T) {
+	*endpoint = "127.0.0.1:0"
+	*maxRuntime = time.Second
+	go main()
+	defer srvCancel()
+	addr := <-srvAddr
+	measurement := &model.Measurement{}
+	measurer := ndt7.NewExperimentMeasurer(ndt7.Config{Server: "ws://" + addr})
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*ndt7.TestKeys)
+	if tk.Failure != nil {
+		t.Fatal("unexpected failure", *tk.Failure)
+	}
+	if tk.Summary.Download <= 0 || tk.Summary.Upload <= 0 {
+		t.Fatal("expected positive speeds")
+	}
+}
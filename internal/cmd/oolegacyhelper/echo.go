@@ -0,0 +1,56 @@
+package main
+
+//
+// The tcp-echo helper used by http_invalid_request_line
+//
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// echoHelperName is the name of the tcp-echo helper.
+const echoHelperName = "tcp-echo"
+
+// echoHandler implements the tcp-echo helper.
+type echoHandler struct {
+	// BaseLogger is the MANDATORY logger to use.
+	BaseLogger model.Logger
+
+	// Indexer is the MANDATORY atomic integer used to assign an index to connections.
+	Indexer *atomic.Int64
+
+	// MaxBytes is the MANDATORY maximum number of bytes to echo.
+	MaxBytes int64
+
+	// Timeout is the MANDATORY maximum lifetime of a connection.
+	Timeout time.Duration
+}
+
+var _ connHandler = &echoHandler{}
+
+// Name implements connHandler.Name.
+func (h *echoHandler) Name() string {
+	return echoHelperName
+}
+
+// Serve implements connHandler.Serve.
+func (h *echoHandler) Serve(conn net.Conn) {
+	defer conn.Close()
+	logger := &indexLogger{
+		indexstr: newIndexString(h.Indexer),
+		logger:   h.BaseLogger,
+	}
+	logger.Debugf("echo: serving %s", conn.RemoteAddr().String())
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	// Implementation note: the client sends an invalid request line and then
+	// keeps reading until it times out, so here we echo everything we
+	// receive until either side closes the connection or we time out.
+	count, err := io.Copy(conn, &io.LimitedReader{R: conn, N: h.MaxBytes})
+	logger.Debugf("echo: echoed %d bytes (err=%v)", count, err)
+	metricConnectionsCount.WithLabelValues(echoHelperName, "ok").Inc()
+}
@@ -0,0 +1,165 @@
+package main
+
+//
+// The http-return-json-headers helper used by http_header_field_manipulation
+//
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/ooni/probe-cli/v3/internal/version"
+)
+
+// headersHelperName is the name of the http-return-json-headers helper.
+const headersHelperName = "http-return-json-headers"
+
+// headersResponse is the response returned by the http-return-json-headers helper.
+type headersResponse struct {
+	// HeadersDict maps each header name, as sent, to its values.
+	HeadersDict map[string][]string `json:"headers_dict"`
+
+	// RequestBytes contains the raw bytes of the request line and headers
+	// or, for HTTP/2, the HPACK-encoded header block.
+	RequestBytes []byte `json:"request_bytes"`
+
+	// RequestHeaders contains the header names and values in the order they were sent.
+	RequestHeaders [][]string `json:"request_headers"`
+
+	// RequestLine is the request line as sent.
+	RequestLine string `json:"request_line"`
+}
+
+// headersHandler implements the http-return-json-headers helper.
+//
+// We cannot use net/http here because it would canonicalize the header
+// names and reject odd methods such as "GeT", which is what the client
+// uses to detect middleboxes rewriting requests. So, we parse the
+// request ourselves and echo it back as JSON.
+type headersHandler struct {
+	// BaseLogger is the MANDATORY logger to use.
+	BaseLogger model.Logger
+
+	// Indexer is the MANDATORY atomic integer used to assign an index to connections.
+	Indexer *atomic.Int64
+
+	// MaxHeaderBytes is the MANDATORY maximum size of the request headers.
+	MaxHeaderBytes int64
+
+	// Timeout is the MANDATORY maximum lifetime of a connection.
+	Timeout time.Duration
+}
+
+var _ connHandler = &headersHandler{}
+
+// Name implements connHandler.Name.
+func (h *headersHandler) Name() string {
+	return headersHelperName
+}
+
+// errInvalidHeaderLine indicates that a header line does not contain a colon.
+var errInvalidHeaderLine = errors.New("oolegacyhelper: invalid header line")
+
+// Serve implements connHandler.Serve.
+func (h *headersHandler) Serve(conn net.Conn) {
+	defer conn.Close()
+	logger := &indexLogger{
+		indexstr: newIndexString(h.Indexer),
+		logger:   h.BaseLogger,
+	}
+	logger.Debugf("headers: serving %s", conn.RemoteAddr().String())
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	serveHTTP1(logger, conn, h.Name(), h.MaxHeaderBytes)
+}
+
+// serveHTTP1 reads a single HTTP/1.1 request from conn and echoes it back.
+func serveHTTP1(logger model.Logger, conn net.Conn, name string, maxHeaderBytes int64) {
+	reader := bufio.NewReader(&io.LimitedReader{R: conn, N: maxHeaderBytes})
+	resp, err := readRequestHeaders(reader)
+	if err != nil {
+		logger.Warnf("headers: cannot read request: %s", err.Error())
+		metricConnectionsCount.WithLabelValues(name, "bad_request").Inc()
+		writeHTTPResponse(conn, 400, "Bad Request", nil)
+		return
+	}
+	data := marshalHeadersResponse(resp)
+	logger.Debugf("headers: %s", string(data))
+	metricConnectionsCount.WithLabelValues(name, "ok").Inc()
+	writeHTTPResponse(conn, 200, "OK", data)
+}
+
+// marshalHeadersResponse serializes the response to JSON.
+func marshalHeadersResponse(resp *headersResponse) []byte {
+	// We assume that the following call cannot fail because it's a
+	// clearly-serializable data structure.
+	data, err := json.Marshal(resp)
+	runtimex.PanicOnError(err, "json.Marshal failed")
+	return data
+}
+
+// readRequestHeaders reads the request line and the headers without
+// changing the capitalization of any of them.
+func readRequestHeaders(reader *bufio.Reader) (*headersResponse, error) {
+	var raw bytes.Buffer
+	line, err := readLine(reader, &raw)
+	if err != nil {
+		return nil, err
+	}
+	resp := &headersResponse{
+		HeadersDict:    map[string][]string{},
+		RequestHeaders: [][]string{},
+		RequestLine:    line,
+	}
+	for {
+		line, err := readLine(reader, &raw)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			resp.RequestBytes = raw.Bytes()
+			return resp, nil
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("%w: %q", errInvalidHeaderLine, line)
+		}
+		value = strings.TrimSpace(value)
+		resp.HeadersDict[name] = append(resp.HeadersDict[name], value)
+		resp.RequestHeaders = append(resp.RequestHeaders, []string{name, value})
+	}
+}
+
+// readLine reads a line, appends it verbatim to raw, and returns
+// it without the trailing CRLF or LF.
+func readLine(reader *bufio.Reader, raw *bytes.Buffer) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	raw.WriteString(line)
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeHTTPResponse writes a minimal HTTP/1.1 response to the conn.
+func writeHTTPResponse(conn net.Conn, code int, reason string, body []byte) {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "HTTP/1.1 %d %s\r\n", code, reason)
+	fmt.Fprintf(&builder, "Server: oolegacyhelper/%s\r\n", version.Version)
+	if body != nil {
+		builder.WriteString("Content-Type: application/json\r\n")
+	}
+	fmt.Fprintf(&builder, "Content-Length: %d\r\n", len(body))
+	builder.WriteString("Connection: close\r\n\r\n")
+	builder.Write(body)
+	conn.Write([]byte(builder.String()))
+}
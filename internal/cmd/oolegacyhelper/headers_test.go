@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadRequestHeaders(t *testing.T) {
+	t.Run("we preserve the capitalization", func(t *testing.T) {
+		request := "GeT / HTTP/1.1\r\nhOsT: example.com\r\nAcCePt: */*\r\naccept: text/html\n\r\n"
+		resp, err := readRequestHeaders(bufio.NewReader(strings.NewReader(request)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.RequestLine != "GeT / HTTP/1.1" {
+			t.Fatal("unexpected request line", resp.RequestLine)
+		}
+		if len(resp.HeadersDict) != 3 || resp.HeadersDict["hOsT"][0] != "example.com" {
+			t.Fatal("unexpected headers dict", resp.HeadersDict)
+		}
+		if len(resp.RequestHeaders) != 3 || resp.RequestHeaders[2][0] != "accept" {
+			t.Fatal("unexpected request headers", resp.RequestHeaders)
+		}
+		if string(resp.RequestBytes) != request {
+			t.Fatal("unexpected request bytes", string(resp.RequestBytes))
+		}
+	})
+
+	t.Run("with an invalid header line", func(t *testing.T) {
+		request := "GET / HTTP/1.1\r\nInvalid\r\n\r\n"
+		_, err := readRequestHeaders(bufio.NewReader(strings.NewReader(request)))
+		if !errors.Is(err, errInvalidHeaderLine) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with a truncated request", func(t *testing.T) {
+		request := "GET / HTTP/1.1\r\nHost: example.com\r\n"
+		_, err := readRequestHeaders(bufio.NewReader(strings.NewReader(request)))
+		if !errors.Is(err, io.EOF) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package main
+
+//
+// The https-return-json-headers helper used by http_header_field_manipulation
+//
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/version"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// httpsHeadersHelperName is the name of the https-return-json-headers helper.
+const httpsHeadersHelperName = "https-return-json-headers"
+
+// http2InitialWindowSize is the initial flow control window defined by
+// RFC 7540, which we never exceed because we ignore WINDOW_UPDATE.
+const http2InitialWindowSize = 65535
+
+// httpsHeadersHandler implements the https-return-json-headers helper.
+//
+// This helper is like http-return-json-headers except that it uses TLS
+// and also speaks HTTP/2, which the client selects using ALPN. For HTTP/2
+// we do not use net/http either, because it would tell us neither
+// the header block we received nor the order of the headers.
+type httpsHeadersHandler struct {
+	// BaseLogger is the MANDATORY logger to use.
+	BaseLogger model.Logger
+
+	// Indexer is the MANDATORY atomic integer used to assign an index to connections.
+	Indexer *atomic.Int64
+
+	// MaxHeaderBytes is the MANDATORY maximum size of the request headers.
+	MaxHeaderBytes int64
+
+	// TLSConfig is the MANDATORY TLS config to use.
+	TLSConfig *tls.Config
+
+	// Timeout is the MANDATORY maximum lifetime of a connection.
+	Timeout time.Duration
+}
+
+var _ connHandler = &httpsHeadersHandler{}
+
+// Name implements connHandler.Name.
+func (h *httpsHeadersHandler) Name() string {
+	return httpsHeadersHelperName
+}
+
+// Serve implements connHandler.Serve.
+func (h *httpsHeadersHandler) Serve(conn net.Conn) {
+	defer conn.Close()
+	logger := &indexLogger{
+		indexstr: newIndexString(h.Indexer),
+		logger:   h.BaseLogger,
+	}
+	logger.Debugf("https-headers: serving %s", conn.RemoteAddr().String())
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	tconn := tls.Server(conn, h.TLSConfig)
+	if err := tconn.Handshake(); err != nil {
+		logger.Warnf("https-headers: TLS handshake failed: %s", err.Error())
+		metricConnectionsCount.WithLabelValues(h.Name(), "tls_handshake_error").Inc()
+		return
+	}
+	if tconn.ConnectionState().NegotiatedProtocol == "h2" {
+		serveHTTP2(logger, tconn, h.Name(), h.MaxHeaderBytes)
+		return
+	}
+	serveHTTP1(logger, tconn, h.Name(), h.MaxHeaderBytes)
+}
+
+var (
+	// errInvalidHTTP2Preface indicates that the client did not send the HTTP/2 preface.
+	errInvalidHTTP2Preface = errors.New("oolegacyhelper: invalid HTTP/2 preface")
+
+	// errHeaderBlockTooLarge indicates that the HTTP/2 header block is too large.
+	errHeaderBlockTooLarge = errors.New("oolegacyhelper: header block too large")
+
+	// errExpectedContinuation indicates that we did not receive a CONTINUATION frame.
+	errExpectedContinuation = errors.New("oolegacyhelper: expected CONTINUATION frame")
+)
+
+// serveHTTP2 serves HTTP/2 requests on conn until the client closes it
+// and echoes back the header block of each request.
+func serveHTTP2(logger model.Logger, conn net.Conn, name string, maxHeaderBytes int64) {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil || string(preface) != http2.ClientPreface {
+		logger.Warnf("headers: h2: %s", errInvalidHTTP2Preface.Error())
+		metricConnectionsCount.WithLabelValues(name, "bad_request").Inc()
+		return
+	}
+	framer := http2.NewFramer(conn, conn)
+	if err := framer.WriteSettings(); err != nil {
+		logger.Warnf("headers: h2: cannot write settings: %s", err.Error())
+		return
+	}
+	// Both the decoder and the encoder have per-connection state.
+	decoder := hpack.NewDecoder(4096, nil)
+	var block bytes.Buffer
+	encoder := hpack.NewEncoder(&block)
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			// this is also what happens when the client closes the conn
+			logger.Debugf("headers: h2: read frame: %s", err.Error())
+			return
+		}
+		switch frame := frame.(type) {
+		case *http2.SettingsFrame:
+			if !frame.IsAck() {
+				framer.WriteSettingsAck()
+			}
+		case *http2.PingFrame:
+			if !frame.IsAck() {
+				framer.WritePing(true, frame.Data)
+			}
+		case *http2.HeadersFrame:
+			resp, err := readHTTP2RequestHeaders(framer, frame, decoder, maxHeaderBytes)
+			if err != nil {
+				logger.Warnf("headers: h2: cannot read request: %s", err.Error())
+				metricConnectionsCount.WithLabelValues(name, "bad_request").Inc()
+				framer.WriteGoAway(frame.StreamID, http2.ErrCodeProtocol, nil)
+				return
+			}
+			data := marshalHeadersResponse(resp)
+			logger.Debugf("headers: h2: %s", string(data))
+			metricConnectionsCount.WithLabelValues(name, "ok").Inc()
+			if err := writeHTTP2Response(framer, encoder, &block, frame.StreamID, data); err != nil {
+				logger.Warnf("headers: h2: cannot write response: %s", err.Error())
+				return
+			}
+		}
+	}
+}
+
+// readHTTP2RequestHeaders reads the whole header block starting with the given
+// HEADERS frame and decodes it without changing the order of the headers.
+func readHTTP2RequestHeaders(framer *http2.Framer, frame *http2.HeadersFrame,
+	decoder *hpack.Decoder, maxHeaderBytes int64) (*headersResponse, error) {
+	var block bytes.Buffer
+	block.Write(frame.HeaderBlockFragment())
+	for ended := frame.HeadersEnded(); !ended; {
+		if int64(block.Len()) > maxHeaderBytes {
+			return nil, errHeaderBlockTooLarge
+		}
+		next, err := framer.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		cont, ok := next.(*http2.ContinuationFrame)
+		if !ok || cont.StreamID != frame.StreamID {
+			return nil, errExpectedContinuation
+		}
+		block.Write(cont.HeaderBlockFragment())
+		ended = cont.HeadersEnded()
+	}
+	if int64(block.Len()) > maxHeaderBytes {
+		return nil, errHeaderBlockTooLarge
+	}
+	return decodeHTTP2Headers(decoder, block.Bytes())
+}
+
+// decodeHTTP2Headers decodes the given HPACK-encoded header block.
+func decodeHTTP2Headers(decoder *hpack.Decoder, block []byte) (*headersResponse, error) {
+	fields, err := decoder.DecodeFull(block)
+	if err != nil {
+		return nil, err
+	}
+	resp := &headersResponse{
+		HeadersDict:    map[string][]string{},
+		RequestBytes:   block,
+		RequestHeaders: [][]string{},
+	}
+	for _, field := range fields {
+		resp.HeadersDict[field.Name] = append(resp.HeadersDict[field.Name], field.Value)
+		resp.RequestHeaders = append(resp.RequestHeaders, []string{field.Name, field.Value})
+	}
+	return resp, nil
+}
+
+// writeHTTP2Response writes the response on the given stream. Because we
+// ignore WINDOW_UPDATE, we refuse to send bodies larger than the initial
+// flow control window and we reply with 431 instead.
+func writeHTTP2Response(framer *http2.Framer, encoder *hpack.Encoder,
+	block *bytes.Buffer, streamID uint32, body []byte) error {
+	status := 200
+	if len(body) > http2InitialWindowSize {
+		status, body = 431, nil
+	}
+	block.Reset()
+	// Writing into a bytes.Buffer cannot fail
+	encoder.WriteField(hpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
+	encoder.WriteField(hpack.HeaderField{Name: "server", Value: "oolegacyhelper/" + version.Version})
+	if body != nil {
+		encoder.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/json"})
+	}
+	encoder.WriteField(hpack.HeaderField{Name: "content-length", Value: strconv.Itoa(len(body))})
+	err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: block.Bytes(),
+		EndStream:     len(body) <= 0,
+		EndHeaders:    true,
+	})
+	if err != nil {
+		return err
+	}
+	// Note: we cannot send frames larger than the default max frame size
+	// because we never advertise or honour a larger SETTINGS_MAX_FRAME_SIZE.
+	const maxFrameSize = 16384
+	for len(body) > 0 {
+		chunk := body
+		if len(chunk) > maxFrameSize {
+			chunk = chunk[:maxFrameSize]
+		}
+		body = body[len(chunk):]
+		if err := framer.WriteData(streamID, len(body) <= 0, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTLSConfig returns the TLS config for the https-return-json-headers helper
+// using the given certificate and key files or, when both are empty, an ephemeral
+// self-signed certificate. We log the SHA-256 of the certificate such that the
+// operator can configure clients to pin it.
+func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if certFile != "" || keyFile != "" {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	} else {
+		cert, err = newSelfSignedCert()
+	}
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(cert.Certificate[0])
+	log.Infof("%s: certificate SHA-256: %s", httpsHeadersHelperName, hex.EncodeToString(digest[:]))
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	return config, nil
+}
+
+// newSelfSignedCert generates a self-signed certificate valid for one year.
+func newSelfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "oolegacyhelper"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
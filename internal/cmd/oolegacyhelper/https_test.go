@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/apex/log"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestDecodeHTTP2Headers(t *testing.T) {
+	t.Run("we preserve the order and the capitalization", func(t *testing.T) {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		encoder.WriteField(hpack.HeaderField{Name: ":method", Value: "GET"})
+		encoder.WriteField(hpack.HeaderField{Name: "User-Agent", Value: "miniooni"})
+		encoder.WriteField(hpack.HeaderField{Name: "accept", Value: "*/*"})
+		encoder.WriteField(hpack.HeaderField{Name: "accept", Value: "text/html"})
+		resp, err := decodeHTTP2Headers(hpack.NewDecoder(4096, nil), block.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.RequestLine != "" {
+			t.Fatal("unexpected request line", resp.RequestLine)
+		}
+		if len(resp.RequestHeaders) != 4 || resp.RequestHeaders[1][0] != "User-Agent" {
+			t.Fatal("unexpected request headers", resp.RequestHeaders)
+		}
+		if len(resp.HeadersDict["accept"]) != 2 {
+			t.Fatal("unexpected headers dict", resp.HeadersDict)
+		}
+		if !bytes.Equal(resp.RequestBytes, block.Bytes()) {
+			t.Fatal("unexpected request bytes")
+		}
+	})
+
+	t.Run("with an invalid header block", func(t *testing.T) {
+		_, err := decodeHTTP2Headers(hpack.NewDecoder(4096, nil), []byte{0xff, 0xff, 0xff})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestReadHTTP2RequestHeaders(t *testing.T) {
+	// readRequest writes the first byte of a header block using HEADERS, then
+	// calls next with the rest of the block, and finally reads the request.
+	readRequest := func(next func(framer *http2.Framer, rest []byte)) (*headersResponse, []byte, error) {
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		encoder.WriteField(hpack.HeaderField{Name: ":method", Value: "GET"})
+		encoder.WriteField(hpack.HeaderField{Name: ":path", Value: "/"})
+		var buffer bytes.Buffer
+		writer := http2.NewFramer(&buffer, nil)
+		writer.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      1,
+			BlockFragment: block.Bytes()[:1],
+			EndStream:     true,
+		})
+		next(writer, block.Bytes()[1:])
+		reader := http2.NewFramer(nil, &buffer)
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := readHTTP2RequestHeaders(reader, frame.(*http2.HeadersFrame),
+			hpack.NewDecoder(4096, nil), maxHeaderBytes)
+		return resp, block.Bytes(), err
+	}
+
+	t.Run("with CONTINUATION", func(t *testing.T) {
+		resp, block, err := readRequest(func(framer *http2.Framer, rest []byte) {
+			framer.WriteContinuation(1, true, rest)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(resp.RequestBytes, block) {
+			t.Fatal("unexpected request bytes")
+		}
+		if len(resp.RequestHeaders) != 2 || resp.RequestHeaders[1][0] != ":path" {
+			t.Fatal("unexpected request headers", resp.RequestHeaders)
+		}
+	})
+
+	t.Run("without CONTINUATION", func(t *testing.T) {
+		_, _, err := readRequest(func(framer *http2.Framer, rest []byte) {
+			framer.WritePing(false, [8]byte{})
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestServeHTTP2(t *testing.T) {
+	t.Run("with an invalid preface", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		go client.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+		serveHTTP2(log.Log, server, httpsHeadersHelperName, maxHeaderBytes)
+		server.Close()
+	})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("with a self-signed certificate", func(t *testing.T) {
+		config, err := newTLSConfig("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Certificates) != 1 || len(config.NextProtos) != 2 {
+			t.Fatal("unexpected config")
+		}
+	})
+
+	t.Run("with nonexistent files", func(t *testing.T) {
+		_, err := newTLSConfig("testdata/nonexistent.crt", "testdata/nonexistent.key")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,163 @@
+// Command oolegacyhelper implements the legacy test helpers used by
+// http_header_field_manipulation and http_invalid_request_line.
+//
+// The http-return-json-headers helper returns the request line and
+// headers exactly as it received them. The https-return-json-headers
+// helper does the same over TLS for both HTTP/1.1 and HTTP/2. The
+// tcp-echo helper echoes back whatever it receives. All helpers only
+// operate on raw connections because we must not normalize what we
+// received.
+//
+// When you do not pass the -cert and -key flags, we generate an ephemeral
+// self-signed certificate. In both cases, we log the certificate SHA-256,
+// which clients may use to detect TLS interception.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// connTimeout is the maximum lifetime of a connection.
+	connTimeout = 30 * time.Second
+
+	// maxHeaderBytes is the maximum size of the request headers.
+	maxHeaderBytes = 1 << 16
+
+	// maxEchoBytes is the maximum number of bytes we echo.
+	maxEchoBytes = 1 << 16
+)
+
+var (
+	echoEndpoint    = flag.String("tcp-echo", "127.0.0.1:8081", "tcp-echo endpoint")
+	headersEndpoint = flag.String("http-return-json-headers", "127.0.0.1:8082", "http-return-json-headers endpoint")
+	httpsEndpoint   = flag.String("https-return-json-headers", "127.0.0.1:8443", "https-return-json-headers endpoint")
+	echoAddr        = make(chan string, 1) // with buffer
+	headersAddr     = make(chan string, 1) // with buffer
+	httpsAddr       = make(chan string, 1) // with buffer
+	srvCancel       context.CancelFunc
+	srvCtx          context.Context
+	srvWg           = new(sync.WaitGroup)
+)
+
+func init() {
+	srvCtx, srvCancel = context.WithCancel(context.Background())
+}
+
+// connHandler handles the connections accepted by a helper.
+type connHandler interface {
+	// Name returns the helper name.
+	Name() string
+
+	// Serve serves and closes the conn.
+	Serve(conn net.Conn)
+}
+
+// newIndexString returns the prefix to use for logging.
+func newIndexString(indexer *atomic.Int64) string {
+	return fmt.Sprintf("<#%d> ", indexer.Add(1))
+}
+
+// serve accepts connections using the given listener until it is closed.
+func serve(listener net.Listener, handler connHandler) {
+	defer srvWg.Done()
+	connWg := &sync.WaitGroup{}
+	defer connWg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Debugf("%s: accept: %s", handler.Name(), err.Error())
+			return
+		}
+		connWg.Add(1)
+		go func() {
+			defer connWg.Done()
+			metricConnectionsInflight.WithLabelValues(handler.Name()).Inc()
+			defer metricConnectionsInflight.WithLabelValues(handler.Name()).Dec()
+			done := make(chan any)
+			defer close(done)
+			go func() {
+				// make sure we interrupt inflight connections on shutdown
+				select {
+				case <-srvCtx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+			handler.Serve(conn)
+		}()
+	}
+}
+
+// listen creates a listener for the given handler and starts serving.
+func listen(endpoint string, addr chan<- string, handler connHandler) net.Listener {
+	listener, err := net.Listen("tcp", endpoint)
+	runtimex.PanicOnError(err, "net.Listen failed")
+	log.Infof("%s: listening at %s", handler.Name(), listener.Addr().String())
+	addr <- listener.Addr().String()
+	srvWg.Add(1)
+	go serve(listener, handler)
+	return listener
+}
+
+func shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+func main() {
+	logmap := map[bool]log.Level{
+		true:  log.DebugLevel,
+		false: log.InfoLevel,
+	}
+	prometheus := flag.String("prometheus", "127.0.0.1:9092", "Prometheus endpoint")
+	debug := flag.Bool("debug", false, "Toggle debug mode")
+	certFile := flag.String("cert", "", "OPTIONAL TLS certificate file")
+	keyFile := flag.String("key", "", "OPTIONAL TLS private key file")
+	flag.Parse()
+	log.SetLevel(logmap[*debug])
+	tlsConfig, err := newTLSConfig(*certFile, *keyFile)
+	runtimex.PanicOnError(err, "newTLSConfig failed")
+	defer srvCancel()
+	indexer := &atomic.Int64{}
+	echoListener := listen(*echoEndpoint, echoAddr, &echoHandler{
+		BaseLogger: log.Log,
+		Indexer:    indexer,
+		MaxBytes:   maxEchoBytes,
+		Timeout:    connTimeout,
+	})
+	headersListener := listen(*headersEndpoint, headersAddr, &headersHandler{
+		BaseLogger:     log.Log,
+		Indexer:        indexer,
+		MaxHeaderBytes: maxHeaderBytes,
+		Timeout:        connTimeout,
+	})
+	httpsListener := listen(*httpsEndpoint, httpsAddr, &httpsHeadersHandler{
+		BaseLogger:     log.Log,
+		Indexer:        indexer,
+		MaxHeaderBytes: maxHeaderBytes,
+		TLSConfig:      tlsConfig,
+		Timeout:        connTimeout,
+	})
+	promMux := http.NewServeMux()
+	promMux.Handle("/metrics", promhttp.Handler())
+	promSrv := &http.Server{Addr: *prometheus, Handler: promMux}
+	go promSrv.ListenAndServe()
+	<-srvCtx.Done()
+	shutdown(promSrv)
+	echoListener.Close()
+	headersListener.Close()
+	httpsListener.Close()
+}
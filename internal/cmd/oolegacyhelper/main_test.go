@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/experiment/hhfm"
+	"github.com/ooni/probe-cli/v3/internal/experiment/hirl"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// certificateSHA256 returns the SHA-256 of the certificate used by the TLS helper.
+func certificateSHA256(t *testing.T, endpoint string) string {
+	conn, err := tls.Dial("tcp", endpoint, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	digest := sha256.Sum256(conn.ConnectionState().PeerCertificates[0].Raw)
+	return hex.EncodeToString(digest[:])
+}
+
+// runExperiment runs the given experiment using the given helpers.
+func runExperiment(t *testing.T, measurer model.ExperimentMeasurer,
+	helpers map[string][]model.OOAPIService) *model.Measurement {
+	measurement := new(model.Measurement)
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session: &mockable.Session{
+			MockableLogger:      log.Log,
+			MockableTestHelpers: helpers,
+		},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	return measurement
+}
+
+func TestMainWorkingAsIntended(t *testing.T) {
+	// let the kernel pick random free ports
+	*echoEndpoint = "127.0.0.1:0"
+	*headersEndpoint = "127.0.0.1:0"
+	*httpsEndpoint = "127.0.0.1:0"
+
+	// run the main function in a background goroutine
+	go main()
+	echoEndpoint := <-echoAddr
+	headersEndpoint := <-headersAddr
+	httpsEndpoint := <-httpsAddr
+	pin := certificateSHA256(t, httpsEndpoint)
+
+	// runHHFM runs hhfm using the given pin.
+	runHHFM := func(t *testing.T, pin string) *hhfm.TestKeys {
+		config := hhfm.Config{TLSHelper: httpsEndpoint, TLSHelperSHA256: pin}
+		measurement := runExperiment(t, hhfm.NewExperimentMeasurer(config),
+			map[string][]model.OOAPIService{
+				headersHelperName: {{
+					Address: "http://" + headersEndpoint + "/",
+					Type:    "legacy",
+				}},
+			})
+		tk := measurement.TestKeys.(*hhfm.TestKeys)
+		if tk.Failure != nil {
+			t.Fatal("unexpected failure", *tk.Failure)
+		}
+		if len(tk.Suite) != 9 {
+			t.Fatal("unexpected number of test cases", len(tk.Suite))
+		}
+		for _, result := range tk.Suite {
+			if result.Failure != nil {
+				t.Fatal("unexpected failure", result.Name, *result.Failure)
+			}
+		}
+		return tk
+	}
+
+	t.Run("hhfm does not see any tampering", func(t *testing.T) {
+		tk := runHHFM(t, pin)
+		if tk.Tampering.IsAnomaly() {
+			t.Fatalf("unexpected tampering: %+v", tk.Tampering)
+		}
+		for _, result := range tk.Suite {
+			if result.Tampering.IsAnomaly() || result.ByteDiff != nil {
+				t.Fatalf("unexpected tampering: %s: %+v", result.Name, result.Tampering)
+			}
+		}
+	})
+
+	t.Run("hhfm detects TLS interception with another pin", func(t *testing.T) {
+		tk := runHHFM(t, strings.Repeat("ab", sha256.Size))
+		for _, result := range tk.Suite {
+			if (result.TLS != nil) != result.Tampering.TLSInterception {
+				t.Fatalf("unexpected tampering: %s: %+v", result.Name, result.Tampering)
+			}
+		}
+	})
+
+	t.Run("hirl does not see any tampering", func(t *testing.T) {
+		measurement := runExperiment(t, hirl.NewExperimentMeasurer(hirl.Config{}),
+			map[string][]model.OOAPIService{
+				echoHelperName: {{
+					Address: echoEndpoint,
+					Type:    "legacy",
+				}},
+			})
+		tk := measurement.TestKeys.(*hirl.TestKeys)
+		if len(tk.TamperingList) != 5 {
+			t.Fatal("unexpected number of results", len(tk.TamperingList))
+		}
+		for idx, failure := range tk.FailureList {
+			if failure != nil {
+				t.Fatal("unexpected failure", idx, *failure)
+			}
+		}
+		if tk.Tampering {
+			t.Fatalf("unexpected tampering: %+v", tk.TamperingList)
+		}
+	})
+
+	t.Run("the headers helper rejects invalid requests", func(t *testing.T) {
+		conn, err := net.Dial("tcp", headersEndpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nInvalidHeaderLine\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 400 {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+
+	// tear down the helpers
+	srvCancel()
+
+	// wait for the background goroutines to join
+	srvWg.Wait()
+}
@@ -0,0 +1,24 @@
+package main
+
+//
+// Metrics definitions
+//
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// metricConnectionsCount counts the number of connections we served.
+	metricConnectionsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oolegacyhelper_connections_count",
+		Help: "Total number of processed connections",
+	}, []string{"helper", "reason"})
+
+	// metricConnectionsInflight gauges the number of connections currently inflight.
+	metricConnectionsInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oolegacyhelper_connections_inflight_gauge",
+		Help: "The number of connections currently inflight",
+	}, []string{"helper"})
+)
@@ -292,6 +292,65 @@ func (d *Database) CreateResult(homePath string, testGroupName string, networkID
 	return &result, nil
 }
 
+// CreateSkippedRun implements WritableDatabase.CreateSkippedRun
+func (d *Database) CreateSkippedRun(testGroupName string, networkID int64, reason string) (*model.DatabaseSkippedRun, error) {
+	skipped := model.DatabaseSkippedRun{
+		TestGroupName: testGroupName,
+		Time:          time.Now().UTC(),
+		Reason:        reason,
+		NetworkID:     networkID,
+	}
+	newID, err := d.sess.Collection("skipped_runs").Insert(skipped)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating skipped run")
+	}
+	skipped.ID = newID.ID().(int64)
+	return &skipped, nil
+}
+
+// ListSkippedRuns implements ReadableDatabase.ListSkippedRuns
+func (d *Database) ListSkippedRuns() ([]model.DatabaseSkippedRun, error) {
+	skipped := []model.DatabaseSkippedRun{}
+	res := d.sess.Collection("skipped_runs").Find().OrderBy("skipped_run_time")
+	if err := res.All(&skipped); err != nil {
+		return nil, errors.Wrap(err, "failed to list skipped runs")
+	}
+	return skipped, nil
+}
+
+// DataUsageByASN implements ReadableDatabase.DataUsageByASN
+func (d *Database) DataUsageByASN(asn uint, since time.Time) (float64, error) {
+	return d.dataUsage(since, "networks.asn = ?", asn)
+}
+
+// DataUsageByCC implements ReadableDatabase.DataUsageByCC
+func (d *Database) DataUsageByCC(cc string, since time.Time) (float64, error) {
+	return d.dataUsage(since, "UPPER(networks.network_country_code) = UPPER(?)", cc)
+}
+
+// DataUsage implements ReadableDatabase.DataUsage
+func (d *Database) DataUsage(since time.Time) (float64, error) {
+	return d.dataUsage(since, "1 = 1")
+}
+
+// dataUsage returns the data usage of the results started after since
+// and run on the networks matching the given condition.
+func (d *Database) dataUsage(since time.Time, cond string, args ...interface{}) (float64, error) {
+	var usage struct {
+		Total sql.NullFloat64 `db:"total"`
+	}
+	where := append([]interface{}{"results.result_start_time >= ? AND " + cond, since.UTC()}, args...)
+	req := d.sess.SQL().Select(
+		db.Raw("SUM(results.result_data_usage_up + results.result_data_usage_down) AS total"),
+	).From("results").
+		Join("networks").On("results.network_id = networks.network_id").
+		Where(where...)
+	if err := req.One(&usage); err != nil {
+		return 0, errors.Wrap(err, "failed to compute data usage")
+	}
+	return usage.Total.Float64, nil
+}
+
 // CreateNetwork implements WritableDatabase.CreateNetwork
 func (d *Database) CreateNetwork(loc model.LocationProvider) (*model.DatabaseNetwork, error) {
 	network := model.DatabaseNetwork{
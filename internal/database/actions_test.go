@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/upper/db/v4"
@@ -423,3 +424,98 @@ func TestGetMeasurementJSON(t *testing.T) {
 		t.Error("inconsistent measurement downloaded")
 	}
 }
+
+func TestSkippedRunsAndDataUsage(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "dbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpdir, err := ioutil.TempDir("", "oonitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	database, err := Open(tmpfile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location := locationInfo{
+		asn:         30722,
+		countryCode: "IT",
+		networkName: "Vodafone Italia S.p.A.",
+	}
+	network, err := database.CreateNetwork(&location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := database.CreateResult(tmpdir, "performance", network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.DataUsageUp = 1024
+	result.DataUsageDown = 2048
+	if err := database.Finished(result); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := database.DataUsageByASN(30722, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 3072 {
+		t.Fatal("unexpected data usage", usage)
+	}
+	usage, err = database.DataUsageByASN(30722, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 0 {
+		t.Fatal("unexpected data usage", usage)
+	}
+	usage, err = database.DataUsageByASN(12345, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 0 {
+		t.Fatal("unexpected data usage", usage)
+	}
+
+	usage, err = database.DataUsageByCC("it", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 3072 {
+		t.Fatal("unexpected data usage", usage)
+	}
+	usage, err = database.DataUsageByCC("DE", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 0 {
+		t.Fatal("unexpected data usage", usage)
+	}
+	usage, err = database.DataUsage(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 3072 {
+		t.Fatal("unexpected data usage", usage)
+	}
+
+	skipped, err := database.CreateSkippedRun("performance", network.ID, "daily data usage limit reached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := database.ListSkippedRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != skipped.ID || runs[0].Reason != skipped.Reason {
+		t.Fatal("unexpected skipped runs", runs)
+	}
+}
@@ -0,0 +1,26 @@
+-- +migrate Down
+-- +migrate StatementBegin
+
+DROP TABLE `skipped_runs`;
+
+-- +migrate StatementEnd
+
+-- +migrate Up
+-- +migrate StatementBegin
+
+-- This table records the test group runs that we did not perform
+-- because of the run policies configured by the user.
+CREATE TABLE `skipped_runs` (
+    `skipped_run_id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `test_group_name` VARCHAR(16) NOT NULL,
+    `skipped_run_time` DATETIME NOT NULL,
+    -- The reason why we skipped, e.g., "daily data usage limit reached".
+    `skip_reason` TEXT NOT NULL,
+
+    `network_id` INTEGER NOT NULL,
+    CONSTRAINT `fk_network_id`
+      FOREIGN KEY(`network_id`)
+      REFERENCES `networks`(`network_id`)
+);
+
+-- +migrate StatementEnd
@@ -0,0 +1,346 @@
+package engine
+
+//
+// Checkpointing the progress of an InputProcessor
+//
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// Checkpoint is the progress of running an experiment over a list
+// of inputs that we persist into the key-value store.
+//
+// We save the experiment name, inputs and options only once, when we
+// measure the first input, and then we only update the fields that
+// change (i.e., the report ID and the completed inputs), such that
+// saving the progress does not depend on the number of inputs.
+type Checkpoint struct {
+	// ExperimentName is the name of the experiment.
+	ExperimentName string `json:"experiment_name"`
+
+	// Inputs contains the inputs in the order we're measuring them.
+	Inputs []model.OOAPIURLInfo `json:"inputs"`
+
+	// Options contains the experiment options.
+	Options []string `json:"options"`
+
+	// ReportID is the ID of the last report we submitted to.
+	ReportID string `json:"-"`
+
+	// Completed contains the indexes of the already measured inputs.
+	Completed []int `json:"-"`
+
+	// UpdatedAt is the last time we updated the checkpoint.
+	UpdatedAt time.Time `json:"-"`
+}
+
+// checkpointState is the part of a Checkpoint we save every
+// time we measure an input.
+type checkpointState struct {
+	ReportID  string    `json:"report_id"`
+	Completed []int     `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrNoCheckpoint indicates that there is no saved checkpoint.
+var ErrNoCheckpoint = errors.New("engine: no saved checkpoint")
+
+// ErrResumeAndRestart indicates that the user asked to both
+// resume and restart an interrupted run.
+var ErrResumeAndRestart = errors.New("engine: cannot both resume and restart")
+
+// reportMaxIdleTime is the maximum amount of time since we last updated
+// a checkpoint after which we do not reuse its report ID, because the
+// backend may have closed the report in the meanwhile.
+const reportMaxIdleTime = time.Hour
+
+// checkpointKeys returns the key-value store keys of the inputs and of the
+// state of the checkpoint for the given config. The keys include a digest
+// of the options and of the inputs (regardless of their order, which may
+// be random), such that runs of the same experiment with different options
+// or inputs do not overwrite each other's checkpoint. When ReuseInputs is
+// true, the inputs change every time, so we only include the options.
+func checkpointKeys(config *CheckpointerConfig) (inputs, state string) {
+	name := config.Name
+	if name == "" {
+		name = config.ExperimentName
+	}
+	options := append([]string{}, config.Options...)
+	sort.Strings(options)
+	var urls []string
+	if !config.ReuseInputs {
+		for _, entry := range config.Inputs {
+			urls = append(urls, entry.URL)
+		}
+		sort.Strings(urls)
+	}
+	hash := sha256.New()
+	for _, entry := range options {
+		fmt.Fprintf(hash, "option %q\n", entry)
+	}
+	for _, entry := range urls {
+		fmt.Fprintf(hash, "input %q\n", entry)
+	}
+	prefix := fmt.Sprintf("checkpoint.%s.%x", name, hash.Sum(nil)[:8])
+	return prefix + ".inputs", prefix + ".state"
+}
+
+// LoadCheckpoint loads the checkpoint matching the given config from
+// the config's key-value store. It returns ErrNoCheckpoint if there's
+// no checkpoint or the checkpoint has been removed.
+func LoadCheckpoint(config *CheckpointerConfig) (*Checkpoint, error) {
+	inputsKey, stateKey := checkpointKeys(config)
+	data, err := config.KVStore.Get(inputsKey)
+	if err != nil || len(data) <= 0 {
+		return nil, ErrNoCheckpoint
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	data, err = config.KVStore.Get(stateKey)
+	if err != nil || len(data) <= 0 {
+		return nil, ErrNoCheckpoint
+	}
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	cp.ReportID, cp.Completed, cp.UpdatedAt = state.ReportID, state.Completed, state.UpdatedAt
+	return &cp, nil
+}
+
+// RemoveCheckpoint removes the checkpoint matching the given config.
+func RemoveCheckpoint(config *CheckpointerConfig) error {
+	inputsKey, stateKey := checkpointKeys(config)
+	return removeCheckpoint(config.KVStore, inputsKey, stateKey)
+}
+
+// removeCheckpoint removes the checkpoint saved using the given keys.
+func removeCheckpoint(kvstore model.KeyValueStore, inputsKey, stateKey string) error {
+	// Note: the key-value store does not know how to delete keys
+	if err := kvstore.Set(stateKey, nil); err != nil {
+		return err
+	}
+	return kvstore.Set(inputsKey, nil)
+}
+
+// CheckpointerConfig contains config for NewCheckpointer.
+type CheckpointerConfig struct {
+	// ExperimentName is the MANDATORY experiment name.
+	ExperimentName string
+
+	// Inputs contains the MANDATORY inputs to measure.
+	Inputs []model.OOAPIURLInfo
+
+	// KVStore is the MANDATORY key-value store.
+	KVStore model.KeyValueStore
+
+	// Logger is the MANDATORY logger.
+	Logger model.Logger
+
+	// Name is the OPTIONAL checkpoint name. If empty, we use
+	// the experiment name as the checkpoint name. We also use a
+	// digest of the options and inputs to identify a checkpoint.
+	Name string
+
+	// Options contains the OPTIONAL experiment options.
+	Options []string
+
+	// Resume OPTIONALLY indicates we should resume an interrupted run.
+	Resume bool
+
+	// Restart OPTIONALLY indicates we should discard an interrupted run.
+	Restart bool
+
+	// ReuseInputs OPTIONALLY indicates that, when resuming, we should use
+	// the inputs saved in the checkpoint even if they differ from Inputs,
+	// which is useful when Inputs comes from the check-in API, which
+	// returns a different list of inputs every time we call it.
+	ReuseInputs bool
+}
+
+// Checkpointer keeps track of the inputs measured by an InputProcessor
+// and saves this information in the key-value store, such that we can
+// later resume an interrupted run and skip the already measured inputs.
+//
+// The zero value is invalid; please, use NewCheckpointer.
+type Checkpointer struct {
+	cp        *Checkpoint
+	completed map[int]bool
+	inputsKey string
+	kvstore   model.KeyValueStore
+	mu        sync.Mutex
+	resumed   bool
+	saved     bool
+	stateKey  string
+	timeNow   func() time.Time
+}
+
+// NewCheckpointer creates a new Checkpointer.
+//
+// When config.Resume is true and there is a checkpoint for the same
+// experiment, options, and set of inputs, we resume from it. Otherwise,
+// we start from scratch. When neither config.Resume nor config.Restart
+// are true and we find an interrupted run, we log how to resume it.
+//
+// Because we may be resuming, the caller MUST measure the inputs returned
+// by the Inputs method rather than the ones inside config.
+func NewCheckpointer(config *CheckpointerConfig) (*Checkpointer, error) {
+	if config.Resume && config.Restart {
+		return nil, ErrResumeAndRestart
+	}
+	inputsKey, stateKey := checkpointKeys(config)
+	c := &Checkpointer{
+		cp: &Checkpoint{
+			ExperimentName: config.ExperimentName,
+			Inputs:         config.Inputs,
+			Options:        config.Options,
+		},
+		completed: map[int]bool{},
+		inputsKey: inputsKey,
+		kvstore:   config.KVStore,
+		stateKey:  stateKey,
+		timeNow:   time.Now,
+	}
+	old, err := LoadCheckpoint(config)
+	switch {
+	case err != nil:
+		// nothing to resume
+	case config.Restart:
+		config.Logger.Infof("checkpoint: discarding interrupted %s run", old.ExperimentName)
+	case !config.Resume:
+		config.Logger.Warnf("checkpoint: found interrupted %s run (%d/%d inputs measured)",
+			old.ExperimentName, len(old.Completed), len(old.Inputs))
+		config.Logger.Warn("checkpoint: use --resume to resume it or --restart to hide this warning")
+	case !old.matches(c.cp, config.ReuseInputs):
+		config.Logger.Warnf("checkpoint: cannot resume %s run: inputs or options changed",
+			old.ExperimentName)
+	default:
+		config.Logger.Infof("checkpoint: resuming %s run (%d/%d inputs measured; report ID: %s)",
+			old.ExperimentName, len(old.Completed), len(old.Inputs), old.ReportID)
+		c.cp = old
+		c.resumed = true
+		c.saved = true
+		for _, idx := range old.Completed {
+			c.completed[idx] = true
+		}
+	}
+	return c, nil
+}
+
+// matches returns whether the checkpoint has the same experiment name
+// and options of other and the same inputs regardless of their order,
+// which may differ when the user asked us to randomize the inputs. When
+// anyInputs is true, we do not compare the inputs.
+func (cp *Checkpoint) matches(other *Checkpoint, anyInputs bool) bool {
+	if cp.ExperimentName != other.ExperimentName || len(cp.Options) != len(other.Options) {
+		return false
+	}
+	options := map[string]int{}
+	for _, entry := range cp.Options {
+		options[entry]++
+	}
+	for _, entry := range other.Options {
+		if options[entry] <= 0 {
+			return false
+		}
+		options[entry]--
+	}
+	if anyInputs {
+		return true
+	}
+	if len(cp.Inputs) != len(other.Inputs) {
+		return false
+	}
+	inputs := map[string]int{}
+	for _, entry := range cp.Inputs {
+		inputs[entry.URL]++
+	}
+	for _, entry := range other.Inputs {
+		if inputs[entry.URL] <= 0 {
+			return false
+		}
+		inputs[entry.URL]--
+	}
+	return true
+}
+
+// Inputs returns the inputs to measure.
+func (c *Checkpointer) Inputs() []model.OOAPIURLInfo {
+	return c.cp.Inputs
+}
+
+// Resumed returns whether we are resuming an interrupted run.
+func (c *Checkpointer) Resumed() bool {
+	return c.resumed
+}
+
+// ReportID returns the ID of the report of the interrupted run, which we
+// should reuse when resuming, or the empty string when we are not resuming
+// or the report has been idle for too long and is probably closed.
+func (c *Checkpointer) ReportID() string {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	if !c.resumed || c.timeNow().Sub(c.cp.UpdatedAt) >= reportMaxIdleTime {
+		return ""
+	}
+	return c.cp.ReportID
+}
+
+// Completed returns whether we have already measured the input with the given index.
+func (c *Checkpointer) Completed(idx int) bool {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	return c.completed[idx]
+}
+
+// MarkCompleted records that we have measured the input with the given index
+// and saves the checkpoint. The reportID argument is the ID of the report we
+// submitted the measurement to, or the empty string.
+func (c *Checkpointer) MarkCompleted(idx int, reportID string) error {
+	defer c.mu.Unlock()
+	c.mu.Lock()
+	if !c.saved {
+		data, err := json.Marshal(c.cp)
+		if err != nil {
+			return err
+		}
+		if err := c.kvstore.Set(c.inputsKey, data); err != nil {
+			return err
+		}
+		c.saved = true
+	}
+	if !c.completed[idx] {
+		c.completed[idx] = true
+		c.cp.Completed = append(c.cp.Completed, idx)
+		sort.Ints(c.cp.Completed)
+	}
+	if reportID != "" {
+		c.cp.ReportID = reportID
+	}
+	c.cp.UpdatedAt = c.timeNow()
+	data, err := json.Marshal(&checkpointState{
+		ReportID:  c.cp.ReportID,
+		Completed: c.cp.Completed,
+		UpdatedAt: c.cp.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return c.kvstore.Set(c.stateKey, data)
+}
+
+// Remove removes the checkpoint once we have measured all the inputs.
+func (c *Checkpointer) Remove() error {
+	return removeCheckpoint(c.kvstore, c.inputsKey, c.stateKey)
+}
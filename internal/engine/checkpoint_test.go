@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func newCheckpointerConfig(kvs model.KeyValueStore, inputs ...string) *CheckpointerConfig {
+	config := &CheckpointerConfig{
+		ExperimentName: "web_connectivity",
+		KVStore:        kvs,
+		Logger:         log.Log,
+		Options:        []string{"fake=true"},
+	}
+	for _, input := range inputs {
+		config.Inputs = append(config.Inputs, model.OOAPIURLInfo{URL: input})
+	}
+	return config
+}
+
+func TestCheckpointer(t *testing.T) {
+	// interruptedRun creates a checkpoint where we measured the first input
+	interruptedRun := func(t *testing.T, config *CheckpointerConfig) {
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MarkCompleted(0, "20230101T000000Z_webconnectivity_IT_30722_n1_xx"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("we can resume an interrupted run", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/"))
+		config := newCheckpointerConfig(kvs, "https://b.org/", "https://a.org/")
+		config.Resume = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := c.Inputs()
+		if len(inputs) != 2 || inputs[0].URL != "https://a.org/" {
+			t.Fatal("expected to use the inputs order of the checkpoint", inputs)
+		}
+		if !c.Resumed() || !c.Completed(0) || c.Completed(1) {
+			t.Fatal("unexpected completed inputs")
+		}
+		if c.ReportID() != "20230101T000000Z_webconnectivity_IT_30722_n1_xx" {
+			t.Fatal("expected to reuse the report ID", c.ReportID())
+		}
+	})
+
+	t.Run("we do not reuse the report ID of an idle run", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/"))
+		config := newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/")
+		config.Resume = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.timeNow = func() time.Time {
+			return time.Now().Add(2 * reportMaxIdleTime)
+		}
+		if !c.Resumed() || c.ReportID() != "" {
+			t.Fatal("expected to resume without reusing the report ID")
+		}
+	})
+
+	t.Run("we cannot resume if inputs changed", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/"))
+		config := newCheckpointerConfig(kvs, "https://a.org/", "https://c.org/")
+		config.Resume = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Completed(0) || c.Inputs()[1].URL != "https://c.org/" {
+			t.Fatal("expected to start from scratch")
+		}
+	})
+
+	t.Run("we can reuse the checkpoint inputs", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		config := newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/")
+		config.ReuseInputs = true
+		interruptedRun(t, config)
+		config = newCheckpointerConfig(kvs, "https://c.org/")
+		config.Resume = true
+		config.ReuseInputs = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Completed(0) || len(c.Inputs()) != 2 {
+			t.Fatal("expected to resume using the checkpoint inputs")
+		}
+	})
+
+	t.Run("we cannot resume if options changed", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/"))
+		config := newCheckpointerConfig(kvs, "https://a.org/")
+		config.Options = []string{"fake=false"}
+		config.Resume = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Completed(0) {
+			t.Fatal("expected to start from scratch")
+		}
+	})
+
+	for _, restart := range []bool{true, false} {
+		t.Run("we start from scratch unless asked to resume", func(t *testing.T) {
+			kvs := &kvstore.Memory{}
+			interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/"))
+			config := newCheckpointerConfig(kvs, "https://a.org/")
+			config.Restart = restart
+			c, err := NewCheckpointer(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Completed(0) || c.ReportID() != "" {
+				t.Fatal("expected to start from scratch")
+			}
+		})
+	}
+
+	t.Run("we keep separate checkpoints for different inputs", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/"))
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://c.org/"))
+		config := newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/")
+		config.Resume = true
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Resumed() || !c.Completed(0) || c.Inputs()[1].URL != "https://b.org/" {
+			t.Fatal("expected to resume the first run")
+		}
+	})
+
+	t.Run("we save the inputs only once", func(t *testing.T) {
+		kvs := &countingKVStore{KeyValueStore: &kvstore.Memory{}, sets: map[string]int{}}
+		config := newCheckpointerConfig(kvs, "https://a.org/", "https://b.org/")
+		c, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for idx := 0; idx < 2; idx++ {
+			if err := c.MarkCompleted(idx, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		inputsKey, stateKey := checkpointKeys(config)
+		if kvs.sets[inputsKey] != 1 || kvs.sets[stateKey] != 2 {
+			t.Fatal("unexpected number of writes", kvs.sets)
+		}
+	})
+
+	t.Run("we cannot both resume and restart", func(t *testing.T) {
+		config := newCheckpointerConfig(&kvstore.Memory{})
+		config.Resume, config.Restart = true, true
+		if _, err := NewCheckpointer(config); !errors.Is(err, ErrResumeAndRestart) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("we save the report ID and remove the checkpoint", func(t *testing.T) {
+		kvs := &kvstore.Memory{}
+		interruptedRun(t, newCheckpointerConfig(kvs, "https://a.org/"))
+		config := newCheckpointerConfig(kvs, "https://a.org/")
+		cp, err := LoadCheckpoint(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cp.ReportID == "" || len(cp.Completed) != 1 || cp.UpdatedAt.IsZero() {
+			t.Fatal("unexpected checkpoint", cp)
+		}
+		if err := RemoveCheckpoint(config); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCheckpoint(config); !errors.Is(err, ErrNoCheckpoint) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+}
+
+// countingKVStore counts the number of times we set each key.
+type countingKVStore struct {
+	model.KeyValueStore
+	sets map[string]int
+}
+
+func (kvs *countingKVStore) Set(key string, value []byte) error {
+	kvs.sets[key]++
+	return kvs.KeyValueStore.Set(key, value)
+}
+
+func TestInputProcessorWithCheckpointer(t *testing.T) {
+	kvs := &kvstore.Memory{}
+	config := newCheckpointerConfig(kvs, "https://www.kernel.org/", "https://www.slashdot.org/")
+	c, err := NewCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkCompleted(0, ""); err != nil {
+		t.Fatal(err)
+	}
+	fipe := &FakeInputProcessorExperiment{}
+	ip := &InputProcessor{
+		Checkpointer: c,
+		Experiment:   NewInputProcessorExperimentWrapper(fipe),
+		Inputs:       c.Inputs(),
+		Saver:        NewInputProcessorSaverWrapper(&FakeInputProcessorSaver{}),
+		Submitter:    NewInputProcessorSubmitterWrapper(&FakeInputProcessorSubmitter{}),
+	}
+	reason, err := ip.run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != stopNormal {
+		t.Fatal("unexpected reason", reason)
+	}
+	if len(fipe.M) != 1 || fipe.M[0].Input != "https://www.slashdot.org/" {
+		t.Fatal("expected to only measure the second input")
+	}
+	if _, err := LoadCheckpoint(config); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatal("expected the checkpoint to be removed", err)
+	}
+}
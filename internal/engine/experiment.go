@@ -11,10 +11,12 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
 	"github.com/ooni/probe-cli/v3/internal/probeservices"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/version"
@@ -29,8 +31,10 @@ func formatTimeNowUTC() string {
 // experiment implements Experiment.
 type experiment struct {
 	byteCounter   *bytecounter.Counter
+	bytesReported [2]int64 // received and sent bytes already exported as metrics
 	callbacks     model.ExperimentCallbacks
 	measurer      model.ExperimentMeasurer
+	mu            sync.Mutex
 	report        probeservices.ReportChannel
 	session       *Session
 	testName      string
@@ -118,6 +122,28 @@ func (eaw *experimentAsyncWrapper) RunAsync(
 
 // MeasureAsync implements Experiment.MeasureAsync.
 func (e *experiment) MeasureAsync(
+	ctx context.Context, input string) (<-chan *model.Measurement, error) {
+	probemetrics.MeasurementsStarted.WithLabelValues(e.testName).Inc()
+	out, err := e.measureAsync(ctx, input)
+	if err != nil {
+		probemetrics.MeasurementsFailed.WithLabelValues(e.testName).Inc()
+		e.updateByteMetrics()
+	}
+	return out, err
+}
+
+// updateByteMetrics exports the bytes sent and received since the last call.
+func (e *experiment) updateByteMetrics() {
+	defer e.mu.Unlock()
+	e.mu.Lock()
+	received, sent := e.byteCounter.BytesReceived(), e.byteCounter.BytesSent()
+	probemetrics.BytesReceived.WithLabelValues(e.testName).Add(float64(received - e.bytesReported[0]))
+	probemetrics.BytesSent.WithLabelValues(e.testName).Add(float64(sent - e.bytesReported[1]))
+	e.bytesReported = [2]int64{received, sent}
+}
+
+// measureAsync implements MeasureAsync.
+func (e *experiment) measureAsync(
 	ctx context.Context, input string) (<-chan *model.Measurement, error) {
 	err := e.session.MaybeLookupLocationContext(ctx) // this already tracks session bytes
 	if err != nil {
@@ -138,6 +164,7 @@ func (e *experiment) MeasureAsync(
 	out := make(chan *model.Measurement)
 	go func() {
 		defer close(out) // we need to signal the consumer we're done
+		defer e.updateByteMetrics()
 		for tk := range in {
 			measurement := e.newMeasurement(input)
 			measurement.Extensions = tk.Extensions
@@ -150,8 +177,12 @@ func (e *experiment) MeasureAsync(
 				// submit it. Most likely causes of error here are unlikely,
 				// e.g., the TestKeys being not serializable.
 				e.session.Logger().Warnf("can't scrub measurement: %s", err.Error())
+				probemetrics.MeasurementsFailed.WithLabelValues(e.testName).Inc()
 				continue
 			}
+			probemetrics.MeasurementsFinished.WithLabelValues(e.testName).Inc()
+			probemetrics.MeasurementDurationSeconds.WithLabelValues(e.testName).Observe(
+				measurement.MeasurementRuntime)
 			out <- measurement
 		}
 	}()
@@ -187,13 +218,19 @@ func (e *experiment) SaveMeasurement(measurement *model.Measurement, filePath st
 	)
 }
 
+// errReportNotOpen indicates that we did not open a report.
+var errReportNotOpen = errors.New("report is not open")
+
 // SubmitAndUpdateMeasurementContext implements Experiment.SubmitAndUpdateMeasurementContext.
 func (e *experiment) SubmitAndUpdateMeasurementContext(
 	ctx context.Context, measurement *model.Measurement) error {
 	if e.report == nil {
-		return errors.New("report is not open")
+		probemetrics.SubmissionsCount.WithLabelValues(e.testName, probemetrics.Result(errReportNotOpen)).Inc()
+		return errReportNotOpen
 	}
-	return e.report.SubmitMeasurement(ctx, measurement)
+	err := e.report.SubmitMeasurement(ctx, measurement)
+	probemetrics.SubmissionsCount.WithLabelValues(e.testName, probemetrics.Result(err)).Inc()
+	return err
 }
 
 // newMeasurement creates a new measurement for this experiment with the given input.
@@ -235,6 +272,35 @@ func (e *experiment) OpenReportContext(ctx context.Context) error {
 	if e.report != nil {
 		return nil // already open
 	}
+	client, err := e.newProbeServicesClient(ctx)
+	if err != nil {
+		return err
+	}
+	template := e.newReportTemplate()
+	e.report, err = client.OpenReport(ctx, template)
+	if err != nil {
+		e.session.logger.Debugf("experiment: probe services error: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ReopenReportContext implements Experiment.ReopenReportContext.
+func (e *experiment) ReopenReportContext(ctx context.Context, reportID string) error {
+	if e.report != nil {
+		return nil // already open
+	}
+	client, err := e.newProbeServicesClient(ctx)
+	if err != nil {
+		return err
+	}
+	template := e.newReportTemplate()
+	e.report, err = client.ReopenReport(template, reportID)
+	return err
+}
+
+// newProbeServicesClient creates a probe services client for submitting measurements.
+func (e *experiment) newProbeServicesClient(ctx context.Context) (*probeservices.Client, error) {
 	// use custom client to have proper byte accounting
 	httpClient := &http.Client{
 		Transport: bytecounter.WrapHTTPTransport(
@@ -245,16 +311,10 @@ func (e *experiment) OpenReportContext(ctx context.Context) error {
 	client, err := e.session.NewProbeServicesClient(ctx)
 	if err != nil {
 		e.session.logger.Debugf("%+v", err)
-		return err
+		return nil, err
 	}
 	client.HTTPClient = httpClient // patch HTTP client to use
-	template := e.newReportTemplate()
-	e.report, err = client.OpenReport(ctx, template)
-	if err != nil {
-		e.session.logger.Debugf("experiment: probe services error: %s", err.Error())
-		return err
-	}
-	return nil
+	return client, nil
 }
 
 func (e *experiment) newReportTemplate() model.OOAPIReportTemplate {
@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probeservices"
 )
 
 func TestCreateAll(t *testing.T) {
@@ -415,6 +416,26 @@ func TestOpenReportNewClientFailure(t *testing.T) {
 	}
 }
 
+func TestReopenReportMismatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	sess := newSessionForTestingNoBackendsLookup(t)
+	defer sess.Close()
+	builder, err := sess.NewExperimentBuilder("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := builder.NewExperiment()
+	err = exp.ReopenReportContext(context.Background(), "20230101T000000Z_dnscheck_IT_30722_n1_xx")
+	if !errors.Is(err, probeservices.ErrReportIDMismatch) {
+		t.Fatal("not the error we expected", err)
+	}
+	if exp.ReportID() != "" {
+		t.Fatal("expected no open report")
+	}
+}
+
 func TestSubmitAndUpdateMeasurementWithClosedReport(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skip test in short mode")
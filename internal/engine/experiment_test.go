@@ -1,10 +1,14 @@
 package engine
 
 import (
+	"context"
 	"testing"
 
+	"github.com/ooni/probe-cli/v3/internal/bytecounter"
 	"github.com/ooni/probe-cli/v3/internal/geolocate"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestExperimentHonoursSharingDefaults(t *testing.T) {
@@ -73,3 +77,35 @@ func TestExperimentHonoursSharingDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestExperimentExportsMetrics(t *testing.T) {
+	sess := &Session{
+		byteCounter: bytecounter.New(),
+		location:    &geolocate.Results{ASN: 137, CountryCode: "IT", ProbeIP: "130.192.91.211"},
+		logger:      model.DiscardLogger,
+	}
+	builder, err := sess.NewExperimentBuilder("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.SetOptionAny("SleepTime", 0); err != nil {
+		t.Fatal(err)
+	}
+	exp := builder.NewExperiment().(*experiment)
+	started := testutil.ToFloat64(probemetrics.MeasurementsStarted.WithLabelValues("example"))
+	finished := testutil.ToFloat64(probemetrics.MeasurementsFinished.WithLabelValues("example"))
+	received := testutil.ToFloat64(probemetrics.BytesReceived.WithLabelValues("example"))
+	exp.byteCounter.CountBytesReceived(1024)
+	if _, err := exp.MeasureWithContext(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if testutil.ToFloat64(probemetrics.MeasurementsStarted.WithLabelValues("example")) != started+1 {
+		t.Fatal("did not count the started measurement")
+	}
+	if testutil.ToFloat64(probemetrics.MeasurementsFinished.WithLabelValues("example")) != finished+1 {
+		t.Fatal("did not count the finished measurement")
+	}
+	if testutil.ToFloat64(probemetrics.BytesReceived.WithLabelValues("example")) != received+1024 {
+		t.Fatal("did not count the received bytes")
+	}
+}
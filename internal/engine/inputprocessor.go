@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -45,6 +46,14 @@ type InputProcessor struct {
 	// Annotations contains the measurement annotations
 	Annotations map[string]string
 
+	// Checkpointer is the optional code that keeps track
+	// of the inputs we have already measured, such that
+	// we can resume an interrupted run. When set, we skip
+	// the inputs that it reports as completed and we remove
+	// the checkpoint after we've measured all inputs or we
+	// have reached the MaxRuntime.
+	Checkpointer InputProcessorCheckpointer
+
 	// Experiment is the code that will run the experiment.
 	Experiment InputProcessorExperimentWrapper
 
@@ -60,6 +69,12 @@ type InputProcessor struct {
 	// Options contains command line options for this experiment.
 	Options []string
 
+	// Parallelism is the optional number of inputs to measure
+	// concurrently. Zero or one means that we measure inputs one
+	// after the other. Regardless of this setting, we submit and
+	// save measurements following the order of the inputs.
+	Parallelism int
+
 	// Saver is the code that will save measurement results
 	// on persistent storage (e.g. the file system).
 	Saver InputProcessorSaverWrapper
@@ -89,6 +104,16 @@ func (ipsw inputProcessorSaverWrapper) SaveMeasurement(
 	return ipsw.saver.SaveMeasurement(m)
 }
 
+// InputProcessorCheckpointer is InputProcessor's view
+// of a Checkpointer implementation.
+type InputProcessorCheckpointer interface {
+	Completed(idx int) bool
+	MarkCompleted(idx int, reportID string) error
+	Remove() error
+}
+
+var _ InputProcessorCheckpointer = &Checkpointer{}
+
 // InputProcessorSubmitterWrapper is InputProcessor's
 // wrapper for a Submitter implementation.
 type InputProcessorSubmitterWrapper interface {
@@ -135,37 +160,120 @@ const (
 
 // run is like Run but, in addition to returning an error, it
 // also returns the reason why we stopped.
+//
+// We measure up to Parallelism inputs concurrently using background
+// goroutines and we submit and save the measurements in the order of
+// the inputs using the current goroutine. We start measuring an input
+// only after we've processed the measurements of the input that came
+// Parallelism positions earlier, which bounds the memory usage.
 func (ip *InputProcessor) run(ctx context.Context) (int, error) {
-	start := time.Now()
-	for idx, url := range ip.Inputs {
-		if ip.MaxRuntime > 0 && time.Since(start) > ip.MaxRuntime {
-			return stopMaxRuntime, nil
+	parallelism := ip.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	wg := &sync.WaitGroup{}
+	defer wg.Wait() // runs after cancel
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	slots := make(chan bool, parallelism)
+	pending := make(chan chan *inputProcessorResult, parallelism)
+	reason := stopNormal
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+		start := time.Now()
+		for idx, url := range ip.Inputs {
+			if ip.Checkpointer != nil && ip.Checkpointer.Completed(idx) {
+				continue
+			}
+			select {
+			case slots <- true:
+			case <-ctx.Done():
+				return
+			}
+			if ip.MaxRuntime > 0 && time.Since(start) > ip.MaxRuntime {
+				reason = stopMaxRuntime // synchronized by close(pending)
+				return
+			}
+			out := make(chan *inputProcessorResult, 1)
+			pending <- out // cannot block because we hold a slot
+			wg.Add(1)
+			go func(idx int, input string) {
+				defer wg.Done()
+				out <- ip.measure(ctx, idx, input)
+			}(idx, url.URL)
+		}
+	}()
+	for out := range pending {
+		result := <-out
+		if result.err != nil {
+			return 0, result.err
 		}
-		input := url.URL
-		var measurements []*model.Measurement
-		source, err := ip.Experiment.MeasureAsync(ctx, input, idx)
-		if err != nil {
+		if err := ip.process(ctx, result); err != nil {
 			return 0, err
 		}
-		// NOTE: we don't want to intermix measuring with submitting
-		// therefore we collect all measurements first
-		for meas := range source {
-			measurements = append(measurements, meas)
+		<-slots
+	}
+	if ctx.Err() != nil {
+		// The parent context has been canceled (e.g., the user pressed
+		// Ctrl-C). Like we used to do before measuring in parallel, this
+		// is a normal termination, but we keep the checkpoint such that
+		// the user can resume the run later.
+		return stopNormal, nil
+	}
+	return reason, ip.removeCheckpoint()
+}
+
+// inputProcessorResult is the result of measuring an input.
+type inputProcessorResult struct {
+	err          error
+	idx          int
+	measurements []*model.Measurement
+}
+
+// measure measures the input with the given index.
+func (ip *InputProcessor) measure(ctx context.Context, idx int, input string) *inputProcessorResult {
+	source, err := ip.Experiment.MeasureAsync(ctx, input, idx)
+	if err != nil {
+		return &inputProcessorResult{err: err, idx: idx}
+	}
+	// NOTE: we don't want to intermix measuring with submitting
+	// therefore we collect all measurements first
+	var measurements []*model.Measurement
+	for meas := range source {
+		measurements = append(measurements, meas)
+	}
+	return &inputProcessorResult{idx: idx, measurements: measurements}
+}
+
+// process submits and saves the measurements of an input.
+func (ip *InputProcessor) process(ctx context.Context, result *inputProcessorResult) error {
+	var reportID string
+	for _, meas := range result.measurements {
+		meas.AddAnnotations(ip.Annotations)
+		meas.Options = ip.Options
+		if err := ip.Submitter.Submit(ctx, result.idx, meas); err != nil {
+			return err
 		}
-		for _, meas := range measurements {
-			meas.AddAnnotations(ip.Annotations)
-			meas.Options = ip.Options
-			err = ip.Submitter.Submit(ctx, idx, meas)
-			if err != nil {
-				return 0, err
-			}
-			// Note: must be after submission because submission modifies
-			// the measurement to include the report ID.
-			err = ip.Saver.SaveMeasurement(idx, meas)
-			if err != nil {
-				return 0, err
-			}
+		// Note: must be after submission because submission modifies
+		// the measurement to include the report ID.
+		if err := ip.Saver.SaveMeasurement(result.idx, meas); err != nil {
+			return err
 		}
+		reportID = meas.ReportID
+	}
+	if ip.Checkpointer != nil {
+		return ip.Checkpointer.MarkCompleted(result.idx, reportID)
+	}
+	return nil
+}
+
+// removeCheckpoint removes the checkpoint, if any, once we have finished
+// running because we measured all inputs or we reached the max runtime.
+func (ip *InputProcessor) removeCheckpoint() error {
+	if ip.Checkpointer == nil {
+		return nil
 	}
-	return stopNormal, nil
+	return ip.Checkpointer.Remove()
 }
@@ -3,9 +3,11 @@ package engine
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
@@ -206,3 +208,135 @@ func TestInputProcessorMaxRuntime(t *testing.T) {
 		t.Fatal("not terminated by max runtime")
 	}
 }
+
+// parallelInputProcessorExperiment is an experiment that tracks
+// how many inputs we're measuring concurrently.
+type parallelInputProcessorExperiment struct {
+	cur int
+	max int
+	mu  sync.Mutex
+}
+
+func (pipe *parallelInputProcessorExperiment) MeasureAsync(
+	ctx context.Context, input string) (<-chan *model.Measurement, error) {
+	pipe.mu.Lock()
+	pipe.cur++
+	if pipe.cur > pipe.max {
+		pipe.max = pipe.cur
+	}
+	pipe.mu.Unlock()
+	// make later inputs complete earlier to check we preserve ordering
+	time.Sleep(time.Duration(10-len(input)) * 5 * time.Millisecond)
+	pipe.mu.Lock()
+	pipe.cur--
+	pipe.mu.Unlock()
+	out := make(chan *model.Measurement, 1)
+	out <- &model.Measurement{Input: model.MeasurementTarget(input)}
+	close(out)
+	return out, nil
+}
+
+func TestInputProcessorParallelism(t *testing.T) {
+	t.Run("we measure in parallel and save in order", func(t *testing.T) {
+		pipe := &parallelInputProcessorExperiment{}
+		saver := &FakeInputProcessorSaver{}
+		submitter := &FakeInputProcessorSubmitter{}
+		var inputs []model.OOAPIURLInfo
+		for _, input := range []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff"} {
+			inputs = append(inputs, model.OOAPIURLInfo{URL: input})
+		}
+		ip := &InputProcessor{
+			Experiment:  NewInputProcessorExperimentWrapper(pipe),
+			Inputs:      inputs,
+			Parallelism: 3,
+			Saver:       NewInputProcessorSaverWrapper(saver),
+			Submitter:   NewInputProcessorSubmitterWrapper(submitter),
+		}
+		reason, err := ip.run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason != stopNormal {
+			t.Fatal("unexpected reason", reason)
+		}
+		if pipe.max < 2 || pipe.max > 3 {
+			t.Fatal("unexpected parallelism", pipe.max)
+		}
+		if len(saver.M) != len(inputs) || len(submitter.M) != len(inputs) {
+			t.Fatal("not all measurements saved")
+		}
+		for idx, input := range inputs {
+			if string(saver.M[idx].Input) != input.URL || string(submitter.M[idx].Input) != input.URL {
+				t.Fatal("measurements not in order", idx)
+			}
+		}
+	})
+
+	t.Run("we stop at the first error", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		ip := &InputProcessor{
+			Experiment: NewInputProcessorExperimentWrapper(
+				&FakeInputProcessorExperiment{Err: expected},
+			),
+			Inputs:      []model.OOAPIURLInfo{{URL: "a"}, {URL: "b"}, {URL: "c"}},
+			Parallelism: 2,
+		}
+		if _, err := ip.run(context.Background()); !errors.Is(err, expected) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("we honour the max runtime", func(t *testing.T) {
+		ip := &InputProcessor{
+			Experiment:  NewInputProcessorExperimentWrapper(&parallelInputProcessorExperiment{}),
+			Inputs:      []model.OOAPIURLInfo{{URL: "a"}, {URL: "b"}},
+			MaxRuntime:  1 * time.Nanosecond,
+			Parallelism: 4,
+		}
+		reason, err := ip.run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason != stopMaxRuntime {
+			t.Fatal("not terminated by max runtime")
+		}
+	})
+
+	t.Run("we stop normally when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		kvs := &kvstore.Memory{}
+		inputs := []model.OOAPIURLInfo{{URL: "a"}, {URL: "b"}}
+		config := &CheckpointerConfig{
+			ExperimentName: "example",
+			Inputs:         inputs,
+			KVStore:        kvs,
+			Logger:         model.DiscardLogger,
+		}
+		checkpointer, err := NewCheckpointer(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkpointer.MarkCompleted(0, ""); err != nil {
+			t.Fatal(err)
+		}
+		ip := &InputProcessor{
+			Checkpointer: checkpointer,
+			Experiment:   NewInputProcessorExperimentWrapper(&parallelInputProcessorExperiment{}),
+			Inputs:       inputs,
+			Parallelism:  4,
+			Saver:        NewInputProcessorSaverWrapper(&FakeInputProcessorSaver{}),
+			Submitter:    NewInputProcessorSubmitterWrapper(&FakeInputProcessorSubmitter{}),
+		}
+		reason, err := ip.run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason != stopNormal {
+			t.Fatal("unexpected reason", reason)
+		}
+		if _, err := LoadCheckpoint(config); err != nil {
+			t.Fatal("expected to keep the checkpoint", err)
+		}
+	})
+}
@@ -5,22 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/ooni/probe-cli/v3/internal/backendconfig"
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
 	"github.com/ooni/probe-cli/v3/internal/geolocate"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/netxlite"
 	"github.com/ooni/probe-cli/v3/internal/platform"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
 	"github.com/ooni/probe-cli/v3/internal/probeservices"
 	"github.com/ooni/probe-cli/v3/internal/registry"
 	"github.com/ooni/probe-cli/v3/internal/runtimex"
 	"github.com/ooni/probe-cli/v3/internal/sessionresolver"
+	"github.com/ooni/probe-cli/v3/internal/submitqueue"
 	"github.com/ooni/probe-cli/v3/internal/tunnel"
 	"github.com/ooni/probe-cli/v3/internal/version"
 )
@@ -37,10 +42,29 @@ type SessionConfig struct {
 	TorArgs                []string
 	TorBinary              string
 
+	// BackendConfig is the OPTIONAL configuration for using a
+	// self-hosted backend. When set, its probe services override
+	// AvailableProbeServices and its other fields override the
+	// corresponding endpoints returned by the bouncer.
+	BackendConfig *model.BackendConfig
+
 	// SnowflakeRendezvous is the rendezvous method
 	// to be used by the torsf tunnel
 	SnowflakeRendezvous string
 
+	// SubmitQueueDir is the OPTIONAL directory containing the queue of
+	// measurements that we could not submit and that we should retry
+	// submitting later. If empty, we don't queue measurements.
+	SubmitQueueDir string
+
+	// TorBridgeLines contains the bridge lines
+	// to be used by the tor+obfs4 tunnel
+	TorBridgeLines []string
+
+	// TunnelAutoRestart indicates whether we should restart
+	// the tunnel when we notice that it has died.
+	TunnelAutoRestart bool
+
 	// TunnelDir is the directory where we should store
 	// the state of persistent tunnels. This field is
 	// optional _unless_ you want to use tunnels. In such
@@ -56,6 +80,7 @@ type SessionConfig struct {
 type Session struct {
 	availableProbeServices   []model.OOAPIService
 	availableTestHelpers     map[string][]model.OOAPIService
+	backendConfig            *model.BackendConfig
 	byteCounter              *bytecounter.Counter
 	httpDefaultTransport     model.HTTPTransport
 	kvStore                  model.KeyValueStore
@@ -68,6 +93,7 @@ type Session struct {
 	selectedProbeService     *model.OOAPIService
 	softwareName             string
 	softwareVersion          string
+	submitQueue              *submitqueue.Queue
 	tempDir                  string
 
 	// closeOnce allows us to call Close just once.
@@ -106,7 +132,12 @@ type Session struct {
 
 	// tunnel is the optional tunnel that we may be using. It is created
 	// by NewSession and it is cleaned up by Close.
-	tunnel tunnel.Tunnel
+	tunnel *tunnel.Monitor
+
+	// tunnelNeedsCheck indicates that NewProbeServicesClient should check
+	// whether the tunnel works. We set it when we start the tunnel and
+	// whenever a request using the session's HTTP transport fails.
+	tunnelNeedsCheck atomic.Bool
 }
 
 // sessionProbeServicesClientForCheckIn returns the probe services
@@ -153,6 +184,22 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	if config.KVStore == nil {
 		config.KVStore = &kvstore.Memory{}
 	}
+	if config.BackendConfig != nil {
+		if err := backendconfig.Validate(config.BackendConfig); err != nil {
+			return nil, err
+		}
+		if len(config.BackendConfig.ProbeServices) > 0 {
+			config.AvailableProbeServices = config.BackendConfig.ProbeServices
+		}
+	}
+	var submitQueue *submitqueue.Queue
+	if config.SubmitQueueDir != "" {
+		var err error
+		submitQueue, err = submitqueue.New(config.SubmitQueueDir, config.Logger)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Implementation note: if config.TempDir is empty, then Go will
 	// use the temporary directory on the current system. This should
 	// work on Desktop. We tested that it did also work on iOS, but
@@ -170,12 +217,14 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	)
 	sess := &Session{
 		availableProbeServices:  config.AvailableProbeServices,
+		backendConfig:           config.BackendConfig,
 		byteCounter:             bytecounter.New(),
 		kvStore:                 config.KVStore,
 		logger:                  config.Logger,
 		queryProbeServicesCount: &atomic.Int64{},
 		softwareName:            config.SoftwareName,
 		softwareVersion:         config.SoftwareVersion,
+		submitQueue:             submitQueue,
 		tempDir:                 tempDir,
 		torArgs:                 config.TorArgs,
 		torBinary:               config.TorBinary,
@@ -184,10 +233,15 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 	proxyURL := config.ProxyURL
 	if proxyURL != nil {
 		switch proxyURL.Scheme {
-		case "psiphon", "tor", "torsf", "fake":
+		case "psiphon", "tor", "torsf", "tor+obfs4", "http-connect", "socks5", "fake":
 			config.Logger.Infof(
 				"starting '%s' tunnel; please be patient...", proxyURL.Scheme)
-			tunnel, _, err := tunnel.Start(ctx, &tunnel.Config{
+			tunnel, _, err := tunnel.StartMonitor(ctx, &tunnel.Config{
+				BootstrapProgress: func(ev *tunnel.BootstrapEvent) {
+					config.Logger.Infof("tunnel '%s': bootstrapped %d%%: %s", ev.Name, ev.Progress, ev.Summary)
+				},
+				BridgeLines:         config.TorBridgeLines,
+				HealthCheckEndpoint: sessionHealthCheckEndpoint(config.BackendConfig),
 				Logger:              config.Logger,
 				Name:                proxyURL.Scheme,
 				SnowflakeRendezvous: config.SnowflakeRendezvous,
@@ -195,12 +249,14 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 				TorArgs:             config.TorArgs,
 				TorBinary:           config.TorBinary,
 				TunnelDir:           config.TunnelDir,
-			})
+				UpstreamProxyURL:    sessionUpstreamProxyURL(proxyURL),
+			}, config.TunnelAutoRestart)
 			if err != nil {
 				return nil, err
 			}
 			config.Logger.Infof("tunnel '%s' running...", proxyURL.Scheme)
 			sess.tunnel = tunnel
+			sess.tunnelNeedsCheck.Store(true)
 			proxyURL = tunnel.SOCKS5ProxyURL()
 		case "none":
 			proxyURL = nil // explicit way of saying we don't want to use a tunnel
@@ -213,14 +269,87 @@ func NewSession(ctx context.Context, config SessionConfig) (*Session, error) {
 		Logger:      sess.logger,
 		ProxyURL:    proxyURL,
 	}
+	if config.BackendConfig != nil {
+		sess.resolver.URLs = config.BackendConfig.DNSResolvers
+	}
 	txp := netxlite.NewHTTPTransportWithLoggerResolverAndOptionalProxyURL(
 		sess.logger, sess.resolver, sess.proxyURL,
 	)
 	txp = bytecounter.WrapHTTPTransport(txp, sess.byteCounter)
+	if sess.tunnel != nil {
+		txp = &sessionTunnelTransport{HTTPTransport: txp, sess: sess}
+	}
 	sess.httpDefaultTransport = txp
 	return sess, nil
 }
 
+// sessionUpstreamProxyURL returns the URL of the upstream proxy for the
+// tunnels using one. We map an "http-connect://host:port" proxy URL to the
+// "http://host:port" URL of the upstream HTTP proxy and we use a
+// "socks5://host:port" proxy URL as is. For any other proxy URL, there
+// is no upstream proxy and we return nil.
+func sessionUpstreamProxyURL(proxyURL *url.URL) *url.URL {
+	switch proxyURL.Scheme {
+	case "http-connect":
+		out := *proxyURL
+		out.Scheme = "http"
+		return &out
+	case "socks5":
+		return proxyURL
+	default:
+		return nil
+	}
+}
+
+// sessionHealthCheckEndpoint returns the TCP endpoint of the backend described
+// by config, to which the tunnel monitor connects to check whether the tunnel
+// works. We return an empty string, meaning the default OONI backend, when
+// config is nil or we cannot find any suitable endpoint.
+func sessionHealthCheckEndpoint(config *model.BackendConfig) string {
+	if config == nil {
+		return ""
+	}
+	var URLs []string
+	for _, svc := range config.ProbeServices {
+		if svc.Type == "https" {
+			URLs = append(URLs, svc.Address)
+		}
+	}
+	URLs = append(URLs, config.CheckInURL, config.CollectorURL)
+	for _, entry := range URLs {
+		URL, err := url.Parse(entry)
+		if err != nil || URL.Hostname() == "" {
+			continue
+		}
+		port := URL.Port()
+		if port == "" {
+			port = "443"
+			if URL.Scheme == "http" {
+				port = "80"
+			}
+		}
+		return net.JoinHostPort(URL.Hostname(), port)
+	}
+	return ""
+}
+
+// sessionTunnelTransport is the session's HTTP transport when we're using
+// a tunnel. It marks the tunnel as needing a health check when a request
+// fails, such that the next NewProbeServicesClient checks the tunnel.
+type sessionTunnelTransport struct {
+	model.HTTPTransport
+	sess *Session
+}
+
+// RoundTrip implements model.HTTPTransport.
+func (txp *sessionTunnelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := txp.HTTPTransport.RoundTrip(req)
+	if err != nil && req.Context().Err() == nil {
+		txp.sess.tunnelNeedsCheck.Store(true)
+	}
+	return resp, err
+}
+
 // TunnelDir returns the persistent directory used by tunnels.
 func (s *Session) TunnelDir() string {
 	return s.tunnelDir
@@ -296,7 +425,10 @@ func (s *Session) CheckIn(
 	if config.WebConnectivity.CategoryCodes == nil {
 		config.WebConnectivity.CategoryCodes = []string{}
 	}
+	t0 := time.Now()
 	resp, err := client.CheckIn(ctx, *config)
+	probemetrics.CheckInDurationSeconds.WithLabelValues(probemetrics.Result(err)).Observe(
+		time.Since(t0).Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -429,6 +561,9 @@ func (s *Session) NewProbeServicesClient(ctx context.Context) (*probeservices.Cl
 	if ctx.Err() != nil {
 		return nil, ctx.Err() // helps with testing
 	}
+	if err := s.maybeCheckTunnelHealth(ctx); err != nil {
+		return nil, err
+	}
 	if err := s.maybeLookupBackendsContext(ctx); err != nil {
 		return nil, err
 	}
@@ -441,6 +576,76 @@ func (s *Session) NewProbeServicesClient(ctx context.Context) (*probeservices.Cl
 	return probeservices.NewClient(s, *s.selectedProbeService)
 }
 
+// CheckTunnelHealth checks whether the tunnel we're using to communicate with
+// the OONI backend is still working. If the session was configured to restart the
+// tunnel, this function also restarts a dead tunnel. When we're not using any
+// tunnel, this function always returns nil.
+func (s *Session) CheckTunnelHealth(ctx context.Context) error {
+	if s.tunnel == nil {
+		return nil
+	}
+	return s.tunnel.CheckHealth(ctx)
+}
+
+// maybeCheckTunnelHealth calls CheckTunnelHealth only when we have just
+// started the tunnel or a request using the tunnel has failed.
+func (s *Session) maybeCheckTunnelHealth(ctx context.Context) error {
+	if !s.tunnelNeedsCheck.Load() {
+		return nil
+	}
+	if err := s.CheckTunnelHealth(ctx); err != nil {
+		return err
+	}
+	s.tunnelNeedsCheck.Store(false)
+	return nil
+}
+
+// BackendConfig returns the configuration for using a self-hosted
+// backend or nil if we're using the default OONI backend.
+func (s *Session) BackendConfig() *model.BackendConfig {
+	return s.backendConfig
+}
+
+// CheckBackend checks whether the endpoints of the self-hosted backend are
+// reachable and logs the results. When we're using the default OONI backend,
+// this function does nothing and returns nil.
+func (s *Session) CheckBackend(ctx context.Context) []*backendconfig.EndpointReport {
+	if s.backendConfig == nil {
+		return nil
+	}
+	reports := backendconfig.Check(ctx, s.backendConfig, s.DefaultHTTPClient())
+	for _, r := range reports {
+		if !r.Reachable {
+			s.logger.Warnf("backend: %s (%s): unreachable: %s", r.Kind, r.URL, r.Failure)
+			continue
+		}
+		s.logger.Infof("backend: %s (%s): reachable", r.Kind, r.URL)
+	}
+	return reports
+}
+
+// SubmitQueue returns the queue of measurements to submit later
+// or nil if this session does not queue measurements.
+func (s *Session) SubmitQueue() *submitqueue.Queue {
+	return s.submitQueue
+}
+
+// ErrNoSubmitQueue indicates that the session does not queue measurements.
+var ErrNoSubmitQueue = errors.New("engine: session without submission queue")
+
+// FlushSubmitQueue submits the queued measurements. When force is true, we
+// submit all the measurements regardless of their retry backoff.
+func (s *Session) FlushSubmitQueue(ctx context.Context, force bool) (*submitqueue.FlushResult, error) {
+	if s.submitQueue == nil {
+		return nil, ErrNoSubmitQueue
+	}
+	subm, err := s.NewSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.submitQueue.Flush(ctx, subm, force)
+}
+
 // NewSubmitter creates a new submitter instance.
 func (s *Session) NewSubmitter(ctx context.Context) (Submitter, error) {
 	psc, err := s.NewProbeServicesClient(ctx)
@@ -571,6 +776,40 @@ func (s *Session) ResolverNetworkName() string {
 	return nn
 }
 
+// SessionResolverState returns the state of the resolvers used by the
+// session resolver (i.e., the resolver we use to communicate with the
+// OONI backend) sorted by descending score.
+func (s *Session) SessionResolverState() []sessionresolver.ResolverState {
+	return s.resolver.State()
+}
+
+// AddSessionResolver adds a custom resolver to the session resolver (see
+// sessionresolver.Resolver.AddCustom for the supported URLs).
+func (s *Session) AddSessionResolver(URL string) error {
+	return s.resolver.AddCustom(URL)
+}
+
+// RemoveSessionResolver removes a custom resolver from the session resolver.
+func (s *Session) RemoveSessionResolver(URL string) error {
+	return s.resolver.RemoveCustom(URL)
+}
+
+// ResetSessionResolver resets the score and the recent failures of the
+// given resolver, or of all resolvers when the URL is empty.
+func (s *Session) ResetSessionResolver(URL string) error {
+	return s.resolver.Reset(URL)
+}
+
+// PinSessionResolver ensures the session resolver only uses the given resolver.
+func (s *Session) PinSessionResolver(URL string) error {
+	return s.resolver.Pin(URL)
+}
+
+// UnpinSessionResolver undoes the effect of PinSessionResolver.
+func (s *Session) UnpinSessionResolver() error {
+	return s.resolver.Unpin()
+}
+
 // SoftwareName returns the application name.
 func (s *Session) SoftwareName() string {
 	return s.softwareName
@@ -671,6 +910,9 @@ func (s *Session) MaybeLookupBackendsContext(ctx context.Context) error {
 	s.logger.Infof("session: using probe services: %+v", selected.Endpoint)
 	s.selectedProbeService = &selected.Endpoint
 	s.availableTestHelpers = selected.TestHelpers
+	if s.backendConfig != nil && len(s.backendConfig.TestHelpers) > 0 {
+		s.availableTestHelpers = s.backendConfig.TestHelpers
+	}
 	return nil
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"sync"
 	"testing"
@@ -11,12 +13,15 @@ import (
 
 	"github.com/apex/log"
 	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/backendconfig"
 	"github.com/ooni/probe-cli/v3/internal/experiment/webconnectivity"
 	"github.com/ooni/probe-cli/v3/internal/experiment/webconnectivitylte"
+	"github.com/ooni/probe-cli/v3/internal/fakebackend"
 	"github.com/ooni/probe-cli/v3/internal/geolocate"
 	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/registry"
+	"github.com/ooni/probe-cli/v3/internal/sessionresolver"
 )
 
 func (s *Session) GetAvailableProbeServices() []model.OOAPIService {
@@ -299,6 +304,346 @@ func TestNewSessionWithFakeTunnel(t *testing.T) {
 	sess.Close() // ensure we don't crash
 }
 
+func TestSessionCheckTunnelHealth(t *testing.T) {
+	t.Run("without a tunnel", func(t *testing.T) {
+		sess := &Session{}
+		if err := sess.CheckTunnelHealth(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("with a working tunnel and auto restart", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skip test in short mode") // the health check uses the network
+		}
+		ctx := context.Background()
+		sess, err := NewSession(ctx, SessionConfig{
+			Logger:            log.Log,
+			ProxyURL:          &url.URL{Scheme: "fake"},
+			SoftwareName:      "miniooni",
+			SoftwareVersion:   "0.1.0-dev",
+			TunnelAutoRestart: true,
+			TunnelDir:         "testdata",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		proxyURL := sess.ProxyURL().String()
+		if err := sess.CheckTunnelHealth(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if sess.tunnel.Restarts() != 0 {
+			t.Fatal("expected no restarts here")
+		}
+		if sess.ProxyURL().String() != proxyURL {
+			t.Fatal("the proxy URL should not change")
+		}
+	})
+}
+
+func TestSessionMaybeCheckTunnelHealth(t *testing.T) {
+	// the health check connects to the backend through the tunnel
+	backend := httptest.NewServer(http.NotFoundHandler())
+	defer backend.Close()
+	ctx := context.Background()
+	sess, err := NewSession(ctx, SessionConfig{
+		BackendConfig: &model.BackendConfig{
+			ProbeServices: []model.OOAPIService{{Address: backend.URL, Type: "https"}},
+		},
+		Logger:          log.Log,
+		ProxyURL:        &url.URL{Scheme: "fake"},
+		SoftwareName:    "miniooni",
+		SoftwareVersion: "0.1.0-dev",
+		TunnelDir:       "testdata",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	t.Run("we check the tunnel after starting it", func(t *testing.T) {
+		if !sess.tunnelNeedsCheck.Load() {
+			t.Fatal("expected to need a check")
+		}
+		if err := sess.maybeCheckTunnelHealth(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if sess.tunnelNeedsCheck.Load() {
+			t.Fatal("expected not to need a check")
+		}
+	})
+
+	t.Run("we check the tunnel after a request fails", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:1/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sess.DefaultHTTPClient().Do(req); err == nil {
+			t.Fatal("expected an error")
+		}
+		if !sess.tunnelNeedsCheck.Load() {
+			t.Fatal("expected to need a check")
+		}
+		if err := sess.maybeCheckTunnelHealth(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if sess.tunnelNeedsCheck.Load() {
+			t.Fatal("expected not to need a check")
+		}
+	})
+}
+
+func TestSessionHealthCheckEndpoint(t *testing.T) {
+	type testcase struct {
+		name   string
+		config *model.BackendConfig
+		expect string
+	}
+	testcases := []testcase{{
+		name:   "without a backend config",
+		expect: "",
+	}, {
+		name: "with https probe services",
+		config: &model.BackendConfig{
+			ProbeServices: []model.OOAPIService{
+				{Address: "httpo://jehhrikjjqrlpufu.onion", Type: "onion"},
+				{Address: "https://api.example.com", Type: "https"},
+			},
+			CollectorURL: "https://collector.example.com:4443",
+		},
+		expect: "api.example.com:443",
+	}, {
+		name: "with only the check-in and collector URLs",
+		config: &model.BackendConfig{
+			CheckInURL:   "http://checkin.example.com",
+			CollectorURL: "https://collector.example.com:4443",
+		},
+		expect: "checkin.example.com:80",
+	}, {
+		name: "with only the collector URL",
+		config: &model.BackendConfig{
+			CollectorURL: "https://collector.example.com:4443",
+		},
+		expect: "collector.example.com:4443",
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sessionHealthCheckEndpoint(tc.config); got != tc.expect {
+				t.Fatal("unexpected endpoint", got)
+			}
+		})
+	}
+}
+
+func TestSessionWithBackendConfig(t *testing.T) {
+	t.Run("with an invalid config", func(t *testing.T) {
+		sess, err := NewSession(context.Background(), SessionConfig{
+			BackendConfig:   &model.BackendConfig{CollectorURL: "ftp://x.org"},
+			Logger:          log.Log,
+			SoftwareName:    "miniooni",
+			SoftwareVersion: "0.1.0-dev",
+		})
+		if !errors.Is(err, backendconfig.ErrInvalidConfig) {
+			t.Fatal("unexpected err", err)
+		}
+		if sess != nil {
+			t.Fatal("expected nil session")
+		}
+	})
+
+	t.Run("with a valid config", func(t *testing.T) {
+		bouncer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/test-helpers" {
+				w.WriteHeader(404)
+				return
+			}
+			w.Write([]byte(`{"web-connectivity":[{"address":"https://0.th.ooni.org","type":"https"}]}`))
+		}))
+		defer bouncer.Close()
+		config := &model.BackendConfig{
+			ProbeServices: []model.OOAPIService{{Address: bouncer.URL, Type: "https"}},
+			CollectorURL:  "http://127.0.0.1:1", // nothing listens here
+			TestHelpers: map[string][]model.OOAPIService{
+				"web-connectivity": {{Address: "https://th.example.com", Type: "https"}},
+			},
+			DNSResolvers: []string{"system:///"},
+		}
+		ctx := context.Background()
+		sess, err := NewSession(ctx, SessionConfig{
+			AvailableProbeServices: []model.OOAPIService{{Address: "https://x.org", Type: "https"}},
+			BackendConfig:          config,
+			Logger:                 log.Log,
+			SoftwareName:           "miniooni",
+			SoftwareVersion:        "0.1.0-dev",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		if sess.BackendConfig() != config {
+			t.Fatal("unexpected backend config")
+		}
+		if diff := cmp.Diff(config.DNSResolvers, sess.resolver.URLs); diff != "" {
+			t.Fatal(diff)
+		}
+		if err := sess.MaybeLookupBackendsContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if sess.selectedProbeService.Address != bouncer.URL {
+			t.Fatal("did not use the configured probe services")
+		}
+		ths, _ := sess.GetTestHelpersByName("web-connectivity")
+		if diff := cmp.Diff(config.TestHelpers["web-connectivity"], ths); diff != "" {
+			t.Fatal(diff)
+		}
+		reports := sess.CheckBackend(ctx)
+		if len(reports) != 3 || !reports[0].Reachable || reports[1].Reachable {
+			t.Fatal("unexpected reports", reports)
+		}
+	})
+
+	t.Run("CheckBackend without a backend config", func(t *testing.T) {
+		sess := &Session{}
+		if reports := sess.CheckBackend(context.Background()); reports != nil {
+			t.Fatal("expected nil reports")
+		}
+	})
+}
+
+func TestSessionManagesSessionResolvers(t *testing.T) {
+	sess := &Session{resolver: &sessionresolver.Resolver{KVStore: &kvstore.Memory{}}}
+	const URL = "dot://dns.google"
+	if err := sess.AddSessionResolver(URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.PinSessionResolver(URL); err != nil {
+		t.Fatal(err)
+	}
+	state := sess.SessionResolverState()
+	if len(state) <= 0 || state[0].URL != URL || !state[0].Custom || !state[0].Pinned {
+		t.Fatal("unexpected state", state)
+	}
+	if err := sess.ResetSessionResolver(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.UnpinSessionResolver(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.RemoveSessionResolver(URL); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range sess.SessionResolverState() {
+		if e.URL == URL || e.Pinned {
+			t.Fatal("unexpected entry", e)
+		}
+	}
+}
+
+func TestSessionWithFakeBackend(t *testing.T) {
+	backend := fakebackend.New()
+	srvr := httptest.NewServer(backend)
+	defer srvr.Close()
+	ctx := context.Background()
+	sess, err := NewSession(ctx, SessionConfig{
+		BackendConfig:   backend.BackendConfig(srvr.URL),
+		Logger:          log.Log,
+		SoftwareName:    "miniooni",
+		SoftwareVersion: "0.1.0-dev",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	sess.location = &geolocate.Results{ASN: 137, CountryCode: "IT"} // avoid network lookups
+	if err := sess.MaybeLookupBackendsContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := sess.CheckIn(ctx, &model.OOAPICheckInConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WebConnectivity == nil || len(result.WebConnectivity.URLs) != 2 {
+		t.Fatal("unexpected check-in result", result)
+	}
+	targets, err := sess.FetchTorTargets(ctx, "IT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 {
+		t.Fatal("unexpected tor targets", targets)
+	}
+	submitter, err := sess.NewSubmitter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	measurement := &model.Measurement{
+		ProbeASN:        "AS137",
+		ProbeCC:         "IT",
+		SoftwareName:    "miniooni",
+		SoftwareVersion: "0.1.0-dev",
+		TestName:        "example",
+		TestStartTime:   "2023-01-01 00:00:00",
+		TestVersion:     "0.1.0",
+	}
+	if err := submitter.Submit(ctx, measurement); err != nil {
+		t.Fatal(err)
+	}
+	submitted := backend.Measurements()
+	if len(submitted) != 1 || submitted[0].ReportID != measurement.ReportID {
+		t.Fatal("unexpected submitted measurements", submitted)
+	}
+	if backend.Calls(fakebackend.EndpointRegister) != 1 || backend.Calls(fakebackend.EndpointLogin) != 1 {
+		t.Fatal("expected to register and login once")
+	}
+}
+
+func TestSessionFlushSubmitQueue(t *testing.T) {
+	t.Run("without a queue", func(t *testing.T) {
+		sess := &Session{}
+		result, err := sess.FlushSubmitQueue(context.Background(), false)
+		if !errors.Is(err, ErrNoSubmitQueue) {
+			t.Fatal("unexpected err", err)
+		}
+		if result != nil {
+			t.Fatal("expected nil result")
+		}
+	})
+
+	t.Run("with a queue", func(t *testing.T) {
+		backend := fakebackend.New()
+		srvr := httptest.NewServer(backend)
+		defer srvr.Close()
+		ctx := context.Background()
+		sess, err := NewSession(ctx, SessionConfig{
+			BackendConfig:   backend.BackendConfig(srvr.URL),
+			Logger:          log.Log,
+			SoftwareName:    "miniooni",
+			SoftwareVersion: "0.1.0-dev",
+			SubmitQueueDir:  t.TempDir(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		sess.location = &geolocate.Results{ASN: 137, CountryCode: "IT"} // avoid network lookups
+		measurement := &model.Measurement{
+			ProbeCC:  "IT",
+			TestName: "example",
+		}
+		if _, err := sess.SubmitQueue().Enqueue(measurement, nil); err != nil {
+			t.Fatal(err)
+		}
+		result, err := sess.FlushSubmitQueue(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Submitted != 1 || len(backend.Measurements()) != 1 {
+			t.Fatal("unexpected result", result)
+		}
+	})
+}
+
 func TestNewSessionWithFakeTunnelAndCancelledContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // fail immediately
@@ -399,3 +744,29 @@ func TestSessionNewExperimentBuilder(t *testing.T) {
 		}
 	})
 }
+
+func TestSessionUpstreamProxyURL(t *testing.T) {
+	t.Run("with an http-connect proxy URL", func(t *testing.T) {
+		URL := &url.URL{Scheme: "http-connect", Host: "127.0.0.1:8080", User: url.User("antani")}
+		out := sessionUpstreamProxyURL(URL)
+		if out.String() != "http://antani@127.0.0.1:8080" {
+			t.Fatal("unexpected URL", out)
+		}
+		if URL.Scheme != "http-connect" {
+			t.Fatal("we should not modify the original URL")
+		}
+	})
+
+	t.Run("with a socks5 proxy URL", func(t *testing.T) {
+		URL := &url.URL{Scheme: "socks5", Host: "127.0.0.1:9050"}
+		if out := sessionUpstreamProxyURL(URL); out.String() != "socks5://127.0.0.1:9050" {
+			t.Fatal("unexpected URL", out)
+		}
+	})
+
+	t.Run("with any other proxy URL", func(t *testing.T) {
+		if out := sessionUpstreamProxyURL(&url.URL{Scheme: "tor"}); out != nil {
+			t.Fatal("expected nil URL", out)
+		}
+	})
+}
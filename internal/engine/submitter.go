@@ -2,13 +2,15 @@ package engine
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
+	"github.com/ooni/probe-cli/v3/internal/submitqueue"
 )
 
-// TODO(bassosimone): maybe keep track of which measurements
-// could not be submitted by a specific submitter?
-
 // Submitter is an alias for model.Submitter
 type Submitter = model.Submitter
 
@@ -18,6 +20,15 @@ type SubmitterSession interface {
 	NewSubmitter(ctx context.Context) (Submitter, error)
 }
 
+// submitterSessionWithQueue is a SubmitterSession with a submission queue.
+type submitterSessionWithQueue interface {
+	SubmitQueue() *submitqueue.Queue
+}
+
+// ErrMeasurementQueued indicates that we could not submit a measurement
+// and we have added it to the submission queue to retry later.
+var ErrMeasurementQueued = errors.New("engine: measurement queued for later submission")
+
 // SubmitterConfig contains settings for NewSubmitter.
 type SubmitterConfig struct {
 	// Enabled is true if measurement submission is enabled.
@@ -33,15 +44,63 @@ type SubmitterConfig struct {
 // NewSubmitter creates a new submitter instance. Depending on
 // whether submission is enabled or not, the returned submitter
 // instance migh just be a stub implementation.
+//
+// If the session has a SubmitQueue method returning a non-nil queue, we
+// first try to submit some of the measurements in the queue and then we
+// return a submitter that enqueues the measurements it cannot submit. In
+// such a case, we also enqueue all measurements if we cannot reach the
+// backend. To avoid delaying the caller when the queue is large or the
+// backend is slow, we bound the flush with submitQueueFlushTimeout and
+// submitQueueFlushLimit, and we leave the rest for later.
 func NewSubmitter(ctx context.Context, config SubmitterConfig) (Submitter, error) {
 	if !config.Enabled {
 		return stubSubmitter{}, nil
 	}
+	var queue *submitqueue.Queue
+	if swq, ok := config.Session.(submitterSessionWithQueue); ok {
+		queue = swq.SubmitQueue()
+	}
 	subm, err := config.Session.NewSubmitter(ctx)
 	if err != nil {
-		return nil, err
+		if queue == nil {
+			return nil, err
+		}
+		config.Logger.Warnf("cannot submit measurements: %s", err.Error())
+		return queueingSubmitter{err: err, logger: config.Logger, queue: queue}, nil
+	}
+	if queue == nil {
+		return realSubmitter{subm: subm, logger: config.Logger}, nil
+	}
+	flushSubmitQueue(ctx, config.Logger, queue, subm)
+	return queueingSubmitter{
+		logger: config.Logger,
+		queue:  queue,
+		subm:   realSubmitter{subm: subm, logger: config.Logger},
+	}, nil
+}
+
+const (
+	// submitQueueFlushTimeout is the maximum time NewSubmitter spends
+	// submitting the measurements in the submission queue.
+	submitQueueFlushTimeout = 10 * time.Second
+
+	// submitQueueFlushLimit is the maximum number of queued measurements
+	// NewSubmitter tries to submit.
+	submitQueueFlushLimit = 10
+)
+
+// flushSubmitQueue submits some of the queued measurements using the given submitter.
+func flushSubmitQueue(ctx context.Context, logger model.Logger, queue *submitqueue.Queue, subm Submitter) {
+	ctx, cancel := context.WithTimeout(ctx, submitQueueFlushTimeout)
+	defer cancel()
+	result, err := queue.FlushAtMost(ctx, subm, false, submitQueueFlushLimit)
+	if err != nil {
+		logger.Warnf("cannot flush the submission queue: %s", err.Error())
+	}
+	if result != nil && (result.Submitted > 0 || result.Failed > 0 || result.Deferred > 0) {
+		logger.Infof("submission queue: submitted %d, failed %d, deferred %d",
+			result.Submitted, result.Failed, result.Deferred)
 	}
-	return realSubmitter{subm: subm, logger: config.Logger}, nil
 }
 
 type stubSubmitter struct{}
@@ -59,5 +118,36 @@ type realSubmitter struct {
 
 func (rs realSubmitter) Submit(ctx context.Context, m *model.Measurement) error {
 	rs.logger.Info("submitting measurement to OONI collector; please be patient...")
-	return rs.subm.Submit(ctx, m)
+	err := rs.subm.Submit(ctx, m)
+	probemetrics.SubmissionsCount.WithLabelValues(m.TestName, probemetrics.Result(err)).Inc()
+	return err
+}
+
+// queueingSubmitter enqueues the measurements it cannot submit.
+type queueingSubmitter struct {
+	// err is the error that prevented us from creating subm.
+	err error
+
+	// logger is the logger to use.
+	logger model.Logger
+
+	// queue is the submission queue.
+	queue *submitqueue.Queue
+
+	// subm is the submitter to use, which is nil if we could not create it.
+	subm Submitter
+}
+
+func (qs queueingSubmitter) Submit(ctx context.Context, m *model.Measurement) error {
+	err := qs.err
+	if qs.subm != nil {
+		if err = qs.subm.Submit(ctx, m); err == nil {
+			return nil
+		}
+	}
+	if _, qerr := qs.queue.Enqueue(m, err); qerr != nil {
+		qs.logger.Warnf("cannot add measurement to the submission queue: %s", qerr.Error())
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrMeasurementQueued, err.Error())
 }
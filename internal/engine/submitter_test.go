@@ -8,6 +8,7 @@ import (
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/submitqueue"
 )
 
 func TestSubmitterNotEnabled(t *testing.T) {
@@ -91,3 +92,118 @@ func TestNewSubmitterWithFailedSubmission(t *testing.T) {
 		t.Fatal("unexpected number of calls")
 	}
 }
+
+type FakeSubmitterSessionWithQueue struct {
+	FakeSubmitterSession
+	Queue *submitqueue.Queue
+}
+
+func (fse FakeSubmitterSessionWithQueue) SubmitQueue() *submitqueue.Queue {
+	return fse.Queue
+}
+
+func TestNewSubmitterWithQueue(t *testing.T) {
+	newQueue := func(t *testing.T) *submitqueue.Queue {
+		queue, err := submitqueue.New(t.TempDir(), log.Log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return queue
+	}
+
+	t.Run("when we cannot create a submitter", func(t *testing.T) {
+		queue := newQueue(t)
+		ctx := context.Background()
+		submitter, err := NewSubmitter(ctx, SubmitterConfig{
+			Enabled: true,
+			Logger:  log.Log,
+			Session: FakeSubmitterSessionWithQueue{
+				FakeSubmitterSession: FakeSubmitterSession{Error: errors.New("mocked error")},
+				Queue:                queue,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = submitter.Submit(ctx, &model.Measurement{TestName: "example"})
+		if !errors.Is(err, ErrMeasurementQueued) {
+			t.Fatal("unexpected err", err)
+		}
+		entries, err := queue.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].LastFailure != "mocked error" {
+			t.Fatal("unexpected entries", entries)
+		}
+	})
+
+	t.Run("we flush the queue and enqueue failed submissions", func(t *testing.T) {
+		queue := newQueue(t)
+		if _, err := queue.Enqueue(&model.Measurement{TestName: "old"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		fakeSubmitter := &FakeSubmitter{Calls: &atomic.Int64{}}
+		ctx := context.Background()
+		submitter, err := NewSubmitter(ctx, SubmitterConfig{
+			Enabled: true,
+			Logger:  log.Log,
+			Session: FakeSubmitterSessionWithQueue{
+				FakeSubmitterSession: FakeSubmitterSession{Submitter: fakeSubmitter},
+				Queue:                queue,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fakeSubmitter.Calls.Load() != 1 {
+			t.Fatal("expected to submit the queued measurement")
+		}
+		if err := submitter.Submit(ctx, &model.Measurement{TestName: "new"}); err != nil {
+			t.Fatal(err)
+		}
+		fakeSubmitter.Error = errors.New("mocked error")
+		err = submitter.Submit(ctx, &model.Measurement{TestName: "failed"})
+		if !errors.Is(err, ErrMeasurementQueued) {
+			t.Fatal("unexpected err", err)
+		}
+		entries, err := queue.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].TestName != "failed" {
+			t.Fatal("unexpected entries", entries)
+		}
+	})
+
+	t.Run("we only flush part of a large queue", func(t *testing.T) {
+		queue := newQueue(t)
+		for idx := 0; idx < submitQueueFlushLimit+2; idx++ {
+			if _, err := queue.Enqueue(&model.Measurement{TestName: "old"}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fakeSubmitter := &FakeSubmitter{Calls: &atomic.Int64{}}
+		_, err := NewSubmitter(context.Background(), SubmitterConfig{
+			Enabled: true,
+			Logger:  log.Log,
+			Session: FakeSubmitterSessionWithQueue{
+				FakeSubmitterSession: FakeSubmitterSession{Submitter: fakeSubmitter},
+				Queue:                queue,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fakeSubmitter.Calls.Load() != submitQueueFlushLimit {
+			t.Fatal("unexpected number of calls", fakeSubmitter.Calls.Load())
+		}
+		entries, err := queue.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Fatal("unexpected number of entries", len(entries))
+		}
+	})
+}
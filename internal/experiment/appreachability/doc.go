@@ -0,0 +1,39 @@
+// Package appreachability implements the appreachability experiment, which
+// measures the reachability of an app using a declarative spec, such that we
+// can check new apps (or apps that changed their infrastructure) without
+// writing code or shipping a new release.
+//
+// The spec is a JSON or YAML document like the following:
+//
+//	name: jitsi
+//	targets:
+//	  - type: dns
+//	    target: meet.jit.si
+//	  - type: tcp
+//	    target: meet.jit.si:443
+//	  - type: tls
+//	    target: meet.jit.si:443
+//	  - type: quic
+//	    target: meet.jit.si:443
+//	    informational: true
+//	  - type: https
+//	    target: https://meet.jit.si/
+//	pinned_cas: []
+//	thresholds:
+//	  dns: 0.5
+//
+// The dns targets are domain names to resolve. The tcp, tls and quic targets
+// are endpoints to connect to, optionally with an SNI (defaulting to the
+// endpoint host). For quic targets, we send an HTTP/3 request after the QUIC
+// handshake. The https targets are URLs to fetch, optionally with a method.
+// We measure informational targets but we don't use them to decide whether
+// the app is blocked. The pinned_cas field contains PEM encoded CAs that we
+// trust instead of the system ones for all the targets, like apps pinning their
+// own CA do (e.g., Signal), such that we fail when a chain is not issued by one
+// of the pinned CAs, even if it is publicly trusted.
+//
+// We group the results by target type. We consider a type blocked when the
+// ratio of failed targets is greater than its threshold (zero by default,
+// meaning that any failure means blocked) and the app is blocked when at
+// least one type is blocked.
+package appreachability
@@ -0,0 +1,143 @@
+package appreachability
+
+//
+// Measurer
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+const (
+	testName    = "appreachability"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// Spec is the app spec in JSON or YAML format.
+	Spec string `ooni:"app spec in JSON or YAML format"`
+
+	// SpecFile is the file containing the app spec.
+	SpecFile string `ooni:"file containing the app spec in JSON or YAML format"`
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	// Config contains the experiment settings.
+	Config Config
+
+	// Getter is an optional getter to be used for testing.
+	Getter urlgetter.MultiGetter
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+// ErrNoSpec indicates that the user did not provide any app spec.
+var ErrNoSpec = errors.New("appreachability: you must set either Spec or SpecFile")
+
+// ErrSpecAndSpecFile indicates that the user provided both Spec and SpecFile.
+var ErrSpecAndSpecFile = errors.New("appreachability: cannot set both Spec and SpecFile")
+
+// loadSpec loads the spec from the config.
+func (m Measurer) loadSpec() (*Spec, error) {
+	switch {
+	case m.Config.Spec != "" && m.Config.SpecFile != "":
+		return nil, ErrSpecAndSpecFile
+	case m.Config.Spec != "":
+		return ParseSpec([]byte(m.Config.Spec))
+	case m.Config.SpecFile != "":
+		data, err := os.ReadFile(m.Config.SpecFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSpec, err.Error())
+		}
+		return ParseSpec(data)
+	default:
+		return nil, ErrNoSpec
+	}
+}
+
+// multiInputKey returns the key to map a urlgetter input back to its target.
+func multiInputKey(input urlgetter.MultiInput) string {
+	return fmt.Sprintf("%s h3=%v", input.Target, input.Config.HTTP3Enabled)
+}
+
+// Run implements ExperimentMeasurer.Run.
+func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	callbacks := args.Callbacks
+	measurement := args.Measurement
+	sess := args.Session
+	spec, err := m.loadSpec()
+	if err != nil {
+		return err
+	}
+	pool, err := spec.certPool()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	urlgetter.RegisterExtensions(measurement)
+	var inputs []urlgetter.MultiInput
+	indexes := make(map[string]int)
+	for idx, target := range spec.Targets {
+		input := target.multiInput(pool)
+		indexes[multiInputKey(input)] = idx
+		inputs = append(inputs, input)
+	}
+	multi := urlgetter.Multi{Begin: time.Now(), Getter: m.Getter, Session: sess}
+	testkeys := NewTestKeys(spec)
+	testkeys.Agent = "redirect"
+	measurement.TestKeys = testkeys
+	for entry := range multi.Collect(ctx, inputs, spec.Name, callbacks) {
+		testkeys.update(indexes[multiInputKey(entry.Input)], entry)
+	}
+	testkeys.computeStatus()
+	return nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return Measurer{Config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with ooniprobe
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	AppName    string  `json:"app_name"`
+	AppStatus  string  `json:"app_status"`
+	AppFailure *string `json:"app_failure"`
+	IsAnomaly  bool    `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	tk, ok := measurement.TestKeys.(*TestKeys)
+	if !ok {
+		return nil, errors.New("invalid test keys type")
+	}
+	sk := SummaryKeys{
+		AppName:    tk.AppName,
+		AppStatus:  tk.AppStatus,
+		AppFailure: tk.AppFailure,
+		IsAnomaly:  tk.AppStatus == "blocked",
+	}
+	return sk, nil
+}
@@ -0,0 +1,171 @@
+package appreachability
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+func TestNewExperimentMeasurer(t *testing.T) {
+	measurer := NewExperimentMeasurer(Config{})
+	if measurer.ExperimentName() != "appreachability" {
+		t.Fatal("unexpected name")
+	}
+	if measurer.ExperimentVersion() != "0.1.0" {
+		t.Fatal("unexpected version")
+	}
+}
+
+// newGetterFailingFor returns a MultiGetter failing for the
+// targets containing any of the given substrings.
+func newGetterFailingFor(substrings ...string) urlgetter.MultiGetter {
+	return func(ctx context.Context, g urlgetter.Getter) (urlgetter.TestKeys, error) {
+		for _, entry := range substrings {
+			if strings.Contains(g.Target, entry) {
+				failure := netxlite.FailureConnectionReset
+				tk := urlgetter.TestKeys{Failure: &failure}
+				return tk, errors.New(failure)
+			}
+		}
+		return urlgetter.TestKeys{}, nil
+	}
+}
+
+const testSpec = `
+name: example
+targets:
+  - type: dns
+    target: a.example.com
+  - type: dns
+    target: b.example.com
+  - type: tcp
+    target: a.example.com:443
+  - type: quic
+    target: a.example.com:443
+    informational: true
+  - type: https
+    target: https://a.example.com/
+thresholds:
+  dns: 0.5
+`
+
+func runWithGetter(t *testing.T, getter urlgetter.MultiGetter) (*model.Measurement, *TestKeys) {
+	measurer := &Measurer{Config: Config{Spec: testSpec}, Getter: getter}
+	measurement := new(model.Measurement)
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session:     &mockable.Session{MockableLogger: log.Log},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	return measurement, measurement.TestKeys.(*TestKeys)
+}
+
+func TestMeasurerRun(t *testing.T) {
+	t.Run("when everything works", func(t *testing.T) {
+		measurement, tk := runWithGetter(t, newGetterFailingFor())
+		if tk.AppName != "example" || tk.AppStatus != "ok" || tk.AppFailure != nil {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if len(tk.Targets) != 5 || len(tk.Types) != 4 {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.Types["dns"].Total != 2 || tk.Types["quic"].Total != 0 {
+			t.Fatal("unexpected type results")
+		}
+		sk, err := Measurer{}.GetSummaryKeys(measurement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sk.(SummaryKeys).IsAnomaly {
+			t.Fatal("expected no anomaly")
+		}
+	})
+
+	t.Run("failures below the threshold are not blocking", func(t *testing.T) {
+		_, tk := runWithGetter(t, newGetterFailingFor("dnslookup://b.example.com"))
+		if tk.AppStatus != "ok" || tk.Types["dns"].Failed != 1 || tk.Types["dns"].Status != "ok" {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.Targets[1].Failure == nil || tk.Targets[0].Failure != nil {
+			t.Fatal("unexpected target results")
+		}
+	})
+
+	t.Run("informational targets are not blocking", func(t *testing.T) {
+		_, tk := runWithGetter(t, newGetterFailingFor("https://a.example.com:443/"))
+		if tk.AppStatus != "ok" || tk.Targets[3].Failure == nil || tk.Targets[4].Failure != nil {
+			t.Fatal("unexpected test keys", tk)
+		}
+	})
+
+	t.Run("failures above the threshold are blocking", func(t *testing.T) {
+		measurement, tk := runWithGetter(t, newGetterFailingFor("tcpconnect://"))
+		if tk.AppStatus != "blocked" || tk.Types["tcp"].Status != "blocked" {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.AppFailure == nil || *tk.AppFailure != netxlite.FailureConnectionReset {
+			t.Fatal("unexpected app failure")
+		}
+		sk, err := Measurer{}.GetSummaryKeys(measurement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sk.(SummaryKeys).IsAnomaly {
+			t.Fatal("expected an anomaly")
+		}
+	})
+}
+
+func TestMeasurerLoadSpec(t *testing.T) {
+	t.Run("without any spec", func(t *testing.T) {
+		if _, err := (Measurer{}).loadSpec(); !errors.Is(err, ErrNoSpec) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with both Spec and SpecFile", func(t *testing.T) {
+		m := Measurer{Config: Config{Spec: testSpec, SpecFile: "testdata/jitsi.yaml"}}
+		if _, err := m.loadSpec(); !errors.Is(err, ErrSpecAndSpecFile) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with a SpecFile", func(t *testing.T) {
+		m := Measurer{Config: Config{SpecFile: "testdata/jitsi.yaml"}}
+		spec, err := m.loadSpec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec.Name != "jitsi" {
+			t.Fatal("unexpected spec", spec)
+		}
+	})
+
+	t.Run("with a nonexistent SpecFile", func(t *testing.T) {
+		m := Measurer{Config: Config{SpecFile: "testdata/nonexistent.yaml"}}
+		if _, err := m.loadSpec(); !errors.Is(err, ErrInvalidSpec) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestGetSummaryKeysInvalidType(t *testing.T) {
+	measurer := Measurer{}
+	out, err := measurer.GetSummaryKeys(&model.Measurement{TestKeys: make(chan int)})
+	if err == nil || err.Error() != "invalid test keys type" {
+		t.Fatal("not the error we expected", err)
+	}
+	if out != nil {
+		t.Fatal("expected nil output here")
+	}
+}
@@ -0,0 +1,210 @@
+package appreachability
+
+//
+// The app spec
+//
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes the app to measure (see the package documentation).
+type Spec struct {
+	// Name is the MANDATORY app name.
+	Name string `json:"name" yaml:"name"`
+
+	// Targets contains the MANDATORY targets to measure.
+	Targets []*Target `json:"targets" yaml:"targets"`
+
+	// PinnedCAs contains OPTIONAL PEM encoded CAs to trust instead of the system ones.
+	PinnedCAs []string `json:"pinned_cas" yaml:"pinned_cas"`
+
+	// Thresholds OPTIONALLY maps a target type to the ratio of failed
+	// targets above which we consider such a target type blocked.
+	Thresholds map[string]float64 `json:"thresholds" yaml:"thresholds"`
+}
+
+// Target is a target to measure.
+type Target struct {
+	// Type is the MANDATORY target type (one of dns, tcp, tls, quic, https).
+	Type string `json:"type" yaml:"type"`
+
+	// Target is the MANDATORY domain, endpoint or URL to measure.
+	Target string `json:"target" yaml:"target"`
+
+	// SNI is the OPTIONAL SNI to use for tls and quic targets.
+	SNI string `json:"sni,omitempty" yaml:"sni"`
+
+	// Method is the OPTIONAL HTTP method to use for https targets.
+	Method string `json:"method,omitempty" yaml:"method"`
+
+	// Informational OPTIONALLY indicates we should not use this
+	// target to decide whether the app is blocked.
+	Informational bool `json:"informational" yaml:"informational"`
+}
+
+// These are the supported target types.
+const (
+	TargetTypeDNS   = "dns"
+	TargetTypeTCP   = "tcp"
+	TargetTypeTLS   = "tls"
+	TargetTypeQUIC  = "quic"
+	TargetTypeHTTPS = "https"
+)
+
+// targetTypes contains all the supported target types.
+var targetTypes = []string{
+	TargetTypeDNS,
+	TargetTypeTCP,
+	TargetTypeTLS,
+	TargetTypeQUIC,
+	TargetTypeHTTPS,
+}
+
+// ErrInvalidSpec indicates that the app spec is invalid.
+var ErrInvalidSpec = errors.New("appreachability: invalid spec")
+
+// ParseSpec parses and validates a JSON or YAML app spec. Because
+// JSON is valid YAML, we always use the YAML parser.
+func ParseSpec(data []byte) (*Spec, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // catch typos in the spec
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSpec, err.Error())
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate returns an error if the spec is not valid.
+func (s *Spec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidSpec)
+	}
+	if len(s.Targets) <= 0 {
+		return fmt.Errorf("%w: no targets", ErrInvalidSpec)
+	}
+	seen := make(map[string]bool)
+	for _, target := range s.Targets {
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidSpec, err.Error())
+		}
+		key := target.Type + " " + target.Target
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate target: %s", ErrInvalidSpec, key)
+		}
+		seen[key] = true
+	}
+	for key, value := range s.Thresholds {
+		if !isTargetType(key) {
+			return fmt.Errorf("%w: unknown threshold: %s", ErrInvalidSpec, key)
+		}
+		if value < 0 || value > 1 {
+			return fmt.Errorf("%w: threshold not in [0, 1]: %s", ErrInvalidSpec, key)
+		}
+	}
+	if _, err := s.certPool(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// isTargetType returns whether value is a supported target type.
+func isTargetType(value string) bool {
+	for _, entry := range targetTypes {
+		if value == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// validate returns an error if the target is not valid.
+func (t *Target) validate() error {
+	switch t.Type {
+	case TargetTypeDNS:
+		if t.Target == "" || strings.ContainsAny(t.Target, ":/") {
+			return fmt.Errorf("invalid domain: %q", t.Target)
+		}
+	case TargetTypeTCP, TargetTypeTLS, TargetTypeQUIC:
+		if _, _, err := net.SplitHostPort(t.Target); err != nil {
+			return fmt.Errorf("invalid endpoint: %q", t.Target)
+		}
+	case TargetTypeHTTPS:
+		URL, err := url.Parse(t.Target)
+		if err != nil || (URL.Scheme != "https" && URL.Scheme != "http") || URL.Host == "" {
+			return fmt.Errorf("invalid URL: %q", t.Target)
+		}
+	default:
+		return fmt.Errorf("unknown target type: %q", t.Type)
+	}
+	return nil
+}
+
+// errAppendCertsFromPEM indicates that we cannot add a pinned CA.
+var errAppendCertsFromPEM = errors.New("appreachability: AppendCertsFromPEM failed")
+
+// certPool returns the cert pool to use or nil when we should use the default. When
+// there are pinned CAs, the pool only contains them, such that a chain issued by any
+// other CA (e.g., a middlebox using a publicly trusted certificate) fails.
+func (s *Spec) certPool() (*x509.CertPool, error) {
+	if len(s.PinnedCAs) <= 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, entry := range s.PinnedCAs {
+		if !pool.AppendCertsFromPEM([]byte(entry)) {
+			return nil, errAppendCertsFromPEM
+		}
+	}
+	return pool, nil
+}
+
+// multiInput returns the urlgetter input to measure the target.
+func (t *Target) multiInput(pool *x509.CertPool) urlgetter.MultiInput {
+	switch t.Type {
+	case TargetTypeDNS:
+		return urlgetter.MultiInput{Target: "dnslookup://" + t.Target}
+	case TargetTypeTCP:
+		return urlgetter.MultiInput{Target: "tcpconnect://" + t.Target}
+	case TargetTypeTLS:
+		return urlgetter.MultiInput{Target: "tlshandshake://" + t.Target, Config: urlgetter.Config{
+			CertPool:      pool,
+			TLSServerName: t.SNI,
+		}}
+	case TargetTypeQUIC:
+		// Note: urlgetter cannot just perform a QUIC handshake, so we
+		// send an HTTP/3 request and ignore the HTTP status code.
+		return urlgetter.MultiInput{Target: "https://" + t.Target + "/", Config: urlgetter.Config{
+			CertPool:          pool,
+			HTTP3Enabled:      true,
+			HTTPHost:          t.SNI,
+			Method:            "GET",
+			NoFollowRedirects: true,
+			TLSServerName:     t.SNI,
+		}}
+	default:
+		method := t.Method
+		if method == "" {
+			method = "GET"
+		}
+		// Here we need to provide the method explicitly. See
+		// https://github.com/ooni/probe-engine/issues/827.
+		return urlgetter.MultiInput{Target: t.Target, Config: urlgetter.Config{
+			CertPool: pool,
+			Method:   method,
+		}}
+	}
+}
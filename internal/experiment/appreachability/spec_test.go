@@ -0,0 +1,181 @@
+package appreachability
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+func TestParseSpec(t *testing.T) {
+	t.Run("we can parse the example specs", func(t *testing.T) {
+		for _, filename := range []string{"testdata/jitsi.yaml", "testdata/signal.json"} {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec, err := ParseSpec(data)
+			if err != nil {
+				t.Fatal(filename, err)
+			}
+			if spec.Name == "" || len(spec.Targets) <= 0 {
+				t.Fatal("unexpected spec", spec)
+			}
+			if _, err := spec.certPool(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("we reject invalid specs", func(t *testing.T) {
+		var testCases = []struct {
+			name string
+			spec string
+		}{{
+			name: "not YAML",
+			spec: "{",
+		}, {
+			name: "unknown field",
+			spec: "{name: x, targets: [{type: dns, target: x.org}], extra: 1}",
+		}, {
+			name: "missing name",
+			spec: "{targets: [{type: dns, target: x.org}]}",
+		}, {
+			name: "no targets",
+			spec: "{name: x}",
+		}, {
+			name: "unknown target type",
+			spec: "{name: x, targets: [{type: ftp, target: x.org}]}",
+		}, {
+			name: "invalid domain",
+			spec: "{name: x, targets: [{type: dns, target: 'x.org:53'}]}",
+		}, {
+			name: "invalid endpoint",
+			spec: "{name: x, targets: [{type: tls, target: x.org}]}",
+		}, {
+			name: "invalid URL",
+			spec: "{name: x, targets: [{type: https, target: 'ftp://x.org/'}]}",
+		}, {
+			name: "duplicate target",
+			spec: "{name: x, targets: [{type: dns, target: x.org}, {type: dns, target: x.org}]}",
+		}, {
+			name: "unknown threshold",
+			spec: "{name: x, targets: [{type: dns, target: x.org}], thresholds: {ftp: 0.5}}",
+		}, {
+			name: "threshold out of range",
+			spec: "{name: x, targets: [{type: dns, target: x.org}], thresholds: {dns: 1.5}}",
+		}}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				spec, err := ParseSpec([]byte(tc.spec))
+				if !errors.Is(err, ErrInvalidSpec) {
+					t.Fatal("unexpected error", err)
+				}
+				if spec != nil {
+					t.Fatal("expected nil spec")
+				}
+			})
+		}
+	})
+
+	t.Run("we reject invalid pinned CAs", func(t *testing.T) {
+		spec := "{name: x, targets: [{type: dns, target: x.org}], pinned_cas: [INVALID]}"
+		if _, err := ParseSpec([]byte(spec)); !errors.Is(err, errAppendCertsFromPEM) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestSpecCertPool(t *testing.T) {
+	// parseCert parses the first PEM encoded certificate in data.
+	parseCert := func(t *testing.T, data []byte) *x509.Certificate {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			t.Fatal("cannot decode PEM")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	// Note: we use a fixed time at which both the Signal CA and ISRG Root X1 are valid.
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("without pinned CAs we use the default pool", func(t *testing.T) {
+		spec := &Spec{}
+		pool, err := spec.certPool()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pool != nil {
+			t.Fatal("expected nil pool")
+		}
+	})
+
+	data, err := os.ReadFile("testdata/signal.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := ParseSpec(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := spec.certPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("we accept chains issued by a pinned CA", func(t *testing.T) {
+		cert := parseCert(t, []byte(spec.PinnedCAs[0]))
+		if _, err := cert.Verify(x509.VerifyOptions{CurrentTime: now, Roots: pool}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("we reject publicly trusted chains not issued by a pinned CA", func(t *testing.T) {
+		data, err := os.ReadFile("testdata/isrgrootx1.pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := parseCert(t, data)
+		// make sure that the chain is actually publicly trusted
+		publicOpts := x509.VerifyOptions{CurrentTime: now, Roots: netxlite.NewDefaultCertPool()}
+		if _, err := cert.Verify(publicOpts); err != nil {
+			t.Fatal(err)
+		}
+		pinnedOpts := x509.VerifyOptions{CurrentTime: now, Roots: pool}
+		if _, err := cert.Verify(pinnedOpts); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+}
+
+func TestTargetMultiInput(t *testing.T) {
+	t.Run("for quic targets we use HTTP/3", func(t *testing.T) {
+		target := &Target{Type: TargetTypeQUIC, Target: "1.1.1.1:443", SNI: "example.com"}
+		input := target.multiInput(nil)
+		if input.Target != "https://1.1.1.1:443/" || !input.Config.HTTP3Enabled {
+			t.Fatal("unexpected input", input)
+		}
+		if input.Config.TLSServerName != "example.com" || input.Config.HTTPHost != "example.com" {
+			t.Fatal("unexpected input", input)
+		}
+	})
+
+	t.Run("for https targets we use GET by default", func(t *testing.T) {
+		target := &Target{Type: TargetTypeHTTPS, Target: "https://example.com/"}
+		if input := target.multiInput(nil); input.Config.Method != "GET" {
+			t.Fatal("unexpected input", input)
+		}
+		target.Method = "HEAD"
+		if input := target.multiInput(nil); input.Config.Method != "HEAD" {
+			t.Fatal("unexpected input", input)
+		}
+	})
+}
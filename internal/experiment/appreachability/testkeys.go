@@ -0,0 +1,120 @@
+package appreachability
+
+//
+// Test keys
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
+)
+
+// TestKeys contains the experiment test keys.
+type TestKeys struct {
+	urlgetter.TestKeys
+
+	// AppName is the name of the app in the spec.
+	AppName string `json:"app_name"`
+
+	// AppStatus is "ok" or "blocked".
+	AppStatus string `json:"app_status"`
+
+	// AppFailure is the first failure that caused AppStatus to be "blocked".
+	AppFailure *string `json:"app_failure"`
+
+	// Types contains the results grouped by target type.
+	Types map[string]*TypeResult `json:"types"`
+
+	// Targets contains the result of each target in the spec order.
+	Targets []*TargetResult `json:"targets"`
+}
+
+// TypeResult contains the results of the targets of a given type.
+type TypeResult struct {
+	// Total is the number of non-informational targets.
+	Total int64 `json:"total"`
+
+	// Failed is the number of failed non-informational targets.
+	Failed int64 `json:"failed"`
+
+	// Threshold is the ratio of failed targets above which we consider blocked.
+	Threshold float64 `json:"threshold"`
+
+	// Status is "ok" or "blocked".
+	Status string `json:"status"`
+}
+
+// TargetResult is the result of measuring a target.
+type TargetResult struct {
+	// Type is the target type.
+	Type string `json:"type"`
+
+	// Target is the measured domain, endpoint or URL.
+	Target string `json:"target"`
+
+	// Informational indicates an informational target.
+	Informational bool `json:"informational"`
+
+	// Failure is the failure that occurred or nil.
+	Failure *string `json:"failure"`
+}
+
+// NewTestKeys creates new TestKeys for the given spec.
+func NewTestKeys(spec *Spec) *TestKeys {
+	tk := &TestKeys{
+		AppName:   spec.Name,
+		AppStatus: "ok",
+		Types:     make(map[string]*TypeResult),
+	}
+	for _, target := range spec.Targets {
+		tk.Targets = append(tk.Targets, &TargetResult{
+			Type:          target.Type,
+			Target:        target.Target,
+			Informational: target.Informational,
+		})
+		if _, found := tk.Types[target.Type]; !found {
+			tk.Types[target.Type] = &TypeResult{
+				Threshold: spec.Thresholds[target.Type],
+				Status:    "ok",
+			}
+		}
+	}
+	return tk
+}
+
+// update updates the test keys using the result of measuring
+// the target with the given index.
+func (tk *TestKeys) update(idx int, v urlgetter.MultiOutput) {
+	tk.NetworkEvents = append(tk.NetworkEvents, v.TestKeys.NetworkEvents...)
+	tk.Queries = append(tk.Queries, v.TestKeys.Queries...)
+	tk.Requests = append(tk.Requests, v.TestKeys.Requests...)
+	tk.TCPConnect = append(tk.TCPConnect, v.TestKeys.TCPConnect...)
+	tk.TLSHandshakes = append(tk.TLSHandshakes, v.TestKeys.TLSHandshakes...)
+	tk.Targets[idx].Failure = v.TestKeys.Failure
+}
+
+// computeStatus computes the status of each target type and of the app
+// once we have measured all the targets.
+func (tk *TestKeys) computeStatus() {
+	for _, target := range tk.Targets {
+		if target.Informational {
+			continue
+		}
+		tr := tk.Types[target.Type]
+		tr.Total++
+		if target.Failure != nil {
+			tr.Failed++
+		}
+	}
+	for _, tr := range tk.Types {
+		if tr.Total > 0 && float64(tr.Failed)/float64(tr.Total) > tr.Threshold {
+			tr.Status = "blocked"
+		}
+	}
+	for _, target := range tk.Targets {
+		if !target.Informational && target.Failure != nil && tk.Types[target.Type].Status == "blocked" {
+			tk.AppStatus = "blocked"
+			tk.AppFailure = target.Failure
+			return
+		}
+	}
+}
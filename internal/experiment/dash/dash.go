@@ -11,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/montanaflynn/stats"
@@ -25,23 +26,47 @@ const (
 	defaultTimeout = 120 * time.Second
 	magicVersion   = "0.008000000"
 	testName       = "dash"
-	testVersion    = "0.13.0"
+	testVersion    = "0.14.0"
 	totalStep      = 15
 )
 
 var (
-	errServerBusy        = errors.New("dash: server busy; try again later")
-	errHTTPRequestFailed = errors.New("dash: request failed")
+	errServerBusy           = errors.New("dash: server busy; try again later")
+	errHTTPRequestFailed    = errors.New("dash: request failed")
+	errUploadNeedsServer    = errors.New("dash: upload requires a server implementing the upload extension")
+	errUploadNotImplemented = errors.New("dash: server does not implement the upload extension")
 )
 
 // Config contains the experiment config.
-type Config struct{}
+type Config struct {
+	// Server is the OPTIONAL neubot server to use instead of
+	// asking mlab-locate which server we should use.
+	Server string `ooni:"neubot server to use (host or host:port) instead of using locate"`
+
+	// Streams is the number of concurrent streams, which
+	// approximates the number of concurrent video viewers.
+	Streams int64 `ooni:"number of concurrent streams" ooni_default:"1"`
+
+	// Upload indicates whether we should also measure the upload. The upload
+	// is an extension to the neubot protocol that the servers returned by
+	// mlab-locate do not implement, so this option requires setting Server
+	// to a server implementing the extension (see the package docs).
+	Upload bool `ooni:"also measure the upload after the download (requires a custom server)"`
+}
+
+func (c Config) streams() int64 {
+	if c.Streams > 0 {
+		return c.Streams
+	}
+	return 1
+}
 
 // Simple contains the experiment total summary
 type Simple struct {
-	ConnectLatency  float64 `json:"connect_latency"`
-	MedianBitrate   int64   `json:"median_bitrate"`
-	MinPlayoutDelay float64 `json:"min_playout_delay"`
+	ConnectLatency      float64 `json:"connect_latency"`
+	MedianBitrate       int64   `json:"median_bitrate"`
+	MinPlayoutDelay     float64 `json:"min_playout_delay"`
+	UploadMedianBitrate int64   `json:"upload_median_bitrate,omitempty"`
 }
 
 // ServerInfo contains information on the selected server
@@ -53,16 +78,33 @@ type ServerInfo struct {
 	Site     string `json:"site,omitempty"`
 }
 
-// TestKeys contains the test keys
-type TestKeys struct {
-	Server       ServerInfo      `json:"server"`
+// StreamResults contains the results of a single stream when
+// we are using more than one concurrent stream.
+type StreamResults struct {
+	Stream       int64           `json:"stream"`
 	Simple       Simple          `json:"simple"`
-	Failure      *string         `json:"failure"`
 	ReceiverData []clientResults `json:"receiver_data"`
+	SenderData   []clientResults `json:"sender_data,omitempty"`
+}
+
+// TestKeys contains the test keys
+//
+// When using more than one stream, ReceiverData and SenderData contain the
+// results of all the streams, Streams contains the results of each stream,
+// and Simple summarizes the results of each stream.
+type TestKeys struct {
+	Server        ServerInfo       `json:"server"`
+	Simple        Simple           `json:"simple"`
+	Failure       *string          `json:"failure"`
+	ReceiverData  []clientResults  `json:"receiver_data"`
+	SenderData    []clientResults  `json:"sender_data,omitempty"`
+	Streams       []*StreamResults `json:"streams,omitempty"`
+	UploadFailure *string          `json:"upload_failure,omitempty"`
 }
 
 type runner struct {
 	callbacks  model.ExperimentCallbacks
+	config     Config
 	httpClient *http.Client
 	saver      *tracex.Saver
 	sess       model.ExperimentSession
@@ -97,40 +139,150 @@ func (r runner) UserAgent() string {
 	return r.sess.UserAgent()
 }
 
-func (r runner) loop(ctx context.Context, numIterations int64) error {
+func (r runner) server(ctx context.Context) (string, error) {
+	if r.config.Server != "" {
+		r.tk.Server = ServerInfo{Hostname: r.config.Server}
+		return r.config.Server, nil
+	}
 	locateResult, err := locate(ctx, r)
 	if err != nil {
-		return err
+		return "", err
 	}
 	r.tk.Server = ServerInfo{
 		Hostname: locateResult.FQDN,
 		Site:     locateResult.Site,
 	}
-	fqdn := locateResult.FQDN
+	return locateResult.FQDN, nil
+}
+
+func (r runner) loop(ctx context.Context, numIterations int64) error {
+	fqdn, err := r.server(ctx)
+	if err != nil {
+		return err
+	}
 	r.callbacks.OnProgress(0.0, fmt.Sprintf("streaming: server: %s", fqdn))
 	negotiateResp, err := negotiate(ctx, fqdn, r)
 	if err != nil {
 		return err
 	}
-	if err := r.measure(ctx, fqdn, negotiateResp, numIterations); err != nil {
+	streams := r.newStreams()
+	err = r.runStreams(streams, func(sr *StreamResults) (err error) {
+		sr.ReceiverData, err = r.measure(ctx, fqdn, negotiateResp, numIterations, sr.Stream)
+		return
+	})
+	for _, sr := range streams {
+		r.tk.ReceiverData = append(r.tk.ReceiverData, sr.ReceiverData...)
+	}
+	if err != nil {
 		return err
 	}
+	if r.config.Upload {
+		r.measureUploadStreams(ctx, fqdn, negotiateResp, numIterations, streams)
+	}
+	// Note: we collect after the upload such that the server
+	// also receives the results of the upload.
 	// TODO(bassosimone): it seems we're not saving the server data?
-	err = collect(ctx, fqdn, negotiateResp.Authorization, r.tk.ReceiverData, r)
+	results := append(append([]clientResults{}, r.tk.ReceiverData...), r.tk.SenderData...)
+	err = collect(ctx, fqdn, negotiateResp.Authorization, results, r)
 	if err != nil {
 		return err
 	}
+	if len(streams) > 1 {
+		r.tk.Streams = streams
+	}
 	return r.tk.analyze()
 }
 
+// measureUploadStreams measures the upload using all the streams after checking
+// whether the server implements the upload extension. Because not all servers
+// implement the extension, we do not want an upload failure to cause us to throw
+// away the download results, so we record it into the UploadFailure field.
+func (r runner) measureUploadStreams(ctx context.Context, fqdn string,
+	negotiateResp negotiateResponse, numIterations int64, streams []*StreamResults) {
+	err := checkUpload(ctx, uploadConfig{
+		authorization: negotiateResp.Authorization,
+		begin:         time.Now(),
+		deps:          r,
+		fqdn:          fqdn,
+	})
+	if err == nil {
+		err = r.runStreams(streams, func(sr *StreamResults) (err error) {
+			sr.SenderData, err = r.measureUpload(ctx, fqdn, negotiateResp, numIterations, sr.Stream)
+			return
+		})
+		for _, sr := range streams {
+			r.tk.SenderData = append(r.tk.SenderData, sr.SenderData...)
+		}
+	}
+	if err != nil {
+		s := err.Error()
+		r.tk.UploadFailure = &s
+	}
+}
+
+// newStreams creates the results of each configured stream.
+func (r runner) newStreams() (out []*StreamResults) {
+	for idx := int64(0); idx < r.config.streams(); idx++ {
+		out = append(out, &StreamResults{Stream: idx})
+	}
+	return
+}
+
+// runStreams runs fn for each stream concurrently and returns the
+// first error that occurred, if any.
+func (r runner) runStreams(streams []*StreamResults, fn func(sr *StreamResults) error) error {
+	errs := make([]error, len(streams))
+	wg := &sync.WaitGroup{}
+	for idx, sr := range streams {
+		wg.Add(1)
+		go func(idx int, sr *StreamResults) {
+			defer wg.Done()
+			errs[idx] = fn(sr)
+		}(idx, sr)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connectTime reads the events so far and possibly updates our measurement
+// of the latest connect time. We should have one sample in most cases, because
+// the connection should be persistent. When using several streams, another
+// stream may read our events, in which case we keep the previous value.
+func (r runner) connectTime(previous float64) float64 {
+	for _, ev := range r.saver.Read() {
+		if _, ok := ev.(*tracex.EventConnectOperation); ok {
+			previous = ev.Value().Duration.Seconds()
+		}
+	}
+	return previous
+}
+
+// progress emits progress on behalf of the first stream only, to avoid
+// emitting the same information once per stream.
+func (r runner) progress(stream int64, percentage float64, message string) {
+	if stream != 0 {
+		return
+	}
+	if r.config.Upload {
+		percentage /= 2 // the download is the first half of the work
+	}
+	r.callbacks.OnProgress(percentage, message)
+}
+
+// Note: according to a comment in MK sources 3000 kbit/s was the
+// minimum speed recommended by Netflix for SD quality in 2017.
+//
+// See: <https://help.netflix.com/en/node/306>.
+const initialBitrate = 3000
+
 func (r runner) measure(
 	ctx context.Context, fqdn string, negotiateResp negotiateResponse,
-	numIterations int64) error {
-	// Note: according to a comment in MK sources 3000 kbit/s was the
-	// minimum speed recommended by Netflix for SD quality in 2017.
-	//
-	// See: <https://help.netflix.com/en/node/306>.
-	const initialBitrate = 3000
+	numIterations, stream int64) ([]clientResults, error) {
 	current := clientResults{
 		ElapsedTarget: 2,
 		Platform:      runtime.GOOS,
@@ -141,6 +293,7 @@ func (r runner) measure(
 	var (
 		begin       = time.Now()
 		connectTime float64
+		out         []clientResults
 		total       int64
 	)
 	for current.Iteration < numIterations {
@@ -160,47 +313,120 @@ func (r runner) measure(
 			// return error here. This comment is being introduced so
 			// that we don't do https://github.com/ooni/probe-engine/pull/526
 			// again, because that isn't accurate.
-			return err
+			return out, err
 		}
 		current.Elapsed = result.elapsed
 		current.Received = result.received
 		current.RequestTicks = result.requestTicks
 		current.Timestamp = result.timestamp
 		current.ServerURL = result.serverURL
-		// Read the events so far and possibly update our measurement
-		// of the latest connect time. We should have one sample in most
-		// cases, because the connection should be persistent.
-		for _, ev := range r.saver.Read() {
-			if _, ok := ev.(*tracex.EventConnectOperation); ok {
-				connectTime = ev.Value().Duration.Seconds()
-			}
-		}
+		connectTime = r.connectTime(connectTime)
 		current.ConnectTime = connectTime
-		r.tk.ReceiverData = append(r.tk.ReceiverData, current)
+		out = append(out, current)
 		total += current.Received
 		avgspeed := 8 * float64(total) / time.Since(begin).Seconds()
 		percentage := float64(current.Iteration) / float64(numIterations)
 		message := fmt.Sprintf("streaming: speed: %s", humanize.SI(avgspeed, "bit/s"))
-		r.callbacks.OnProgress(percentage, message)
+		r.progress(stream, percentage, message)
 		current.Iteration++
 		speed := float64(current.Received) / float64(current.Elapsed)
 		speed *= 8.0    // to bits per second
 		speed /= 1000.0 // to kbit/s
 		current.Rate = int64(speed)
 	}
-	return nil
+	return out, nil
+}
+
+// measureUpload is like measure but uploads segments to the server.
+func (r runner) measureUpload(
+	ctx context.Context, fqdn string, negotiateResp negotiateResponse,
+	numIterations, stream int64) ([]clientResults, error) {
+	current := clientResults{
+		ElapsedTarget: 2,
+		Platform:      runtime.GOOS,
+		Rate:          initialBitrate,
+		RealAddress:   negotiateResp.RealAddress,
+		Version:       magicVersion,
+	}
+	var (
+		begin       = time.Now()
+		connectTime float64
+		out         []clientResults
+		total       int64
+	)
+	for current.Iteration < numIterations {
+		result, err := upload(ctx, uploadConfig{
+			authorization: negotiateResp.Authorization,
+			begin:         begin,
+			currentRate:   current.Rate,
+			deps:          r,
+			elapsedTarget: current.ElapsedTarget,
+			fqdn:          fqdn,
+		})
+		if err != nil {
+			return out, err
+		}
+		current.Elapsed = result.elapsed
+		current.Sent = result.sent
+		current.RequestTicks = result.requestTicks
+		current.Timestamp = result.timestamp
+		current.ServerURL = result.serverURL
+		connectTime = r.connectTime(connectTime)
+		current.ConnectTime = connectTime
+		out = append(out, current)
+		total += current.Sent
+		avgspeed := 8 * float64(total) / time.Since(begin).Seconds()
+		percentage := 1 + float64(current.Iteration)/float64(numIterations)
+		message := fmt.Sprintf("uploading: speed: %s", humanize.SI(avgspeed, "bit/s"))
+		r.progress(stream, percentage, message)
+		current.Iteration++
+		speed := float64(current.Sent) / float64(current.Elapsed)
+		speed *= 8.0    // to bits per second
+		speed /= 1000.0 // to kbit/s
+		current.Rate = int64(speed)
+	}
+	return out, nil
 }
 
 func (tk *TestKeys) analyze() error {
+	if len(tk.Streams) <= 0 {
+		return tk.Simple.analyze(tk.ReceiverData, tk.SenderData)
+	}
+	// With several streams, each stream is a viewer, hence we summarize
+	// using the median of the viewers' bitrates and the worst delay.
+	var downloadRates, uploadRates []float64
+	for _, sr := range tk.Streams {
+		if err := sr.Simple.analyze(sr.ReceiverData, sr.SenderData); err != nil {
+			return err
+		}
+		downloadRates = append(downloadRates, float64(sr.Simple.MedianBitrate))
+		if len(sr.SenderData) > 0 {
+			uploadRates = append(uploadRates, float64(sr.Simple.UploadMedianBitrate))
+		}
+		if sr.Simple.MinPlayoutDelay > tk.Simple.MinPlayoutDelay {
+			tk.Simple.MinPlayoutDelay = sr.Simple.MinPlayoutDelay
+		}
+	}
+	tk.Simple.ConnectLatency = tk.Streams[0].Simple.ConnectLatency
+	median, err := stats.Median(downloadRates)
+	tk.Simple.MedianBitrate = int64(median)
+	if len(uploadRates) > 0 {
+		median, _ := stats.Median(uploadRates)
+		tk.Simple.UploadMedianBitrate = int64(median)
+	}
+	return err
+}
+
+func (s *Simple) analyze(receiverData, senderData []clientResults) error {
 	var (
 		rates          []float64
 		frameReadyTime float64
 		playTime       float64
 	)
-	for _, results := range tk.ReceiverData {
+	for _, results := range receiverData {
 		rates = append(rates, float64(results.Rate))
 		// Same in all samples if we're using a single connection
-		tk.Simple.ConnectLatency = results.ConnectTime
+		s.ConnectLatency = results.ConnectTime
 		// Rationale: first segment plays when it arrives. Subsequent segments
 		// would play in ElapsedTarget seconds. However, will play when they
 		// arrive. Stall is the time we need to wait for a frame to arrive with
@@ -212,12 +438,20 @@ func (tk *TestKeys) analyze() error {
 			playTime += float64(results.ElapsedTarget)
 		}
 		stall := frameReadyTime - playTime
-		if stall > tk.Simple.MinPlayoutDelay {
-			tk.Simple.MinPlayoutDelay = stall
+		if stall > s.MinPlayoutDelay {
+			s.MinPlayoutDelay = stall
+		}
+	}
+	if len(senderData) > 0 {
+		var uploadRates []float64
+		for _, results := range senderData {
+			uploadRates = append(uploadRates, float64(results.Rate))
 		}
+		median, _ := stats.Median(uploadRates)
+		s.UploadMedianBitrate = int64(median)
 	}
 	median, err := stats.Median(rates)
-	tk.Simple.MedianBitrate = int64(median)
+	s.MedianBitrate = int64(median)
 	return err
 }
 
@@ -253,6 +487,11 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	callbacks := args.Callbacks
 	measurement := args.Measurement
 	sess := args.Session
+	// Note: this is a fundamental failure because the configuration is
+	// such that the upload is always going to fail (see the package docs).
+	if m.config.Upload && m.config.Server == "" {
+		return errUploadNeedsServer
+	}
 	tk := new(TestKeys)
 	measurement.TestKeys = tk
 	saver := &tracex.Saver{}
@@ -272,12 +511,17 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	defer httpClient.CloseIdleConnections()
 	r := runner{
 		callbacks:  callbacks,
+		config:     m.config,
 		httpClient: httpClient,
 		saver:      saver,
 		sess:       sess,
 		tk:         tk,
 	}
-	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	timeout := defaultTimeout
+	if m.config.Upload {
+		timeout *= 2 // we're going to run twice the number of iterations
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	// Implementation note: we ignore the return value of r.do rather than
 	// returning it to the caller. We do that because returning an error means
@@ -298,10 +542,11 @@ func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
 // Note that this structure is part of the ABI contract with ooniprobe
 // therefore we should be careful when changing it.
 type SummaryKeys struct {
-	Latency   float64 `json:"connect_latency"`
-	Bitrate   float64 `json:"median_bitrate"`
-	Delay     float64 `json:"min_playout_delay"`
-	IsAnomaly bool    `json:"-"`
+	Latency       float64 `json:"connect_latency"`
+	Bitrate       float64 `json:"median_bitrate"`
+	Delay         float64 `json:"min_playout_delay"`
+	UploadBitrate float64 `json:"upload_median_bitrate,omitempty"`
+	IsAnomaly     bool    `json:"-"`
 }
 
 // GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
@@ -314,5 +559,6 @@ func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, e
 	sk.Latency = tk.Simple.ConnectLatency
 	sk.Bitrate = float64(tk.Simple.MedianBitrate)
 	sk.Delay = tk.Simple.MinPlayoutDelay
+	sk.UploadBitrate = float64(tk.Simple.UploadMedianBitrate)
 	return sk, nil
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -260,7 +261,7 @@ func TestNewExperimentMeasurer(t *testing.T) {
 	if measurer.ExperimentName() != "dash" {
 		t.Fatal("unexpected name")
 	}
-	if measurer.ExperimentVersion() != "0.13.0" {
+	if measurer.ExperimentVersion() != "0.14.0" {
 		t.Fatal("unexpected version")
 	}
 }
@@ -293,6 +294,21 @@ func TestMeasureWithCancelledContext(t *testing.T) {
 	}
 }
 
+func TestMeasureUploadWithoutServer(t *testing.T) {
+	m := &Measurer{config: Config{Upload: true}}
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: new(model.Measurement),
+		Session: &mockable.Session{
+			MockableHTTPClient: http.DefaultClient,
+			MockableLogger:     log.Log,
+		},
+	}
+	if err := m.Run(context.Background(), args); !errors.Is(err, errUploadNeedsServer) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 func TestSummaryKeysInvalidType(t *testing.T) {
 	measurement := new(model.Measurement)
 	m := &Measurer{}
@@ -327,3 +343,110 @@ func TestSummaryKeysGood(t *testing.T) {
 		t.Fatal("invalid isAnomaly")
 	}
 }
+
+// newStubRunner returns a runner using the given stub server and config.
+func newStubRunner(srv *httptest.Server, config Config) runner {
+	config.Server = srv.Listener.Addr().String()
+	return runner{
+		callbacks:  model.NewPrinterCallbacks(log.Log),
+		config:     config,
+		httpClient: srv.Client(),
+		saver:      new(tracex.Saver),
+		sess: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+		tk: new(TestKeys),
+	}
+}
+
+func TestRunnerLoopWithStubServer(t *testing.T) {
+	t.Run("with a single stream and no upload", func(t *testing.T) {
+		stub := &stubServer{}
+		srv := stub.Start()
+		defer srv.Close()
+		r := newStubRunner(srv, Config{})
+		if err := r.loop(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		if r.tk.Server.Hostname != srv.Listener.Addr().String() {
+			t.Fatal("unexpected server")
+		}
+		if len(r.tk.ReceiverData) != 2 || stub.downloads != 2 {
+			t.Fatal("unexpected number of downloads")
+		}
+		if len(r.tk.SenderData) != 0 || stub.uploads != 0 {
+			t.Fatal("unexpected number of uploads")
+		}
+		if stub.collected != 2 {
+			t.Fatal("unexpected number of collected results")
+		}
+		if r.tk.Streams != nil {
+			t.Fatal("expected no per-stream results")
+		}
+		if r.tk.Simple.MedianBitrate <= 0 || r.tk.Simple.UploadMedianBitrate != 0 {
+			t.Fatal("unexpected simple results", r.tk.Simple)
+		}
+	})
+
+	t.Run("with several streams and upload", func(t *testing.T) {
+		stub := &stubServer{}
+		srv := stub.Start()
+		defer srv.Close()
+		r := newStubRunner(srv, Config{Streams: 3, Upload: true})
+		if err := r.loop(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		if len(r.tk.ReceiverData) != 6 || stub.downloads != 6 {
+			t.Fatal("unexpected number of downloads")
+		}
+		// Note: the first upload checks whether the server implements the extension
+		if len(r.tk.SenderData) != 6 || stub.uploads != 7 {
+			t.Fatal("unexpected number of uploads")
+		}
+		if stub.collected != 12 {
+			t.Fatal("expected to collect both the download and the upload results")
+		}
+		if r.tk.UploadFailure != nil {
+			t.Fatal("unexpected upload failure", *r.tk.UploadFailure)
+		}
+		if len(r.tk.Streams) != 3 {
+			t.Fatal("expected per-stream results")
+		}
+		for idx, sr := range r.tk.Streams {
+			if sr.Stream != int64(idx) {
+				t.Fatal("unexpected stream index")
+			}
+			if len(sr.ReceiverData) != 2 || len(sr.SenderData) != 2 {
+				t.Fatal("unexpected per-stream data")
+			}
+			if sr.Simple.MedianBitrate <= 0 || sr.Simple.UploadMedianBitrate <= 0 {
+				t.Fatal("unexpected per-stream simple results", sr.Simple)
+			}
+			if sr.SenderData[0].Sent <= 0 {
+				t.Fatal("expected to have sent some bytes")
+			}
+		}
+		if r.tk.Simple.MedianBitrate <= 0 || r.tk.Simple.UploadMedianBitrate <= 0 {
+			t.Fatal("unexpected simple results", r.tk.Simple)
+		}
+	})
+
+	t.Run("with a server not supporting upload", func(t *testing.T) {
+		stub := &stubServer{NoUpload: true}
+		srv := stub.Start()
+		defer srv.Close()
+		r := newStubRunner(srv, Config{Upload: true})
+		if err := r.loop(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		if r.tk.UploadFailure == nil || *r.tk.UploadFailure != errUploadNotImplemented.Error() {
+			t.Fatal("expected an upload failure")
+		}
+		if len(r.tk.ReceiverData) != 2 || len(r.tk.SenderData) != 0 {
+			t.Fatal("unexpected results")
+		}
+		if r.tk.Simple.MedianBitrate <= 0 {
+			t.Fatal("expected to keep the download results")
+		}
+	})
+}
@@ -1,4 +1,12 @@
 // Package dash implements the DASH network experiment.
 //
 // Spec: https://github.com/ooni/spec/blob/master/nettests/ts-021-dash.md
+//
+// When the Upload option is set, we also measure the upload using an extension
+// to the neubot protocol where the client POSTs zero-filled segments to the
+// /dash/upload path of the server, which replies with 200 after reading the
+// whole segment. Because the neubot servers returned by mlab-locate do not
+// implement this extension, the Upload option requires setting Server. Before
+// uploading, we check whether the server implements the extension by POSTing an
+// empty segment, and we record a failure in UploadFailure if it does not.
 package dash
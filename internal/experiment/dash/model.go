@@ -4,7 +4,8 @@ package dash
 // structure is sent to the server in the collection phase.
 //
 // All the fields listed here are part of the original specification
-// of DASH, except ServerURL, added in MK v0.10.6.
+// of DASH, except ServerURL, added in MK v0.10.6, and Sent, which we
+// only set when measuring the upload.
 type clientResults struct {
 	ConnectTime     float64 `json:"connect_time"`
 	DeltaSysTime    float64 `json:"delta_sys_time"`
@@ -19,6 +20,7 @@ type clientResults struct {
 	Received        int64   `json:"received"`
 	RemoteAddress   string  `json:"remote_address"`
 	RequestTicks    float64 `json:"request_ticks"`
+	Sent            int64   `json:"sent,omitempty"`
 	ServerURL       string  `json:"server_url"`
 	Timestamp       int64   `json:"timestamp"`
 	UUID            string  `json:"uuid"`
@@ -9,6 +9,10 @@ const (
 	// the server to send you as part of the next chunk.
 	downloadPath = "/dash/download/"
 
+	// uploadPath is the URL path used to upload DASH segments. This path
+	// is an extension to the original neubot protocol.
+	uploadPath = "/dash/upload"
+
 	// collectPath is the URL path used to collect
 	collectPath = "/collect/dash"
 )
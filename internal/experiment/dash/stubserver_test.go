@@ -0,0 +1,102 @@
+package dash
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// stubServer is a neubot-compatible DASH server for tests.
+type stubServer struct {
+	// NoUpload OPTIONALLY disables the upload extension.
+	NoUpload bool
+
+	// downloads counts the download requests.
+	downloads int64
+
+	// uploads counts the upload requests.
+	uploads int64
+
+	// collected counts the results received by collect.
+	collected int64
+}
+
+// stubMaxSegmentSize is the maximum segment size served by the stub
+// server, which allows us to run tests quickly on the loopback.
+const stubMaxSegmentSize = 1 << 20
+
+// stubAuthorization is the authorization token returned by the stub server.
+const stubAuthorization = "deadbeef"
+
+// Start starts a TLS server using the stub server handler.
+func (s *stubServer) Start() *httptest.Server {
+	return httptest.NewTLSServer(s)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == negotiatePath {
+		s.negotiate(w, req)
+		return
+	}
+	if req.Header.Get("Authorization") != stubAuthorization {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case strings.HasPrefix(req.URL.Path, downloadPath):
+		s.download(w, req)
+	case req.URL.Path == uploadPath && !s.NoUpload:
+		s.upload(w, req)
+	case req.URL.Path == collectPath:
+		s.collect(w, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *stubServer) negotiate(w http.ResponseWriter, req *http.Request) {
+	var request negotiateRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	data, _ := json.Marshal(negotiateResponse{
+		Authorization: stubAuthorization,
+		RealAddress:   "127.0.0.1",
+		Unchoked:      1,
+	})
+	w.Write(data)
+}
+
+func (s *stubServer) download(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&s.downloads, 1)
+	size, err := strconv.ParseInt(strings.TrimPrefix(req.URL.Path, downloadPath), 10, 64)
+	if err != nil || size < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if size > stubMaxSegmentSize {
+		size = stubMaxSegmentSize
+	}
+	w.Write(make([]byte, size))
+}
+
+func (s *stubServer) upload(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&s.uploads, 1)
+	io.Copy(io.Discard, req.Body)
+}
+
+func (s *stubServer) collect(w http.ResponseWriter, req *http.Request) {
+	var results []clientResults
+	if err := json.NewDecoder(req.Body).Decode(&results); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	atomic.AddInt64(&s.collected, int64(len(results)))
+	w.Write([]byte(`[]`))
+}
@@ -0,0 +1,100 @@
+package dash
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type uploadDeps interface {
+	HTTPClient() *http.Client
+	NewHTTPRequest(method string, url string, body io.Reader) (*http.Request, error)
+	ReadAllContext(ctx context.Context, r io.Reader) ([]byte, error)
+	Scheme() string
+	UserAgent() string
+}
+
+type uploadConfig struct {
+	authorization string
+	begin         time.Time
+	currentRate   int64
+	deps          uploadDeps
+	elapsedTarget int64
+	fqdn          string
+}
+
+type uploadResult struct {
+	elapsed      float64
+	sent         int64
+	requestTicks float64
+	serverURL    string
+	timestamp    int64
+}
+
+// zeroReader is an infinite reader returning zero bytes.
+type zeroReader struct{}
+
+// Read implements io.Reader.
+func (zeroReader) Read(data []byte) (int, error) {
+	for idx := range data {
+		data[idx] = 0
+	}
+	return len(data), nil
+}
+
+func upload(ctx context.Context, config uploadConfig) (uploadResult, error) {
+	nbytes := (config.currentRate * 1000 * config.elapsedTarget) >> 3
+	var URL url.URL
+	URL.Scheme = config.deps.Scheme()
+	URL.Host = config.fqdn
+	URL.Path = uploadPath
+	var body io.Reader = http.NoBody
+	if nbytes > 0 {
+		body = io.LimitReader(zeroReader{}, nbytes)
+	}
+	req, err := config.deps.NewHTTPRequest("POST", URL.String(), body)
+	var result uploadResult
+	if err != nil {
+		return result, err
+	}
+	result.serverURL = URL.String()
+	// We need to set the content length explicitly because NewRequest
+	// only knows how to compute it for a few well known readers.
+	req.ContentLength = nbytes
+	req.Header.Set("User-Agent", config.deps.UserAgent())
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", config.authorization)
+	savedTicks := time.Now()
+	resp, err := config.deps.HTTPClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return result, errHTTPRequestFailed
+	}
+	if _, err := config.deps.ReadAllContext(ctx, resp.Body); err != nil {
+		return result, err
+	}
+	// Like for the download, the elapsed time includes the time to
+	// receive the response, which is negligible for large segments.
+	result.elapsed = time.Since(savedTicks).Seconds()
+	result.sent = nbytes
+	result.requestTicks = savedTicks.Sub(config.begin).Seconds()
+	result.timestamp = time.Now().Unix()
+	return result, nil
+}
+
+// checkUpload checks whether the server implements the upload extension by
+// uploading an empty segment, which allows us to fail fast otherwise.
+func checkUpload(ctx context.Context, config uploadConfig) error {
+	config.currentRate = 0 // i.e., upload zero bytes
+	_, err := upload(ctx, config)
+	if errors.Is(err, errHTTPRequestFailed) {
+		return errUploadNotImplemented
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package dash
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestUploadNewHTTPRequestFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	_, err := upload(context.Background(), uploadConfig{
+		deps: FakeDeps{newHTTPRequestErr: expected},
+	})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestUploadHTTPClientDoFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	txp := FakeHTTPTransport{err: expected}
+	_, err := upload(context.Background(), uploadConfig{
+		deps: FakeDeps{httpTransport: txp, newHTTPRequestResult: &http.Request{
+			Header: http.Header{},
+			URL:    &url.URL{},
+		}},
+	})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestUploadInternalError(t *testing.T) {
+	txp := FakeHTTPTransport{resp: &http.Response{StatusCode: 500}}
+	_, err := upload(context.Background(), uploadConfig{
+		deps: FakeDeps{httpTransport: txp, newHTTPRequestResult: &http.Request{
+			Header: http.Header{},
+			URL:    &url.URL{},
+		}},
+	})
+	if !errors.Is(err, errHTTPRequestFailed) {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestUploadReadAllFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	txp := FakeHTTPTransport{resp: &http.Response{
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		StatusCode: 200,
+	}}
+	_, err := upload(context.Background(), uploadConfig{
+		deps: FakeDeps{
+			httpTransport: txp,
+			newHTTPRequestResult: &http.Request{
+				Header: http.Header{},
+				URL:    &url.URL{},
+			},
+			readAllErr: expected,
+		},
+	})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestUploadSuccess(t *testing.T) {
+	txp := FakeHTTPTransport{resp: &http.Response{
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		StatusCode: 200,
+	}}
+	result, err := upload(context.Background(), uploadConfig{
+		currentRate: 3000,
+		deps: FakeDeps{
+			httpTransport: txp,
+			newHTTPRequestResult: &http.Request{
+				Header: http.Header{},
+				URL:    &url.URL{},
+			},
+		},
+		elapsedTarget: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.elapsed <= 0 {
+		t.Fatal("invalid elapsed")
+	}
+	if result.sent != 750000 {
+		t.Fatal("invalid sent", result.sent)
+	}
+	if result.requestTicks <= 0 {
+		t.Fatal("invalid requestTicks")
+	}
+	if result.serverURL == "" {
+		t.Fatal("invalid serverURL")
+	}
+	if result.timestamp <= 0 {
+		t.Fatal("invalid timestamp")
+	}
+}
@@ -0,0 +1,261 @@
+// Package dnsconsistency is the experimental dnsconsistency experiment.
+//
+// We resolve a list of domains using several resolvers concurrently (by
+// default, the system resolver, the ISP's resolver and some DoH and DoT
+// resolvers) and compare their answers. We use the answers of the encrypted
+// resolvers as the reference. The answer of another resolver is consistent
+// when it contains at least an address that is also in the reference answers,
+// that belongs to the same ASN of a reference address, or that is valid
+// for the domain because we can successfully perform a TLS handshake with
+// it using the domain as the SNI. We flag each domain for which a resolver
+// returned an inconsistent answer (including failing to resolve it). When a
+// reference resolver fails, we mark it as a reference failure instead.
+package dnsconsistency
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/geoipx"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+const (
+	testName    = "dnsconsistency"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment configuration.
+type Config struct {
+	// Domains is the list of domains to measure.
+	Domains []string `ooni:"list of domains to measure"`
+
+	// Resolvers is the list of resolver URLs to use.
+	Resolvers []string `ooni:"list of resolver URLs (system:///, udp://, dot://, https://)"`
+}
+
+func (c Config) domains() []string {
+	if len(c.Domains) > 0 {
+		return c.Domains
+	}
+	return []string{"example.com", "www.facebook.com", "www.youtube.com", "twitter.com"}
+}
+
+// defaultResolvers contains the resolvers we use by default in addition
+// to the ISP's resolver discovered by the session.
+var defaultResolvers = []string{
+	"system:///",
+	"https://dns.google/dns-query",
+	"https://cloudflare-dns.com/dns-query",
+	"dot://1.1.1.1:853",
+	"dot://8.8.8.8:853",
+}
+
+func (c Config) resolvers(sess model.ExperimentSession) []string {
+	if len(c.Resolvers) > 0 {
+		return c.Resolvers
+	}
+	out := append([]string{}, defaultResolvers...)
+	if ip := sess.ResolverIP(); net.ParseIP(ip) != nil && !netxlite.IsBogon(ip) {
+		out = append(out, "udp://"+net.JoinHostPort(ip, "53"))
+	}
+	return out
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+
+	// newTrace is an OPTIONAL hook to create traces used for testing.
+	newTrace func(index int64, zeroTime time.Time) *measurexlite.Trace
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperiExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+// errNoReferenceResolver indicates that there is no DoH or DoT resolver.
+var errNoReferenceResolver = errors.New("dnsconsistency: need at least a DoH or DoT resolver")
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	measurement := args.Measurement
+	sess := args.Session
+	var resolvers []*resolverInfo
+	var haveReference bool
+	for _, value := range m.config.resolvers(sess) {
+		ri, err := parseResolverURL(value)
+		if err != nil {
+			return err
+		}
+		haveReference = haveReference || ri.isReference()
+		resolvers = append(resolvers, ri)
+	}
+	if !haveReference {
+		return errNoReferenceResolver
+	}
+	tk := NewTestKeys()
+	measurement.TestKeys = tk
+	index := &atomic.Int64{}
+	domains := m.config.domains()
+	results := make([]*DomainResult, len(domains))
+	wg := new(sync.WaitGroup)
+	for idx, domain := range domains {
+		wg.Add(1)
+		go func(idx int, domain string) {
+			defer wg.Done()
+			results[idx] = m.measureDomain(ctx, measurement.MeasurementStartTimeSaved,
+				sess.Logger(), index, domain, resolvers, tk)
+		}(idx, domain)
+	}
+	wg.Wait()
+	for _, dr := range results { // preserve the domains order
+		tk.addDomain(dr)
+	}
+	return nil // return nil so we always submit the measurement
+}
+
+// trace creates a new trace with the given index.
+func (m *Measurer) trace(index int64, zeroTime time.Time) *measurexlite.Trace {
+	if m.newTrace != nil {
+		return m.newTrace(index, zeroTime)
+	}
+	return measurexlite.NewTrace(index, zeroTime)
+}
+
+// measureDomain resolves the domain using all the resolvers, checks the addresses
+// that are not consistent with the reference ones and computes the status.
+func (m *Measurer) measureDomain(ctx context.Context, zeroTime time.Time, logger model.Logger,
+	index *atomic.Int64, domain string, resolvers []*resolverInfo, tk *TestKeys) *DomainResult {
+	dr := &DomainResult{
+		Domain:    domain,
+		IPInfo:    map[string]*model.THIPInfo{},
+		Resolvers: make([]*ResolverResult, len(resolvers)),
+	}
+	wg := new(sync.WaitGroup)
+	for idx, ri := range resolvers {
+		wg.Add(1)
+		go func(idx int, ri *resolverInfo) {
+			defer wg.Done()
+			dr.Resolvers[idx] = m.lookup(ctx, zeroTime, logger, index.Add(1), domain, ri, tk)
+		}(idx, ri)
+	}
+	wg.Wait()
+	for _, rr := range dr.Resolvers {
+		for _, addr := range rr.Addresses {
+			if _, found := dr.IPInfo[addr]; found {
+				continue
+			}
+			info := &model.THIPInfo{}
+			if netxlite.IsBogon(addr) {
+				info.Flags |= model.THIPInfoFlagIsBogon
+			}
+			asn, _, _ := geoipx.LookupASN(addr) // AS0 on failure
+			info.ASN = int64(asn)
+			dr.IPInfo[addr] = info
+		}
+	}
+	for _, addr := range dr.needsTLSCheck() {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			// Note: each goroutine writes a distinct entry
+			if m.validForDomain(ctx, zeroTime, logger, index.Add(1), domain, addr, tk) {
+				dr.IPInfo[addr].Flags |= model.THIPInfoFlagValidForDomain
+			}
+		}(addr)
+	}
+	wg.Wait()
+	dr.computeStatus()
+	return dr
+}
+
+// lookupTimeout is the timeout of each DNS lookup.
+const lookupTimeout = 10 * time.Second
+
+// tlsCheckTimeout is the timeout for checking whether an address
+// is valid for a domain, including connecting and handshaking.
+const tlsCheckTimeout = 10 * time.Second
+
+// lookup resolves the domain using the given resolver.
+func (m *Measurer) lookup(ctx context.Context, zeroTime time.Time, logger model.Logger,
+	index int64, domain string, ri *resolverInfo, tk *TestKeys) *ResolverResult {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	trace := m.trace(index, zeroTime)
+	ol := measurexlite.NewOperationLogger(logger, "DNSConsistency #%d %s %s", index, ri.URL, domain)
+	resolver := ri.newResolver(trace, logger)
+	defer resolver.CloseIdleConnections()
+	addrs, err := resolver.LookupHost(ctx, domain)
+	ol.Stop(err)
+	tk.addQueries(trace.DNSLookupsFromRoundTrip())
+	return &ResolverResult{
+		URL:       ri.URL,
+		Reference: ri.isReference(),
+		Addresses: addrs,
+		Failure:   measurexlite.NewFailure(err),
+	}
+}
+
+// validForDomain returns whether we can perform a TLS handshake with
+// the given address using the domain as the SNI.
+func (m *Measurer) validForDomain(ctx context.Context, zeroTime time.Time, logger model.Logger,
+	index int64, domain, addr string, tk *TestKeys) bool {
+	ctx, cancel := context.WithTimeout(ctx, tlsCheckTimeout)
+	defer cancel()
+	trace := m.trace(index, zeroTime)
+	endpoint := net.JoinHostPort(addr, "443")
+	ol := measurexlite.NewOperationLogger(logger, "DNSConsistency #%d TLS %s %s", index, endpoint, domain)
+	dialer := trace.NewDialerWithoutResolver(logger)
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		ol.Stop(err)
+		return false
+	}
+	defer conn.Close()
+	thx := trace.NewTLSHandshakerStdlib(logger)
+	config := &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		RootCAs:    netxlite.NewDefaultCertPool(),
+		ServerName: domain,
+	}
+	_, _, err = thx.Handshake(ctx, conn, config)
+	ol.Stop(err)
+	tk.addTLSHandshake(trace.FirstTLSHandshakeOrNil())
+	return err == nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with ooniprobe
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	IsAnomaly bool `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	tk, ok := measurement.TestKeys.(*TestKeys)
+	if !ok {
+		return nil, errors.New("invalid test keys type")
+	}
+	return SummaryKeys{IsAnomaly: len(tk.Inconsistent) > 0}, nil
+}
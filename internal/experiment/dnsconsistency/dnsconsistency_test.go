@@ -0,0 +1,325 @@
+package dnsconsistency
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/model/mocks"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("domains", func(t *testing.T) {
+		if len((Config{}).domains()) != 4 {
+			t.Fatal("invalid default domains list")
+		}
+		if out := (Config{Domains: []string{"a.com", "b.com"}}).domains(); len(out) != 2 {
+			t.Fatal("unexpected domains", out)
+		}
+	})
+
+	t.Run("resolvers", func(t *testing.T) {
+		sess := &mockable.Session{MockableResolverIP: "130.192.91.211"}
+		out := (Config{}).resolvers(sess)
+		if len(out) != len(defaultResolvers)+1 || out[len(out)-1] != "udp://130.192.91.211:53" {
+			t.Fatal("unexpected resolvers", out)
+		}
+		sess = &mockable.Session{MockableResolverIP: "127.0.0.2"}
+		if out := (Config{}).resolvers(sess); len(out) != len(defaultResolvers) {
+			t.Fatal("unexpected resolvers", out)
+		}
+		if out := (Config{Resolvers: []string{"system:///"}}).resolvers(sess); len(out) != 1 {
+			t.Fatal("unexpected resolvers", out)
+		}
+	})
+}
+
+func TestParseResolverURL(t *testing.T) {
+	var testCases = []struct {
+		input   string
+		scheme  string
+		address string
+		err     error
+	}{
+		{input: "system:///", scheme: "system"},
+		{input: "udp://8.8.8.8", scheme: "udp", address: "8.8.8.8:53"},
+		{input: "udp://[::1]:5353", scheme: "udp", address: "[::1]:5353"},
+		{input: "dot://dns.google", scheme: "dot", address: "dns.google:853"},
+		{input: "https://dns.google/dns-query", scheme: "https", address: "https://dns.google/dns-query"},
+		{input: "\t", err: errInvalidResolverURL},
+		{input: "udp://", err: errInvalidResolverURL},
+		{input: "https:///dns-query", err: errInvalidResolverURL},
+		{input: "tcp://8.8.8.8:53", err: errInvalidResolverURL},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			ri, err := parseResolverURL(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatal("unexpected error", err)
+			}
+			if err != nil {
+				return
+			}
+			if ri.Scheme != tc.scheme || ri.Address != tc.address || ri.URL != tc.input {
+				t.Fatal("unexpected resolver info", ri)
+			}
+		})
+	}
+}
+
+// newResolverReturning returns a resolver returning the given addrs or error.
+func newResolverReturning(addrs []string, err error) model.Resolver {
+	return &mocks.Resolver{
+		MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+			return addrs, err
+		},
+		MockCloseIdleConnections: func() {},
+	}
+}
+
+// fakeNetwork describes the behavior of the mocked network.
+type fakeNetwork struct {
+	// system, udp, doh and dot are the resolvers to use.
+	system, udp, doh, dot model.Resolver
+
+	// tlsErr is the error returned by the TLS handshake.
+	tlsErr error
+}
+
+// newTrace creates a new trace using the fake network.
+func (fn *fakeNetwork) newTrace(index int64, zeroTime time.Time) *measurexlite.Trace {
+	trace := measurexlite.NewTrace(index, zeroTime)
+	trace.NewStdlibResolverFn = func(logger model.Logger) model.Resolver {
+		return fn.system
+	}
+	trace.NewParallelUDPResolverFn = func(logger model.Logger, dialer model.Dialer, address string) model.Resolver {
+		return fn.udp
+	}
+	trace.NewParallelDNSOverHTTPSResolverFn = func(logger model.Logger, URL string) model.Resolver {
+		return fn.doh
+	}
+	trace.NewParallelDNSOverTLSResolverFn = func(logger model.Logger, dialer model.Dialer, address string) model.Resolver {
+		return fn.dot
+	}
+	trace.NewDialerWithoutResolverFn = func(dl model.DebugLogger) model.Dialer {
+		return &mocks.Dialer{
+			MockDialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return &mocks.Conn{MockClose: func() error { return nil }}, nil
+			},
+		}
+	}
+	trace.NewTLSHandshakerStdlibFn = func(dl model.DebugLogger) model.TLSHandshaker {
+		return &mocks.TLSHandshaker{
+			MockHandshake: func(ctx context.Context, conn net.Conn, config *tls.Config) (
+				net.Conn, tls.ConnectionState, error) {
+				return conn, tls.ConnectionState{}, fn.tlsErr
+			},
+		}
+	}
+	return trace
+}
+
+// runWithFakeNetwork runs the experiment using the given fake network.
+func runWithFakeNetwork(t *testing.T, fn *fakeNetwork) (*model.Measurement, *TestKeys) {
+	m := &Measurer{
+		config: Config{
+			Domains:   []string{"example.com"},
+			Resolvers: []string{"system:///", "udp://192.0.2.1", "https://dns.google/dns-query", "dot://1.1.1.1"},
+		},
+		newTrace: fn.newTrace,
+	}
+	measurement := &model.Measurement{}
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session:     &mockable.Session{MockableLogger: log.Log},
+	}
+	if err := m.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if len(tk.Domains) != 1 || len(tk.Domains[0].Resolvers) != 4 {
+		t.Fatal("unexpected test keys", tk)
+	}
+	return measurement, tk
+}
+
+// isAnomaly returns the value of the IsAnomaly summary key.
+func isAnomaly(t *testing.T, measurement *model.Measurement) bool {
+	sk, err := (Measurer{}).GetSummaryKeys(measurement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk.(SummaryKeys).IsAnomaly
+}
+
+func TestMeasurerRun(t *testing.T) {
+	t.Run("when all the answers are consistent", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning([]string{"1.1.1.1"}, nil), // valid for domain
+			udp:    newResolverReturning([]string{"8.8.4.4"}, nil), // same ASN
+			doh:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			dot:    newResolverReturning([]string{"8.8.8.8"}, nil),
+		})
+		for _, rr := range tk.Domains[0].Resolvers {
+			if rr.Status != StatusConsistent {
+				t.Fatal("unexpected status", rr.URL, rr.Status)
+			}
+		}
+		if tk.Domains[0].IPInfo["1.1.1.1"].Flags&model.THIPInfoFlagValidForDomain == 0 {
+			t.Fatal("expected 1.1.1.1 to be valid for domain")
+		}
+		if len(tk.TLSHandshakes) != 0 { // mocked handshakers do not emit events
+			t.Fatal("unexpected TLS handshakes")
+		}
+		if isAnomaly(t, measurement) || len(tk.Inconsistent) != 0 {
+			t.Fatal("expected no anomaly")
+		}
+	})
+
+	t.Run("when the system resolver returns a bogon", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning([]string{"127.0.0.1"}, nil),
+			udp:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			doh:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			dot:    newResolverReturning([]string{"8.8.4.4"}, nil),
+		})
+		if tk.Domains[0].Resolvers[0].Status != StatusInconsistent {
+			t.Fatal("unexpected status", tk.Domains[0].Resolvers[0].Status)
+		}
+		if tk.Domains[0].IPInfo["127.0.0.1"].Flags&model.THIPInfoFlagIsBogon == 0 {
+			t.Fatal("expected 127.0.0.1 to be a bogon")
+		}
+		if !isAnomaly(t, measurement) || len(tk.Inconsistent) != 1 {
+			t.Fatal("expected an anomaly")
+		}
+	})
+
+	t.Run("when an address is not valid for the domain", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning([]string{"8.8.8.8"}, nil),
+			udp:    newResolverReturning([]string{"1.1.1.1"}, nil),
+			doh:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			dot:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			tlsErr: errors.New(netxlite.FailureSSLInvalidHostname),
+		})
+		if tk.Domains[0].Resolvers[1].Status != StatusInconsistent {
+			t.Fatal("unexpected status", tk.Domains[0].Resolvers[1].Status)
+		}
+		if !isAnomaly(t, measurement) {
+			t.Fatal("expected an anomaly")
+		}
+	})
+
+	t.Run("when the system resolver fails", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning(nil, errors.New(netxlite.FailureDNSNXDOMAINError)),
+			udp:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			doh:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			dot:    newResolverReturning(nil, errors.New(netxlite.FailureGenericTimeoutError)),
+		})
+		rr := tk.Domains[0].Resolvers[0]
+		if rr.Status != StatusInconsistent || rr.Failure == nil {
+			t.Fatal("unexpected result", rr)
+		}
+		if tk.Domains[0].Resolvers[3].Status != StatusReferenceFailure {
+			t.Fatal("unexpected status", tk.Domains[0].Resolvers[3].Status)
+		}
+		if !isAnomaly(t, measurement) {
+			t.Fatal("expected an anomaly")
+		}
+	})
+
+	t.Run("when a reference resolver fails", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning([]string{"8.8.8.8"}, nil),
+			udp:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			doh:    newResolverReturning(nil, errors.New(netxlite.FailureGenericTimeoutError)),
+			dot:    newResolverReturning([]string{"8.8.8.8"}, nil),
+		})
+		for idx, rr := range tk.Domains[0].Resolvers {
+			expect := StatusConsistent
+			if idx == 2 {
+				expect = StatusReferenceFailure
+			}
+			if rr.Status != expect {
+				t.Fatal("unexpected status", rr.URL, rr.Status)
+			}
+		}
+		if isAnomaly(t, measurement) || len(tk.Inconsistent) != 0 {
+			t.Fatal("expected no anomaly")
+		}
+	})
+
+	t.Run("when all the reference resolvers fail", func(t *testing.T) {
+		measurement, tk := runWithFakeNetwork(t, &fakeNetwork{
+			system: newResolverReturning([]string{"127.0.0.1"}, nil),
+			udp:    newResolverReturning([]string{"8.8.8.8"}, nil),
+			doh:    newResolverReturning(nil, errors.New(netxlite.FailureGenericTimeoutError)),
+			dot:    newResolverReturning(nil, errors.New(netxlite.FailureGenericTimeoutError)),
+		})
+		for _, rr := range tk.Domains[0].Resolvers {
+			expect := StatusUnknown
+			if rr.Reference {
+				expect = StatusReferenceFailure
+			}
+			if rr.Status != expect {
+				t.Fatal("unexpected status", rr.URL, rr.Status)
+			}
+		}
+		if isAnomaly(t, measurement) {
+			t.Fatal("expected no anomaly")
+		}
+	})
+
+	t.Run("without any reference resolver", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{Resolvers: []string{"system:///", "udp://8.8.8.8"}})
+		args := &model.ExperimentArgs{
+			Measurement: &model.Measurement{},
+			Session:     &mockable.Session{MockableLogger: log.Log},
+		}
+		if err := m.Run(context.Background(), args); !errors.Is(err, errNoReferenceResolver) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with an invalid resolver URL", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{Resolvers: []string{"ftp://8.8.8.8"}})
+		args := &model.ExperimentArgs{
+			Measurement: &model.Measurement{},
+			Session:     &mockable.Session{MockableLogger: log.Log},
+		}
+		if err := m.Run(context.Background(), args); !errors.Is(err, errInvalidResolverURL) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestMeasurerMetadata(t *testing.T) {
+	m := NewExperimentMeasurer(Config{})
+	if m.ExperimentName() != "dnsconsistency" {
+		t.Fatal("invalid experiment name")
+	}
+	if m.ExperimentVersion() != "0.1.0" {
+		t.Fatal("invalid experiment version")
+	}
+}
+
+func TestGetSummaryKeysInvalidType(t *testing.T) {
+	measurer := Measurer{}
+	out, err := measurer.GetSummaryKeys(&model.Measurement{TestKeys: make(chan int)})
+	if err == nil || err.Error() != "invalid test keys type" {
+		t.Fatal("not the error we expected", err)
+	}
+	if out != nil {
+		t.Fatal("expected nil output here")
+	}
+}
@@ -0,0 +1,84 @@
+package dnsconsistency
+
+//
+// Parsing resolver URLs and creating resolvers
+//
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+// errInvalidResolverURL indicates that a resolver URL is invalid.
+var errInvalidResolverURL = errors.New("dnsconsistency: invalid resolver URL")
+
+// resolverInfo describes a resolver we should use.
+type resolverInfo struct {
+	// URL is the resolver URL as provided by the user.
+	URL string
+
+	// Scheme is the resolver URL scheme.
+	Scheme string
+
+	// Address is the endpoint for udp and dot, the URL for https.
+	Address string
+}
+
+// isReference returns whether we should use this resolver's answers
+// as the reference for the other resolvers. We only trust encrypted
+// resolvers because they are harder to tamper with.
+func (ri *resolverInfo) isReference() bool {
+	return ri.Scheme == "https" || ri.Scheme == "dot"
+}
+
+// parseResolverURL parses a resolver URL. We support system:///,
+// udp://<host>[:<port>], dot://<host>[:<port>] and https:// URLs.
+func parseResolverURL(value string) (*resolverInfo, error) {
+	URL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidResolverURL, err.Error())
+	}
+	switch URL.Scheme {
+	case "system":
+		return &resolverInfo{URL: value, Scheme: URL.Scheme}, nil
+	case "https":
+		if URL.Host == "" {
+			return nil, fmt.Errorf("%w: missing host: %s", errInvalidResolverURL, value)
+		}
+		return &resolverInfo{URL: value, Scheme: URL.Scheme, Address: value}, nil
+	case "udp", "dot":
+		if URL.Hostname() == "" {
+			return nil, fmt.Errorf("%w: missing host: %s", errInvalidResolverURL, value)
+		}
+		port := URL.Port()
+		if port == "" {
+			port = map[string]string{"udp": "53", "dot": "853"}[URL.Scheme]
+		}
+		address := net.JoinHostPort(URL.Hostname(), port)
+		return &resolverInfo{URL: value, Scheme: URL.Scheme, Address: address}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme: %s", errInvalidResolverURL, value)
+	}
+}
+
+// newResolver creates a trace-aware resolver for this resolverInfo.
+func (ri *resolverInfo) newResolver(trace *measurexlite.Trace, logger model.Logger) model.Resolver {
+	switch ri.Scheme {
+	case "system":
+		return trace.NewStdlibResolver(logger)
+	case "https":
+		return trace.NewParallelDNSOverHTTPSResolver(logger, ri.Address)
+	case "dot":
+		dialer := netxlite.NewDialerWithStdlibResolver(logger)
+		return trace.NewParallelDNSOverTLSResolver(logger, dialer, ri.Address)
+	default:
+		dialer := netxlite.NewDialerWithStdlibResolver(logger)
+		return trace.NewParallelUDPResolver(logger, dialer, ri.Address)
+	}
+}
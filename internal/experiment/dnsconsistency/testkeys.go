@@ -0,0 +1,195 @@
+package dnsconsistency
+
+//
+// Test keys
+//
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// These are the possible values of ResolverResult.Status.
+const (
+	// StatusConsistent indicates that the resolver's answer is consistent
+	// with the answers of the reference resolvers.
+	StatusConsistent = "consistent"
+
+	// StatusInconsistent indicates that the resolver's answer is not
+	// consistent with the answers of the reference resolvers.
+	StatusInconsistent = "inconsistent"
+
+	// StatusUnknown indicates that we cannot say anything because the
+	// reference resolvers did not return any address.
+	StatusUnknown = "unknown"
+
+	// StatusReferenceFailure indicates that this is a reference resolver
+	// and it failed, which is not evidence of interference because we
+	// cannot say whether the domain is consistent with it.
+	StatusReferenceFailure = "reference_failure"
+)
+
+// TestKeys contains the experiment results.
+type TestKeys struct {
+	// Queries contains all the DNS lookups we performed.
+	Queries []*model.ArchivalDNSLookupResult `json:"queries"`
+
+	// TLSHandshakes contains the handshakes we used to check whether
+	// an address is valid for a domain.
+	TLSHandshakes []*model.ArchivalTLSOrQUICHandshakeResult `json:"tls_handshakes"`
+
+	// Domains contains the per-domain results.
+	Domains []*DomainResult `json:"domains"`
+
+	// Inconsistent contains the domains for which at least
+	// one resolver returned an inconsistent answer.
+	Inconsistent []string `json:"inconsistent"`
+
+	// mu provides mutual exclusion
+	mu sync.Mutex
+}
+
+// DomainResult contains the results for a domain.
+type DomainResult struct {
+	// Domain is the domain we resolved.
+	Domain string `json:"domain"`
+
+	// IPInfo maps each resolved address to its ASN and flags. We
+	// use the model.THIPInfoFlagIsBogon and model.THIPInfoFlagValidForDomain
+	// flags, with the same semantics they have for the test helper.
+	IPInfo map[string]*model.THIPInfo `json:"ip_info"`
+
+	// Resolvers contains the results of each resolver.
+	Resolvers []*ResolverResult `json:"resolvers"`
+
+	// Inconsistent indicates that at least one resolver
+	// returned an inconsistent answer.
+	Inconsistent bool `json:"inconsistent"`
+}
+
+// ResolverResult contains the result of resolving a domain with a resolver.
+type ResolverResult struct {
+	// URL is the resolver URL.
+	URL string `json:"url"`
+
+	// Reference indicates whether this is a reference resolver.
+	Reference bool `json:"reference"`
+
+	// Addresses contains the resolved addresses.
+	Addresses []string `json:"addresses"`
+
+	// Failure is the lookup failure or nil.
+	Failure *string `json:"failure"`
+
+	// Status is one of StatusConsistent, StatusInconsistent, StatusUnknown,
+	// and StatusReferenceFailure.
+	Status string `json:"status"`
+}
+
+// NewTestKeys creates new dnsconsistency TestKeys.
+func NewTestKeys() *TestKeys {
+	return &TestKeys{
+		Queries:       []*model.ArchivalDNSLookupResult{},
+		TLSHandshakes: []*model.ArchivalTLSOrQUICHandshakeResult{},
+		Domains:       []*DomainResult{},
+		Inconsistent:  []string{},
+		mu:            sync.Mutex{},
+	}
+}
+
+// addQueries adds []*model.ArchivalDNSLookupResult to the test keys.
+func (tk *TestKeys) addQueries(queries []*model.ArchivalDNSLookupResult) {
+	tk.mu.Lock()
+	tk.Queries = append(tk.Queries, queries...)
+	tk.mu.Unlock()
+}
+
+// addTLSHandshake adds a *model.ArchivalTLSOrQUICHandshakeResult to the test keys.
+func (tk *TestKeys) addTLSHandshake(handshake *model.ArchivalTLSOrQUICHandshakeResult) {
+	if handshake != nil {
+		tk.mu.Lock()
+		tk.TLSHandshakes = append(tk.TLSHandshakes, handshake)
+		tk.mu.Unlock()
+	}
+}
+
+// addDomain adds a *DomainResult to the test keys.
+func (tk *TestKeys) addDomain(dr *DomainResult) {
+	tk.mu.Lock()
+	tk.Domains = append(tk.Domains, dr)
+	if dr.Inconsistent {
+		tk.Inconsistent = append(tk.Inconsistent, dr.Domain)
+	}
+	tk.mu.Unlock()
+}
+
+// references returns the addresses and the ASNs of the reference resolvers.
+func (dr *DomainResult) references() (map[string]bool, map[int64]bool) {
+	refAddrs, refASNs := make(map[string]bool), make(map[int64]bool)
+	for _, rr := range dr.Resolvers {
+		if !rr.Reference {
+			continue
+		}
+		for _, addr := range rr.Addresses {
+			refAddrs[addr] = true
+			if info := dr.IPInfo[addr]; info.Flags&model.THIPInfoFlagIsBogon == 0 && info.ASN != 0 {
+				refASNs[info.ASN] = true
+			}
+		}
+	}
+	return refAddrs, refASNs
+}
+
+// needsTLSCheck returns the addresses that are neither bogons nor consistent
+// with the reference addresses and ASNs, for which we need to check whether
+// they're valid for the domain using TLS.
+func (dr *DomainResult) needsTLSCheck() (out []string) {
+	refAddrs, refASNs := dr.references()
+	if len(refAddrs) <= 0 {
+		return // we cannot say anything in this case
+	}
+	for addr, info := range dr.IPInfo {
+		if info.Flags&model.THIPInfoFlagIsBogon == 0 && !refAddrs[addr] && !refASNs[info.ASN] {
+			out = append(out, addr)
+		}
+	}
+	sort.Strings(out)
+	return
+}
+
+// computeStatus computes the status of each resolver given the
+// reference addresses and the IPInfo for all the addresses.
+func (dr *DomainResult) computeStatus() {
+	refAddrs, refASNs := dr.references()
+	for _, rr := range dr.Resolvers {
+		switch {
+		case rr.Reference && len(rr.Addresses) <= 0:
+			rr.Status = StatusReferenceFailure
+		case len(refAddrs) <= 0:
+			rr.Status = StatusUnknown
+		case dr.isConsistent(rr.Addresses, refAddrs, refASNs):
+			rr.Status = StatusConsistent
+		default:
+			rr.Status = StatusInconsistent
+			dr.Inconsistent = true
+		}
+	}
+}
+
+// isConsistent returns whether at least one address is among the reference
+// addresses, belongs to a reference ASN, or is valid for the domain.
+func (dr *DomainResult) isConsistent(
+	addrs []string, refAddrs map[string]bool, refASNs map[int64]bool) bool {
+	for _, addr := range addrs {
+		info := dr.IPInfo[addr]
+		if refAddrs[addr] || info.Flags&model.THIPInfoFlagValidForDomain != 0 {
+			return true
+		}
+		if info.Flags&model.THIPInfoFlagIsBogon == 0 && refASNs[info.ASN] {
+			return true
+		}
+	}
+	return false
+}
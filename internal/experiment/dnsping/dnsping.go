@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"strings"
 	"sync"
 	"time"
 
@@ -27,8 +26,8 @@ type Config struct {
 	// Delay is the delay between each repetition (in milliseconds).
 	Delay int64 `ooni:"number of milliseconds to wait before sending each ping"`
 
-	// Domains is the space-separated list of domains to measure.
-	Domains string `ooni:"space-separated list of domains to measure"`
+	// Domains is the list of domains to measure.
+	Domains []string `ooni:"list of domains to measure"`
 
 	// Repetitions is the number of repetitions for each ping.
 	Repetitions int64 `ooni:"number of times to repeat the measurement"`
@@ -48,11 +47,11 @@ func (c Config) repetitions() int64 {
 	return 10
 }
 
-func (c Config) domains() string {
-	if c.Domains != "" {
+func (c Config) domains() []string {
+	if len(c.Domains) > 0 {
 		return c.Domains
 	}
-	return "edge-chat.instagram.com example.com"
+	return []string{"edge-chat.instagram.com", "example.com"}
 }
 
 // Measurer performs the measurement.
@@ -104,7 +103,7 @@ func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	}
 	tk := NewTestKeys()
 	measurement.TestKeys = tk
-	domains := strings.Split(m.config.domains(), " ")
+	domains := m.config.domains()
 	wg := new(sync.WaitGroup)
 	wg.Add(len(domains))
 	for _, domain := range domains {
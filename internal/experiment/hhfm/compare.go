@@ -0,0 +1,109 @@
+package hhfm
+
+//
+// Comparing what we sent with what the helper received
+//
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// ByteDiff describes the smallest contiguous region in which the
+// bytes received by the helper differ from the bytes we sent.
+type ByteDiff struct {
+	// Offset is the offset of the region from the beginning.
+	Offset int64 `json:"offset"`
+
+	// Sent contains the bytes we sent in this region.
+	Sent model.ArchivalMaybeBinaryData `json:"sent"`
+
+	// Received contains the bytes the helper received in this region.
+	Received model.ArchivalMaybeBinaryData `json:"received"`
+}
+
+// NewByteDiff returns nil if sent and received are equal and otherwise
+// the region between their common prefix and their common suffix.
+func NewByteDiff(sent, received []byte) *ByteDiff {
+	if bytes.Equal(sent, received) {
+		return nil
+	}
+	prefix := 0
+	for prefix < len(sent) && prefix < len(received) && sent[prefix] == received[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(sent)-prefix && suffix < len(received)-prefix &&
+		sent[len(sent)-1-suffix] == received[len(received)-1-suffix] {
+		suffix++
+	}
+	return &ByteDiff{
+		Offset:   int64(prefix),
+		Sent:     model.ArchivalMaybeBinaryData{Value: string(sent[prefix : len(sent)-suffix])},
+		Received: model.ArchivalMaybeBinaryData{Value: string(received[prefix : len(received)-suffix])},
+	}
+}
+
+// compare fills the tampering fields by comparing the request of the
+// test case, whose serialization is sent, with what the helper received.
+func (t *Tampering) compare(tc *testCase, sent []byte, received *JSONHeaders) {
+	t.RequestLineCapitalization = tc.requestLine != received.RequestLine
+	t.HeaderFieldNumber = len(tc.headers) != len(received.RequestHeaders)
+	t.RequestBytes = !bytes.Equal(sent, received.RequestBytes)
+	sentIndex := indexHeaders(tc.headers)
+	receivedIndex := indexHeaders(received.RequestHeaders)
+	t.HeaderFieldName = len(sentIndex) != len(receivedIndex)
+	for _, key := range commonHeaderNames(tc.headers, sentIndex) {
+		expected := sentIndex[key]
+		got, found := receivedIndex[key]
+		if !found {
+			t.HeaderFieldName = true
+			continue
+		}
+		if expected[0] != got[0] {
+			t.HeaderNameCapitalization = true
+			t.HeaderNameDiff = append(t.HeaderNameDiff, expected[0], got[0])
+		}
+		if expected[1] != got[1] {
+			t.HeaderFieldValue = true
+		}
+	}
+	sentOrder := commonHeaderNames(tc.headers, receivedIndex)
+	receivedOrder := commonHeaderNames(received.RequestHeaders, sentIndex)
+	t.HeaderFieldOrder = strings.Join(sentOrder, "\n") != strings.Join(receivedOrder, "\n")
+}
+
+// indexHeaders maps the lowercase name of each header to the first
+// header with such a name, skipping malformed entries.
+func indexHeaders(headers [][]string) map[string][]string {
+	out := make(map[string][]string)
+	for _, hdr := range headers {
+		if len(hdr) != 2 {
+			continue
+		}
+		key := strings.ToLower(hdr[0])
+		if _, found := out[key]; !found {
+			out[key] = hdr
+		}
+	}
+	return out
+}
+
+// commonHeaderNames returns, in order, the lowercase names of the
+// headers whose name also appears in the other index.
+func commonHeaderNames(headers [][]string, other map[string][]string) (out []string) {
+	seen := make(map[string]bool)
+	for _, hdr := range headers {
+		if len(hdr) != 2 {
+			continue
+		}
+		key := strings.ToLower(hdr[0])
+		if _, found := other[key]; found && !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return
+}
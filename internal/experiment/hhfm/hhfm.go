@@ -1,5 +1,11 @@
 // Package hhfm contains the HTTP Header Field Manipulation network experiment.
 //
+// In addition to the request sent by the MK implementation, we run a suite
+// of test cases sending byte-exact HTTP/1.1 requests with different header
+// orderings and capitalizations. When the TLS helper is configured, we also
+// send such requests over TLS along with HTTP/2 requests, and we compare the
+// helper's certificate chain with a pinned SHA-256 to detect interception.
+//
 // See https://github.com/ooni/spec/blob/master/nettests/ts-006-header-field-manipulation.md
 package hhfm
 
@@ -23,21 +29,33 @@ import (
 
 const (
 	testName    = "http_header_field_manipulation"
-	testVersion = "0.2.0"
+	testVersion = "0.3.0"
 )
 
 // Config contains the experiment config.
-type Config struct{}
+type Config struct {
+	// TLSHelper is the OPTIONAL endpoint (i.e., host and port) of the
+	// https-return-json-headers helper. When set, we also run the TLS
+	// test cases, including the HTTP/2 ones.
+	TLSHelper string `ooni:"endpoint of the TLS helper returning JSON headers"`
+
+	// TLSHelperSHA256 is the OPTIONAL SHA-256 of the TLS helper's certificate
+	// or of its CA. When set, we flag TLS interception if no certificate in the
+	// chain we receive matches it. We accept hex with or without colons.
+	TLSHelperSHA256 string `ooni:"SHA-256 of the TLS helper's certificate or CA"`
+}
 
 // TestKeys contains the experiment test keys.
 //
 // Here we are emitting for the same set of test keys that are
-// produced by the MK implementation.
+// produced by the MK implementation, plus the Suite, which contains
+// the results of the test cases sending byte-exact requests.
 type TestKeys struct {
 	Agent      string                `json:"agent"`
 	Failure    *string               `json:"failure"`
 	Requests   []tracex.RequestEntry `json:"requests"`
 	SOCKSProxy *string               `json:"socksproxy"`
+	Suite      []*CaseResult         `json:"suite"`
 	Tampering  Tampering             `json:"tampering"`
 }
 
@@ -52,6 +70,24 @@ type Tampering struct {
 	HeaderNameDiff            []string `json:"header_name_diff"`
 	RequestLineCapitalization bool     `json:"request_line_capitalization"`
 	Total                     bool     `json:"total"`
+
+	// The following fields are only set by the test cases of the suite.
+	HeaderFieldOrder bool `json:"header_field_order,omitempty"`
+	RequestBytes     bool `json:"request_bytes,omitempty"`
+	TLSInterception  bool `json:"tls_interception,omitempty"`
+}
+
+// IsAnomaly returns whether we detected any form of tampering.
+func (t *Tampering) IsAnomaly() bool {
+	return (t.HeaderFieldName ||
+		t.HeaderFieldNumber ||
+		t.HeaderFieldValue ||
+		t.HeaderNameCapitalization ||
+		t.RequestLineCapitalization ||
+		t.Total ||
+		t.HeaderFieldOrder ||
+		t.RequestBytes ||
+		t.TLSInterception)
 }
 
 // NewExperimentMeasurer creates a new ExperimentMeasurer.
@@ -68,7 +104,8 @@ type Transport interface {
 // Measurer performs the measurement.
 type Measurer struct {
 	Config    Config
-	Transport Transport // for testing
+	Dialer    model.SimpleDialer // for testing
+	Transport Transport          // for testing
 }
 
 // ExperimentName implements ExperimentMeasurer.ExperiExperimentName.
@@ -87,6 +124,12 @@ var (
 
 	// ErrInvalidHelperType is emitted when the helper type is invalid.
 	ErrInvalidHelperType = errors.New("invalid helper type")
+
+	// ErrInvalidTLSHelper is emitted when the TLS helper is not a valid endpoint.
+	ErrInvalidTLSHelper = errors.New("invalid TLS helper endpoint")
+
+	// ErrInvalidTLSHelperSHA256 is emitted when the TLS helper SHA-256 is invalid.
+	ErrInvalidTLSHelperSHA256 = errors.New("invalid TLS helper SHA-256")
 )
 
 // Run implements ExperimentMeasurer.Run.
@@ -94,13 +137,22 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	callbacks := args.Callbacks
 	measurement := args.Measurement
 	sess := args.Session
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
 	urlgetter.RegisterExtensions(measurement)
 	tk := new(TestKeys)
 	tk.Agent = "agent"
+	tk.Suite = []*CaseResult{}
 	tk.Tampering.HeaderNameDiff = []string{}
 	measurement.TestKeys = tk
+	// validate the config
+	if m.Config.TLSHelper != "" {
+		if _, _, err := net.SplitHostPort(m.Config.TLSHelper); err != nil {
+			return ErrInvalidTLSHelper
+		}
+	}
+	pin, err := normalizePin(m.Config.TLSHelperSHA256)
+	if err != nil {
+		return err
+	}
 	// parse helper
 	const helperName = "http-return-json-headers"
 	helpers, ok := sess.GetTestHelpersByName(helperName)
@@ -114,11 +166,40 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	measurement.TestHelpers = map[string]interface{}{
 		"backend": helper.Address,
 	}
-	// prepare request
-	req, err := http.NewRequest("GeT", helper.Address, nil)
+	if m.Config.TLSHelper != "" {
+		measurement.TestHelpers["tls_backend"] = m.Config.TLSHelper
+	}
+	// run the legacy test case, which fills the top-level keys
+	received, err := m.runLegacy(ctx, callbacks, helper.Address, tk)
 	if err != nil {
 		return err
 	}
+	// run the test cases sending byte-exact requests
+	plainURL := helper.Address
+	if received != nil && received.RequestBytes == nil {
+		// The cleartext test cases would only fail, so we skip them
+		sess.Logger().Infof("hhfm: skipping the cleartext test cases: %s",
+			errHelperWithoutRequestBytes.Error())
+		plainURL = ""
+	}
+	tk.Suite = m.runSuite(ctx, callbacks, sess.Logger(),
+		measurement.MeasurementStartTimeSaved, plainURL, pin)
+	return nil
+}
+
+// runLegacy runs the test case originally implemented by MK, which sends
+// a single request using net/http and fills the top-level test keys. We
+// return the helper's response or nil if we could not obtain it, and we
+// only return an error if we cannot create the request.
+func (m Measurer) runLegacy(ctx context.Context, callbacks model.ExperimentCallbacks,
+	helperURL string, tk *TestKeys) (*JSONHeaders, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	// prepare request
+	req, err := http.NewRequest("GeT", helperURL, nil)
+	if err != nil {
+		return nil, err
+	}
 	headers := map[string]string{
 		randx.ChangeCapitalization("Accept"):          model.HTTPHeaderAccept,
 		randx.ChangeCapitalization("Accept-Charset"):  "ISO-8859-1,utf-8;q=0.7,*;q=0.3",
@@ -154,7 +235,7 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 		tk.Failure = tracex.NewFailure(err)
 		tk.Requests[0].Failure = tk.Failure
 		tk.Tampering.Total = true
-		return nil // measurement did not fail, we measured tampering
+		return nil, nil // measurement did not fail, we measured tampering
 	}
 	// fill tk.Requests[0].Response
 	tk.Requests[0].Response = NewHTTPResponse(resp, data)
@@ -164,11 +245,11 @@ func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 		failure := netxlite.FailureJSONParseError
 		tk.Failure = &failure
 		tk.Tampering.Total = true
-		return nil // measurement did not fail, we measured tampering
+		return nil, nil // measurement did not fail, we measured tampering
 	}
 	// fill tampering
 	tk.FillTampering(req, jsonHeaders, headers)
-	return nil
+	return &jsonHeaders, nil
 }
 
 // Transact performs the HTTP transaction which consists of performing
@@ -292,10 +373,15 @@ func NewHTTPResponse(resp *http.Response, data []byte) (out tracex.HTTPResponse)
 
 // JSONHeaders contains the response from the backend server.
 //
-// Here we're defining only the fields we care about.
+// Here we're defining only the fields we care about. Note that
+// RequestHeaders and RequestBytes are only returned by recent
+// helpers, which echo the headers in order and the raw bytes of
+// the request (for HTTP/2, the HPACK-encoded header block).
 type JSONHeaders struct {
-	HeadersDict map[string][]string `json:"headers_dict"`
-	RequestLine string              `json:"request_line"`
+	HeadersDict    map[string][]string `json:"headers_dict"`
+	RequestBytes   []byte              `json:"request_bytes"`
+	RequestHeaders [][]string          `json:"request_headers"`
+	RequestLine    string              `json:"request_line"`
 }
 
 // Dialer is a dialer that performs headers transformations.
@@ -359,11 +445,9 @@ func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, e
 	if !ok {
 		return sk, errors.New("invalid test keys type")
 	}
-	sk.IsAnomaly = (tk.Tampering.HeaderFieldName ||
-		tk.Tampering.HeaderFieldNumber ||
-		tk.Tampering.HeaderFieldValue ||
-		tk.Tampering.HeaderNameCapitalization ||
-		tk.Tampering.RequestLineCapitalization ||
-		tk.Tampering.Total)
+	sk.IsAnomaly = tk.Tampering.IsAnomaly()
+	for _, result := range tk.Suite {
+		sk.IsAnomaly = sk.IsAnomaly || result.Tampering.IsAnomaly()
+	}
 	return sk, nil
 }
@@ -26,7 +26,7 @@ func TestNewExperimentMeasurer(t *testing.T) {
 	if measurer.ExperimentName() != "http_header_field_manipulation" {
 		t.Fatal("unexpected name")
 	}
-	if measurer.ExperimentVersion() != "0.2.0" {
+	if measurer.ExperimentVersion() != "0.3.0" {
 		t.Fatal("unexpected version")
 	}
 }
@@ -318,6 +318,7 @@ func TestNoActualHelpersInList(t *testing.T) {
 	measurer := hhfm.NewExperimentMeasurer(hhfm.Config{})
 	ctx := context.Background()
 	sess := &mockable.Session{
+		MockableLogger: log.Log,
 		MockableTestHelpers: map[string][]model.OOAPIService{
 			"http-return-json-headers": nil,
 		},
@@ -373,6 +374,7 @@ func TestWrongTestHelperType(t *testing.T) {
 	measurer := hhfm.NewExperimentMeasurer(hhfm.Config{})
 	ctx := context.Background()
 	sess := &mockable.Session{
+		MockableLogger: log.Log,
 		MockableTestHelpers: map[string][]model.OOAPIService{
 			"http-return-json-headers": {{
 				Address: "http://127.0.0.1",
@@ -431,6 +433,7 @@ func TestNewRequestFailure(t *testing.T) {
 	measurer := hhfm.NewExperimentMeasurer(hhfm.Config{})
 	ctx := context.Background()
 	sess := &mockable.Session{
+		MockableLogger: log.Log,
 		MockableTestHelpers: map[string][]model.OOAPIService{
 			"http-return-json-headers": {{
 				Address: "http://127.0.0.1\t\t\t", // invalid
@@ -493,6 +496,7 @@ func TestInvalidJSONBody(t *testing.T) {
 	measurer := hhfm.NewExperimentMeasurer(hhfm.Config{})
 	ctx := context.Background()
 	sess := &mockable.Session{
+		MockableLogger: log.Log,
 		MockableTestHelpers: map[string][]model.OOAPIService{
 			"http-return-json-headers": {{
 				Address: server.URL,
@@ -0,0 +1,589 @@
+package hhfm
+
+//
+// Test cases sending byte-exact HTTP/1.1 requests and HTTP/2 frames
+//
+// Unlike the legacy test case, which uses net/http and restores the
+// capitalization of the headers on the wire, here we serialize the
+// request ourselves. So, we know exactly which bytes we sent and we
+// can compare them with the bytes echoed back by the helper.
+//
+// Because the legacy helper does not echo the request bytes, we skip
+// the cleartext test cases when the response of the legacy test case
+// shows that the helper lacks such support.
+//
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/bytecounter"
+	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/randx"
+	"github.com/ooni/probe-cli/v3/internal/tracex"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	// protocolHTTP1 is the ALPN identifier of HTTP/1.1.
+	protocolHTTP1 = "http/1.1"
+
+	// protocolHTTP2 is the ALPN identifier of HTTP/2.
+	protocolHTTP2 = "h2"
+
+	// caseTimeout is the maximum duration of each test case.
+	caseTimeout = 10 * time.Second
+
+	// maxBodySize is the maximum size of the helper's response body.
+	maxBodySize = 1 << 20
+)
+
+var (
+	// errUnexpectedALPN indicates that the TLS handshake did not negotiate
+	// the protocol we asked for, e.g., because a proxy downgraded HTTP/2.
+	errUnexpectedALPN = errors.New("hhfm: unexpected ALPN")
+
+	// errHTTP2GoAway indicates that the server sent GOAWAY before answering.
+	errHTTP2GoAway = errors.New("hhfm: received GOAWAY")
+
+	// errHTTP2StreamReset indicates that the server reset our stream.
+	errHTTP2StreamReset = errors.New("hhfm: stream reset")
+
+	// errHelperWithoutRequestBytes indicates that the helper does not echo
+	// the request bytes, which typically means it is an old helper.
+	errHelperWithoutRequestBytes = errors.New("hhfm: helper does not echo request bytes")
+)
+
+// CaseResult is the result of a test case of the suite.
+type CaseResult struct {
+	// Name is the name of the test case.
+	Name string `json:"name"`
+
+	// Address is the helper's endpoint.
+	Address string `json:"address"`
+
+	// Protocol is either "http/1.1" or "h2".
+	Protocol string `json:"protocol"`
+
+	// Failure is the failure that occurred, if any.
+	Failure *string `json:"failure"`
+
+	// NetworkEvents contains the I/O events of the test case.
+	NetworkEvents []*model.ArchivalNetworkEvent `json:"network_events"`
+
+	// TCPConnect contains the result of connecting to the helper.
+	TCPConnect *model.ArchivalTCPConnectResult `json:"tcp_connect"`
+
+	// TLSHandshake contains the TLS handshake result or is nil for
+	// cleartext test cases and when we could not connect.
+	TLSHandshake *model.ArchivalTLSOrQUICHandshakeResult `json:"tls_handshake"`
+
+	// TLS contains TLS information or is nil for cleartext test cases.
+	TLS *TLSResult `json:"tls"`
+
+	// RequestLine is the request line we sent (HTTP/1.1 only).
+	RequestLine string `json:"request_line,omitempty"`
+
+	// RequestHeaders contains the headers we sent, in order. For HTTP/2
+	// this list also contains the pseudo-headers.
+	RequestHeaders [][]string `json:"request_headers"`
+
+	// RequestBytes contains the bytes we sent. For HTTP/2, this is the
+	// HPACK-encoded header block.
+	RequestBytes model.ArchivalMaybeBinaryData `json:"request_bytes"`
+
+	// ReceivedRequestLine is the request line received by the helper.
+	ReceivedRequestLine string `json:"received_request_line,omitempty"`
+
+	// ReceivedHeaders contains the headers received by the helper, in order.
+	ReceivedHeaders [][]string `json:"received_headers"`
+
+	// ReceivedBytes contains the bytes received by the helper.
+	ReceivedBytes model.ArchivalMaybeBinaryData `json:"received_bytes"`
+
+	// ByteDiff is nil when the helper received exactly the bytes we sent
+	// and otherwise describes where the bytes differ.
+	ByteDiff *ByteDiff `json:"byte_diff"`
+
+	// Tampering describes the tampering we detected.
+	Tampering Tampering `json:"tampering"`
+}
+
+// TLSResult contains information about the TLS connection with the helper.
+type TLSResult struct {
+	// NegotiatedProtocol is the protocol negotiated using ALPN.
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+
+	// PeerCertificates contains the DER certificates sent by the peer.
+	PeerCertificates []model.ArchivalMaybeBinaryData `json:"peer_certificates"`
+
+	// PeerCertificatesSHA256 contains the hex SHA-256 of each certificate.
+	PeerCertificatesSHA256 []string `json:"peer_certificates_sha256"`
+
+	// PinnedSHA256 is the pinned SHA-256, if any, we compared the chain with.
+	PinnedSHA256 string `json:"pinned_sha256"`
+}
+
+// NewTLSResult creates a new TLSResult given the peer certificates
+// and the normalized pinned SHA-256 (which MAY be empty).
+func NewTLSResult(alpn string, chain []*x509.Certificate, pin string) *TLSResult {
+	out := &TLSResult{
+		NegotiatedProtocol:     alpn,
+		PeerCertificates:       []model.ArchivalMaybeBinaryData{},
+		PeerCertificatesSHA256: []string{},
+		PinnedSHA256:           pin,
+	}
+	for _, cert := range chain {
+		digest := sha256.Sum256(cert.Raw)
+		out.PeerCertificates = append(out.PeerCertificates,
+			model.ArchivalMaybeBinaryData{Value: string(cert.Raw)})
+		out.PeerCertificatesSHA256 = append(out.PeerCertificatesSHA256,
+			hex.EncodeToString(digest[:]))
+	}
+	return out
+}
+
+// Intercepted returns true when we have a pin and no certificate in
+// the chain matches it, which means that someone (e.g., a transparent
+// proxy) is terminating TLS in place of the helper. We accept a match
+// with any certificate so one can pin either the helper or its CA.
+func (r *TLSResult) Intercepted() bool {
+	if r.PinnedSHA256 == "" {
+		return false
+	}
+	for _, digest := range r.PeerCertificatesSHA256 {
+		if digest == r.PinnedSHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizePin converts a SHA-256 pin, possibly in the colon separated
+// format printed by openssl, to lowercase hex without separators.
+func normalizePin(pin string) (string, error) {
+	pin = strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+	if pin == "" {
+		return "", nil
+	}
+	data, err := hex.DecodeString(pin)
+	if err != nil || len(data) != sha256.Size {
+		return "", ErrInvalidTLSHelperSHA256
+	}
+	return pin, nil
+}
+
+// testCase is a test case of the suite.
+type testCase struct {
+	// name is the test case name.
+	name string
+
+	// protocol is either protocolHTTP1 or protocolHTTP2.
+	protocol string
+
+	// tls indicates whether we should use TLS.
+	tls bool
+
+	// requestLine is the request line (HTTP/1.1 only).
+	requestLine string
+
+	// headers contains the headers to send, in order.
+	headers [][]string
+}
+
+// newTestCases returns the test cases of the suite. The plainHost argument,
+// when not empty, is the Host of the cleartext helper, which we need for running
+// the cleartext test cases, and tlsHost, when not empty, is the Host of the TLS
+// helper, which we need for running the TLS test cases.
+func newTestCases(plainHost, tlsHost string) (out []*testCase) {
+	if plainHost != "" {
+		out = append(out, newHTTP1TestCases("http1", false, plainHost)...)
+	}
+	if tlsHost == "" {
+		return
+	}
+	out = append(out, newHTTP1TestCases("https1", true, tlsHost)...)
+	pseudo := [][]string{
+		{":method", "GET"},
+		{":scheme", "https"},
+		{":authority", tlsHost},
+		{":path", "/"},
+	}
+	// Note that HTTP/2 requires lowercase header names, hence we
+	// only change the order of the headers for HTTP/2.
+	regular := lowercaseHeaders(baseHeaders("")[1:])
+	out = append(out, &testCase{
+		name:     "h2_canonical",
+		protocol: protocolHTTP2,
+		tls:      true,
+		headers:  concatHeaders(pseudo, regular),
+	}, &testCase{
+		name:     "h2_reversed",
+		protocol: protocolHTTP2,
+		tls:      true,
+		headers:  concatHeaders(pseudo, reverseHeaders(regular)),
+	}, &testCase{
+		name:     "h2_random",
+		protocol: protocolHTTP2,
+		tls:      true,
+		headers:  concatHeaders(shuffleHeaders(pseudo), shuffleHeaders(regular)),
+	})
+	return
+}
+
+// newHTTP1TestCases returns the HTTP/1.1 test cases.
+func newHTTP1TestCases(prefix string, tls bool, host string) []*testCase {
+	headers := baseHeaders(host)
+	return []*testCase{{
+		name:        prefix + "_canonical",
+		protocol:    protocolHTTP1,
+		tls:         tls,
+		requestLine: "GET / HTTP/1.1",
+		headers:     headers,
+	}, {
+		name:        prefix + "_lowercase_reversed",
+		protocol:    protocolHTTP1,
+		tls:         tls,
+		requestLine: "GET / HTTP/1.1",
+		headers:     lowercaseHeaders(reverseHeaders(headers)),
+	}, {
+		name:        prefix + "_random",
+		protocol:    protocolHTTP1,
+		tls:         tls,
+		requestLine: randx.ChangeCapitalization("GET") + " / HTTP/1.1",
+		headers:     randomCaseHeaders(shuffleHeaders(headers)),
+	}}
+}
+
+// baseHeaders returns the headers we send in canonical order and capitalization.
+func baseHeaders(host string) [][]string {
+	return [][]string{
+		{"Host", host},
+		{"User-Agent", model.HTTPHeaderUserAgent},
+		{"Accept", model.HTTPHeaderAccept},
+		{"Accept-Language", model.HTTPHeaderAcceptLanguage},
+		{"Accept-Charset", "ISO-8859-1,utf-8;q=0.7,*;q=0.3"},
+		{"Accept-Encoding", "identity"},
+	}
+}
+
+// concatHeaders returns a new list containing the given lists of headers.
+func concatHeaders(lists ...[][]string) (out [][]string) {
+	for _, list := range lists {
+		out = append(out, list...)
+	}
+	return
+}
+
+// reverseHeaders returns a copy of headers in reverse order.
+func reverseHeaders(headers [][]string) (out [][]string) {
+	for idx := len(headers) - 1; idx >= 0; idx-- {
+		out = append(out, headers[idx])
+	}
+	return
+}
+
+// shuffleHeaders returns a copy of headers in random order. Note that
+// this function uses a non-cryptographically-secure generator.
+func shuffleHeaders(headers [][]string) [][]string {
+	out := concatHeaders(headers)
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rnd.Shuffle(len(out), func(i, j int) {
+		out[i], out[j] = out[j], out[i]
+	})
+	return out
+}
+
+// lowercaseHeaders returns a copy of headers with lowercase names.
+func lowercaseHeaders(headers [][]string) (out [][]string) {
+	for _, hdr := range headers {
+		out = append(out, []string{strings.ToLower(hdr[0]), hdr[1]})
+	}
+	return
+}
+
+// randomCaseHeaders returns a copy of headers with randomly capitalized names.
+func randomCaseHeaders(headers [][]string) (out [][]string) {
+	for _, hdr := range headers {
+		out = append(out, []string{randx.ChangeCapitalization(hdr[0]), hdr[1]})
+	}
+	return
+}
+
+// helperEndpoint returns the TCP endpoint and the Host of the helper URL
+// or empty strings when the helper URL is empty.
+func helperEndpoint(helperURL string) (string, string, error) {
+	if helperURL == "" {
+		return "", "", nil
+	}
+	URL, err := url.Parse(helperURL)
+	if err != nil {
+		return "", "", err
+	}
+	port := URL.Port()
+	if port == "" {
+		port = "80"
+	}
+	return net.JoinHostPort(URL.Hostname(), port), URL.Host, nil
+}
+
+// runSuite runs all the test cases of the suite. When helperURL is
+// empty, we skip the cleartext test cases.
+func (m Measurer) runSuite(ctx context.Context, callbacks model.ExperimentCallbacks,
+	logger model.Logger, zeroTime time.Time, helperURL, pin string) (out []*CaseResult) {
+	out = []*CaseResult{}
+	plainAddress, plainHost, err := helperEndpoint(helperURL)
+	if err != nil {
+		return // cannot happen because we already used the URL
+	}
+	cases := newTestCases(plainHost, m.Config.TLSHelper)
+	for idx, tc := range cases {
+		address := plainAddress
+		if tc.tls {
+			address = m.Config.TLSHelper
+		}
+		callbacks.OnProgress(float64(idx)/float64(len(cases)),
+			fmt.Sprintf("running %s using %s...", tc.name, address))
+		trace := measurexlite.NewTrace(int64(idx+1), zeroTime)
+		out = append(out, m.runCase(ctx, trace, logger, tc, address, pin))
+	}
+	return
+}
+
+// runCase runs the given test case using the helper at the given address
+// and collects the observations of the test case using the given trace.
+func (m Measurer) runCase(ctx context.Context, trace *measurexlite.Trace,
+	logger model.Logger, tc *testCase, address, pin string) *CaseResult {
+	ctx, cancel := context.WithTimeout(ctx, caseTimeout)
+	defer cancel()
+	out := &CaseResult{
+		Name:            tc.name,
+		Address:         address,
+		Protocol:        tc.protocol,
+		RequestLine:     tc.requestLine,
+		RequestHeaders:  tc.headers,
+		ReceivedHeaders: [][]string{},
+		Tampering:       Tampering{HeaderNameDiff: []string{}},
+	}
+	ol := measurexlite.NewOperationLogger(logger, "HHFM %s %s", tc.name, address)
+	data, err := m.roundTrip(ctx, trace, logger, tc, address, pin, out)
+	ol.Stop(err)
+	out.NetworkEvents = trace.NetworkEvents()
+	out.TCPConnect = trace.FirstTCPConnectOrNil()
+	out.TLSHandshake = trace.FirstTLSHandshakeOrNil()
+	if err != nil {
+		out.Failure = tracex.NewFailure(netxlite.NewTopLevelGenericErrWrapper(err))
+		out.Tampering.Total = true
+		return out
+	}
+	var received JSONHeaders
+	if err := json.Unmarshal(data, &received); err != nil {
+		failure := netxlite.FailureJSONParseError
+		out.Failure = &failure
+		out.Tampering.Total = true
+		return out
+	}
+	if received.RequestBytes == nil {
+		// Not tampering: the helper cannot tell us what it received.
+		out.Failure = tracex.NewFailure(errHelperWithoutRequestBytes)
+		return out
+	}
+	out.ReceivedRequestLine = received.RequestLine
+	if received.RequestHeaders != nil {
+		out.ReceivedHeaders = received.RequestHeaders
+	}
+	out.ReceivedBytes = model.ArchivalMaybeBinaryData{Value: string(received.RequestBytes)}
+	sent := []byte(out.RequestBytes.Value)
+	out.ByteDiff = NewByteDiff(sent, received.RequestBytes)
+	out.Tampering.compare(tc, sent, &received)
+	return out
+}
+
+// roundTrip sends the request of the test case and returns the body
+// of the response. It also fills the TLS-related fields of out.
+func (m Measurer) roundTrip(ctx context.Context, trace *measurexlite.Trace, logger model.Logger,
+	tc *testCase, address, pin string, out *CaseResult) ([]byte, error) {
+	var child model.SimpleDialer = m.Dialer
+	if child == nil {
+		child = bytecounter.WrapWithContextAwareDialer(trace.NewDialerWithoutResolver(logger))
+	}
+	conn, err := child.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn = trace.MaybeWrapNetConn(conn)
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if tc.tls {
+		tconn, err := handshake(ctx, trace, logger, conn, tc.protocol, address, pin, out)
+		if err != nil {
+			return nil, err
+		}
+		conn = tconn
+	}
+	if tc.protocol == protocolHTTP2 {
+		return roundTripHTTP2(conn, tc, out)
+	}
+	return roundTripHTTP1(conn, tc, out)
+}
+
+// handshake performs the TLS handshake and checks whether the helper's
+// certificate chain matches the pinned SHA-256.
+func handshake(ctx context.Context, trace *measurexlite.Trace, logger model.Logger,
+	conn net.Conn, protocol, address, pin string, out *CaseResult) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	thx := trace.NewTLSHandshakerStdlib(logger)
+	tconn, state, err := thx.Handshake(ctx, conn, &tls.Config{
+		ServerName: host,
+		NextProtos: []string{protocol},
+		// The helper may use a self-signed certificate, so we do not
+		// verify the chain and we instead compare it with the pin.
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.TLS = NewTLSResult(state.NegotiatedProtocol, state.PeerCertificates, pin)
+	out.Tampering.TLSInterception = out.TLS.Intercepted()
+	if protocol == protocolHTTP2 && state.NegotiatedProtocol != protocolHTTP2 {
+		return nil, fmt.Errorf("%w: %q", errUnexpectedALPN, state.NegotiatedProtocol)
+	}
+	return tconn, nil
+}
+
+// SerializeHTTP1 returns the byte-exact HTTP/1.1 request with the given
+// request line and headers. We do not send any body.
+func SerializeHTTP1(requestLine string, headers [][]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(requestLine + "\r\n")
+	for _, hdr := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", hdr[0], hdr[1])
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// roundTripHTTP1 sends a raw HTTP/1.1 request and returns the response body.
+func roundTripHTTP1(conn net.Conn, tc *testCase, out *CaseResult) ([]byte, error) {
+	raw := SerializeHTTP1(tc.requestLine, tc.headers)
+	out.RequestBytes = model.ArchivalMaybeBinaryData{Value: string(raw)}
+	if _, err := conn.Write(raw); err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, urlgetter.ErrHTTPRequestFailed
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+}
+
+// roundTripHTTP2 sends the request using HTTP/2 frames on stream 1
+// and returns the response body.
+func roundTripHTTP2(conn net.Conn, tc *testCase, out *CaseResult) ([]byte, error) {
+	var block bytes.Buffer
+	encoder := hpack.NewEncoder(&block)
+	for _, hdr := range tc.headers {
+		// Writing into a bytes.Buffer cannot fail
+		encoder.WriteField(hpack.HeaderField{Name: hdr[0], Value: hdr[1]})
+	}
+	out.RequestBytes = model.ArchivalMaybeBinaryData{Value: block.String()}
+	if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+		return nil, err
+	}
+	framer := http2.NewFramer(conn, conn)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := framer.WriteSettings(); err != nil {
+		return nil, err
+	}
+	err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: block.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readHTTP2Response(framer, 1)
+}
+
+// readHTTP2Response reads frames until the response on the given stream
+// is complete. We never send WINDOW_UPDATE, so the initial flow control
+// window bounds the size of the body we accept.
+func readHTTP2Response(framer *http2.Framer, streamID uint32) ([]byte, error) {
+	var body bytes.Buffer
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch frame := frame.(type) {
+		case *http2.SettingsFrame:
+			if !frame.IsAck() {
+				if err := framer.WriteSettingsAck(); err != nil {
+					return nil, err
+				}
+			}
+		case *http2.PingFrame:
+			if !frame.IsAck() {
+				if err := framer.WritePing(true, frame.Data); err != nil {
+					return nil, err
+				}
+			}
+		case *http2.GoAwayFrame:
+			if frame.LastStreamID < streamID {
+				return nil, fmt.Errorf("%w: %s", errHTTP2GoAway, frame.ErrCode)
+			}
+		case *http2.RSTStreamFrame:
+			if frame.StreamID == streamID {
+				return nil, fmt.Errorf("%w: %s", errHTTP2StreamReset, frame.ErrCode)
+			}
+		case *http2.MetaHeadersFrame:
+			if frame.StreamID != streamID {
+				continue
+			}
+			if frame.PseudoValue("status") != "200" {
+				return nil, urlgetter.ErrHTTPRequestFailed
+			}
+			if frame.StreamEnded() {
+				return body.Bytes(), nil
+			}
+		case *http2.DataFrame:
+			if frame.StreamID != streamID {
+				continue
+			}
+			body.Write(frame.Data())
+			if frame.StreamEnded() {
+				return body.Bytes(), nil
+			}
+		}
+	}
+}
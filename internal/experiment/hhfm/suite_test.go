@@ -0,0 +1,498 @@
+package hhfm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/measurexlite"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// startRawHelper starts a helper that behaves like http-return-json-headers
+// after transforming the raw request with the given function.
+func startRawHelper(t *testing.T, transform func([]byte) []byte) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveRawHelper(conn, transform)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// serveRawHelper serves a single request for startRawHelper.
+func serveRawHelper(conn net.Conn, transform func([]byte) []byte) {
+	defer conn.Close()
+	var raw bytes.Buffer
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		raw.WriteString(line)
+		if line == "\r\n" {
+			break
+		}
+	}
+	data := transform(raw.Bytes())
+	lines := strings.Split(strings.TrimSuffix(string(data), "\r\n\r\n"), "\r\n")
+	resp := &JSONHeaders{
+		HeadersDict:    map[string][]string{},
+		RequestBytes:   data,
+		RequestHeaders: [][]string{},
+		RequestLine:    lines[0],
+	}
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ": ")
+		resp.HeadersDict[name] = append(resp.HeadersDict[name], value)
+		resp.RequestHeaders = append(resp.RequestHeaders, []string{name, value})
+	}
+	body, _ := json.Marshal(resp)
+	fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// runCase runs the given cleartext test case using the given measurer.
+func runCase(m Measurer, tc *testCase, address string) *CaseResult {
+	trace := measurexlite.NewTrace(1, time.Now())
+	return m.runCase(context.Background(), trace, log.Log, tc, address, "")
+}
+
+func TestRunCase(t *testing.T) {
+	tc := &testCase{
+		name:        "http1_canonical",
+		protocol:    protocolHTTP1,
+		requestLine: "GET / HTTP/1.1",
+		headers:     baseHeaders("example.com"),
+	}
+
+	t.Run("without tampering", func(t *testing.T) {
+		address := startRawHelper(t, func(b []byte) []byte { return b })
+		result := runCase(Measurer{}, tc, address)
+		if result.Failure != nil {
+			t.Fatal("unexpected failure", *result.Failure)
+		}
+		if result.Tampering.IsAnomaly() || result.ByteDiff != nil {
+			t.Fatalf("unexpected tampering: %+v", result.Tampering)
+		}
+		if result.RequestBytes.Value != result.ReceivedBytes.Value {
+			t.Fatal("unexpected received bytes", result.ReceivedBytes.Value)
+		}
+		if result.TCPConnect == nil || result.TCPConnect.Status.Failure != nil {
+			t.Fatalf("unexpected TCP connect: %+v", result.TCPConnect)
+		}
+		if len(result.NetworkEvents) <= 0 || result.TLSHandshake != nil {
+			t.Fatal("unexpected network events or TLS handshake")
+		}
+	})
+
+	t.Run("with a middlebox lowercasing the User-Agent", func(t *testing.T) {
+		address := startRawHelper(t, func(b []byte) []byte {
+			return bytes.Replace(b, []byte("User-Agent"), []byte("user-agent"), 1)
+		})
+		result := runCase(Measurer{}, tc, address)
+		if result.Failure != nil {
+			t.Fatal("unexpected failure", *result.Failure)
+		}
+		if !result.Tampering.HeaderNameCapitalization || !result.Tampering.RequestBytes {
+			t.Fatalf("unexpected tampering: %+v", result.Tampering)
+		}
+		if result.Tampering.HeaderFieldOrder || result.Tampering.HeaderFieldValue {
+			t.Fatalf("unexpected tampering: %+v", result.Tampering)
+		}
+		if result.ByteDiff == nil || result.ByteDiff.Sent.Value != "User-A" ||
+			result.ByteDiff.Received.Value != "user-a" {
+			t.Fatalf("unexpected byte diff: %+v", result.ByteDiff)
+		}
+	})
+
+	t.Run("with a helper that does not echo the request bytes", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			bufio.NewReader(conn).ReadString('\n')
+			body := `{"request_line":"GET / HTTP/1.1"}`
+			fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		}()
+		result := runCase(Measurer{}, tc, listener.Addr().String())
+		if result.Failure == nil || !strings.HasSuffix(*result.Failure, errHelperWithoutRequestBytes.Error()) {
+			t.Fatal("unexpected failure", result.Failure)
+		}
+		if result.Tampering.IsAnomaly() {
+			t.Fatalf("unexpected tampering: %+v", result.Tampering)
+		}
+	})
+
+	t.Run("with a dial failure", func(t *testing.T) {
+		expected := errors.New("mocked error")
+		m := Measurer{Dialer: failingDialer{err: expected}}
+		result := runCase(m, tc, "127.0.0.1:80")
+		if result.Failure == nil || !strings.HasSuffix(*result.Failure, "mocked error") {
+			t.Fatal("unexpected failure", result.Failure)
+		}
+		if !result.Tampering.Total {
+			t.Fatal("expected total tampering")
+		}
+	})
+}
+
+// failingDialer is a model.SimpleDialer returning an error.
+type failingDialer struct {
+	err error
+}
+
+func (d failingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, d.err
+}
+
+func TestTamperingCompare(t *testing.T) {
+	tc := &testCase{
+		requestLine: "GET / HTTP/1.1",
+		headers:     [][]string{{"Host", "example.com"}, {"Accept", "*/*"}, {"User-Agent", "x"}},
+	}
+	sent := SerializeHTTP1(tc.requestLine, tc.headers)
+
+	type testcase struct {
+		name     string
+		line     string
+		headers  [][]string
+		expected Tampering
+	}
+	cases := []testcase{{
+		name:     "with no changes",
+		line:     "GET / HTTP/1.1",
+		headers:  [][]string{{"Host", "example.com"}, {"Accept", "*/*"}, {"User-Agent", "x"}},
+		expected: Tampering{},
+	}, {
+		name:    "with a different request line",
+		line:    "get / HTTP/1.1",
+		headers: [][]string{{"Host", "example.com"}, {"Accept", "*/*"}, {"User-Agent", "x"}},
+		expected: Tampering{
+			RequestLineCapitalization: true,
+			RequestBytes:              true,
+		},
+	}, {
+		name:    "with a different order",
+		line:    "GET / HTTP/1.1",
+		headers: [][]string{{"Accept", "*/*"}, {"Host", "example.com"}, {"User-Agent", "x"}},
+		expected: Tampering{
+			HeaderFieldOrder: true,
+			RequestBytes:     true,
+		},
+	}, {
+		name:    "with a removed and an added header",
+		line:    "GET / HTTP/1.1",
+		headers: [][]string{{"Host", "example.com"}, {"Via", "proxy"}, {"User-Agent", "x"}},
+		expected: Tampering{
+			HeaderFieldName: true,
+			RequestBytes:    true,
+		},
+	}, {
+		name:    "with an added header",
+		line:    "GET / HTTP/1.1",
+		headers: [][]string{{"Host", "example.com"}, {"Accept", "*/*"}, {"User-Agent", "x"}, {"Via", "proxy"}},
+		expected: Tampering{
+			HeaderFieldName:   true,
+			HeaderFieldNumber: true,
+			RequestBytes:      true,
+		},
+	}, {
+		name:    "with different capitalization and values",
+		line:    "GET / HTTP/1.1",
+		headers: [][]string{{"host", "example.com"}, {"Accept", "text/html"}, {"User-Agent", "x"}},
+		expected: Tampering{
+			HeaderFieldValue:         true,
+			HeaderNameCapitalization: true,
+			HeaderNameDiff:           []string{"Host", "host"},
+			RequestBytes:             true,
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			received := &JSONHeaders{
+				RequestBytes:   SerializeHTTP1(c.line, c.headers),
+				RequestHeaders: c.headers,
+				RequestLine:    c.line,
+			}
+			var tampering Tampering
+			tampering.compare(tc, sent, received)
+			expected, got := fmt.Sprintf("%+v", c.expected), fmt.Sprintf("%+v", tampering)
+			if expected != got {
+				t.Fatalf("expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestNewByteDiff(t *testing.T) {
+	type testcase struct {
+		name     string
+		sent     string
+		received string
+		expected *ByteDiff
+	}
+	cases := []testcase{{
+		name:     "with equal bytes",
+		sent:     "GET / HTTP/1.1\r\n",
+		received: "GET / HTTP/1.1\r\n",
+		expected: nil,
+	}, {
+		name:     "with a changed byte",
+		sent:     "GET / HTTP/1.1\r\n",
+		received: "GeT / HTTP/1.1\r\n",
+		expected: &ByteDiff{
+			Offset:   1,
+			Sent:     model.ArchivalMaybeBinaryData{Value: "E"},
+			Received: model.ArchivalMaybeBinaryData{Value: "e"},
+		},
+	}, {
+		name:     "with added bytes",
+		sent:     "Host: a\r\n\r\n",
+		received: "Host: a\r\nVia: b\r\n\r\n",
+		expected: &ByteDiff{
+			Offset:   9,
+			Sent:     model.ArchivalMaybeBinaryData{Value: ""},
+			Received: model.ArchivalMaybeBinaryData{Value: "Via: b\r\n"},
+		},
+	}, {
+		name:     "with removed bytes",
+		sent:     "aaaa",
+		received: "aa",
+		expected: &ByteDiff{
+			Offset:   2,
+			Sent:     model.ArchivalMaybeBinaryData{Value: "aa"},
+			Received: model.ArchivalMaybeBinaryData{Value: ""},
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := NewByteDiff([]byte(c.sent), []byte(c.received))
+			if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizePin(t *testing.T) {
+	digest := sha256.Sum256([]byte("antani"))
+	pin := hex.EncodeToString(digest[:])
+
+	t.Run("with an empty pin", func(t *testing.T) {
+		got, err := normalizePin("")
+		if err != nil || got != "" {
+			t.Fatal("unexpected result", got, err)
+		}
+	})
+
+	t.Run("with an uppercase pin with colons", func(t *testing.T) {
+		var parts []string
+		for idx := 0; idx < len(pin); idx += 2 {
+			parts = append(parts, strings.ToUpper(pin[idx:idx+2]))
+		}
+		got, err := normalizePin(strings.Join(parts, ":"))
+		if err != nil || got != pin {
+			t.Fatal("unexpected result", got, err)
+		}
+	})
+
+	t.Run("with an invalid pin", func(t *testing.T) {
+		if _, err := normalizePin(pin[:10]); !errors.Is(err, ErrInvalidTLSHelperSHA256) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestTLSResultIntercepted(t *testing.T) {
+	chain := []*x509.Certificate{{Raw: []byte("leaf")}, {Raw: []byte("ca")}}
+	digest := sha256.Sum256([]byte("ca"))
+
+	t.Run("without a pin", func(t *testing.T) {
+		if NewTLSResult("h2", chain, "").Intercepted() {
+			t.Fatal("expected no interception")
+		}
+	})
+
+	t.Run("with a pin matching a certificate in the chain", func(t *testing.T) {
+		result := NewTLSResult("h2", chain, hex.EncodeToString(digest[:]))
+		if result.Intercepted() {
+			t.Fatal("expected no interception")
+		}
+		if len(result.PeerCertificates) != 2 || result.PeerCertificates[1].Value != "ca" {
+			t.Fatal("unexpected peer certificates", result.PeerCertificates)
+		}
+	})
+
+	t.Run("with a pin not matching any certificate", func(t *testing.T) {
+		if !NewTLSResult("h2", chain, strings.Repeat("ab", sha256.Size)).Intercepted() {
+			t.Fatal("expected interception")
+		}
+	})
+}
+
+func TestNewTestCases(t *testing.T) {
+	t.Run("without any helper", func(t *testing.T) {
+		if cases := newTestCases("", ""); len(cases) != 0 {
+			t.Fatal("unexpected number of test cases", len(cases))
+		}
+	})
+
+	t.Run("without the cleartext helper", func(t *testing.T) {
+		cases := newTestCases("", "example.org:443")
+		if len(cases) != 6 {
+			t.Fatal("unexpected number of test cases", len(cases))
+		}
+		for _, tc := range cases {
+			if !tc.tls {
+				t.Fatal("unexpected test case", tc.name)
+			}
+		}
+	})
+
+	t.Run("without the TLS helper", func(t *testing.T) {
+		cases := newTestCases("example.com", "")
+		if len(cases) != 3 {
+			t.Fatal("unexpected number of test cases", len(cases))
+		}
+		for _, tc := range cases {
+			if tc.tls || tc.protocol != protocolHTTP1 {
+				t.Fatal("unexpected test case", tc.name)
+			}
+		}
+		reversed := cases[1].headers
+		if reversed[0][0] != "accept-encoding" || reversed[len(reversed)-1][0] != "host" {
+			t.Fatal("unexpected headers", reversed)
+		}
+	})
+
+	t.Run("with the TLS helper", func(t *testing.T) {
+		cases := newTestCases("example.com", "example.org:443")
+		if len(cases) != 9 {
+			t.Fatal("unexpected number of test cases", len(cases))
+		}
+		for _, tc := range cases[6:] {
+			if !tc.tls || tc.protocol != protocolHTTP2 {
+				t.Fatal("unexpected test case", tc.name)
+			}
+			index := indexHeaders(tc.headers)
+			if index[":authority"][1] != "example.org:443" {
+				t.Fatal("unexpected :authority", tc.headers)
+			}
+			for idx, hdr := range tc.headers {
+				if strings.ToLower(hdr[0]) != hdr[0] {
+					t.Fatal("unexpected header name", hdr[0])
+				}
+				if isPseudo := strings.HasPrefix(hdr[0], ":"); isPseudo != (idx < 4) {
+					t.Fatal("pseudo-headers must come first", tc.headers)
+				}
+			}
+		}
+	})
+}
+
+func TestRunWithInvalidConfig(t *testing.T) {
+	run := func(config Config) error {
+		sess := &mockable.Session{MockableLogger: log.Log}
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(log.Log),
+			Measurement: new(model.Measurement),
+			Session:     sess,
+		}
+		return NewExperimentMeasurer(config).Run(context.Background(), args)
+	}
+
+	t.Run("with an invalid TLS helper", func(t *testing.T) {
+		if err := run(Config{TLSHelper: "example.org"}); !errors.Is(err, ErrInvalidTLSHelper) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("with an invalid TLS helper SHA-256", func(t *testing.T) {
+		err := run(Config{TLSHelperSHA256: "antani"})
+		if !errors.Is(err, ErrInvalidTLSHelperSHA256) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestRunSuiteWithInvalidURL(t *testing.T) {
+	results := Measurer{}.runSuite(context.Background(),
+		model.NewPrinterCallbacks(log.Log), log.Log, time.Now(), "\t", "")
+	if len(results) != 0 {
+		t.Fatal("expected no results")
+	}
+}
+
+func TestRunSkipsCleartextCasesWithOldHelper(t *testing.T) {
+	run := func(t *testing.T, helperURL string) *TestKeys {
+		sess := &mockable.Session{
+			MockableLogger: log.Log,
+			MockableTestHelpers: map[string][]model.OOAPIService{
+				"http-return-json-headers": {{Address: helperURL, Type: "legacy"}},
+			},
+		}
+		measurement := new(model.Measurement)
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(log.Log),
+			Measurement: measurement,
+			Session:     sess,
+		}
+		if err := NewExperimentMeasurer(Config{}).Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		return measurement.TestKeys.(*TestKeys)
+	}
+
+	t.Run("with a helper echoing the request bytes", func(t *testing.T) {
+		address := startRawHelper(t, func(b []byte) []byte { return b })
+		tk := run(t, "http://"+address)
+		if len(tk.Suite) != 3 {
+			t.Fatal("unexpected number of test cases", len(tk.Suite))
+		}
+	})
+
+	t.Run("with a helper not echoing the request bytes", func(t *testing.T) {
+		srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"request_line":"GET / HTTP/1.1","headers_dict":{}}`))
+		}))
+		defer srvr.Close()
+		tk := run(t, srvr.URL)
+		if tk.Failure != nil {
+			t.Fatal("unexpected failure", *tk.Failure)
+		}
+		if len(tk.Suite) != 0 {
+			t.Fatal("expected to skip the cleartext test cases", len(tk.Suite))
+		}
+	})
+}
@@ -255,6 +255,16 @@ type RunMethodConfig struct {
 	RequestLine string
 }
 
+// helperEndpoint returns the endpoint to connect to. The helper address
+// is usually just an IP address, in which case we use port 80, but we
+// also accept an explicit port (e.g., when using a local helper).
+func helperEndpoint(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, "80")
+}
+
 // RunMethod runs the specific method using the given config and context
 func RunMethod(ctx context.Context, config RunMethodConfig) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -270,11 +280,12 @@ func RunMethod(ctx context.Context, config RunMethodConfig) {
 		ContextByteCounting: true,
 		Logger:              config.Logger,
 	})
-	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(config.Address, "80"))
+	conn, err := dialer.DialContext(ctx, "tcp", helperEndpoint(config.Address))
 	if err != nil {
 		result.Err = err
 		return
 	}
+	defer conn.Close()
 	deadline := time.Now().Add(timeout)
 	if err := conn.SetDeadline(deadline); err != nil {
 		result.Err = err
@@ -54,6 +54,10 @@ func (mgr downloadManager) reduceErr(err error) error {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return nil
 	}
+	// The server closes the connection normally when it is done.
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		return nil
+	}
 	return err
 }
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/humanize"
@@ -15,15 +16,22 @@ import (
 
 const (
 	testName    = "ndt"
-	testVersion = "0.10.0"
+	testVersion = "0.11.0"
 )
 
 // Config contains the experiment settings
 type Config struct {
+	// Server is the OPTIONAL ndt7 server URL (e.g., wss://ndt.example.com)
+	// to use instead of asking the locate service for a server.
+	Server string `ooni:"ndt7 server URL (ws:// or wss://) to use instead of locate"`
+
 	noDownload bool
 	noUpload   bool
 }
 
+// errInvalidServerURL indicates that the configured server URL is invalid.
+var errInvalidServerURL = errors.New("ndt7: invalid server URL")
+
 // Summary is the measurement summary
 type Summary struct {
 	AvgRTT         float64 `json:"avg_rtt"`         // Average RTT [ms]
@@ -76,6 +84,9 @@ type Measurer struct {
 
 func (m *Measurer) discover(
 	ctx context.Context, sess model.ExperimentSession) (mlablocatev2.NDT7Result, error) {
+	if m.config.Server != "" {
+		return m.customServer()
+	}
 	httpClient := netxlite.NewHTTPClientStdlib(sess.Logger())
 	defer httpClient.CloseIdleConnections()
 	client := mlablocatev2.NewClient(httpClient, sess.Logger(), sess.UserAgent())
@@ -86,6 +97,19 @@ func (m *Measurer) discover(
 	return out[0], nil // same as with locate services v1
 }
 
+// customServer returns the locate result for the configured server.
+func (m *Measurer) customServer() (mlablocatev2.NDT7Result, error) {
+	URL, err := url.Parse(m.config.Server)
+	if err != nil || (URL.Scheme != "ws" && URL.Scheme != "wss") || URL.Host == "" {
+		return mlablocatev2.NDT7Result{}, errInvalidServerURL
+	}
+	return mlablocatev2.NDT7Result{
+		Hostname:       URL.Hostname(),
+		WSSDownloadURL: URL.JoinPath(DownloadPath).String(),
+		WSSUploadURL:   URL.JoinPath(UploadPath).String(),
+	}, nil
+}
+
 // ExperimentName implements ExperimentMeasurer.ExperiExperimentName.
 func (m *Measurer) ExperimentName() string {
 	return testName
@@ -218,6 +242,9 @@ func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	tk.Protocol = 7
 	measurement.TestKeys = tk
 	locateResult, err := m.discover(ctx, sess)
+	if errors.Is(err, errInvalidServerURL) {
+		return err // this is a configuration error
+	}
 	if err != nil {
 		tk.Failure = failureFromError(err)
 		return nil // we still want to submit this measurement
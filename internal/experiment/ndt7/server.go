@@ -0,0 +1,217 @@
+package ndt7
+
+//
+// Server implementation
+//
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+const (
+	// SecWebSocketProtocol is the WebSocket subprotocol used by ndt7.
+	SecWebSocketProtocol = "net.measurementlab.ndt.v7"
+
+	// DownloadPath is the URL path used by the download subtest.
+	DownloadPath = "/ndt/v7/download"
+
+	// UploadPath is the URL path used by the upload subtest.
+	UploadPath = "/ndt/v7/upload"
+
+	// serverWriteGracePeriod is the extra time we allow for writing
+	// after the runtime is over, so that we can close cleanly.
+	serverWriteGracePeriod = 2 * time.Second
+)
+
+// Handler is an http.Handler implementing a minimal ndt7 server, which
+// you can use for testing or for running your own server.
+//
+// The server implements the download and the upload subtests, sends
+// measurement messages containing the application level measurements
+// and, on Linux, a subset of the TCP_INFO metrics.
+type Handler struct {
+	// Logger is the MANDATORY logger to use.
+	Logger model.Logger
+
+	// MaxRuntime is the OPTIONAL maximum runtime of each subtest. If not
+	// set, we use the same maximum runtime used by the client.
+	MaxRuntime time.Duration
+
+	// indexer generates unique indexes for logging.
+	indexer atomic.Int64
+}
+
+var _ http.Handler = &Handler{}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var test TestKind
+	switch req.URL.Path {
+	case DownloadPath:
+		test = TestDownload
+	case UploadPath:
+		test = TestUpload
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if req.Header.Get("Sec-WebSocket-Protocol") != SecWebSocketProtocol {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  paramMaxBufferSize,
+		Subprotocols:    []string{SecWebSocketProtocol},
+		WriteBufferSize: paramMaxBufferSize,
+	}
+	conn, err := upgrader.Upgrade(w, req, nil) // writes the response on error
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	index := h.indexer.Add(1)
+	h.Logger.Infof("ndt7: <#%d> %s from %s", index, test, req.RemoteAddr)
+	srv := &serverConn{
+		conn:       conn,
+		maxRuntime: h.maxRuntime(),
+		start:      time.Now(),
+		test:       test,
+	}
+	switch test {
+	case TestDownload:
+		err = srv.download()
+	default:
+		err = srv.upload()
+	}
+	h.Logger.Infof("ndt7: <#%d> %s done: %+v", index, test, err)
+}
+
+func (h *Handler) maxRuntime() time.Duration {
+	if h.MaxRuntime > 0 {
+		return h.MaxRuntime
+	}
+	return paramMaxRuntime
+}
+
+// serverConn is the server side of an ndt7 subtest.
+type serverConn struct {
+	conn       *websocket.Conn
+	maxRuntime time.Duration
+	start      time.Time
+	test       TestKind
+}
+
+// download sends binary messages of increasing size to the client for
+// the configured runtime, interleaved with measurement messages.
+func (sc *serverConn) download() error {
+	deadline := sc.start.Add(sc.maxRuntime)
+	if err := sc.conn.SetWriteDeadline(deadline.Add(serverWriteGracePeriod)); err != nil {
+		return err
+	}
+	// goroutine that just reads and discards all incoming websockets messages,
+	// which is needed to process the control messages sent by the client
+	go func() {
+		for {
+			if _, _, err := sc.conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	var total int64
+	size := paramMinMessageSize
+	message, err := newMessage(size)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(paramMeasureInterval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		if err := sc.conn.WritePreparedMessage(message); err != nil {
+			return err
+		}
+		total += int64(size)
+		select {
+		case <-ticker.C:
+			if err := sc.conn.WriteJSON(sc.measurement(total)); err != nil {
+				return err
+			}
+		default:
+			// NOTHING
+		}
+		if size >= paramMaxScaledMessageSize || int64(size) >= (total/paramFractionForScaling) {
+			continue
+		}
+		size <<= 1
+		if message, err = newMessage(size); err != nil {
+			return err
+		}
+	}
+	return sc.close()
+}
+
+// upload reads the messages sent by the client for the configured
+// runtime and periodically sends measurement messages.
+func (sc *serverConn) upload() error {
+	deadline := sc.start.Add(sc.maxRuntime)
+	if err := sc.conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	if err := sc.conn.SetWriteDeadline(deadline.Add(serverWriteGracePeriod)); err != nil {
+		return err
+	}
+	sc.conn.SetReadLimit(paramMaxMessageSize)
+	var total int64
+	ticker := time.NewTicker(paramMeasureInterval)
+	defer ticker.Stop()
+	for {
+		_, reader, err := sc.conn.NextReader()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return sc.close() // we reached the maximum runtime
+			}
+			return err
+		}
+		count, _ := io.Copy(io.Discard, reader) // on error, NextReader will fail
+		total += count
+		select {
+		case <-ticker.C:
+			if err := sc.conn.WriteJSON(sc.measurement(total)); err != nil {
+				return err
+			}
+		default:
+			// NOTHING
+		}
+	}
+}
+
+// close sends a normal closure control message to the client.
+func (sc *serverConn) close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	return sc.conn.WriteControl(
+		websocket.CloseMessage, msg, time.Now().Add(serverWriteGracePeriod))
+}
+
+// measurement returns the measurement message to send to the client.
+func (sc *serverConn) measurement(total int64) *Measurement {
+	elapsed := int64(time.Since(sc.start) / time.Microsecond)
+	return &Measurement{
+		AppInfo: &AppInfo{
+			ElapsedTime: elapsed,
+			NumBytes:    total,
+		},
+		ConnectionInfo: &ConnectionInfo{
+			Client: sc.conn.RemoteAddr().String(),
+			Server: sc.conn.LocalAddr().String(),
+		},
+		Origin:  OriginServer,
+		Test:    sc.test,
+		TCPInfo: newTCPInfo(sc.conn.UnderlyingConn(), elapsed),
+	}
+}
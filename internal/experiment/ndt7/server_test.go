@@ -0,0 +1,120 @@
+package ndt7
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// newLocalServer starts a local ndt7 server using a short runtime.
+func newLocalServer() *httptest.Server {
+	return httptest.NewServer(&Handler{Logger: log.Log, MaxRuntime: time.Second})
+}
+
+func TestRunWithLocalServer(t *testing.T) {
+	srv := newLocalServer()
+	defer srv.Close()
+	measurement := new(model.Measurement)
+	measurer := NewExperimentMeasurer(Config{
+		Server: strings.Replace(srv.URL, "http://", "ws://", 1),
+	})
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if tk.Failure != nil {
+		t.Fatal("unexpected failure", *tk.Failure)
+	}
+	if tk.Server.Hostname != "127.0.0.1" {
+		t.Fatal("unexpected server", tk.Server.Hostname)
+	}
+	if len(tk.Download) <= 0 || len(tk.Upload) <= 0 {
+		t.Fatal("expected to see some measurements")
+	}
+	if tk.Summary.Download <= 0 || tk.Summary.Upload <= 0 {
+		t.Fatal("expected positive speeds", tk.Summary)
+	}
+	var seenServer bool
+	for _, m := range tk.Download {
+		seenServer = seenServer || m.Origin == OriginServer
+	}
+	if expect := runtime.GOOS == "linux"; seenServer != expect {
+		t.Fatal("unexpected server measurements presence", seenServer)
+	}
+}
+
+func TestRunWithInvalidServerURL(t *testing.T) {
+	for _, URL := range []string{"\t", "https://127.0.0.1", "ws://"} {
+		measurer := NewExperimentMeasurer(Config{Server: URL})
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(log.Log),
+			Measurement: new(model.Measurement),
+			Session: &mockable.Session{
+				MockableLogger: log.Log,
+			},
+		}
+		err := measurer.Run(context.Background(), args)
+		if !errors.Is(err, errInvalidServerURL) {
+			t.Fatal("not the error we expected", URL, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := newLocalServer()
+	defer srv.Close()
+
+	t.Run("with an unknown path", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/ndt/v7/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+
+	t.Run("without the ndt7 subprotocol", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + DownloadPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+
+	t.Run("without a websocket upgrade", func(t *testing.T) {
+		req, err := http.NewRequest("GET", srv.URL+UploadPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Sec-WebSocket-Protocol", SecWebSocketProtocol)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package ndt7
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// newTCPInfo returns the TCPInfo of the given conn or nil.
+func newTCPInfo(conn net.Conn, elapsed int64) *TCPInfo {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var (
+		info    *unix.TCPInfo
+		infoErr error
+	)
+	err = rc.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil || infoErr != nil {
+		return nil
+	}
+	return &TCPInfo{
+		LinuxTCPInfo: LinuxTCPInfo{
+			State:        info.State,
+			CAState:      info.Ca_state,
+			Retransmits:  info.Retransmits,
+			Probes:       info.Probes,
+			Backoff:      info.Backoff,
+			Options:      info.Options,
+			RTO:          info.Rto,
+			ATO:          info.Ato,
+			SndMSS:       info.Snd_mss,
+			RcvMSS:       info.Rcv_mss,
+			Unacked:      info.Unacked,
+			Sacked:       info.Sacked,
+			Lost:         info.Lost,
+			Retrans:      info.Retrans,
+			Fackets:      info.Fackets,
+			LastDataSent: info.Last_data_sent,
+			LastAckSent:  info.Last_ack_sent,
+			LastDataRecv: info.Last_data_recv,
+			LastAckRecv:  info.Last_ack_recv,
+			PMTU:         info.Pmtu,
+			RcvSsThresh:  info.Rcv_ssthresh,
+			RTT:          info.Rtt,
+			RTTVar:       info.Rttvar,
+			SndSsThresh:  info.Snd_ssthresh,
+			SndCwnd:      info.Snd_cwnd,
+			AdvMSS:       info.Advmss,
+			Reordering:   info.Reordering,
+			RcvRTT:       info.Rcv_rtt,
+			RcvSpace:     info.Rcv_space,
+			TotalRetrans: info.Total_retrans,
+		},
+		ElapsedTime: elapsed,
+	}
+}
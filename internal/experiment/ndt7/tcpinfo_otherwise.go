@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ndt7
+
+import "net"
+
+// newTCPInfo returns nil because we only support TCP_INFO on Linux.
+func newTCPInfo(conn net.Conn, elapsed int64) *TCPInfo {
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package ptreachability contains the ptreachability experiment.
+//
+// This experiment takes in input a tor bridge line using any of the
+// pluggable transports supported by the ptx package (obfs4, meek_lite,
+// snowflake, and webtunnel), dials the bridge using the matching
+// ptx.PTDialer and, optionally, bootstraps tor using the bridge. Because
+// dialing meek does not perform any network activity, for meek we also
+// perform a single poll to check whether the meek server is reachable.
+//
+// The test keys use the same schema regardless of the transport.
+package ptreachability
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/bytecounter"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/ptx"
+	"github.com/ooni/probe-cli/v3/internal/torlogs"
+	"github.com/ooni/probe-cli/v3/internal/tracex"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
+)
+
+// testVersion is the experiment version.
+const testVersion = "0.1.0"
+
+// Config contains the experiment config.
+type Config struct {
+	// TorBootstrap indicates whether we should bootstrap tor using the bridge.
+	TorBootstrap bool `ooni:"Bootstrap tor using the bridge after dialing it"`
+}
+
+// TestKeys contains the experiment's result.
+type TestKeys struct {
+	// BootstrapTime contains the tor bootstrap time on success.
+	BootstrapTime float64 `json:"bootstrap_time"`
+
+	// BridgeAddress contains the bridge address.
+	BridgeAddress string `json:"bridge_address"`
+
+	// BridgeFingerprint contains the bridge fingerprint, if any.
+	BridgeFingerprint string `json:"bridge_fingerprint"`
+
+	// DialTime contains the time required to dial the bridge.
+	DialTime float64 `json:"dial_time"`
+
+	// FailedOperation is the operation that failed or nil.
+	FailedOperation *string `json:"failed_operation"`
+
+	// Failure contains the failure string or nil.
+	Failure *string `json:"failure"`
+
+	// Success indicates whether we succeded.
+	Success bool `json:"success"`
+
+	// TorBootstrap indicates whether we tried to bootstrap tor.
+	TorBootstrap bool `json:"tor_bootstrap"`
+
+	// TorLogs contains the bootstrap logs.
+	TorLogs []string `json:"tor_logs"`
+
+	// TorProgress contains the percentage of the maximum progress reached.
+	TorProgress int64 `json:"tor_progress"`
+
+	// TorProgressTag contains the tag of the maximum progress reached.
+	TorProgressTag string `json:"tor_progress_tag"`
+
+	// TorProgressSummary contains the summary of the maximum progress reached.
+	TorProgressSummary string `json:"tor_progress_summary"`
+
+	// TorVersion contains the version of tor (if it's possible to obtain it).
+	TorVersion string `json:"tor_version"`
+
+	// TransportName contains the name of the pluggable transport.
+	TransportName string `json:"transport_name"`
+}
+
+// Operations that may fail.
+const (
+	// PTDialOperation is the operation of dialing the bridge.
+	PTDialOperation = "pt_dial"
+
+	// TorBootstrapOperation is the operation of bootstrapping tor.
+	TorBootstrapOperation = "tor_bootstrap"
+)
+
+// Measurer performs the measurement.
+type Measurer struct {
+	// config contains the experiment settings.
+	config Config
+
+	// mockStartTunnel is an optional function that allows us to override the
+	// default tunnel.Start function used to start a tunnel.
+	mockStartTunnel func(
+		ctx context.Context, config *tunnel.Config) (tunnel.Tunnel, tunnel.DebugInfo, error)
+}
+
+// ExperimentName implements model.ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return "ptreachability"
+}
+
+// ExperimentVersion implements model.ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+const (
+	// dialTimeout is the maximum time for dialing the bridge.
+	dialTimeout = 60 * time.Second
+
+	// bootstrapTimeout is the maximum time for bootstrapping tor.
+	bootstrapTimeout = 300 * time.Second
+)
+
+var (
+	// ErrNoInput indicates that we're missing the bridge line.
+	ErrNoInput = errors.New("ptreachability: missing bridge line")
+)
+
+// Run implements model.ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	callbacks := args.Callbacks
+	measurement := args.Measurement
+	sess := args.Session
+	line := string(measurement.Input)
+	if line == "" {
+		return ErrNoInput
+	}
+	bl, err := ptx.ParseBridgeLine(line)
+	if err != nil {
+		return err
+	}
+	tunnelDir := filepath.Join(sess.TempDir(), "ptreachability")
+	dialer, err := ptx.NewPTDialerFromBridgeLine(line, tunnelDir)
+	if err != nil {
+		return err
+	}
+	tk := &TestKeys{
+		BridgeAddress:     bl.Address,
+		BridgeFingerprint: bl.Fingerprint,
+		TorBootstrap:      m.config.TorBootstrap,
+		TorLogs:           []string{},
+		TransportName:     dialer.Name(),
+	}
+	measurement.TestKeys = tk
+	logger := sess.Logger()
+
+	// 1. dial the bridge using the pluggable transport
+	logger.Infof("ptreachability: dialing %s bridge %s", dialer.Name(), bl.Address)
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	start := time.Now()
+	err = dialBridge(dialCtx, dialer)
+	tk.DialTime = time.Since(start).Seconds()
+	if err != nil {
+		tk.setFailure(PTDialOperation, err)
+		return nil
+	}
+	if !m.config.TorBootstrap {
+		tk.Success = true
+		return nil
+	}
+	callbacks.OnProgress(0.5, "ptreachability: dial succeeded; bootstrapping tor")
+
+	// 2. bootstrap tor using the bridge
+	ptl := &ptx.Listener{
+		ExperimentByteCounter: bytecounter.ContextExperimentByteCounter(ctx),
+		Logger:                logger,
+		PTDialer:              dialer,
+		SessionByteCounter:    bytecounter.ContextSessionByteCounter(ctx),
+	}
+	if err := ptl.Start(); err != nil {
+		return err // fundamental failure: cannot listen locally
+	}
+	defer ptl.Stop()
+	bootstrapCtx, cancel := context.WithTimeout(ctx, bootstrapTimeout)
+	defer cancel()
+	tun, debugInfo, err := m.startTunnel()(bootstrapCtx, &tunnel.Config{
+		Name:      "tor",
+		Session:   sess,
+		TunnelDir: tunnelDir,
+		Logger:    logger,
+		TorArgs: []string{
+			"UseBridges", "1",
+			"ClientTransportPlugin", ptl.AsClientTransportPluginArgument(),
+			"Bridge", bridgeArgument(line, dialer.Name()),
+		},
+	})
+	tk.TorVersion = debugInfo.Version
+	tk.readTorLogs(logger, debugInfo.LogFilePath)
+	if err != nil {
+		tk.setFailure(TorBootstrapOperation, err)
+		return nil
+	}
+	defer tun.Stop()
+	tk.BootstrapTime = tun.BootstrapTime().Seconds()
+	tk.Success = true
+	return nil
+}
+
+// lazyPTDialer is a ptx.PTDialer whose DialContext does not perform any
+// network activity (e.g., ptx.MeekDialer), which therefore needs to explicitly
+// perform a round trip to check whether the bridge is reachable.
+type lazyPTDialer interface {
+	Poll(ctx context.Context) error
+}
+
+// dialBridge dials the bridge using the given dialer and, if the
+// dialer is lazy, also performs a round trip with the bridge.
+func dialBridge(ctx context.Context, dialer ptx.PTDialer) error {
+	conn, err := dialer.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	if ld, ok := dialer.(lazyPTDialer); ok {
+		return ld.Poll(ctx)
+	}
+	return nil
+}
+
+// bridgeArgument returns the value of the tor Bridge option. We use the original
+// bridge line rather than PTDialer.AsBridgeArgument because the snowflake dialer
+// always returns the default snowflake bridge. We also make sure that the
+// transport name matches the name used by the ptx.Listener.
+func bridgeArgument(line string, transportName string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "Bridge") {
+		fields = fields[1:]
+	}
+	fields[0] = transportName // ParseBridgeLine guarantees there are enough fields
+	return strings.Join(fields, " ")
+}
+
+// setFailure records the failure of the given operation.
+func (tk *TestKeys) setFailure(operation string, err error) {
+	// Note: tracex.NewFailure scrubs IP addresses
+	tk.Failure = tracex.NewFailure(err)
+	tk.FailedOperation = &operation
+	tk.Success = false
+}
+
+// readTorLogs attempts to read and include the tor logs into
+// the test keys if this operation is possible.
+func (tk *TestKeys) readTorLogs(logger model.Logger, logFilePath string) {
+	tk.TorLogs = append(tk.TorLogs, torlogs.ReadBootstrapLogsOrWarn(logger, logFilePath)...)
+	if len(tk.TorLogs) <= 0 {
+		return
+	}
+	last := tk.TorLogs[len(tk.TorLogs)-1]
+	bi, err := torlogs.ParseBootstrapLogLine(last)
+	if err != nil {
+		logger.Warnf("ptreachability: cannot parse bootstrap line: %s", last)
+		return
+	}
+	tk.TorProgress = bi.Progress
+	tk.TorProgressTag = bi.Tag
+	tk.TorProgressSummary = bi.Summary
+}
+
+// startTunnel returns the proper function to start a tunnel.
+func (m *Measurer) startTunnel() func(
+	ctx context.Context, config *tunnel.Config) (tunnel.Tunnel, tunnel.DebugInfo, error) {
+	if m.mockStartTunnel != nil {
+		return m.mockStartTunnel
+	}
+	return tunnel.Start
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with ooniprobe
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	IsAnomaly bool `json:"-"`
+}
+
+var (
+	// errInvalidTestKeysType indicates the test keys type is invalid.
+	errInvalidTestKeysType = errors.New("ptreachability: invalid test keys type")
+
+	//errNilTestKeys indicates that the test keys are nil.
+	errNilTestKeys = errors.New("ptreachability: nil test keys")
+)
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m *Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	testkeys, good := measurement.TestKeys.(*TestKeys)
+	if !good {
+		return nil, errInvalidTestKeysType
+	}
+	if testkeys == nil {
+		return nil, errNilTestKeys
+	}
+	return SummaryKeys{IsAnomaly: !testkeys.Success}, nil
+}
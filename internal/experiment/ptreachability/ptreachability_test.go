@@ -0,0 +1,245 @@
+package ptreachability
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/ptx"
+	"github.com/ooni/probe-cli/v3/internal/tunnel"
+	"github.com/ooni/probe-cli/v3/internal/tunnel/mocks"
+)
+
+func TestExperimentNameAndVersion(t *testing.T) {
+	m := NewExperimentMeasurer(Config{})
+	if m.ExperimentName() != "ptreachability" {
+		t.Fatal("invalid experiment name")
+	}
+	if m.ExperimentVersion() != "0.1.0" {
+		t.Fatal("invalid experiment version")
+	}
+}
+
+// newWebTunnelServer returns a webtunnel server that upgrades
+// connections and then closes them immediately.
+func newWebTunnelServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, bufrw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		bufrw.Flush()
+	}))
+}
+
+func newArgs(t *testing.T, input string) *model.ExperimentArgs {
+	return &model.ExperimentArgs{
+		Callbacks: model.NewPrinterCallbacks(model.DiscardLogger),
+		Measurement: &model.Measurement{
+			Input: model.MeasurementTarget(input),
+		},
+		Session: &mockable.Session{
+			MockableLogger:  model.DiscardLogger,
+			MockableTempDir: t.TempDir(),
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Run("without input", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "")
+		if err := m.Run(context.Background(), args); !errors.Is(err, ErrNoInput) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("with an invalid bridge line", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "obfs4")
+		if err := m.Run(context.Background(), args); !errors.Is(err, ptx.ErrInvalidBridgeLine) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("with an unsupported transport", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "obfs3 192.0.2.1:443")
+		if err := m.Run(context.Background(), args); !errors.Is(err, ptx.ErrUnsupportedTransport) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("when dialing fails", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := listener.Addr().String()
+		listener.Close() // nobody is listening here now
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "webtunnel 192.0.2.1:443 url=http://"+address+"/antani")
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if tk.Success || tk.Failure == nil || tk.FailedOperation == nil || *tk.FailedOperation != PTDialOperation {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.TransportName != "webtunnel" || tk.BridgeAddress != "192.0.2.1:443" {
+			t.Fatal("unexpected test keys", tk)
+		}
+		sk, err := m.GetSummaryKeys(args.Measurement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sk.(SummaryKeys).IsAnomaly {
+			t.Fatal("expected an anomaly")
+		}
+	})
+
+	t.Run("when the meek server is unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := listener.Addr().String()
+		listener.Close() // nobody is listening here now
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "meek_lite 192.0.2.1:80 url=http://"+address+"/ front="+address)
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if tk.Success || tk.Failure == nil || tk.FailedOperation == nil || *tk.FailedOperation != PTDialOperation {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.TransportName != "meek_lite" {
+			t.Fatal("unexpected test keys", tk)
+		}
+	})
+
+	t.Run("when the meek server is reachable", func(t *testing.T) {
+		srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srvr.Close()
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "meek_lite 192.0.2.1:80 url="+srvr.URL+"/")
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if !tk.Success || tk.Failure != nil || tk.TransportName != "meek_lite" {
+			t.Fatal("unexpected test keys", tk)
+		}
+	})
+
+	t.Run("when dialing succeeds without bootstrapping tor", func(t *testing.T) {
+		srvr := newWebTunnelServer()
+		defer srvr.Close()
+		m := NewExperimentMeasurer(Config{})
+		args := newArgs(t, "Bridge webtunnel 192.0.2.1:443 AAAA url="+srvr.URL+"/antani")
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if !tk.Success || tk.Failure != nil || tk.TorBootstrap || tk.BridgeFingerprint != "AAAA" {
+			t.Fatal("unexpected test keys", tk)
+		}
+	})
+
+	t.Run("when bootstrapping tor fails", func(t *testing.T) {
+		srvr := newWebTunnelServer()
+		defer srvr.Close()
+		expected := errors.New("mocked error")
+		var torArgs []string
+		m := &Measurer{
+			config: Config{TorBootstrap: true},
+			mockStartTunnel: func(ctx context.Context, config *tunnel.Config) (tunnel.Tunnel, tunnel.DebugInfo, error) {
+				torArgs = config.TorArgs
+				return nil, tunnel.DebugInfo{Name: "tor", Version: "0.4.7.10"}, expected
+			},
+		}
+		args := newArgs(t, "webtunnel 192.0.2.1:443 url="+srvr.URL+"/antani")
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if tk.Success || tk.FailedOperation == nil || *tk.FailedOperation != TorBootstrapOperation {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if tk.TorVersion != "0.4.7.10" {
+			t.Fatal("unexpected tor version", tk.TorVersion)
+		}
+		if len(torArgs) != 6 || torArgs[5] != "webtunnel 192.0.2.1:443 url="+srvr.URL+"/antani" {
+			t.Fatal("unexpected tor args", torArgs)
+		}
+	})
+
+	t.Run("when bootstrapping tor succeeds", func(t *testing.T) {
+		srvr := newWebTunnelServer()
+		defer srvr.Close()
+		m := &Measurer{
+			config: Config{TorBootstrap: true},
+			mockStartTunnel: func(ctx context.Context, config *tunnel.Config) (tunnel.Tunnel, tunnel.DebugInfo, error) {
+				tun := &mocks.Tunnel{
+					MockBootstrapTime: func() time.Duration {
+						return 2 * time.Second
+					},
+					MockStop: func() {},
+				}
+				return tun, tunnel.DebugInfo{Name: "tor"}, nil
+			},
+		}
+		args := newArgs(t, "webtunnel 192.0.2.1:443 url="+srvr.URL+"/antani")
+		if err := m.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		tk := args.Measurement.TestKeys.(*TestKeys)
+		if !tk.Success || !tk.TorBootstrap || tk.BootstrapTime != 2 {
+			t.Fatal("unexpected test keys", tk)
+		}
+		sk, err := m.GetSummaryKeys(args.Measurement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sk.(SummaryKeys).IsAnomaly {
+			t.Fatal("expected no anomaly")
+		}
+	})
+}
+
+func TestBridgeArgument(t *testing.T) {
+	out := bridgeArgument("Bridge meek 192.0.2.18:80 url=https://meek.example.com/", "meek_lite")
+	if out != "meek_lite 192.0.2.18:80 url=https://meek.example.com/" {
+		t.Fatal("unexpected bridge argument", out)
+	}
+}
+
+func TestGetSummaryKeys(t *testing.T) {
+	t.Run("with invalid test keys type", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{})
+		_, err := m.GetSummaryKeys(&model.Measurement{TestKeys: "antani"})
+		if !errors.Is(err, errInvalidTestKeysType) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("with nil test keys", func(t *testing.T) {
+		m := NewExperimentMeasurer(Config{})
+		var tk *TestKeys
+		_, err := m.GetSummaryKeys(&model.Measurement{TestKeys: tk})
+		if !errors.Is(err, errNilTestKeys) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+}
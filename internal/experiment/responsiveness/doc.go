@@ -0,0 +1,26 @@
+// Package responsiveness contains the responsiveness experiment.
+//
+// This experiment implements a simplified version of the IETF responsiveness
+// methodology (draft-ietf-ippm-responsiveness). For each direction (download
+// and upload) we saturate the network using several parallel HTTP/2 load
+// generating connections and, while the network is loaded, we periodically
+// send probes. Foreign probes use a new connection each time and measure the
+// TCP connect, TLS handshake and HTTP request times. Self probes send a small
+// request using one of the load generating connections. We then compute the
+// round trips per minute (RPM) as:
+//
+//	60 / (1/6*(TM(tcp) + TM(tls) + TM(http_f)) + 1/2*TM(http_s))
+//
+// where TM is the 95% trimmed mean of the corresponding times in seconds.
+//
+// Unlike the full methodology, we use a fixed number of load generating
+// connections and a fixed duration for each phase rather than adding
+// connections until the goodput stabilizes.
+//
+// The server configuration uses the same format of Apple's networkQuality
+// tool and NewHandler implements a compatible server, which we use for
+// testing and which you can use for running your own server. Because this
+// experiment saturates the network, there is no default server and the
+// user must set the ConfigURL option to a server willing to receive
+// such load, e.g., `-O ConfigURL=https://example.org/config`.
+package responsiveness
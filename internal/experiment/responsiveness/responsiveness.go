@@ -0,0 +1,435 @@
+package responsiveness
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/bytecounter"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/ooni/probe-cli/v3/internal/tracex"
+)
+
+const (
+	testName    = "responsiveness"
+	testVersion = "0.1.0"
+)
+
+const (
+	// probeInterval is the interval between two consecutive probes.
+	probeInterval = 100 * time.Millisecond
+
+	// probeTimeout is the maximum time we wait for a probe.
+	probeTimeout = 10 * time.Second
+
+	// configTimeout is the maximum time we wait to fetch the configuration.
+	configTimeout = 15 * time.Second
+)
+
+// Config contains the experiment configuration.
+type Config struct {
+	// ConfigURL is the MANDATORY URL of the server configuration. We do not
+	// have a default because we must not generate load towards servers whose
+	// operators have not agreed to receive it.
+	ConfigURL string `ooni:"URL of a networkQuality compatible server configuration"`
+
+	// Connections is the number of load generating connections.
+	Connections int64 `ooni:"number of load generating connections for each phase" ooni_default:"4"`
+
+	// Duration is the duration of each phase in seconds.
+	Duration int64 `ooni:"duration of each phase in seconds" ooni_default:"10"`
+}
+
+func (c Config) connections() int64 {
+	if c.Connections > 0 {
+		return c.Connections
+	}
+	return 4
+}
+
+func (c Config) duration() time.Duration {
+	if c.Duration > 0 {
+		return time.Duration(c.Duration) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+
+	// rootCAs is the OPTIONAL cert pool used for testing.
+	rootCAs *x509.CertPool
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errMissingConfigURL indicates that the ConfigURL option is empty.
+	errMissingConfigURL = errors.New("responsiveness: missing ConfigURL option")
+
+	// errInvalidServerConfig indicates that the server configuration is invalid.
+	errInvalidServerConfig = errors.New("responsiveness: invalid server configuration")
+
+	// errUnexpectedStatusCode indicates that the server returned an unexpected status code.
+	errUnexpectedStatusCode = errors.New("responsiveness: unexpected status code")
+)
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+	callbacks := args.Callbacks
+	measurement := args.Measurement
+	logger := args.Session.Logger()
+	if m.config.ConfigURL == "" {
+		return errMissingConfigURL
+	}
+	tk := &TestKeys{}
+	measurement.TestKeys = tk
+	config, err := m.fetchConfig(ctx, logger)
+	if err != nil {
+		tk.Failure = tracex.NewFailure(err)
+		return nil // we want to submit the measurement
+	}
+	tk.SmallDownloadURL = config.URLs.SmallDownloadURL
+	tk.LargeDownloadURL = config.URLs.LargeDownloadURL
+	tk.UploadURL = config.URLs.UploadURL
+	callbacks.OnProgress(0.1, "responsiveness: measuring download")
+	tk.Download = m.runPhase(ctx, logger, &config.URLs, m.download, config.URLs.LargeDownloadURL)
+	logger.Infof("responsiveness: download: %.1f Mbit/s, %.0f RPM",
+		tk.Download.Throughput/1e06, tk.Download.RPM)
+	callbacks.OnProgress(0.5, "responsiveness: measuring upload")
+	tk.Upload = m.runPhase(ctx, logger, &config.URLs, m.upload, config.URLs.UploadURL)
+	logger.Infof("responsiveness: upload: %.1f Mbit/s, %.0f RPM",
+		tk.Upload.Throughput/1e06, tk.Upload.RPM)
+	tk.RPM = computeRPM(
+		append(append([]*ForeignProbe{}, tk.Download.ForeignProbes...), tk.Upload.ForeignProbes...),
+		append(append([]*SelfProbe{}, tk.Download.SelfProbes...), tk.Upload.SelfProbes...),
+	)
+	callbacks.OnProgress(1, "responsiveness: done")
+	logger.Infof("responsiveness: overall: %.0f RPM", tk.RPM)
+	return nil
+}
+
+// newTransport creates a new HTTP transport using a single connection.
+func (m *Measurer) newTransport(logger model.Logger) model.HTTPTransport {
+	dialer := netxlite.NewDialerWithResolver(logger, netxlite.NewStdlibResolver(logger))
+	// Account the traffic we generate to the session and the experiment
+	// byte counters, like ndt7 and dash do.
+	dialer = bytecounter.WrapWithContextAwareDialer(dialer)
+	tlsDialer := netxlite.NewTLSDialerWithConfig(
+		dialer, netxlite.NewTLSHandshakerStdlib(logger), m.tlsConfig(""))
+	return netxlite.NewHTTPTransport(logger, dialer, tlsDialer)
+}
+
+// tlsConfig returns the TLS config to use.
+func (m *Measurer) tlsConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		RootCAs:    m.rootCAs,
+		ServerName: serverName,
+	}
+}
+
+// fetchConfig fetches and validates the server configuration.
+func (m *Measurer) fetchConfig(ctx context.Context, logger model.Logger) (*serverConfig, error) {
+	ctx, cancel := context.WithTimeout(ctx, configTimeout)
+	defer cancel()
+	txp := m.newTransport(logger)
+	defer txp.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, "GET", m.config.ConfigURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := txp.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errUnexpectedStatusCode
+	}
+	data, err := netxlite.ReadAllContext(ctx, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var config serverConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	for _, URL := range []string{
+		config.URLs.SmallDownloadURL, config.URLs.LargeDownloadURL, config.URLs.UploadURL} {
+		parsed, err := url.Parse(URL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return nil, errInvalidServerConfig
+		}
+	}
+	return &config, nil
+}
+
+// loadFunc generates load using the given transport and URL and
+// adds the number of bytes it transferred to the given counter.
+type loadFunc func(ctx context.Context, txp model.HTTPTransport, URL string, count *int64) error
+
+// runPhase runs a download or upload phase using the given loadFunc.
+func (m *Measurer) runPhase(ctx context.Context, logger model.Logger,
+	urls *serverConfigURLs, load loadFunc, loadURL string) *PhaseResult {
+	connections := m.config.connections()
+	pr := &PhaseResult{Connections: connections}
+	loadCtx, cancel := context.WithTimeout(ctx, m.config.duration())
+	defer cancel()
+	var (
+		count  int64
+		errs   = make([]error, connections)
+		loadWg sync.WaitGroup
+		txps   []model.HTTPTransport
+	)
+	for idx := int64(0); idx < connections; idx++ {
+		txp := m.newTransport(logger)
+		defer txp.CloseIdleConnections()
+		txps = append(txps, txp)
+		loadWg.Add(1)
+		go func(idx int64) {
+			defer loadWg.Done()
+			errs[idx] = load(loadCtx, txp, loadURL, &count)
+		}(idx)
+	}
+	var probeWg sync.WaitGroup
+	t0 := time.Now()
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for idx := 0; loadCtx.Err() == nil; idx++ {
+		select {
+		case <-ticker.C:
+		case <-loadCtx.Done():
+			continue
+		}
+		probeWg.Add(2)
+		go func(txp model.HTTPTransport) {
+			defer probeWg.Done()
+			pr.addSelfProbe(m.selfProbe(ctx, t0, txp, urls.SmallDownloadURL))
+		}(txps[idx%len(txps)])
+		go func() {
+			defer probeWg.Done()
+			pr.addForeignProbe(m.foreignProbe(ctx, t0, logger, urls.SmallDownloadURL))
+		}()
+	}
+	pr.Elapsed = time.Since(t0).Seconds()
+	loadWg.Wait()
+	probeWg.Wait()
+	pr.Bytes = atomic.LoadInt64(&count)
+	pr.Throughput = float64(pr.Bytes*8) / pr.Elapsed
+	pr.RPM = computeRPM(pr.ForeignProbes, pr.SelfProbes)
+	if pr.Bytes <= 0 {
+		pr.Failure = tracex.NewFailure(firstError(errs))
+	}
+	return pr
+}
+
+// firstError returns the first non-nil error or nil.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// download repeatedly downloads the given URL until the context is done.
+func (m *Measurer) download(ctx context.Context, txp model.HTTPTransport, URL string, count *int64) error {
+	for ctx.Err() == nil {
+		req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := txp.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return errUnexpectedStatusCode
+		}
+		_, err = io.Copy(&countingWriter{count: count}, resp.Body)
+		resp.Body.Close()
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upload repeatedly uploads to the given URL until the context is done.
+func (m *Measurer) upload(ctx context.Context, txp model.HTTPTransport, URL string, count *int64) error {
+	for ctx.Err() == nil {
+		req, err := http.NewRequestWithContext(ctx, "POST", URL, &countingReader{count: count})
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		resp, err := txp.RoundTrip(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil // we interrupted the upload
+			}
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return errUnexpectedStatusCode
+		}
+	}
+	return nil
+}
+
+// selfProbe fetches the small URL using a load generating connection.
+func (m *Measurer) selfProbe(
+	ctx context.Context, t0 time.Time, txp model.HTTPTransport, URL string) *SelfProbe {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	started := time.Now()
+	probe := &SelfProbe{T: started.Sub(t0).Seconds()}
+	if err := fetchSmall(ctx, txp, URL); err != nil {
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	probe.HTTPRequest = time.Since(started).Seconds()
+	return probe
+}
+
+// foreignProbe fetches the small URL using a new connection.
+func (m *Measurer) foreignProbe(
+	ctx context.Context, t0 time.Time, logger model.Logger, URL string) *ForeignProbe {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	probe := &ForeignProbe{T: time.Since(t0).Seconds()}
+	parsed, err := url.Parse(URL)
+	if err != nil {
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "443"
+	}
+	resolver := netxlite.NewStdlibResolver(logger)
+	addrs, err := resolver.LookupHost(ctx, parsed.Hostname())
+	if err != nil {
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	dialer := bytecounter.WrapWithContextAwareDialer(netxlite.NewDialerWithoutResolver(logger))
+	started := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+	if err != nil {
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	probe.TCPConnect = time.Since(started).Seconds()
+	handshaker := netxlite.NewTLSHandshakerStdlib(logger)
+	started = time.Now()
+	tlsConn, _, err := handshaker.Handshake(ctx, conn, m.tlsConfig(parsed.Hostname()))
+	if err != nil {
+		conn.Close()
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	probe.TLSHandshake = time.Since(started).Seconds()
+	txp := netxlite.NewHTTPTransport(
+		logger,
+		netxlite.NewNullDialer(),
+		netxlite.NewSingleUseTLSDialer(tlsConn.(netxlite.TLSConn)),
+	)
+	defer txp.CloseIdleConnections()
+	started = time.Now()
+	if err := fetchSmall(ctx, txp, URL); err != nil {
+		probe.Failure = tracex.NewFailure(err)
+		return probe
+	}
+	probe.HTTPRequest = time.Since(started).Seconds()
+	return probe
+}
+
+// fetchSmall fetches the small URL using the given transport.
+func fetchSmall(ctx context.Context, txp model.HTTPTransport, URL string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := txp.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errUnexpectedStatusCode
+	}
+	_, err = netxlite.ReadAllContext(ctx, resp.Body)
+	return err
+}
+
+// countingWriter counts the bytes written into it.
+type countingWriter struct {
+	count *int64
+}
+
+// Write implements io.Writer.
+func (w *countingWriter) Write(data []byte) (int, error) {
+	atomic.AddInt64(w.count, int64(len(data)))
+	return len(data), nil
+}
+
+// countingReader is an infinite reader that counts the bytes read from it.
+type countingReader struct {
+	count *int64
+}
+
+// Read implements io.Reader.
+func (r *countingReader) Read(data []byte) (int, error) {
+	for idx := range data {
+		data[idx] = 0
+	}
+	atomic.AddInt64(r.count, int64(len(data)))
+	return len(data), nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with ooniprobe
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	RPM       float64 `json:"rpm"`
+	IsAnomaly bool    `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m *Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	tk, ok := measurement.TestKeys.(*TestKeys)
+	if !ok {
+		return SummaryKeys{}, errors.New("invalid test keys type")
+	}
+	return SummaryKeys{RPM: tk.RPM, IsAnomaly: tk.Failure != nil}, nil
+}
@@ -0,0 +1,222 @@
+package responsiveness
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/bytecounter"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestMeasurerExperimentNameVersion(t *testing.T) {
+	measurer := NewExperimentMeasurer(Config{})
+	if measurer.ExperimentName() != "responsiveness" {
+		t.Fatal("unexpected ExperimentName")
+	}
+	if measurer.ExperimentVersion() != "0.1.0" {
+		t.Fatal("unexpected ExperimentVersion")
+	}
+}
+
+// newTestServer creates a TLS server using HTTP/2 and the given
+// handler and returns a cert pool trusting the server certificate.
+func newTestServer(handler http.Handler) (*httptest.Server, *x509.CertPool) {
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv, pool
+}
+
+// runWithConfig runs the measurer with the given config and cert pool.
+func runWithConfig(t *testing.T, config Config, pool *x509.CertPool) (*Measurer, *TestKeys) {
+	measurer := &Measurer{config: config, rootCAs: pool}
+	measurement := new(model.Measurement)
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session: &mockable.Session{
+			MockableLogger: model.DiscardLogger,
+		},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	return measurer, measurement.TestKeys.(*TestKeys)
+}
+
+func TestRunWithLocalServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	srv, pool := newTestServer(NewHandler())
+	defer srv.Close()
+	config := Config{
+		ConfigURL:   srv.URL + "/config",
+		Connections: 2,
+		Duration:    1,
+	}
+	measurer, tk := runWithConfig(t, config, pool)
+	if tk.Failure != nil {
+		t.Fatal("unexpected failure", *tk.Failure)
+	}
+	if tk.SmallDownloadURL != srv.URL+"/small" {
+		t.Fatal("unexpected small download URL", tk.SmallDownloadURL)
+	}
+	for _, pr := range []*PhaseResult{tk.Download, tk.Upload} {
+		if pr.Failure != nil {
+			t.Fatal("unexpected failure", *pr.Failure)
+		}
+		if pr.Connections != 2 {
+			t.Fatal("unexpected number of connections")
+		}
+		if pr.Bytes <= 0 || pr.Throughput <= 0 {
+			t.Fatal("expected to transfer some bytes")
+		}
+		if len(pr.ForeignProbes) <= 0 || len(pr.SelfProbes) <= 0 {
+			t.Fatal("expected to see some probes")
+		}
+		if pr.RPM <= 0 {
+			t.Fatal("expected positive RPM")
+		}
+	}
+	if tk.RPM <= 0 {
+		t.Fatal("expected positive RPM")
+	}
+	sk, err := measurer.GetSummaryKeys(&model.Measurement{TestKeys: tk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.(SummaryKeys).RPM != tk.RPM || sk.(SummaryKeys).IsAnomaly {
+		t.Fatal("unexpected summary keys")
+	}
+}
+
+func TestRunCountsBytes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	srv, pool := newTestServer(NewHandler())
+	defer srv.Close()
+	config := Config{
+		ConfigURL:   srv.URL + "/config",
+		Connections: 1,
+		Duration:    1,
+	}
+	measurer := &Measurer{config: config, rootCAs: pool}
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: new(model.Measurement),
+		Session: &mockable.Session{
+			MockableLogger: model.DiscardLogger,
+		},
+	}
+	sessCounter, expCounter := bytecounter.New(), bytecounter.New()
+	ctx := bytecounter.WithSessionByteCounter(context.Background(), sessCounter)
+	ctx = bytecounter.WithExperimentByteCounter(ctx, expCounter)
+	if err := measurer.Run(ctx, args); err != nil {
+		t.Fatal(err)
+	}
+	tk := args.Measurement.TestKeys.(*TestKeys)
+	if sessCounter.BytesReceived() < tk.Download.Bytes {
+		t.Fatal("the session byte counter did not see the download")
+	}
+	if sessCounter.BytesSent() < tk.Upload.Bytes {
+		t.Fatal("the session byte counter did not see the upload")
+	}
+	if expCounter.BytesReceived() <= 0 || expCounter.BytesSent() <= 0 {
+		t.Fatal("the experiment byte counter did not increase")
+	}
+}
+
+func TestRunWithoutConfigURL(t *testing.T) {
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: new(model.Measurement),
+		Session: &mockable.Session{
+			MockableLogger: model.DiscardLogger,
+		},
+	}
+	err := NewExperimentMeasurer(Config{}).Run(context.Background(), args)
+	if !errors.Is(err, errMissingConfigURL) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestRunWithInvalidServerConfig(t *testing.T) {
+	t.Run("with unexpected status code", func(t *testing.T) {
+		srv, pool := newTestServer(http.NotFoundHandler())
+		defer srv.Close()
+		_, tk := runWithConfig(t, Config{ConfigURL: srv.URL + "/config"}, pool)
+		if tk.Failure == nil || tk.Download != nil || tk.Upload != nil {
+			t.Fatal("expected a failure and no phases")
+		}
+	})
+
+	t.Run("with invalid URLs", func(t *testing.T) {
+		srv, pool := newTestServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"version":1,"urls":{"small_https_download_url":"http://x/small"}}`))
+		}))
+		defer srv.Close()
+		_, tk := runWithConfig(t, Config{ConfigURL: srv.URL + "/config"}, pool)
+		if tk.Failure == nil || *tk.Failure != "unknown_failure: "+errInvalidServerConfig.Error() {
+			t.Fatal("unexpected failure", tk.Failure)
+		}
+	})
+}
+
+func TestComputeRPM(t *testing.T) {
+	t.Run("without probes", func(t *testing.T) {
+		if computeRPM(nil, nil) != 0 {
+			t.Fatal("expected zero RPM")
+		}
+	})
+
+	t.Run("with probes", func(t *testing.T) {
+		foreign := []*ForeignProbe{{TCPConnect: 0.1, TLSHandshake: 0.2, HTTPRequest: 0.3}}
+		self := []*SelfProbe{{HTTPRequest: 0.4}}
+		// 60 / (0.6/6 + 0.4/2) = 60 / 0.3 = 200
+		if rpm := computeRPM(foreign, self); rpm < 199.99 || rpm > 200.01 {
+			t.Fatal("unexpected RPM", rpm)
+		}
+	})
+
+	t.Run("ignoring failed probes", func(t *testing.T) {
+		failure := "generic_timeout_error"
+		self := []*SelfProbe{{HTTPRequest: 0.4}, {HTTPRequest: 100, Failure: &failure}}
+		foreign := []*ForeignProbe{{TCPConnect: 0.1, TLSHandshake: 0.2, HTTPRequest: 0.3}}
+		if rpm := computeRPM(foreign, self); rpm < 199.99 || rpm > 200.01 {
+			t.Fatal("unexpected RPM", rpm)
+		}
+	})
+}
+
+func TestTrimmedMean(t *testing.T) {
+	values := make([]float64, 0, 20)
+	for idx := 0; idx < 19; idx++ {
+		values = append(values, 1)
+	}
+	values = append(values, 1000) // outlier removed by trimming
+	if mean := trimmedMean(values, 0.05); mean != 1 {
+		t.Fatal("unexpected mean", mean)
+	}
+	if trimmedMean(nil, 0.05) != 0 {
+		t.Fatal("expected zero")
+	}
+}
+
+func TestSummaryKeysInvalidType(t *testing.T) {
+	measurement := new(model.Measurement)
+	m := &Measurer{}
+	_, err := m.GetSummaryKeys(measurement)
+	if err == nil || err.Error() != "invalid test keys type" {
+		t.Fatal("not the error we expected", err)
+	}
+}
@@ -0,0 +1,54 @@
+package responsiveness
+
+//
+// Computing the responsiveness
+//
+
+import (
+	"math"
+	"sort"
+)
+
+// trimmedMean returns the mean of the values after removing the
+// given fraction of the largest values (e.g., 0.05 for 95%).
+func trimmedMean(values []float64, trim float64) float64 {
+	if len(values) <= 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	keep := int(math.Ceil(float64(len(sorted)) * (1 - trim)))
+	var sum float64
+	for _, value := range sorted[:keep] {
+		sum += value
+	}
+	return sum / float64(keep)
+}
+
+// computeRPM computes the round trips per minute using the successful
+// probes. It returns zero when we do not have enough probes.
+func computeRPM(foreign []*ForeignProbe, self []*SelfProbe) float64 {
+	var tcp, tls, httpf, https []float64
+	for _, probe := range foreign {
+		if probe.Failure == nil {
+			tcp = append(tcp, probe.TCPConnect)
+			tls = append(tls, probe.TLSHandshake)
+			httpf = append(httpf, probe.HTTPRequest)
+		}
+	}
+	for _, probe := range self {
+		if probe.Failure == nil {
+			https = append(https, probe.HTTPRequest)
+		}
+	}
+	if len(httpf) <= 0 || len(https) <= 0 {
+		return 0
+	}
+	const trim = 0.05
+	foreignTime := (trimmedMean(tcp, trim) + trimmedMean(tls, trim) + trimmedMean(httpf, trim)) / 6
+	selfTime := trimmedMean(https, trim) / 2
+	if foreignTime+selfTime <= 0 {
+		return 0
+	}
+	return 60 / (foreignTime + selfTime)
+}
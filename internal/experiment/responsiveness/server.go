@@ -0,0 +1,86 @@
+package responsiveness
+
+//
+// Server implementation
+//
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+)
+
+// serverConfig is the server configuration.
+type serverConfig struct {
+	// Version is the configuration version.
+	Version int64 `json:"version"`
+
+	// URLs contains the URLs to use.
+	URLs serverConfigURLs `json:"urls"`
+}
+
+// serverConfigURLs contains the URLs to use.
+type serverConfigURLs struct {
+	// SmallDownloadURL is the URL of a small resource used for probing.
+	SmallDownloadURL string `json:"small_https_download_url"`
+
+	// LargeDownloadURL is the URL of a large resource used for download.
+	LargeDownloadURL string `json:"large_https_download_url"`
+
+	// UploadURL is the URL to which we upload data.
+	UploadURL string `json:"https_upload_url"`
+}
+
+// largeDownloadSize is the size of the large resource, which needs to be
+// large enough that we cannot download it within a phase.
+const largeDownloadSize = 8 << 30
+
+// NewHandler returns an http.Handler implementing a responsiveness server that
+// serves its configuration at /config, a small resource at /small, a large
+// resource at /large and accepts uploads at /upload.
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", func(w http.ResponseWriter, req *http.Request) {
+		baseURL := &url.URL{Scheme: "https", Host: req.Host}
+		if req.TLS == nil {
+			baseURL.Scheme = "http"
+		}
+		config := &serverConfig{
+			Version: 1,
+			URLs: serverConfigURLs{
+				SmallDownloadURL: baseURL.JoinPath("small").String(),
+				LargeDownloadURL: baseURL.JoinPath("large").String(),
+				UploadURL:        baseURL.JoinPath("upload").String(),
+			},
+		}
+		data, err := json.Marshal(config)
+		runtimex.PanicOnError(err, "json.Marshal failed")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0})
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		chunk := make([]byte, 1<<16)
+		for sent := int64(0); sent < largeDownloadSize; sent += int64(len(chunk)) {
+			if _, err := w.Write(chunk); err != nil {
+				return // most likely the client closed the stream
+			}
+		}
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost && req.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
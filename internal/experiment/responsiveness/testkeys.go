@@ -0,0 +1,107 @@
+package responsiveness
+
+//
+// Test keys
+//
+
+import (
+	"sync"
+)
+
+// TestKeys contains the experiment results.
+type TestKeys struct {
+	// SmallDownloadURL is the URL we used for probing.
+	SmallDownloadURL string `json:"small_download_url"`
+
+	// LargeDownloadURL is the URL we used for downloading.
+	LargeDownloadURL string `json:"large_download_url"`
+
+	// UploadURL is the URL we used for uploading.
+	UploadURL string `json:"upload_url"`
+
+	// Download contains the results of the download phase.
+	Download *PhaseResult `json:"download"`
+
+	// Upload contains the results of the upload phase.
+	Upload *PhaseResult `json:"upload"`
+
+	// RPM is the responsiveness computed using the probes of both phases.
+	RPM float64 `json:"rpm"`
+
+	// Failure is the failure that prevented us from measuring.
+	Failure *string `json:"failure"`
+}
+
+// PhaseResult contains the results of a download or upload phase.
+type PhaseResult struct {
+	// Connections is the number of load generating connections.
+	Connections int64 `json:"connections"`
+
+	// Bytes is the number of bytes transferred by the load generating connections.
+	Bytes int64 `json:"bytes"`
+
+	// Elapsed is the phase duration in seconds.
+	Elapsed float64 `json:"elapsed"`
+
+	// Throughput is the goodput in bits per second.
+	Throughput float64 `json:"throughput"`
+
+	// ForeignProbes contains the results of the foreign probes.
+	ForeignProbes []*ForeignProbe `json:"foreign_probes"`
+
+	// SelfProbes contains the results of the self probes.
+	SelfProbes []*SelfProbe `json:"self_probes"`
+
+	// RPM is the responsiveness computed using the probes of this phase.
+	RPM float64 `json:"rpm"`
+
+	// Failure is the failure of the load generating connections, if all failed.
+	Failure *string `json:"failure"`
+
+	// mu provides mutual exclusion
+	mu sync.Mutex
+}
+
+// ForeignProbe is a probe using a new connection.
+type ForeignProbe struct {
+	// T is the time when we started the probe relative to the phase start.
+	T float64 `json:"t"`
+
+	// TCPConnect is the TCP connect time in seconds.
+	TCPConnect float64 `json:"tcp_connect"`
+
+	// TLSHandshake is the TLS handshake time in seconds.
+	TLSHandshake float64 `json:"tls_handshake"`
+
+	// HTTPRequest is the HTTP round trip time in seconds.
+	HTTPRequest float64 `json:"http_request"`
+
+	// Failure is the probe failure or nil.
+	Failure *string `json:"failure"`
+}
+
+// SelfProbe is a probe using a load generating connection.
+type SelfProbe struct {
+	// T is the time when we started the probe relative to the phase start.
+	T float64 `json:"t"`
+
+	// HTTPRequest is the HTTP round trip time in seconds.
+	HTTPRequest float64 `json:"http_request"`
+
+	// Failure is the probe failure or nil.
+	Failure *string `json:"failure"`
+}
+
+// addForeignProbe adds a *ForeignProbe to the phase result.
+func (pr *PhaseResult) addForeignProbe(probe *ForeignProbe) {
+	pr.mu.Lock()
+	pr.ForeignProbes = append(pr.ForeignProbes, probe)
+	pr.mu.Unlock()
+}
+
+// addSelfProbe adds a *SelfProbe to the phase result.
+func (pr *PhaseResult) addSelfProbe(probe *SelfProbe) {
+	pr.mu.Lock()
+	pr.SelfProbes = append(pr.SelfProbes, probe)
+	pr.mu.Unlock()
+}
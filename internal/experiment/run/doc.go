@@ -1,4 +1,13 @@
 // Package run contains code to run other experiments.
 //
+// Each input is a JSON document containing the name of the experiment
+// to run, its input, its options and extra annotations, e.g.:
+//
+//	{"name": "tcpping", "input": "tcpconnect://8.8.8.8:443", "options": {"Repetitions": "3"}}
+//
+// We can run any registered experiment, thus making run a batch runner
+// for lists of structured inputs. We submit each measurement using the
+// name of the experiment we actually ran.
+//
 // This code is currently alpha.
 package run
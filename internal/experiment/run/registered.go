@@ -0,0 +1,30 @@
+package run
+
+import (
+	"context"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// registeredMain runs any registered experiment.
+type registeredMain struct {
+	newMeasurer MeasurerFactory
+}
+
+func (m *registeredMain) do(ctx context.Context, input StructuredInput,
+	sess model.ExperimentSession, measurement *model.Measurement,
+	callbacks model.ExperimentCallbacks) error {
+	exp, err := m.newMeasurer(input.Name, input.Options)
+	if err != nil {
+		return err
+	}
+	measurement.TestName = exp.ExperimentName()
+	measurement.TestVersion = exp.ExperimentVersion()
+	measurement.Input = model.MeasurementTarget(input.Input)
+	args := &model.ExperimentArgs{
+		Callbacks:   callbacks,
+		Measurement: measurement,
+		Session:     sess,
+	}
+	return exp.Run(ctx, args)
+}
@@ -13,8 +13,18 @@ import (
 // Config contains settings.
 type Config struct{}
 
+// MeasurerFactory creates the measurer of the registered experiment with
+// the given name after setting the given options.
+type MeasurerFactory func(name string, options map[string]any) (model.ExperimentMeasurer, error)
+
 // Measurer runs the measurement.
-type Measurer struct{}
+type Measurer struct {
+	// NewMeasurer is the OPTIONAL factory to run any registered experiment. We
+	// cannot use the registry package here, because it depends on this package,
+	// so the registry passes us this factory. When this field is nil, we only
+	// run the experiments inside table.
+	NewMeasurer MeasurerFactory
+}
 
 // ExperimentName implements ExperimentMeasurer.ExperimentName.
 func (Measurer) ExperimentName() string {
@@ -23,7 +33,7 @@ func (Measurer) ExperimentName() string {
 
 // ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
 func (Measurer) ExperimentVersion() string {
-	return "0.2.0"
+	return "0.3.0"
 }
 
 // StructuredInput contains structured input for this experiment.
@@ -41,12 +51,17 @@ type StructuredInput struct {
 	// Name is the name of the experiment to run.
 	Name string `json:"name"`
 
+	// Options contains the options for the experiment to run. We
+	// use the dedicated DNSCheck and URLGetter fields instead of
+	// this field for the dnscheck and urlgetter experiments.
+	Options map[string]any `json:"options"`
+
 	// Input is the input for this experiment.
 	Input string `json:"input"`
 }
 
 // Run implements ExperimentMeasurer.ExperimentVersion.
-func (Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
+func (m Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	callbacks := args.Callbacks
 	measurement := args.Measurement
 	sess := args.Session
@@ -54,23 +69,46 @@ func (Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	if err := json.Unmarshal([]byte(measurement.Input), &input); err != nil {
 		return err
 	}
-	exprun, found := table[input.Name]
-	if !found {
-		return fmt.Errorf("no such experiment: %s", input.Name)
+	exprun, err := m.experimentMain(input.Name)
+	if err != nil {
+		return err
 	}
 	measurement.AddAnnotations(input.Annotations)
 	return exprun.do(ctx, input, sess, measurement, callbacks)
 }
 
+// experimentMain returns the experimentMain for the given experiment name.
+func (m Measurer) experimentMain(name string) (experimentMain, error) {
+	if exprun, found := table[name]; found {
+		return exprun, nil
+	}
+	if m.NewMeasurer == nil {
+		return nil, fmt.Errorf("no such experiment: %s", name)
+	}
+	return &registeredMain{newMeasurer: m.NewMeasurer}, nil
+}
+
 // GetSummaryKeys implements ExperimentMeasurer.GetSummaryKeys
-func (Measurer) GetSummaryKeys(*model.Measurement) (interface{}, error) {
-	// TODO(bassosimone): we could extend this interface to call the
-	// specific GetSummaryKeys of the experiment we're running.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	// Note: Run sets the measurement's test name to the name of the experiment
+	// it runs, hence we can use the specific GetSummaryKeys of such experiment.
+	if _, found := table[measurement.TestName]; !found && m.NewMeasurer != nil {
+		if exp, err := m.NewMeasurer(measurement.TestName, nil); err == nil {
+			return exp.GetSummaryKeys(measurement)
+		}
+	}
 	return dnscheck.SummaryKeys{IsAnomaly: false}, nil
 }
 
 // NewExperimentMeasurer creates a new model.ExperimentMeasurer
-// implementing the run experiment.
+// implementing the run experiment that only runs the experiments
+// inside table (i.e., dnscheck and urlgetter).
 func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
 	return Measurer{}
 }
+
+// NewExperimentMeasurerWithFactory is like NewExperimentMeasurer but
+// uses the given factory to run any registered experiment.
+func NewExperimentMeasurerWithFactory(config Config, factory MeasurerFactory) model.ExperimentMeasurer {
+	return Measurer{NewMeasurer: factory}
+}
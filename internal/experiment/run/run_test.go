@@ -2,10 +2,12 @@ package run_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-cli/v3/internal/experiment/dnscheck"
+	"github.com/ooni/probe-cli/v3/internal/experiment/example"
 	"github.com/ooni/probe-cli/v3/internal/experiment/run"
 	"github.com/ooni/probe-cli/v3/internal/experiment/urlgetter"
 	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
@@ -17,7 +19,7 @@ func TestExperimentNameAndVersion(t *testing.T) {
 	if measurer.ExperimentName() != "run" {
 		t.Error("unexpected experiment name")
 	}
-	if measurer.ExperimentVersion() != "0.2.0" {
+	if measurer.ExperimentVersion() != "0.3.0" {
 		t.Error("unexpected experiment version")
 	}
 }
@@ -125,3 +127,73 @@ func TestRunWithUnknownExperiment(t *testing.T) {
 		t.Fatalf("not the error we expected: %+v", err)
 	}
 }
+
+func TestRunWithFactory(t *testing.T) {
+	// newFactory returns a MeasurerFactory creating the example experiment
+	newFactory := func(gotOptions *map[string]any) run.MeasurerFactory {
+		return func(name string, options map[string]any) (model.ExperimentMeasurer, error) {
+			if name != "example" {
+				return nil, fmt.Errorf("no such experiment: %s", name)
+			}
+			*gotOptions = options
+			config := example.Config{ReturnError: options["ReturnError"] == "true"}
+			return example.NewExperimentMeasurer(config, "example"), nil
+		}
+	}
+
+	// runWithInput runs the measurer with the given input
+	runWithInput := func(measurer model.ExperimentMeasurer, input string) (*model.Measurement, error) {
+		measurement := new(model.Measurement)
+		measurement.Input = model.MeasurementTarget(input)
+		args := &model.ExperimentArgs{
+			Callbacks:   model.NewPrinterCallbacks(log.Log),
+			Measurement: measurement,
+			Session:     &mockable.Session{MockableLogger: log.Log},
+		}
+		return measurement, measurer.Run(context.Background(), args)
+	}
+
+	t.Run("we can run any experiment", func(t *testing.T) {
+		var options map[string]any
+		measurer := run.NewExperimentMeasurerWithFactory(run.Config{}, newFactory(&options))
+		input := `{"name": "example", "input": "x", "options": {"Message": "hello"}, "annotations": {"a": "b"}}`
+		measurement, err := runWithInput(measurer, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measurement.TestName != "example" || measurement.Input != "x" {
+			t.Fatal("unexpected measurement", measurement.TestName, measurement.Input)
+		}
+		if measurement.Annotations["a"] != "b" {
+			t.Fatal("unexpected annotations", measurement.Annotations)
+		}
+		if options["Message"] != "hello" {
+			t.Fatal("did not pass the options", options)
+		}
+		sk, err := measurer.GetSummaryKeys(measurement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := sk.(example.SummaryKeys); !ok {
+			t.Fatal("expected the example summary keys")
+		}
+	})
+
+	t.Run("we return the experiment error", func(t *testing.T) {
+		var options map[string]any
+		measurer := run.NewExperimentMeasurerWithFactory(run.Config{}, newFactory(&options))
+		input := `{"name": "example", "options": {"ReturnError": "true"}}`
+		if _, err := runWithInput(measurer, input); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("we return the factory error", func(t *testing.T) {
+		var options map[string]any
+		measurer := run.NewExperimentMeasurerWithFactory(run.Config{}, newFactory(&options))
+		input := `{"name": "antani"}`
+		if _, err := runWithInput(measurer, input); err == nil || err.Error() != "no such experiment: antani" {
+			t.Fatal("not the error we expected", err)
+		}
+	})
+}
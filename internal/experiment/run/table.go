@@ -13,12 +13,11 @@ type experimentMain interface {
 		callbacks model.ExperimentCallbacks) error
 }
 
+// table contains the experiments with a dedicated StructuredInput field. We
+// run any other experiment using registeredMain. Note that the submitter
+// keeps a distinct report open for each experiment name, so we do not mix
+// different experiments into the same report ID.
 var table = map[string]experimentMain{
-	// TODO(bassosimone): before extending run to support more than
-	// single experiment, we need to handle the case in which we are
-	// including different experiments into the same report ID.
-	// Probably, the right way to implement this functionality is to
-	// use proveservices.Submitter to submit reports.
 	"dnscheck": &dnsCheckMain{
 		Endpoints: &dnscheck.Endpoints{},
 	},
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"net"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
@@ -27,7 +26,7 @@ const (
 // Config contains the experiment configuration.
 type Config struct {
 	// ALPN allows to specify which ALPN or ALPNs to send.
-	ALPN string `ooni:"space separated list of ALPNs to use"`
+	ALPN []string `ooni:"list of ALPNs to use"`
 
 	// Delay is the delay between each repetition (in milliseconds).
 	Delay int64 `ooni:"number of milliseconds to wait before sending each ping"`
@@ -39,11 +38,11 @@ type Config struct {
 	SNI string `ooni:"the SNI value to use"`
 }
 
-func (c *Config) alpn() string {
-	if c.ALPN != "" {
+func (c *Config) alpn() []string {
+	if len(c.ALPN) > 0 {
 		return c.ALPN
 	}
-	return "h3"
+	return []string{"h3"}
 }
 
 func (c *Config) delay() time.Duration {
@@ -168,7 +167,7 @@ func (m *Measurer) quicHandshake(ctx context.Context, index int64,
 		QUICHandshake: nil,
 	}
 	sni := m.config.sni(address)
-	alpn := strings.Split(m.config.alpn(), " ")
+	alpn := m.config.alpn()
 	trace := measurexlite.NewTrace(index, zeroTime)
 	ol := measurexlite.NewOperationLogger(logger, "SimpleQUICPing #%d %s %s %v", index, address, sni, alpn)
 	listener := netxlite.NewQUICListener()
@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/lucas-clemente/quic-go"
 	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -21,7 +22,7 @@ import (
 
 func TestConfig_alpn(t *testing.T) {
 	c := Config{}
-	if c.alpn() != "h3" {
+	if diff := cmp.Diff([]string{"h3"}, c.alpn()); diff != "" {
 		t.Fatal("invalid default alpn list")
 	}
 }
@@ -47,7 +48,7 @@ func TestMeasurer_run(t *testing.T) {
 	// runHelper is an helper function to run this set of tests.
 	runHelper := func(input string) (*model.Measurement, model.ExperimentMeasurer, error) {
 		m := NewExperimentMeasurer(Config{
-			ALPN:        "h3",
+			ALPN:        []string{"h3"},
 			Delay:       1, // millisecond
 			Repetitions: expectedPings,
 		})
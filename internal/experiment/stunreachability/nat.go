@@ -0,0 +1,269 @@
+package stunreachability
+
+//
+// NAT behavior discovery (RFC 5780)
+//
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/pion/stun"
+)
+
+// These are the possible NAT mapping and filtering behaviors.
+const (
+	// NATBehaviorEndpointIndependent means that the NAT reuses the same
+	// mapping (or accepts packets) regardless of the remote endpoint.
+	NATBehaviorEndpointIndependent = "endpoint_independent"
+
+	// NATBehaviorAddressDependent means that the NAT uses a distinct
+	// mapping (or filters packets) per remote address.
+	NATBehaviorAddressDependent = "address_dependent"
+
+	// NATBehaviorAddressAndPortDependent means that the NAT uses a distinct
+	// mapping (or filters packets) per remote address and port.
+	NATBehaviorAddressAndPortDependent = "address_and_port_dependent"
+)
+
+// These are the flags of the CHANGE-REQUEST attribute.
+const (
+	changeRequestIP   = 0x04
+	changeRequestPort = 0x02
+)
+
+// errNATBehaviorUnsupported indicates that the server does not support RFC 5780.
+var errNATBehaviorUnsupported = errors.New("stun: server does not support RFC 5780")
+
+// natBindingResponse is the response to a binding request.
+type natBindingResponse struct {
+	// Mapped is the XOR-MAPPED-ADDRESS.
+	Mapped string
+
+	// Other is the OTHER-ADDRESS or nil.
+	Other *net.UDPAddr
+}
+
+// natBinder sends binding requests using always the same local socket,
+// which is what we need to discover the NAT behavior.
+type natBinder interface {
+	// Binding sends a binding request to the given address, including the
+	// given CHANGE-REQUEST flags when nonzero, and waits for the response.
+	Binding(ctx context.Context, addr *net.UDPAddr, change uint32) (*natBindingResponse, error)
+
+	// Close closes the underlying socket.
+	Close() error
+}
+
+// classifyNAT returns the NAT mapping and filtering behaviors using the
+// tests described in Sections 4.3 and 4.4 of RFC 5780.
+func classifyNAT(ctx context.Context, binder natBinder, server *net.UDPAddr) (
+	mapping string, filtering string, err error) {
+	first, err := binder.Binding(ctx, server, 0)
+	if err != nil {
+		return "", "", err
+	}
+	if first.Other == nil {
+		return "", "", errNATBehaviorUnsupported
+	}
+	mapping, err = classifyNATMapping(ctx, binder, server, first)
+	if err != nil {
+		return "", "", err
+	}
+	filtering, err = classifyNATFiltering(ctx, binder, server)
+	if err != nil {
+		return "", "", err
+	}
+	return mapping, filtering, nil
+}
+
+// classifyNATMapping implements the mapping tests II and III.
+func classifyNATMapping(ctx context.Context, binder natBinder,
+	server *net.UDPAddr, first *natBindingResponse) (string, error) {
+	second, err := binder.Binding(ctx, &net.UDPAddr{IP: first.Other.IP, Port: server.Port}, 0)
+	if err != nil {
+		return "", err
+	}
+	if second.Mapped == first.Mapped {
+		return NATBehaviorEndpointIndependent, nil
+	}
+	third, err := binder.Binding(ctx, first.Other, 0)
+	if err != nil {
+		return "", err
+	}
+	if third.Mapped == second.Mapped {
+		return NATBehaviorAddressDependent, nil
+	}
+	return NATBehaviorAddressAndPortDependent, nil
+}
+
+// classifyNATFiltering implements the filtering tests II and III. Here a
+// timeout is the expected outcome when the NAT filters the responses.
+func classifyNATFiltering(ctx context.Context, binder natBinder, server *net.UDPAddr) (string, error) {
+	_, err := binder.Binding(ctx, server, changeRequestIP|changeRequestPort)
+	if err == nil {
+		return NATBehaviorEndpointIndependent, nil
+	}
+	if !isTimeout(err) {
+		return "", err
+	}
+	_, err = binder.Binding(ctx, server, changeRequestPort)
+	if err == nil {
+		return NATBehaviorAddressDependent, nil
+	}
+	if !isTimeout(err) {
+		return "", err
+	}
+	return NATBehaviorAddressAndPortDependent, nil
+}
+
+// isTimeout returns whether the error is a timeout.
+func isTimeout(err error) bool {
+	return err != nil && err.Error() == netxlite.FailureGenericTimeoutError
+}
+
+// udpNATBinder is the natBinder using a UDP socket.
+type udpNATBinder struct {
+	// conn is the UDP socket.
+	conn model.UDPLikeConn
+
+	// attempts is the number of times we send each request.
+	attempts int
+
+	// timeout is the time we wait for a response to each request.
+	timeout time.Duration
+}
+
+// newUDPNATBinder creates a new udpNATBinder.
+func newUDPNATBinder(logger model.Logger) (*udpNATBinder, error) {
+	listener := netxlite.NewQUICListener()
+	conn, err := listener.Listen(&net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	return &udpNATBinder{conn: conn, attempts: 3, timeout: time.Second}, nil
+}
+
+var _ natBinder = &udpNATBinder{}
+
+// Close implements natBinder.Close.
+func (b *udpNATBinder) Close() error {
+	return b.conn.Close()
+}
+
+// Binding implements natBinder.Binding.
+func (b *udpNATBinder) Binding(
+	ctx context.Context, addr *net.UDPAddr, change uint32) (*natBindingResponse, error) {
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if change != 0 {
+		setters = append(setters, stun.RawAttribute{
+			Type:  stun.AttrChangeRequest,
+			Value: []byte{byte(change >> 24), byte(change >> 16), byte(change >> 8), byte(change)},
+		})
+	}
+	request := stun.MustBuild(setters...)
+	buffer := make([]byte, 1500)
+	for attempt := 0; attempt < b.attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, netxlite.NewTopLevelGenericErrWrapper(err)
+		}
+		if _, err := b.conn.WriteTo(request.Raw, addr); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(b.timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		b.conn.SetReadDeadline(deadline)
+		for {
+			count, _, err := b.conn.ReadFrom(buffer)
+			if err != nil {
+				if isTimeout(err) {
+					break // retransmit
+				}
+				return nil, err
+			}
+			response := &stun.Message{Raw: append([]byte{}, buffer[:count]...)}
+			if err := response.Decode(); err != nil || response.TransactionID != request.TransactionID {
+				continue // not the response we're waiting for
+			}
+			return newNATBindingResponse(response)
+		}
+	}
+	return nil, errors.New(netxlite.FailureGenericTimeoutError)
+}
+
+// newNATBindingResponse parses a binding response.
+func newNATBindingResponse(response *stun.Message) (*natBindingResponse, error) {
+	var mapped stun.XORMappedAddress
+	if err := mapped.GetFrom(response); err != nil {
+		return nil, err
+	}
+	out := &natBindingResponse{Mapped: mapped.String()}
+	var other stun.OtherAddress
+	if err := other.GetFrom(response); err == nil {
+		out.Other = &net.UDPAddr{IP: other.IP, Port: other.Port}
+	}
+	return out, nil
+}
+
+// runNAT discovers the NAT behavior using the given endpoint. We try each
+// address of the server in turn until we can classify the NAT behavior. Note
+// that we do not save the mapped addresses because they would reveal the
+// probe's IP address.
+func (tk *TestKeys) runNAT(ctx context.Context, config Config, logger model.Logger, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return err
+	}
+	lookupHost := netxlite.NewStdlibResolver(logger).LookupHost
+	if config.lookupHost != nil {
+		lookupHost = config.lookupHost
+	}
+	addrs, err := lookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	newBinder := func(logger model.Logger) (natBinder, error) {
+		return newUDPNATBinder(logger)
+	}
+	if config.newNATBinder != nil {
+		newBinder = config.newNATBinder
+	}
+	for _, addr := range addrs {
+		var mapping, filtering string
+		mapping, filtering, err = tk.runNATWithAddress(ctx, logger, newBinder, net.JoinHostPort(addr, port))
+		logger.Infof("stunreachability: NAT behavior: %s (%s)... mapping=%s filtering=%s",
+			endpoint, addr, mapping, filtering)
+		if err == nil {
+			tk.NATMapping = &mapping
+			tk.NATFiltering = &filtering
+			return nil
+		}
+		if ctx.Err() != nil {
+			break // no point in trying the next address
+		}
+	}
+	return err
+}
+
+// runNATWithAddress classifies the NAT behavior using the server at the given address.
+func (tk *TestKeys) runNATWithAddress(ctx context.Context, logger model.Logger,
+	newBinder func(logger model.Logger) (natBinder, error), address string) (string, string, error) {
+	server, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return "", "", err
+	}
+	binder, err := newBinder(logger)
+	if err != nil {
+		return "", "", err
+	}
+	defer binder.Close()
+	return classifyNAT(ctx, binder, server)
+}
@@ -0,0 +1,235 @@
+package stunreachability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/pion/stun"
+)
+
+// fakeNATBinder is a natBinder returning canned responses keyed
+// by the destination address and the CHANGE-REQUEST flags.
+type fakeNATBinder struct {
+	responses map[string]*natBindingResponse
+	errors    map[string]error
+}
+
+func (b *fakeNATBinder) Binding(
+	ctx context.Context, addr *net.UDPAddr, change uint32) (*natBindingResponse, error) {
+	key := fmt.Sprintf("%s/%d", addr.String(), change)
+	if err := b.errors[key]; err != nil {
+		return nil, err
+	}
+	if resp := b.responses[key]; resp != nil {
+		return resp, nil
+	}
+	return nil, errors.New(netxlite.FailureGenericTimeoutError)
+}
+
+func (b *fakeNATBinder) Close() error {
+	return nil
+}
+
+func TestClassifyNAT(t *testing.T) {
+	server := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 3478}
+	other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 3479}
+	withOther := func(mapped string) *natBindingResponse {
+		return &natBindingResponse{Mapped: mapped, Other: other}
+	}
+	plain := func(mapped string) *natBindingResponse {
+		return &natBindingResponse{Mapped: mapped}
+	}
+
+	var testCases = []struct {
+		name      string
+		binder    *fakeNATBinder
+		mapping   string
+		filtering string
+		err       error
+	}{{
+		name: "when the first binding fails",
+		binder: &fakeNATBinder{errors: map[string]error{
+			"10.0.0.1:3478/0": errors.New(netxlite.FailureConnectionRefused),
+		}},
+		err: errors.New(netxlite.FailureConnectionRefused),
+	}, {
+		name: "when the server does not support RFC 5780",
+		binder: &fakeNATBinder{responses: map[string]*natBindingResponse{
+			"10.0.0.1:3478/0": plain("1.2.3.4:5"),
+		}},
+		err: errNATBehaviorUnsupported,
+	}, {
+		name: "with an endpoint independent NAT",
+		binder: &fakeNATBinder{responses: map[string]*natBindingResponse{
+			"10.0.0.1:3478/0": withOther("1.2.3.4:5"),
+			"10.0.0.2:3478/0": plain("1.2.3.4:5"),
+			"10.0.0.1:3478/6": plain("1.2.3.4:5"),
+		}},
+		mapping:   NATBehaviorEndpointIndependent,
+		filtering: NATBehaviorEndpointIndependent,
+	}, {
+		name: "with an address dependent NAT",
+		binder: &fakeNATBinder{responses: map[string]*natBindingResponse{
+			"10.0.0.1:3478/0": withOther("1.2.3.4:5"),
+			"10.0.0.2:3478/0": plain("1.2.3.4:6"),
+			"10.0.0.2:3479/0": plain("1.2.3.4:6"),
+			"10.0.0.1:3478/2": plain("1.2.3.4:5"),
+		}},
+		mapping:   NATBehaviorAddressDependent,
+		filtering: NATBehaviorAddressDependent,
+	}, {
+		name: "with an address and port dependent NAT",
+		binder: &fakeNATBinder{responses: map[string]*natBindingResponse{
+			"10.0.0.1:3478/0": withOther("1.2.3.4:5"),
+			"10.0.0.2:3478/0": plain("1.2.3.4:6"),
+			"10.0.0.2:3479/0": plain("1.2.3.4:7"),
+		}},
+		mapping:   NATBehaviorAddressAndPortDependent,
+		filtering: NATBehaviorAddressAndPortDependent,
+	}, {
+		name: "when the filtering test fails",
+		binder: &fakeNATBinder{
+			responses: map[string]*natBindingResponse{
+				"10.0.0.1:3478/0": withOther("1.2.3.4:5"),
+				"10.0.0.2:3478/0": plain("1.2.3.4:5"),
+			},
+			errors: map[string]error{
+				"10.0.0.1:3478/6": errors.New(netxlite.FailureConnectionRefused),
+			},
+		},
+		err: errors.New(netxlite.FailureConnectionRefused),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, filtering, err := classifyNAT(context.Background(), tc.binder, server)
+			if (err == nil) != (tc.err == nil) || (err != nil && err.Error() != tc.err.Error()) {
+				t.Fatal("unexpected error", err)
+			}
+			if mapping != tc.mapping || filtering != tc.filtering {
+				t.Fatal("unexpected result", mapping, filtering)
+			}
+		})
+	}
+}
+
+// startFakeSTUNServer starts a STUN server that answers binding requests
+// including OTHER-ADDRESS (pointing to itself) and ignores the requests
+// containing CHANGE-REQUEST, like a server behind a filtering NAT.
+func startFakeSTUNServer(t *testing.T) net.PacketConn {
+	pconn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := pconn.LocalAddr().(*net.UDPAddr)
+	go func() {
+		buffer := make([]byte, 1500)
+		for {
+			count, addr, err := pconn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			request := &stun.Message{Raw: append([]byte{}, buffer[:count]...)}
+			if err := request.Decode(); err != nil || request.Contains(stun.AttrChangeRequest) {
+				continue
+			}
+			udpAddr := addr.(*net.UDPAddr)
+			response := stun.MustBuild(
+				stun.NewTransactionIDSetter(request.TransactionID),
+				stun.BindingSuccess,
+				&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port},
+				&stun.OtherAddress{IP: self.IP, Port: self.Port},
+			)
+			pconn.WriteTo(response.Raw, addr)
+		}
+	}()
+	return pconn
+}
+
+// newFastUDPNATBinder returns a udpNATBinder with short timeouts.
+func newFastUDPNATBinder(logger model.Logger) (natBinder, error) {
+	binder, err := newUDPNATBinder(logger)
+	if err != nil {
+		return nil, err
+	}
+	binder.attempts, binder.timeout = 1, 100*time.Millisecond
+	return binder, nil
+}
+
+func TestUDPNATBinder(t *testing.T) {
+	server := startFakeSTUNServer(t)
+	defer server.Close()
+	binder, err := newFastUDPNATBinder(log.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer binder.Close()
+	mapping, filtering, err := classifyNAT(
+		context.Background(), binder, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping != NATBehaviorEndpointIndependent || filtering != NATBehaviorAddressAndPortDependent {
+		t.Fatal("unexpected result", mapping, filtering)
+	}
+}
+
+func TestRunNATWithSeveralAddresses(t *testing.T) {
+	binder := &fakeNATBinder{responses: map[string]*natBindingResponse{
+		"10.0.0.1:3478/0": {Mapped: "1.2.3.4:5", Other: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 3479}},
+		"10.0.0.2:3478/0": {Mapped: "1.2.3.4:5"},
+		"10.0.0.1:3478/6": {Mapped: "1.2.3.4:5"},
+	}}
+	config := Config{
+		lookupHost: func(ctx context.Context, domain string) ([]string, error) {
+			return []string{"10.0.0.9", "10.0.0.1"}, nil
+		},
+		newNATBinder: func(logger model.Logger) (natBinder, error) {
+			return binder, nil
+		},
+	}
+	tk := &TestKeys{}
+	if err := tk.runNAT(context.Background(), config, log.Log, "stun.example.com:3478"); err != nil {
+		t.Fatal(err)
+	}
+	if tk.NATMapping == nil || *tk.NATMapping != NATBehaviorEndpointIndependent {
+		t.Fatal("unexpected NAT mapping")
+	}
+}
+
+func TestRunWithNATBehavior(t *testing.T) {
+	server := startFakeSTUNServer(t)
+	defer server.Close()
+	measurer := NewExperimentMeasurer(Config{
+		NATBehavior:  true,
+		newNATBinder: newFastUDPNATBinder,
+	})
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget("stun://" + server.LocalAddr().String())
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session:     &mockable.Session{MockableLogger: log.Log},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if tk.Failure != nil || tk.NATFailure != nil {
+		t.Fatal("unexpected failure", tk.Failure, tk.NATFailure)
+	}
+	if tk.NATMapping == nil || *tk.NATMapping != NATBehaviorEndpointIndependent {
+		t.Fatal("unexpected NAT mapping")
+	}
+	if tk.NATFiltering == nil || *tk.NATFiltering != NATBehaviorAddressAndPortDependent {
+		t.Fatal("unexpected NAT filtering")
+	}
+}
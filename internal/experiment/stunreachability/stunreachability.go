@@ -1,6 +1,14 @@
 // Package stunreachability contains the STUN reachability experiment.
 //
 // See https://github.com/ooni/spec/blob/master/nettests/ts-025-stun-reachability.md.
+//
+// Besides sending a binding request to stun:// URLs, this experiment can
+// optionally classify the NAT mapping and filtering behavior (RFC 5780) when
+// the server supports it and can attempt a TURN allocation when the input
+// is a turn:// URL (UDP by default or TCP using ?transport=tcp) or a turns://
+// URL (TLS). We measure a single server per measurement, so running with the
+// static default input gives us per-server results for the servers in the
+// stuninput package.
 package stunreachability
 
 import (
@@ -19,21 +27,39 @@ import (
 
 const (
 	testName    = "stunreachability"
-	testVersion = "0.4.0"
+	testVersion = "0.5.0"
 )
 
 // Config contains the experiment config.
 type Config struct {
-	dialContext func(ctx context.Context, network, address string) (net.Conn, error)
-	newClient   func(conn stun.Connection, options ...stun.ClientOption) (*stun.Client, error)
+	// NATBehavior indicates whether to classify the NAT behavior.
+	NATBehavior bool `ooni:"classify the NAT mapping and filtering behavior (RFC 5780)"`
+
+	// SafeTURNUsername is the username to use for TURN allocations. We use
+	// the Safe prefix so that we do not include it in the measurement.
+	SafeTURNUsername string `ooni:"username for TURN allocations"`
+
+	// SafeTURNPassword is the password to use for TURN allocations. We use
+	// the Safe prefix so that we do not include it in the measurement.
+	SafeTURNPassword string `ooni:"password for TURN allocations"`
+
+	dialContext  func(ctx context.Context, network, address string) (net.Conn, error)
+	lookupHost   func(ctx context.Context, domain string) ([]string, error)
+	newClient    func(conn stun.Connection, options ...stun.ClientOption) (*stun.Client, error)
+	newNATBinder func(logger model.Logger) (natBinder, error)
 }
 
 // TestKeys contains the experiment's result.
 type TestKeys struct {
-	Endpoint      string                 `json:"endpoint"`
-	Failure       *string                `json:"failure"`
-	NetworkEvents []tracex.NetworkEvent  `json:"network_events"`
-	Queries       []tracex.DNSQueryEntry `json:"queries"`
+	Endpoint       string                 `json:"endpoint"`
+	Failure        *string                `json:"failure"`
+	NATFailure     *string                `json:"nat_failure,omitempty"`
+	NATFiltering   *string                `json:"nat_filtering,omitempty"`
+	NATMapping     *string                `json:"nat_mapping,omitempty"`
+	NetworkEvents  []tracex.NetworkEvent  `json:"network_events"`
+	Queries        []tracex.DNSQueryEntry `json:"queries"`
+	RelayedAddress string                 `json:"relayed_address,omitempty"`
+	Transport      string                 `json:"transport,omitempty"`
 }
 
 func registerExtensions(m *model.Measurement) {
@@ -91,32 +117,45 @@ func (m *Measurer) Run(ctx context.Context, args *model.ExperimentArgs) error {
 	if URL.Port() == "" {
 		return errStunMissingPortInURL
 	}
-	if URL.Scheme != "stun" {
+	if URL.Scheme != "stun" && URL.Scheme != "turn" && URL.Scheme != "turns" {
 		return errUnsupportedURLScheme
 	}
-	if err := wrap(tk.run(ctx, m.config, sess, measurement, callbacks, URL.Host)); err != nil {
+	if err := wrap(tk.run(ctx, m.config, sess, measurement, callbacks, URL)); err != nil {
 		s := err.Error()
 		tk.Failure = &s
 		return nil // we want to submit this measurement
 	}
+	if URL.Scheme == "stun" && m.config.NATBehavior {
+		if err := wrap(tk.runNAT(ctx, m.config, sess.Logger(), URL.Host)); err != nil {
+			s := err.Error()
+			tk.NATFailure = &s
+		}
+	}
 	return nil
 }
 
 func (tk *TestKeys) run(
 	ctx context.Context, config Config, sess model.ExperimentSession,
 	measurement *model.Measurement, callbacks model.ExperimentCallbacks,
-	endpoint string,
+	URL *url.URL,
 ) error {
-	tk.Endpoint = endpoint
+	tk.Endpoint = URL.Host
 	saver := new(tracex.Saver)
 	begin := time.Now()
-	err := tk.do(ctx, config, netx.NewDialer(netx.Config{
+	dialer := netx.NewDialer(netx.Config{
 		ContextByteCounting: true,
 		Logger:              sess.Logger(),
 		ReadWriteSaver:      saver,
 		Saver:               saver,
-	}), endpoint)
-	sess.Logger().Infof("stunreachability: measuring: %s... %s", endpoint, model.ErrorToStringOrOK(err))
+	})
+	var err error
+	switch URL.Scheme {
+	case "turn", "turns":
+		err = tk.runTURN(ctx, config, dialer, sess.Logger(), URL)
+	default:
+		err = tk.do(ctx, config, dialer, URL.Host)
+	}
+	sess.Logger().Infof("stunreachability: measuring: %s... %s", URL.String(), model.ErrorToStringOrOK(err))
 	events := saver.Read()
 	tk.NetworkEvents = append(
 		tk.NetworkEvents, tracex.NewNetworkEventsList(begin, events)...,
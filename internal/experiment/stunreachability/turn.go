@@ -0,0 +1,147 @@
+package stunreachability
+
+//
+// TURN allocation
+//
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+	"github.com/pion/turn/v2"
+)
+
+// errUnsupportedTURNTransport means we don't support the TURN transport
+var errUnsupportedTURNTransport = errors.New("stun: unsupported TURN transport")
+
+// turnTransport returns the transport to use for a turn or turns URL.
+func turnTransport(URL *url.URL) (string, error) {
+	transport := URL.Query().Get("transport")
+	switch {
+	case URL.Scheme == "turns" && (transport == "" || transport == "tcp"):
+		return "tls", nil
+	case URL.Scheme == "turn" && (transport == "" || transport == "udp"):
+		return "udp", nil
+	case URL.Scheme == "turn" && transport == "tcp":
+		return "tcp", nil
+	default:
+		return "", errUnsupportedTURNTransport
+	}
+}
+
+// turnConn returns the net.PacketConn to use for the TURN client along
+// with the server address we have connected to. We always
+// use the given dialer, which resolves the server name, saves the network
+// events, and counts the bytes, and we use IPv4 because the TURN client only
+// supports IPv4 server addresses.
+func turnConn(ctx context.Context, dialer model.Dialer, logger model.Logger,
+	transport string, URL *url.URL) (net.PacketConn, string, error) {
+	if transport == "udp" {
+		conn, err := dialer.DialContext(ctx, "udp4", URL.Host)
+		if err != nil {
+			return nil, "", err
+		}
+		return &connectedPacketConn{Conn: conn}, conn.RemoteAddr().String(), nil
+	}
+	conn, err := dialer.DialContext(ctx, "tcp4", URL.Host)
+	if err != nil {
+		return nil, "", err
+	}
+	serverAddr := conn.RemoteAddr().String()
+	if transport == "tls" {
+		thx := netxlite.NewTLSHandshakerStdlib(logger)
+		tlsConn, _, err := thx.Handshake(ctx, conn, &tls.Config{
+			RootCAs:    netxlite.NewDefaultCertPool(),
+			ServerName: URL.Hostname(),
+		})
+		if err != nil {
+			conn.Close()
+			return nil, "", err
+		}
+		conn = tlsConn
+	}
+	return turn.NewSTUNConn(conn), serverAddr, nil
+}
+
+// connectedPacketConn adapts a connected UDP net.Conn to the net.PacketConn
+// used by the TURN client, which only talks with the server we dialed.
+type connectedPacketConn struct {
+	net.Conn
+}
+
+var _ net.PacketConn = &connectedPacketConn{}
+
+// ReadFrom implements net.PacketConn.ReadFrom.
+func (c *connectedPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	count, err := c.Conn.Read(b)
+	return count, c.Conn.RemoteAddr(), err
+}
+
+// WriteTo implements net.PacketConn.WriteTo.
+func (c *connectedPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Conn.Write(b)
+}
+
+// runTURN attempts to create a TURN allocation using the given URL.
+func (tk *TestKeys) runTURN(ctx context.Context, config Config,
+	dialer model.Dialer, logger model.Logger, URL *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	transport, err := turnTransport(URL)
+	if err != nil {
+		return err
+	}
+	tk.Transport = transport
+	conn, serverAddr, err := turnConn(ctx, dialer, logger, transport, URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Implementation note: we pass the client the address we have already
+	// connected to, such that it does not resolve the server name again.
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: serverAddr,
+		TURNServerAddr: serverAddr,
+		Username:       config.SafeTURNUsername,
+		Password:       config.SafeTURNPassword,
+		Software:       "ooniprobe",
+		Conn:           conn,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Listen(); err != nil {
+		return err
+	}
+	// Implementation note: Allocate does not take a context, so we run it
+	// in the background and close the client when the context is done, which
+	// causes all the pending transactions to fail.
+	type allocateResult struct {
+		relay net.PacketConn
+		err   error
+	}
+	out := make(chan *allocateResult, 1)
+	go func() {
+		relay, err := client.Allocate()
+		out <- &allocateResult{relay: relay, err: err}
+	}()
+	var result *allocateResult
+	select {
+	case result = <-out:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if result.err != nil {
+		return result.err
+	}
+	defer result.relay.Close()
+	tk.RelayedAddress = result.relay.LocalAddr().String()
+	return nil
+}
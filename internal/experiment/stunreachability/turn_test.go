@@ -0,0 +1,143 @@
+package stunreachability
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/pion/turn/v2"
+)
+
+func TestTURNTransport(t *testing.T) {
+	var testCases = []struct {
+		input     string
+		transport string
+		err       error
+	}{
+		{input: "turn://example.com:3478", transport: "udp"},
+		{input: "turn://example.com:3478?transport=udp", transport: "udp"},
+		{input: "turn://example.com:3478?transport=tcp", transport: "tcp"},
+		{input: "turns://example.com:5349", transport: "tls"},
+		{input: "turns://example.com:5349?transport=tcp", transport: "tls"},
+		{input: "turns://example.com:5349?transport=udp", err: errUnsupportedTURNTransport},
+		{input: "turn://example.com:3478?transport=sctp", err: errUnsupportedTURNTransport},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			URL, err := url.Parse(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport, err := turnTransport(URL)
+			if !errors.Is(err, tc.err) {
+				t.Fatal("unexpected error", err)
+			}
+			if transport != tc.transport {
+				t.Fatal("unexpected transport", transport)
+			}
+		})
+	}
+}
+
+// startTURNServer starts a local TURN server listening on both UDP and TCP.
+func startTURNServer(t *testing.T) (*turn.Server, string, string) {
+	udpConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := &turn.RelayAddressGeneratorStatic{
+		RelayAddress: net.ParseIP("127.0.0.1"),
+		Address:      "127.0.0.1",
+	}
+	key := turn.GenerateAuthKey("ooni", "ooni.org", "antani")
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm: "ooni.org",
+		AuthHandler: func(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+			return key, username == "ooni"
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            udpConn,
+			RelayAddressGenerator: generator,
+		}},
+		ListenerConfigs: []turn.ListenerConfig{{
+			Listener:              tcpListener,
+			RelayAddressGenerator: generator,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, udpConn.LocalAddr().String(), tcpListener.Addr().String()
+}
+
+// runTURNMeasurement runs the experiment with the given input and config.
+func runTURNMeasurement(t *testing.T, config Config, input string) *TestKeys {
+	measurer := NewExperimentMeasurer(config)
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	args := &model.ExperimentArgs{
+		Callbacks:   model.NewPrinterCallbacks(log.Log),
+		Measurement: measurement,
+		Session:     &mockable.Session{MockableLogger: log.Log},
+	}
+	if err := measurer.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	return measurement.TestKeys.(*TestKeys)
+}
+
+func TestRunWithTURN(t *testing.T) {
+	server, udpEndpoint, tcpEndpoint := startTURNServer(t)
+	defer server.Close()
+	config := Config{SafeTURNUsername: "ooni", SafeTURNPassword: "antani"}
+
+	t.Run("we can allocate using UDP", func(t *testing.T) {
+		tk := runTURNMeasurement(t, config, "turn://"+udpEndpoint)
+		if tk.Failure != nil {
+			t.Fatal("unexpected failure", *tk.Failure)
+		}
+		if tk.Transport != "udp" || tk.RelayedAddress == "" {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if len(tk.NetworkEvents) <= 0 {
+			t.Fatal("expected network events")
+		}
+	})
+
+	t.Run("we can allocate using TCP", func(t *testing.T) {
+		tk := runTURNMeasurement(t, config, "turn://"+tcpEndpoint+"?transport=tcp")
+		if tk.Failure != nil {
+			t.Fatal("unexpected failure", *tk.Failure)
+		}
+		if tk.Transport != "tcp" || tk.RelayedAddress == "" {
+			t.Fatal("unexpected test keys", tk)
+		}
+		if len(tk.NetworkEvents) <= 0 {
+			t.Fatal("expected network events")
+		}
+	})
+
+	t.Run("we fail with invalid credentials", func(t *testing.T) {
+		config := Config{SafeTURNUsername: "ooni", SafeTURNPassword: "invalid"}
+		tk := runTURNMeasurement(t, config, "turn://"+udpEndpoint)
+		if tk.Failure == nil || tk.RelayedAddress != "" {
+			t.Fatal("expected a failure", tk)
+		}
+	})
+
+	t.Run("we fail with an unsupported transport", func(t *testing.T) {
+		tk := runTURNMeasurement(t, config, "turn://"+udpEndpoint+"?transport=sctp")
+		if tk.Failure == nil {
+			t.Fatal("expected a failure")
+		}
+	})
+}
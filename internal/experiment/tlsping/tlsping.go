@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"net"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/measurexlite"
@@ -26,7 +25,7 @@ const (
 // Config contains the experiment configuration.
 type Config struct {
 	// ALPN allows to specify which ALPN or ALPNs to send.
-	ALPN string `ooni:"space separated list of ALPNs to use"`
+	ALPN []string `ooni:"list of ALPNs to use"`
 
 	// Delay is the delay between each repetition (in milliseconds).
 	Delay int64 `ooni:"number of milliseconds to wait before sending each ping"`
@@ -38,11 +37,11 @@ type Config struct {
 	SNI string `ooni:"the SNI value to use"`
 }
 
-func (c *Config) alpn() string {
-	if c.ALPN != "" {
+func (c *Config) alpn() []string {
+	if len(c.ALPN) > 0 {
 		return c.ALPN
 	}
-	return "h2 http/1.1"
+	return []string{"h2", "http/1.1"}
 }
 
 func (c *Config) delay() time.Duration {
@@ -170,7 +169,7 @@ func (m *Measurer) tlsConnectAndHandshake(ctx context.Context, index int64,
 	}
 	trace := measurexlite.NewTrace(index, zeroTime)
 	dialer := trace.NewDialerWithoutResolver(logger)
-	alpn := strings.Split(m.config.alpn(), " ")
+	alpn := m.config.alpn()
 	sni := m.config.sni(address)
 	ol := measurexlite.NewOperationLogger(logger, "TLSPing #%d %s %s %v", index, address, sni, alpn)
 	conn, err := dialer.DialContext(ctx, "tcp", address)
@@ -9,13 +9,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
 
 func TestConfig_alpn(t *testing.T) {
 	c := Config{}
-	if c.alpn() != "h2 http/1.1" {
+	if diff := cmp.Diff([]string{"h2", "http/1.1"}, c.alpn()); diff != "" {
 		t.Fatal("invalid default alpn list")
 	}
 }
@@ -41,7 +42,7 @@ func TestMeasurer_run(t *testing.T) {
 	// runHelper is an helper function to run this set of tests.
 	runHelper := func(ctx context.Context, input string) (*model.Measurement, model.ExperimentMeasurer, error) {
 		m := NewExperimentMeasurer(Config{
-			ALPN:        "http/1.1",
+			ALPN:        []string{"http/1.1"},
 			Delay:       1, // millisecond
 			Repetitions: expectedPings,
 		})
@@ -36,7 +36,7 @@ type Config struct {
 	DisableProgress bool `ooni:"Disable printing progress messages"`
 
 	// RendezvousMethod allows to choose the method with which to rendezvous.
-	RendezvousMethod string `ooni:"Choose the method with which to rendezvous. Must be one of amp and domain_fronting. Leaving this field empty means we should use the default."`
+	RendezvousMethod string `ooni:"Choose the method with which to rendezvous. Must be one of amp and domain_fronting. Leaving this field empty means we should use the default." ooni_enum:"amp,domain_fronting"`
 }
 
 // TestKeys contains the experiment's result.
@@ -0,0 +1,451 @@
+// Package fakebackend implements an in-process fake OONI backend that
+// we can use for integration testing without the real OONI backend. Because
+// it does not implement geolocation, make sure you also mock the location
+// lookup or allow it to use the network.
+//
+// The Backend implements the bouncer, check-in, register and login, the
+// collector, tor targets and psiphon config APIs. You can program its
+// state (e.g., to inject failures) and inspect what clients submitted.
+//
+// Typical usage:
+//
+//	backend := fakebackend.New()
+//	srvr := httptest.NewServer(backend)
+//	defer srvr.Close()
+//	config := backend.BackendConfig(srvr.URL) // use with engine.SessionConfig
+package fakebackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// Names of the endpoints implemented by the Backend, which you
+// can use with InjectFailure and Calls.
+const (
+	EndpointCheckIn       = "check-in"
+	EndpointCloseReport   = "close-report"
+	EndpointLogin         = "login"
+	EndpointOpenReport    = "open-report"
+	EndpointPsiphonConfig = "psiphon-config"
+	EndpointRegister      = "register"
+	EndpointTestHelpers   = "test-helpers"
+	EndpointTorTargets    = "tor-targets"
+	EndpointUpdateReport  = "update-report"
+)
+
+// Report is a report opened with the collector.
+type Report struct {
+	// ID is the report ID.
+	ID string
+
+	// Template is the template used to open the report.
+	Template model.OOAPIReportTemplate
+
+	// Closed indicates whether the report has been closed.
+	Closed bool
+}
+
+// Measurement is a measurement submitted to the collector.
+type Measurement struct {
+	// ReportID is the ID of the report.
+	ReportID string
+
+	// MeasurementID is the ID we assigned to the measurement.
+	MeasurementID string
+
+	// Content is the raw measurement.
+	Content json.RawMessage
+}
+
+// failure is a failure injected using InjectFailure.
+type failure struct {
+	statusCode int
+	times      int
+}
+
+// Backend is a fake OONI backend. It implements http.Handler. The
+// exported fields are the programmable state and you MUST NOT change
+// them while the Backend is serving requests. Use New to construct.
+type Backend struct {
+	// CheckInFeatures contains the features returned by the check-in API.
+	CheckInFeatures map[string]bool
+
+	// PsiphonConfig is the psiphon config returned to logged-in clients.
+	PsiphonConfig []byte
+
+	// TestHelpers contains the test helpers returned by the bouncer.
+	TestHelpers map[string][]model.OOAPIService
+
+	// TorTargets contains the tor targets returned to logged-in clients.
+	TorTargets map[string]model.OOAPITorTarget
+
+	// URLs contains the URLs returned by the check-in API, which filters
+	// them according to the category codes requested by the client.
+	URLs []model.OOAPIURLInfo
+
+	// calls counts the calls for each endpoint.
+	calls map[string]int
+
+	// clients maps each registered client ID to its password.
+	clients map[string]string
+
+	// failures contains the injected failures.
+	failures map[string]*failure
+
+	// measurements contains the submitted measurements.
+	measurements []*Measurement
+
+	// mu provides mutual exclusion.
+	mu sync.Mutex
+
+	// mux routes the requests.
+	mux *http.ServeMux
+
+	// reports contains the open reports in order.
+	reports []*Report
+
+	// tokens contains the tokens of logged-in clients.
+	tokens map[string]bool
+}
+
+var _ http.Handler = &Backend{}
+
+// New creates a new Backend with reasonable defaults for all the
+// programmable fields (i.e., the exported fields).
+func New() *Backend {
+	b := &Backend{
+		CheckInFeatures: map[string]bool{},
+		PsiphonConfig:   []byte(`{}`),
+		TestHelpers: map[string][]model.OOAPIService{
+			"web-connectivity": {{
+				Address: "https://0.th.ooni.org",
+				Type:    "https",
+			}},
+		},
+		TorTargets: map[string]model.OOAPITorTarget{
+			"fake-or-port": {
+				Address:  "127.0.0.1:9001",
+				Name:     "fake",
+				Protocol: "or_port",
+				Source:   "fakebackend",
+			},
+		},
+		URLs: []model.OOAPIURLInfo{{
+			CategoryCode: "NEWS",
+			CountryCode:  "XX",
+			URL:          "https://www.example.com/",
+		}, {
+			CategoryCode: "SRCH",
+			CountryCode:  "XX",
+			URL:          "https://www.example.org/",
+		}},
+		calls:    map[string]int{},
+		clients:  map[string]string{},
+		failures: map[string]*failure{},
+		tokens:   map[string]bool{},
+	}
+	b.mux = http.NewServeMux()
+	b.mux.HandleFunc("/api/v1/test-helpers", b.handle(EndpointTestHelpers, "GET", b.testHelpers))
+	b.mux.HandleFunc("/api/v1/check-in", b.handle(EndpointCheckIn, "POST", b.checkIn))
+	b.mux.HandleFunc("/api/v1/register", b.handle(EndpointRegister, "POST", b.register))
+	b.mux.HandleFunc("/api/v1/login", b.handle(EndpointLogin, "POST", b.login))
+	b.mux.HandleFunc("/api/v1/test-list/tor-targets",
+		b.handle(EndpointTorTargets, "GET", b.withAuth(b.torTargets)))
+	b.mux.HandleFunc("/api/v1/test-list/psiphon-config",
+		b.handle(EndpointPsiphonConfig, "GET", b.withAuth(b.psiphonConfig)))
+	b.mux.HandleFunc("/report", b.handle(EndpointOpenReport, "POST", b.openReport))
+	b.mux.HandleFunc("/report/", b.routeReport)
+	return b
+}
+
+// BackendConfig returns the config for using this backend, assuming
+// it is serving requests at the given base URL.
+func (b *Backend) BackendConfig(baseURL string) *model.BackendConfig {
+	return &model.BackendConfig{
+		ProbeServices: []model.OOAPIService{{
+			Address: baseURL,
+			Type:    "https",
+		}},
+	}
+}
+
+// InjectFailure causes the given endpoint to fail with the given status
+// code for the given number of times. If times is zero or negative, the
+// endpoint will fail until you call ClearFailures.
+func (b *Backend) InjectFailure(endpoint string, statusCode, times int) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.failures[endpoint] = &failure{statusCode: statusCode, times: times}
+}
+
+// ClearFailures removes all the injected failures.
+func (b *Backend) ClearFailures() {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	b.failures = map[string]*failure{}
+}
+
+// Calls returns the number of times the given endpoint has been called.
+func (b *Backend) Calls(endpoint string) int {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	return b.calls[endpoint]
+}
+
+// Measurements returns the measurements submitted so far.
+func (b *Backend) Measurements() []*Measurement {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	return append([]*Measurement{}, b.measurements...)
+}
+
+// Reports returns a copy of the reports opened so far.
+func (b *Backend) Reports() []Report {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+	var out []Report
+	for _, r := range b.reports {
+		out = append(out, *r)
+	}
+	return out
+}
+
+// ServeHTTP implements http.Handler.
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// handler is the signature of the handlers of each endpoint. They run
+// with the mutex locked and return the status code and the body to send,
+// which we serialize as JSON unless it's already a []byte.
+type handler func(r *http.Request) (int, any)
+
+// handle wraps a handler to check the method, count the calls and
+// apply the injected failures, if any.
+func (b *Backend) handle(endpoint, method string, fn handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		b.mu.Lock()
+		b.calls[endpoint]++
+		if f := b.failures[endpoint]; f != nil {
+			if f.times > 0 {
+				if f.times--; f.times <= 0 {
+					delete(b.failures, endpoint)
+				}
+			}
+			b.mu.Unlock()
+			w.WriteHeader(f.statusCode)
+			return
+		}
+		status, body := fn(r)
+		b.mu.Unlock()
+		data, ok := body.([]byte)
+		if !ok {
+			var err error
+			data, err = json.Marshal(body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+}
+
+// withAuth ensures that the client sent a valid bearer token.
+func (b *Backend) withAuth(fn handler) handler {
+	return func(r *http.Request) (int, any) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !b.tokens[token] {
+			return http.StatusUnauthorized, map[string]string{"error": "unauthorized"}
+		}
+		return fn(r)
+	}
+}
+
+// errBadRequest is the body we return in case of bad requests.
+var errBadRequest = map[string]string{"error": "bad request"}
+
+func (b *Backend) testHelpers(r *http.Request) (int, any) {
+	return http.StatusOK, b.TestHelpers
+}
+
+func (b *Backend) checkIn(r *http.Request) (int, any) {
+	var config model.OOAPICheckInConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		return http.StatusBadRequest, errBadRequest
+	}
+	categories := map[string]bool{}
+	for _, c := range config.WebConnectivity.CategoryCodes {
+		categories[c] = true
+	}
+	var urls []model.OOAPIURLInfo
+	for _, u := range b.URLs {
+		if len(categories) > 0 && !categories[u.CategoryCode] {
+			continue
+		}
+		urls = append(urls, u)
+	}
+	report := b.newReportLocked(model.OOAPIReportTemplate{
+		DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
+		Format:            model.OOAPIReportDefaultFormat,
+		ProbeASN:          config.ProbeASN,
+		ProbeCC:           config.ProbeCC,
+		SoftwareName:      config.SoftwareName,
+		SoftwareVersion:   config.SoftwareVersion,
+		TestName:          "web_connectivity",
+	})
+	return http.StatusOK, &model.OOAPICheckInResult{
+		Conf:     model.OOAPICheckInResultConfig{Features: b.CheckInFeatures},
+		ProbeASN: config.ProbeASN,
+		ProbeCC:  config.ProbeCC,
+		Tests: model.OOAPICheckInResultNettests{
+			WebConnectivity: &model.OOAPICheckInInfoWebConnectivity{
+				ReportID: report.ID,
+				URLs:     urls,
+			},
+		},
+		UTCTime: time.Now().UTC(),
+		V:       1,
+	}
+}
+
+func (b *Backend) register(r *http.Request) (int, any) {
+	var req model.OOAPIRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		return http.StatusBadRequest, errBadRequest
+	}
+	clientID := fmt.Sprintf("fake-client-%d", len(b.clients)+1)
+	b.clients[clientID] = req.Password
+	return http.StatusOK, &model.OOAPIRegisterResponse{ClientID: clientID}
+}
+
+func (b *Backend) login(r *http.Request) (int, any) {
+	var creds model.OOAPILoginCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		return http.StatusBadRequest, errBadRequest
+	}
+	if pwd, found := b.clients[creds.Username]; !found || pwd != creds.Password {
+		return http.StatusUnauthorized, map[string]string{"error": "wrong credentials"}
+	}
+	token := fmt.Sprintf("fake-token-%d", len(b.tokens)+1)
+	b.tokens[token] = true
+	return http.StatusOK, &model.OOAPILoginAuth{
+		Expire: time.Now().Add(time.Hour),
+		Token:  token,
+	}
+}
+
+func (b *Backend) torTargets(r *http.Request) (int, any) {
+	return http.StatusOK, b.TorTargets
+}
+
+func (b *Backend) psiphonConfig(r *http.Request) (int, any) {
+	return http.StatusOK, b.PsiphonConfig
+}
+
+// newReportLocked creates a new report. This function assumes
+// that the caller has already locked the mutex.
+func (b *Backend) newReportLocked(tmpl model.OOAPIReportTemplate) *Report {
+	report := &Report{
+		ID: fmt.Sprintf("20230101T000000Z_%s_%s_n1_%08d",
+			tmpl.TestName, tmpl.ProbeCC, len(b.reports)+1),
+		Template: tmpl,
+	}
+	b.reports = append(b.reports, report)
+	return report
+}
+
+// findReportLocked returns the report with the given ID or nil. This
+// function assumes that the caller has already locked the mutex.
+func (b *Backend) findReportLocked(reportID string) *Report {
+	for _, r := range b.reports {
+		if r.ID == reportID {
+			return r
+		}
+	}
+	return nil
+}
+
+func (b *Backend) openReport(r *http.Request) (int, any) {
+	var tmpl model.OOAPIReportTemplate
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		return http.StatusBadRequest, errBadRequest
+	}
+	if tmpl.Format != model.OOAPIReportDefaultFormat || tmpl.TestName == "" {
+		return http.StatusBadRequest, errBadRequest
+	}
+	report := b.newReportLocked(tmpl)
+	return http.StatusOK, &model.OOAPICollectorOpenResponse{
+		BackendVersion:   "fakebackend",
+		ReportID:         report.ID,
+		SupportedFormats: []string{model.OOAPIReportDefaultFormat},
+	}
+}
+
+// routeReport routes /report/{id} and /report/{id}/close.
+func (b *Backend) routeReport(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/report/")
+	if reportID, found := cutSuffix(path, "/close"); found {
+		b.handle(EndpointCloseReport, "POST", func(r *http.Request) (int, any) {
+			return b.closeReport(reportID)
+		})(w, r)
+		return
+	}
+	b.handle(EndpointUpdateReport, "POST", func(r *http.Request) (int, any) {
+		return b.updateReport(path, r)
+	})(w, r)
+}
+
+// cutSuffix is like strings.CutSuffix, which is not available in go1.19.
+func cutSuffix(s, suffix string) (string, bool) {
+	if !strings.HasSuffix(s, suffix) {
+		return s, false
+	}
+	return strings.TrimSuffix(s, suffix), true
+}
+
+func (b *Backend) updateReport(reportID string, r *http.Request) (int, any) {
+	report := b.findReportLocked(reportID)
+	if report == nil || report.Closed {
+		return http.StatusNotFound, map[string]string{"error": "report not found"}
+	}
+	var req struct {
+		Format  string          `json:"format"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, errBadRequest
+	}
+	if req.Format != model.OOAPIReportDefaultFormat || len(req.Content) <= 0 {
+		return http.StatusBadRequest, errBadRequest
+	}
+	measurementID := fmt.Sprintf("fake-measurement-%d", len(b.measurements)+1)
+	b.measurements = append(b.measurements, &Measurement{
+		ReportID:      reportID,
+		MeasurementID: measurementID,
+		Content:       req.Content,
+	})
+	return http.StatusOK, &model.OOAPICollectorUpdateResponse{MeasurementID: measurementID}
+}
+
+func (b *Backend) closeReport(reportID string) (int, any) {
+	report := b.findReportLocked(reportID)
+	if report == nil || report.Closed {
+		return http.StatusNotFound, map[string]string{"error": "report not found"}
+	}
+	report.Closed = true
+	return http.StatusOK, map[string]string{}
+}
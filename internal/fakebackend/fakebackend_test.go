@@ -0,0 +1,214 @@
+package fakebackend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/httpx"
+	"github.com/ooni/probe-cli/v3/internal/legacy/mockable"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/probeservices"
+)
+
+func newClient(t *testing.T, URL string) *probeservices.Client {
+	client, err := probeservices.NewClient(&mockable.Session{
+		MockableHTTPClient: http.DefaultClient,
+		MockableLogger:     log.Log,
+	}, model.OOAPIService{
+		Address: URL,
+		Type:    "https",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBackend(t *testing.T) {
+	t.Run("we can use all the APIs", func(t *testing.T) {
+		backend := New()
+		srvr := httptest.NewServer(backend)
+		defer srvr.Close()
+		client := newClient(t, srvr.URL)
+		ctx := context.Background()
+
+		ths, err := client.GetTestHelpers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ths["web-connectivity"]) != 1 {
+			t.Fatal("unexpected test helpers", ths)
+		}
+
+		result, err := client.CheckIn(ctx, model.OOAPICheckInConfig{
+			ProbeASN: "AS0",
+			ProbeCC:  "ZZ",
+			WebConnectivity: model.OOAPICheckInConfigWebConnectivity{
+				CategoryCodes: []string{"NEWS"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wc := result.Tests.WebConnectivity
+		if len(wc.URLs) != 1 || wc.URLs[0].CategoryCode != "NEWS" || wc.ReportID == "" {
+			t.Fatal("unexpected check-in result", wc)
+		}
+
+		if err := client.MaybeRegister(ctx, model.OOAPIProbeMetadata{
+			Platform:        "linux",
+			ProbeASN:        "AS0",
+			ProbeCC:         "ZZ",
+			SoftwareName:    "miniooni",
+			SoftwareVersion: "0.1.0-dev",
+			SupportedTests:  []string{"web_connectivity"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.MaybeLogin(ctx); err != nil {
+			t.Fatal(err)
+		}
+		targets, err := client.FetchTorTargets(ctx, "ZZ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(targets) != 1 {
+			t.Fatal("unexpected tor targets", targets)
+		}
+		config, err := client.FetchPsiphonConfig(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(config) != "{}" {
+			t.Fatal("unexpected psiphon config", string(config))
+		}
+
+		report, err := client.OpenReport(ctx, model.OOAPIReportTemplate{
+			DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
+			Format:            model.OOAPIReportDefaultFormat,
+			ProbeCC:           "ZZ",
+			TestName:          "dummy",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := report.SubmitMeasurement(ctx, &model.Measurement{TestName: "dummy"}); err != nil {
+			t.Fatal(err)
+		}
+
+		measurements := backend.Measurements()
+		if len(measurements) != 1 || measurements[0].ReportID != report.ReportID() {
+			t.Fatal("unexpected measurements", measurements)
+		}
+		var m model.Measurement
+		if err := json.Unmarshal(measurements[0].Content, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.TestName != "dummy" || m.ReportID != report.ReportID() {
+			t.Fatal("unexpected measurement", m)
+		}
+		if reports := backend.Reports(); len(reports) != 2 || reports[1].Template.TestName != "dummy" {
+			t.Fatal("unexpected reports", reports)
+		}
+		if backend.Calls(EndpointUpdateReport) != 1 {
+			t.Fatal("unexpected number of calls")
+		}
+	})
+
+	t.Run("we can close a report", func(t *testing.T) {
+		backend := New()
+		srvr := httptest.NewServer(backend)
+		defer srvr.Close()
+		client := newClient(t, srvr.URL)
+		ctx := context.Background()
+		report, err := client.OpenReport(ctx, model.OOAPIReportTemplate{
+			DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
+			Format:            model.OOAPIReportDefaultFormat,
+			TestName:          "dummy",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		closeURL := srvr.URL + "/report/" + report.ReportID() + "/close"
+		for _, expect := range []int{http.StatusOK, http.StatusNotFound} {
+			resp, err := http.Post(closeURL, "application/json", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != expect {
+				t.Fatal("unexpected status code", resp.StatusCode)
+			}
+		}
+		if reports := backend.Reports(); !reports[0].Closed {
+			t.Fatal("expected the report to be closed")
+		}
+		if err := report.SubmitMeasurement(ctx, &model.Measurement{}); err == nil {
+			t.Fatal("expected an error when submitting to a closed report")
+		}
+	})
+
+	t.Run("we can inject failures", func(t *testing.T) {
+		backend := New()
+		srvr := httptest.NewServer(backend)
+		defer srvr.Close()
+		client := newClient(t, srvr.URL)
+		ctx := context.Background()
+		backend.InjectFailure(EndpointTestHelpers, 500, 1)
+		if _, err := client.GetTestHelpers(ctx); err == nil {
+			t.Fatal("expected an error")
+		}
+		if _, err := client.GetTestHelpers(ctx); err != nil {
+			t.Fatal(err)
+		}
+		backend.InjectFailure(EndpointCheckIn, 503, 0)
+		for i := 0; i < 3; i++ {
+			if _, err := client.CheckIn(ctx, model.OOAPICheckInConfig{}); err == nil {
+				t.Fatal("expected an error")
+			}
+		}
+		backend.ClearFailures()
+		if _, err := client.CheckIn(ctx, model.OOAPICheckInConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if backend.Calls(EndpointCheckIn) != 4 {
+			t.Fatal("unexpected number of calls")
+		}
+	})
+
+	t.Run("we require authentication", func(t *testing.T) {
+		backend := New()
+		srvr := httptest.NewServer(backend)
+		defer srvr.Close()
+		client := &httpx.APIClientTemplate{
+			BaseURL:    srvr.URL,
+			HTTPClient: http.DefaultClient,
+			Logger:     log.Log,
+		}
+		ctx := context.Background()
+		var targets map[string]model.OOAPITorTarget
+		err := client.BuildWithAuthorization("Bearer antani").GetJSON(
+			ctx, "/api/v1/test-list/tor-targets", &targets)
+		if err == nil || !strings.HasSuffix(err.Error(), "401 Unauthorized") {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("we reject the wrong method", func(t *testing.T) {
+		srvr := httptest.NewServer(New())
+		defer srvr.Close()
+		resp, err := http.Get(srvr.URL + "/report")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+	})
+}
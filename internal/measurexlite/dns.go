@@ -105,6 +105,11 @@ func (tx *Trace) NewParallelDNSOverHTTPSResolver(logger model.Logger, URL string
 	return tx.wrapResolver(tx.newParallelDNSOverHTTPSResolver(logger, URL))
 }
 
+// NewParallelDNSOverTLSResolver returns a trace-aware parallel DoT resolver
+func (tx *Trace) NewParallelDNSOverTLSResolver(logger model.Logger, dialer model.Dialer, address string) model.Resolver {
+	return tx.wrapResolver(tx.newParallelDNSOverTLSResolver(logger, dialer, address))
+}
+
 // OnDNSRoundTripForLookupHost implements model.Trace.OnDNSRoundTripForLookupHost
 func (tx *Trace) OnDNSRoundTripForLookupHost(started time.Time, reso model.Resolver, query model.DNSQuery,
 	response model.DNSResponse, addrs []string, err error, finished time.Time) {
@@ -304,6 +304,20 @@ func TestNewWrappedResolvers(t *testing.T) {
 		}
 	})
 
+	t.Run("NewParallelDNSOverTLSResolver works as intended", func(t *testing.T) {
+		zeroTime := time.Now()
+		trace := NewTrace(0, zeroTime)
+		dialer := netxlite.NewDialerWithStdlibResolver(model.DiscardLogger)
+		resolver := trace.NewParallelDNSOverTLSResolver(model.DiscardLogger, dialer, "1.1.1.1:853")
+		resolvert := resolver.(*resolverTrace)
+		if resolvert.tx != trace {
+			t.Fatal("invalid trace")
+		}
+		if resolver.Network() != "dot" {
+			t.Fatal("unexpected resolver network")
+		}
+	})
+
 	t.Run("NewParallelUDPResolver works as intended", func(t *testing.T) {
 		zeroTime := time.Now()
 		trace := NewTrace(0, zeroTime)
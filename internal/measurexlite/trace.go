@@ -50,6 +50,10 @@ type Trace struct {
 	// calls to the netxlite.NewParallelDNSOverHTTPSUDPResolver factory.
 	NewParallelDNSOverHTTPSResolverFn func(logger model.Logger, URL string) model.Resolver
 
+	// NewParallelDNSOverTLSResolverFn is OPTIONAL and can be used to overide
+	// calls to the netxlite.NewParallelDNSOverTLSResolver factory.
+	NewParallelDNSOverTLSResolverFn func(logger model.Logger, dialer model.Dialer, address string) model.Resolver
+
 	// NewDialerWithoutResolverFn is OPTIONAL and can be used to override
 	// calls to the netxlite.NewDialerWithoutResolver factory.
 	NewDialerWithoutResolverFn func(dl model.DebugLogger) model.Dialer
@@ -187,6 +191,15 @@ func (tx *Trace) newParallelDNSOverHTTPSResolver(logger model.Logger, URL string
 	return netxlite.NewParallelDNSOverHTTPSResolver(logger, URL)
 }
 
+// newParallelDNSOverTLSResolver indirectly calls the passed netxlite.NewParallelDNSOverTLSResolver
+// thus allowing us to mock this function for testing
+func (tx *Trace) newParallelDNSOverTLSResolver(logger model.Logger, dialer model.Dialer, address string) model.Resolver {
+	if tx.NewParallelDNSOverTLSResolverFn != nil {
+		return tx.NewParallelDNSOverTLSResolverFn(logger, dialer, address)
+	}
+	return netxlite.NewParallelDNSOverTLSResolver(logger, dialer, address)
+}
+
 // newDialerWithoutResolver indirectly calls netxlite.NewDialerWithoutResolver
 // thus allowing us to mock this func for testing.
 func (tx *Trace) newDialerWithoutResolver(dl model.DebugLogger) model.Dialer {
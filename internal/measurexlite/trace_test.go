@@ -67,6 +67,12 @@ func TestNewTrace(t *testing.T) {
 			}
 		})
 
+		t.Run("NewParallelDNSOverTLSResolverFn is nil", func(t *testing.T) {
+			if trace.NewParallelDNSOverTLSResolverFn != nil {
+				t.Fatal("expected nil NewParallelDNSOverTLSResolverFn")
+			}
+		})
+
 		t.Run("NewDialerWithoutResolverFn is nil", func(t *testing.T) {
 			if trace.NewDialerWithoutResolverFn != nil {
 				t.Fatal("expected nil NewDialerWithoutResolverFn")
@@ -323,6 +329,48 @@ func TestTrace(t *testing.T) {
 		})
 	})
 
+	t.Run("NewParallelDNSOverTLSResolverFn works as intended", func(t *testing.T) {
+		t.Run("when not nil", func(t *testing.T) {
+			mockedErr := errors.New("mocked")
+			tx := &Trace{
+				NewParallelDNSOverTLSResolverFn: func(logger model.Logger, dialer model.Dialer, address string) model.Resolver {
+					return &mocks.Resolver{
+						MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+							return []string{}, mockedErr
+						},
+					}
+				},
+			}
+			dialer := netxlite.NewDialerWithStdlibResolver(model.DiscardLogger)
+			resolver := tx.newParallelDNSOverTLSResolver(model.DiscardLogger, dialer, "1.1.1.1:853")
+			ctx := context.Background()
+			addrs, err := resolver.LookupHost(ctx, "example.com")
+			if !errors.Is(err, mockedErr) {
+				t.Fatal("unexpected err", err)
+			}
+			if len(addrs) != 0 {
+				t.Fatal("expected array of size 0")
+			}
+		})
+
+		t.Run("when nil", func(t *testing.T) {
+			tx := &Trace{
+				NewParallelDNSOverTLSResolverFn: nil,
+			}
+			dialer := netxlite.NewDialerWithStdlibResolver(model.DiscardLogger)
+			resolver := tx.newParallelDNSOverTLSResolver(model.DiscardLogger, dialer, "1.1.1.1:853")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			addrs, err := resolver.LookupHost(ctx, "example.com")
+			if err == nil || err.Error() != netxlite.FailureInterrupted {
+				t.Fatal("unexpected err", err)
+			}
+			if len(addrs) != 0 {
+				t.Fatal("expected array of size 0")
+			}
+		})
+	})
+
 	t.Run("NewDialerWithoutResolverFn works as intended", func(t *testing.T) {
 		t.Run("when not nil", func(t *testing.T) {
 			mockedErr := errors.New("mocked")
@@ -0,0 +1,27 @@
+package model
+
+//
+// Configuration of self-hosted backends
+//
+
+// BackendConfig allows to replace the OONI backend infrastructure with
+// a self-hosted deployment. Every field is OPTIONAL and, when a field is
+// empty, we use the corresponding default OONI infrastructure.
+type BackendConfig struct {
+	// ProbeServices contains the probe services to use for the bouncer
+	// and, unless overridden below, for all the other APIs.
+	ProbeServices []OOAPIService `json:"probe_services,omitempty"`
+
+	// CollectorURL is the base URL of the collector.
+	CollectorURL string `json:"collector_url,omitempty"`
+
+	// CheckInURL is the base URL of the check-in API.
+	CheckInURL string `json:"check_in_url,omitempty"`
+
+	// TestHelpers replaces the test helpers returned by the bouncer.
+	TestHelpers map[string][]OOAPIService `json:"test_helpers,omitempty"`
+
+	// DNSResolvers replaces the DNS resolvers used by the session to
+	// resolve backend domains (e.g., "https://dns.example.com/dns-query").
+	DNSResolvers []string `json:"dns_resolvers,omitempty"`
+}
@@ -147,6 +147,19 @@ type WritableDatabase interface {
 	//
 	// Returns a non-nil error if the measurement update failed
 	Failed(msmt *DatabaseMeasurement, failure string) error
+
+	// CreateSkippedRun records that we did not run a test group
+	//
+	// Arguments:
+	//
+	// - testGroupName is the name of the test group we skipped
+	//
+	// - networkID is the id of the underlying network
+	//
+	// - reason explains why we skipped running the test group
+	//
+	// Returns either a database skipped run instance or an error
+	CreateSkippedRun(testGroupName string, networkID int64, reason string) (*DatabaseSkippedRun, error)
 }
 
 // ReadableDatabase only supports reading data.
@@ -175,6 +188,44 @@ type ReadableDatabase interface {
 	//
 	// Returns the measurement JSON or an error
 	GetMeasurementJSON(msmtID int64) (map[string]interface{}, error)
+
+	// ListSkippedRuns returns the list of skipped runs
+	//
+	// Arguments:
+	//
+	// Returns either the skipped runs or an error
+	ListSkippedRuns() ([]DatabaseSkippedRun, error)
+
+	// DataUsageByASN returns the data usage of the results
+	//
+	// Arguments:
+	//
+	// - asn is the ASN of the network where we run the results
+	//
+	// - since is the time since when to account for results
+	//
+	// Returns either the data usage (sent plus received) in KiB or an error
+	DataUsageByASN(asn uint, since time.Time) (float64, error)
+
+	// DataUsageByCC returns the data usage of the results
+	//
+	// Arguments:
+	//
+	// - cc is the country code of the network where we run the results
+	//
+	// - since is the time since when to account for results
+	//
+	// Returns either the data usage (sent plus received) in KiB or an error
+	DataUsageByCC(cc string, since time.Time) (float64, error)
+
+	// DataUsage returns the data usage of the results on any network
+	//
+	// Arguments:
+	//
+	// - since is the time since when to account for results
+	//
+	// Returns either the data usage (sent plus received) in KiB or an error
+	DataUsage(since time.Time) (float64, error)
 }
 
 // ResultNetwork is used to represent the structure made from the JOIN
@@ -265,3 +316,12 @@ type PerformanceTestKeys struct {
 	Ping     float64 `json:"ping"`
 	Bitrate  float64 `json:"median_bitrate"`
 }
+
+// DatabaseSkippedRun represents a test group run we skipped
+type DatabaseSkippedRun struct {
+	ID            int64     `db:"skipped_run_id,omitempty"`
+	TestGroupName string    `db:"test_group_name"`
+	Time          time.Time `db:"skipped_run_time"`
+	Reason        string    `db:"skip_reason"`
+	NetworkID     int64     `db:"network_id"`
+}
@@ -220,6 +220,13 @@ type Experiment interface {
 	//
 	// Deprecated: new code should use a Submitter.
 	OpenReportContext(ctx context.Context) error
+
+	// ReopenReportContext is like OpenReportContext but reuses the report
+	// with the given ID, which we have previously opened, such that we can
+	// resume an interrupted run and keep submitting to the same report.
+	//
+	// Deprecated: new code should use a Submitter.
+	ReopenReportContext(ctx context.Context, reportID string) error
 }
 
 // InputPolicy describes the experiment policy with respect to input. That is
@@ -290,6 +297,20 @@ type ExperimentOptionInfo struct {
 
 	// Type contains the type.
 	Type string
+
+	// Value contains the current value, if the option is exported.
+	Value any
+
+	// Default is the OPTIONAL default value the experiment uses when
+	// the option has its zero value, according to the `ooni_default` tag.
+	Default string
+
+	// Enum contains the OPTIONAL values allowed for a string option,
+	// according to the comma-separated values of the `ooni_enum` tag.
+	Enum []string
+
+	// Fields contains information about the fields of a struct option.
+	Fields map[string]ExperimentOptionInfo
 }
 
 // ExperimentInputLoader loads inputs from local or remote sources.
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
@@ -24,6 +25,11 @@ type Database struct {
 	MockListResults        func() ([]model.DatabaseResultNetwork, []model.DatabaseResultNetwork, error)
 	MockListMeasurements   func(resultID int64) ([]model.DatabaseMeasurementURLNetwork, error)
 	MockGetMeasurementJSON func(msmtID int64) (map[string]interface{}, error)
+	MockCreateSkippedRun   func(testGroupName string, networkID int64, reason string) (*model.DatabaseSkippedRun, error)
+	MockListSkippedRuns    func() ([]model.DatabaseSkippedRun, error)
+	MockDataUsageByASN     func(asn uint, since time.Time) (float64, error)
+	MockDataUsageByCC      func(cc string, since time.Time) (float64, error)
+	MockDataUsage          func(since time.Time) (float64, error)
 }
 
 var _ model.WritableDatabase = &Database{}
@@ -89,6 +95,11 @@ func (d *Database) Failed(msmt *model.DatabaseMeasurement, failure string) error
 	return d.MockFailed(msmt, failure)
 }
 
+// CreateSkippedRun calls MockCreateSkippedRun
+func (d *Database) CreateSkippedRun(testGroupName string, networkID int64, reason string) (*model.DatabaseSkippedRun, error) {
+	return d.MockCreateSkippedRun(testGroupName, networkID, reason)
+}
+
 var _ model.ReadableDatabase = &Database{}
 
 // ListResults calla MockListResults
@@ -105,3 +116,23 @@ func (d *Database) ListMeasurements(resultID int64) ([]model.DatabaseMeasurement
 func (d *Database) GetMeasurementJSON(msmtID int64) (map[string]interface{}, error) {
 	return d.MockGetMeasurementJSON(msmtID)
 }
+
+// ListSkippedRuns calls MockListSkippedRuns
+func (d *Database) ListSkippedRuns() ([]model.DatabaseSkippedRun, error) {
+	return d.MockListSkippedRuns()
+}
+
+// DataUsageByASN calls MockDataUsageByASN
+func (d *Database) DataUsageByASN(asn uint, since time.Time) (float64, error) {
+	return d.MockDataUsageByASN(asn, since)
+}
+
+// DataUsageByCC calls MockDataUsageByCC
+func (d *Database) DataUsageByCC(cc string, since time.Time) (float64, error) {
+	return d.MockDataUsageByCC(cc, since)
+}
+
+// DataUsage calls MockDataUsage
+func (d *Database) DataUsage(since time.Time) (float64, error) {
+	return d.MockDataUsage(since)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
@@ -244,4 +245,84 @@ func TestDatabase(t *testing.T) {
 			t.Fatal("not the error we expected")
 		}
 	})
+
+	t.Run("CreateSkippedRun", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockCreateSkippedRun: func(testGroupName string, networkID int64, reason string) (*model.DatabaseSkippedRun, error) {
+				return nil, expected
+			},
+		}
+		skipped, err := db.CreateSkippedRun("performance", 0, "")
+		if skipped != nil {
+			t.Fatal("expected nil skipped run")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("ListSkippedRuns", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockListSkippedRuns: func() ([]model.DatabaseSkippedRun, error) {
+				return nil, expected
+			},
+		}
+		runs, err := db.ListSkippedRuns()
+		if runs != nil {
+			t.Fatal("expected nil skipped runs")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("DataUsageByASN", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockDataUsageByASN: func(asn uint, since time.Time) (float64, error) {
+				return 0, expected
+			},
+		}
+		usage, err := db.DataUsageByASN(0, time.Now())
+		if usage != 0 {
+			t.Fatal("expected zero usage")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("DataUsageByCC", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockDataUsageByCC: func(cc string, since time.Time) (float64, error) {
+				return 0, expected
+			},
+		}
+		usage, err := db.DataUsageByCC("IT", time.Now())
+		if usage != 0 {
+			t.Fatal("expected zero usage")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
+
+	t.Run("DataUsage", func(t *testing.T) {
+		expected := errors.New("mocked")
+		db := &Database{
+			MockDataUsage: func(since time.Time) (float64, error) {
+				return 0, expected
+			},
+		}
+		usage, err := db.DataUsage(time.Now())
+		if usage != 0 {
+			t.Fatal("expected zero usage")
+		}
+		if !errors.Is(err, expected) {
+			t.Fatal("not the error we expected")
+		}
+	})
 }
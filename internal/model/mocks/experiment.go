@@ -29,6 +29,8 @@ type Experiment struct {
 		ctx context.Context, measurement *model.Measurement) error
 
 	MockOpenReportContext func(ctx context.Context) error
+
+	MockReopenReportContext func(ctx context.Context, reportID string) error
 }
 
 func (e *Experiment) KibiBytesReceived() float64 {
@@ -73,3 +75,7 @@ func (e *Experiment) SubmitAndUpdateMeasurementContext(
 func (e *Experiment) OpenReportContext(ctx context.Context) error {
 	return e.MockOpenReportContext(ctx)
 }
+
+func (e *Experiment) ReopenReportContext(ctx context.Context, reportID string) error {
+	return e.MockReopenReportContext(ctx, reportID)
+}
@@ -143,4 +143,16 @@ func TestExperiment(t *testing.T) {
 			t.Fatal("unexpected err", err)
 		}
 	})
+	t.Run("ReopenReportContext", func(t *testing.T) {
+		expected := errors.New("mocked err")
+		e := &Experiment{
+			MockReopenReportContext: func(ctx context.Context, reportID string) error {
+				return expected
+			},
+		}
+		err := e.ReopenReportContext(context.Background(), "antani")
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected err", err)
+		}
+	})
 }
@@ -91,6 +91,27 @@ func NewParallelUDPResolver(logger model.DebugLogger, dialer model.Dialer,
 	))
 }
 
+// NewParallelDNSOverTLSResolver creates a new Resolver using DNS-over-TLS
+// that performs parallel A/AAAA lookups during LookupHost.
+//
+// Arguments:
+//
+// - logger is the logger to use
+//
+// - dialer is the dialer to create and connect TCP conns
+//
+// - address is the server address (e.g., 1.1.1.1:853)
+//
+// - wrappers is the optional list of wrappers to wrap the underlying
+// transport.  Any nil wrapper will be silently ignored.
+func NewParallelDNSOverTLSResolver(logger model.DebugLogger, dialer model.Dialer,
+	address string, wrappers ...model.DNSTransportWrapper) model.Resolver {
+	tlsDialer := NewTLSDialer(dialer, NewTLSHandshakerStdlib(logger))
+	return WrapResolver(logger, NewUnwrappedParallelResolver(
+		WrapDNSTransport(NewUnwrappedDNSOverTLSTransport(tlsDialer.DialTLSContext, address), wrappers...),
+	))
+}
+
 // WrapResolver creates a new resolver that wraps an
 // existing resolver to add these properties:
 //
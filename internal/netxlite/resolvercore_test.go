@@ -88,6 +88,24 @@ func TestNewParallelDNSOverHTTPSResolver(t *testing.T) {
 	}
 }
 
+func TestNewParallelDNSOverTLSResolver(t *testing.T) {
+	dialer := NewDialerWithStdlibResolver(log.Log)
+	resolver := NewParallelDNSOverTLSResolver(log.Log, dialer, "1.1.1.1:853")
+	idna := resolver.(*resolverIDNA)
+	logger := idna.Resolver.(*resolverLogger)
+	if logger.Logger != log.Log {
+		t.Fatal("invalid logger")
+	}
+	shortCircuit := logger.Resolver.(*resolverShortCircuitIPAddr)
+	errWrapper := shortCircuit.Resolver.(*resolverErrWrapper)
+	para := errWrapper.Resolver.(*ParallelResolver)
+	txp := para.Transport().(*dnsTransportErrWrapper)
+	dnsTxp := txp.DNSTransport.(*DNSOverTCPTransport)
+	if dnsTxp.Address() != "1.1.1.1:853" || dnsTxp.Network() != "dot" {
+		t.Fatal("invalid transport")
+	}
+}
+
 func TestResolverSystem(t *testing.T) {
 	t.Run("Network", func(t *testing.T) {
 		expected := "antani"
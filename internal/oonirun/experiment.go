@@ -35,6 +35,11 @@ type Experiment struct {
 	// InputFilePaths contains OPTIONAL files to read inputs from.
 	InputFilePaths []string
 
+	// KVStore is the OPTIONAL key-value store where we save the progress
+	// of the run, such that we can resume it if interrupted. When this field
+	// is nil, we do not save the progress.
+	KVStore model.KeyValueStore
+
 	// MaxRuntime is the OPTIONAL maximum runtime in seconds.
 	MaxRuntime int64
 
@@ -47,6 +52,9 @@ type Experiment struct {
 	// NoJSON OPTIONALLY indicates we don't want to save measurements to a JSON file.
 	NoJSON bool
 
+	// Parallelism is the OPTIONAL number of inputs to measure concurrently.
+	Parallelism int
+
 	// Random OPTIONALLY indicates we should randomize inputs.
 	Random bool
 
@@ -54,6 +62,12 @@ type Experiment struct {
 	// used when noJSON is set to false.
 	ReportFile string
 
+	// Restart OPTIONALLY indicates we should discard an interrupted run.
+	Restart bool
+
+	// Resume OPTIONALLY indicates we should resume an interrupted run.
+	Resume bool
+
 	// Session is the MANDATORY session.
 	Session Session
 
@@ -104,7 +118,17 @@ func (ed *Experiment) Run(ctx context.Context) error {
 		return err
 	}
 
-	// 5. construct the experiment instance
+	// 5. create the checkpointer, which may resume an interrupted run
+	// and therefore change the inputs order if we randomized them
+	checkpointer, err := ed.newCheckpointer(inputList)
+	if err != nil {
+		return err
+	}
+	if checkpointer != nil {
+		inputList = checkpointer.Inputs()
+	}
+
+	// 6. construct the experiment instance
 	experiment := builder.NewExperiment()
 	logger := ed.Session.Logger()
 	defer func() {
@@ -114,22 +138,22 @@ func (ed *Experiment) Run(ctx context.Context) error {
 		)
 	}()
 
-	// 6. create the submitter
+	// 7. create the submitter
 	submitter, err := ed.newSubmitter(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 7. create the saver
+	// 8. create the saver
 	saver, err := ed.newSaver(experiment)
 	if err != nil {
 		return err
 	}
 
-	// 8. create an input processor
-	inputProcessor := ed.newInputProcessor(experiment, inputList, saver, submitter)
+	// 9. create an input processor
+	inputProcessor := ed.newInputProcessor(experiment, inputList, saver, submitter, checkpointer)
 
-	// 9. process input and generate measurements
+	// 10. process input and generate measurements
 	return inputProcessor.Run(ctx)
 }
 
@@ -137,27 +161,51 @@ func (ed *Experiment) Run(ctx context.Context) error {
 type inputProcessor = model.ExperimentInputProcessor
 
 // newInputProcessor creates a new inputProcessor instance.
-func (ed *Experiment) newInputProcessor(experiment model.Experiment,
-	inputList []model.OOAPIURLInfo, saver engine.Saver, submitter engine.Submitter) inputProcessor {
+func (ed *Experiment) newInputProcessor(experiment model.Experiment, inputList []model.OOAPIURLInfo,
+	saver engine.Saver, submitter engine.Submitter, checkpointer *engine.Checkpointer) inputProcessor {
 	if ed.newInputProcessorFn != nil {
 		return ed.newInputProcessorFn(experiment, inputList, saver, submitter)
 	}
-	return &engine.InputProcessor{
+	ip := &engine.InputProcessor{
 		Annotations: ed.Annotations,
 		Experiment: &experimentWrapper{
 			child:  engine.NewInputProcessorExperimentWrapper(experiment),
 			logger: ed.Session.Logger(),
 			total:  len(inputList),
 		},
-		Inputs:     inputList,
-		MaxRuntime: time.Duration(ed.MaxRuntime) * time.Second,
-		Options:    experimentOptionsToStringList(ed.ExtraOptions),
-		Saver:      engine.NewInputProcessorSaverWrapper(saver),
+		Inputs:      inputList,
+		MaxRuntime:  time.Duration(ed.MaxRuntime) * time.Second,
+		Options:     experimentOptionsToStringList(ed.ExtraOptions),
+		Parallelism: ed.Parallelism,
+		Saver:       engine.NewInputProcessorSaverWrapper(saver),
 		Submitter: &experimentSubmitterWrapper{
 			child:  engine.NewInputProcessorSubmitterWrapper(submitter),
 			logger: ed.Session.Logger(),
 		},
 	}
+	if checkpointer != nil {
+		// Note: assigning a nil *engine.Checkpointer would cause the
+		// interface to be non-nil and the input processor to crash
+		ip.Checkpointer = checkpointer
+	}
+	return ip
+}
+
+// newCheckpointer creates a new engine.Checkpointer instance or returns
+// nil if we're not saving the progress of this run.
+func (ed *Experiment) newCheckpointer(inputList []model.OOAPIURLInfo) (*engine.Checkpointer, error) {
+	if ed.KVStore == nil {
+		return nil, nil
+	}
+	return engine.NewCheckpointer(&engine.CheckpointerConfig{
+		ExperimentName: ed.Name,
+		Inputs:         inputList,
+		KVStore:        ed.KVStore,
+		Logger:         ed.Session.Logger(),
+		Options:        experimentOptionsToStringList(ed.ExtraOptions),
+		Restart:        ed.Restart,
+		Resume:         ed.Resume,
+	})
 }
 
 // newSaver creates a new engine.Saver instance.
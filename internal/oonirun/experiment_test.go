@@ -5,12 +5,16 @@ import (
 	"errors"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-cli/v3/internal/engine"
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/model/mocks"
+	"github.com/ooni/probe-cli/v3/internal/registry"
 	"github.com/ooni/probe-cli/v3/internal/testingx"
 )
 
@@ -113,6 +117,78 @@ func TestExperimentRunWithFailureToSubmitAndShuffle(t *testing.T) {
 	}
 }
 
+func TestExperimentRunWithCheckpoint(t *testing.T) {
+	kvs := &kvstore.Memory{}
+	inputs := []model.OOAPIURLInfo{{URL: "a"}, {URL: "b"}, {URL: "c"}}
+	config := &engine.CheckpointerConfig{
+		ExperimentName: "example",
+		Inputs:         inputs,
+		KVStore:        kvs,
+		Logger:         model.DiscardLogger,
+	}
+	checkpointer, err := engine.NewCheckpointer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpointer.MarkCompleted(0, ""); err != nil {
+		t.Fatal(err)
+	}
+	var measured []string
+	desc := &Experiment{
+		Inputs:      []string{"c", "b", "a"},
+		KVStore:     kvs,
+		Name:        "example",
+		NoCollector: true,
+		NoJSON:      true,
+		Resume:      true,
+		Session: &mocks.Session{
+			MockNewExperimentBuilder: func(name string) (model.ExperimentBuilder, error) {
+				eb := &mocks.ExperimentBuilder{
+					MockInputPolicy: func() model.InputPolicy {
+						return model.InputOptional
+					},
+					MockSetOptionsAny: func(options map[string]any) error {
+						return nil
+					},
+					MockNewExperiment: func() model.Experiment {
+						exp := &mocks.Experiment{
+							MockMeasureAsync: func(ctx context.Context, input string) (<-chan *model.Measurement, error) {
+								measured = append(measured, input)
+								out := make(chan *model.Measurement)
+								go func() {
+									defer close(out)
+									out <- &model.Measurement{Input: model.MeasurementTarget(input)}
+								}()
+								return out, nil
+							},
+							MockKibiBytesReceived: func() float64 {
+								return 0
+							},
+							MockKibiBytesSent: func() float64 {
+								return 0
+							},
+						}
+						return exp
+					},
+				}
+				return eb, nil
+			},
+			MockLogger: func() model.Logger {
+				return model.DiscardLogger
+			},
+		},
+	}
+	if err := desc.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b", "c"}, measured); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, err := engine.LoadCheckpoint(config); !errors.Is(err, engine.ErrNoCheckpoint) {
+		t.Fatal("expected the checkpoint to be removed", err)
+	}
+}
+
 func Test_experimentOptionsToStringList(t *testing.T) {
 	type args struct {
 		options map[string]any
@@ -143,6 +219,17 @@ func Test_experimentOptionsToStringList(t *testing.T) {
 			},
 			wantOut: []string{"foo=1"},
 		},
+		{
+			name: "the stunreachability TURN credentials are skipped from the output",
+			args: args{
+				map[string]any{
+					"NATBehavior":      true,
+					"SafeTURNUsername": "ooni",
+					"SafeTURNPassword": "antani",
+				},
+			},
+			wantOut: []string{"NATBehavior=true"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -155,6 +242,26 @@ func Test_experimentOptionsToStringList(t *testing.T) {
 	}
 }
 
+func TestExperimentOptionsNeverIncludeTURNCredentials(t *testing.T) {
+	factory, err := registry.NewFactory("stunreachability")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := factory.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := make(map[string]any)
+	for name := range infos {
+		options[name] = "antani"
+	}
+	for _, entry := range experimentOptionsToStringList(options) {
+		if strings.Contains(entry, "TURN") {
+			t.Fatal("TURN credentials would be published", entry)
+		}
+	}
+}
+
 func TestExperimentRun(t *testing.T) {
 	errMocked := errors.New("mocked error")
 	type fields struct {
@@ -42,6 +42,12 @@ type LinkConfig struct {
 	// used when noJSON is set to false.
 	ReportFile string
 
+	// Restart OPTIONALLY indicates we should discard interrupted runs.
+	Restart bool
+
+	// Resume OPTIONALLY indicates we should resume interrupted runs.
+	Resume bool
+
 	// Session is the MANDATORY Session to use.
 	Session Session
 }
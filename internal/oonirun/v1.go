@@ -76,12 +76,15 @@ func v1Measure(ctx context.Context, config *LinkConfig, URL string) error {
 		ExtraOptions:           nil, // no way to specify with v1 URLs
 		Inputs:                 inputs,
 		InputFilePaths:         nil,
+		KVStore:                config.KVStore,
 		MaxRuntime:             config.MaxRuntime,
 		Name:                   name,
 		NoCollector:            config.NoCollector,
 		NoJSON:                 config.NoJSON,
 		Random:                 config.Random,
 		ReportFile:             config.ReportFile,
+		Restart:                config.Restart,
+		Resume:                 config.Resume,
 		Session:                config.Session,
 		newExperimentBuilderFn: nil,
 		newInputLoaderFn:       nil,
@@ -56,6 +56,9 @@ type V2Nettest struct {
 	// to the OONI backend.
 	Options map[string]any `json:"options"`
 
+	// Parallelism is the OPTIONAL number of inputs to measure concurrently.
+	Parallelism int `json:"parallelism,omitempty"`
+
 	// TestName contains the nettest name.
 	TestName string `json:"test_name"`
 }
@@ -180,12 +183,16 @@ func V2MeasureDescriptor(ctx context.Context, config *LinkConfig, desc *V2Descri
 			ExtraOptions:           nettest.Options,
 			Inputs:                 nettest.Inputs,
 			InputFilePaths:         nil,
+			KVStore:                config.KVStore,
 			MaxRuntime:             config.MaxRuntime,
 			Name:                   nettest.TestName,
 			NoCollector:            config.NoCollector,
 			NoJSON:                 config.NoJSON,
+			Parallelism:            nettest.Parallelism,
 			Random:                 config.Random,
 			ReportFile:             config.ReportFile,
+			Restart:                config.Restart,
+			Resume:                 config.Resume,
 			Session:                config.Session,
 			newExperimentBuilderFn: nil,
 			newInputLoaderFn:       nil,
@@ -220,6 +227,36 @@ func v2DescriptorDiff(oldValue, newValue *V2Descriptor, URL string) string {
 	return fmt.Sprint(gotextdiff.ToUnified(oldFile, newFile, oldString, edits))
 }
 
+// V2PullDescriptor fetches the OONI Run v2 descriptor at the given URL and
+// returns it along with a diff against the copy we have in cache. An empty diff
+// means the descriptor did not change since the user last accepted it.
+//
+// This function DOES NOT update the cache. It is up to the caller to decide
+// whether to ask the user for consent and then call V2AcceptDescriptor.
+func V2PullDescriptor(ctx context.Context, config *LinkConfig, URL string) (*V2Descriptor, string, error) {
+	cache, err := v2DescriptorCacheLoad(config.KVStore)
+	if err != nil {
+		return nil, "", err
+	}
+	clnt := config.Session.DefaultHTTPClient()
+	logger := config.Session.Logger()
+	oldValue, newValue, err := cache.PullChangesWithoutSideEffects(ctx, clnt, logger, URL)
+	if err != nil {
+		return nil, "", err
+	}
+	return newValue, v2DescriptorDiff(oldValue, newValue, URL), nil
+}
+
+// V2AcceptDescriptor records in the cache that the user accepted to
+// run the given descriptor fetched from the given URL.
+func V2AcceptDescriptor(config *LinkConfig, URL string, desc *V2Descriptor) error {
+	cache, err := v2DescriptorCacheLoad(config.KVStore)
+	if err != nil {
+		return err
+	}
+	return cache.Update(config.KVStore, URL, desc)
+}
+
 // v2MeasureHTTPS performs a measurement using an HTTPS v2 OONI Run URL
 // and returns whether performing this measurement failed.
 //
@@ -233,23 +270,17 @@ func v2DescriptorDiff(oldValue, newValue *V2Descriptor, URL string) string {
 func v2MeasureHTTPS(ctx context.Context, config *LinkConfig, URL string) error {
 	logger := config.Session.Logger()
 	logger.Infof("oonirun/v2: running %s", URL)
-	cache, err := v2DescriptorCacheLoad(config.KVStore)
-	if err != nil {
-		return err
-	}
-	clnt := config.Session.DefaultHTTPClient()
-	oldValue, newValue, err := cache.PullChangesWithoutSideEffects(ctx, clnt, logger, URL)
+	newValue, diff, err := V2PullDescriptor(ctx, config, URL)
 	if err != nil {
 		return err
 	}
-	diff := v2DescriptorDiff(oldValue, newValue, URL)
 	if !config.AcceptChanges && diff != "" {
 		logger.Warnf("oonirun: %s changed as follows:\n\n%s", URL, diff)
 		logger.Warnf("oonirun: we are not going to run this link until you accept changes")
 		return ErrNeedToAcceptChanges
 	}
 	if diff != "" {
-		if err := cache.Update(config.KVStore, URL, newValue); err != nil {
+		if err := V2AcceptDescriptor(config, URL, newValue); err != nil {
 			return err
 		}
 	}
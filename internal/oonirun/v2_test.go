@@ -335,6 +335,50 @@ func TestV2MeasureHTTPS(t *testing.T) {
 	})
 }
 
+func TestV2PullAndAcceptDescriptor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		descriptor := &V2Descriptor{
+			Name:        "",
+			Description: "",
+			Author:      "",
+			Nettests: []V2Nettest{{
+				Inputs:   []string{},
+				Options:  map[string]any{},
+				TestName: "example",
+			}},
+		}
+		data, err := json.Marshal(descriptor)
+		runtimex.PanicOnError(err, "json.Marshal failed")
+		w.Write(data)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	config := &LinkConfig{
+		KVStore: &kvstore.Memory{},
+		Session: newMinimalFakeSession(),
+	}
+	desc, diff, err := V2PullDescriptor(ctx, config, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == "" {
+		t.Fatal("expected a diff for a new descriptor")
+	}
+	if len(desc.Nettests) != 1 || desc.Nettests[0].TestName != "example" {
+		t.Fatal("unexpected descriptor", desc)
+	}
+	if err := V2AcceptDescriptor(config, server.URL, desc); err != nil {
+		t.Fatal(err)
+	}
+	_, diff, err = V2PullDescriptor(ctx, config, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Fatal("expected no diff after accepting the descriptor", diff)
+	}
+}
+
 func TestV2DescriptorCacheLoad(t *testing.T) {
 	t.Run("cannot unmarshal cache content", func(t *testing.T) {
 		fsstore := &kvstore.Memory{}
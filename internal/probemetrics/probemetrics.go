@@ -0,0 +1,111 @@
+// Package probemetrics contains the Prometheus metrics exported by
+// long-running probes (e.g., `miniooni --repeat-every` and `ooniprobe
+// serve`) to allow operators to monitor a fleet of probes.
+//
+// We always collect these metrics, which is cheap, but we only expose
+// them when the user explicitly asks us to do so (see Serve).
+package probemetrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// MeasurementsStarted counts the measurements we started.
+	MeasurementsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_measurements_started_total",
+		Help: "Total number of measurements started",
+	}, []string{"experiment"})
+
+	// MeasurementsFinished counts the measurements we finished.
+	MeasurementsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_measurements_finished_total",
+		Help: "Total number of measurements finished",
+	}, []string{"experiment"})
+
+	// MeasurementsFailed counts the measurements that failed.
+	MeasurementsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_measurements_failed_total",
+		Help: "Total number of measurements that failed",
+	}, []string{"experiment"})
+
+	// MeasurementDurationSeconds is the histogram of the measurements runtime.
+	MeasurementDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ooniprobe_engine_measurement_duration_seconds",
+		Help:    "Time to complete a measurement (in seconds)",
+		Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"experiment"})
+
+	// SubmissionsCount counts the measurement submissions.
+	SubmissionsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_submissions_total",
+		Help: "Total number of measurement submissions",
+	}, []string{"experiment", "result"})
+
+	// BytesReceived counts the bytes received by experiments.
+	BytesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_bytes_received_total",
+		Help: "Total number of bytes received by experiments",
+	}, []string{"experiment"})
+
+	// BytesSent counts the bytes sent by experiments.
+	BytesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_bytes_sent_total",
+		Help: "Total number of bytes sent by experiments",
+	}, []string{"experiment"})
+
+	// CheckInDurationSeconds is the histogram of the check-in API latency.
+	CheckInDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ooniprobe_engine_checkin_duration_seconds",
+		Help:    "Time to complete a call to the check-in API (in seconds)",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"result"})
+
+	// ResolverLookupsCount counts the sessionresolver lookups by resolver.
+	ResolverLookupsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ooniprobe_engine_sessionresolver_lookups_total",
+		Help: "Total number of lookups performed by each session resolver",
+	}, []string{"resolver", "result"})
+)
+
+// Result returns the value of the "result" label for the given error.
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Handler returns the http.Handler exposing the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes the metrics at /metrics using the given TCP address. This
+// function returns after it has started listening and serves the metrics in
+// a background goroutine until you call the returned server's Close method.
+// The Addr field of the returned server contains the actual address.
+func Serve(address string, logger model.Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	// Note: we set Addr such that the caller knows the actual address
+	srvr := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+	logger.Infof("probemetrics: serving metrics at http://%s/metrics", listener.Addr().String())
+	go func() {
+		if err := srvr.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			logger.Warnf("probemetrics: %s", err.Error())
+		}
+	}()
+	return srvr, nil
+}
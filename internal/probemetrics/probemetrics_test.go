@@ -0,0 +1,49 @@
+package probemetrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+)
+
+func TestResult(t *testing.T) {
+	if Result(nil) != "success" {
+		t.Fatal("unexpected result")
+	}
+	if Result(errors.New("mocked error")) != "failure" {
+		t.Fatal("unexpected result")
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Run("we serve the metrics", func(t *testing.T) {
+		srvr, err := Serve("127.0.0.1:0", log.Log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srvr.Close()
+		MeasurementsStarted.WithLabelValues("example").Inc()
+		resp, err := http.Get("http://" + srvr.Addr + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `ooniprobe_engine_measurements_started_total{experiment="example"} 1`) {
+			t.Fatal("metric not found")
+		}
+	})
+
+	t.Run("we fail if we cannot listen", func(t *testing.T) {
+		if _, err := Serve("127.0.0.1:-1", log.Log); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
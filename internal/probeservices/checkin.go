@@ -15,6 +15,10 @@ import (
 func (c Client) CheckIn(
 	ctx context.Context, config model.OOAPICheckInConfig) (*model.OOAPICheckInResult, error) {
 	epnt := c.newHTTPAPIEndpoint()
+	if c.CheckInBaseURL != "" {
+		epnt.BaseURL = c.CheckInBaseURL
+		epnt.Host = "" // the Host is only meaningful for the original URL
+	}
 	desc := ooapi.NewDescriptorCheckIn(&config)
 	resp, err := httpapi.Call(ctx, desc, epnt)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -21,6 +22,10 @@ var (
 	// ErrJSONFormatNotSupported indicates that the collector we're using
 	// does not support the JSON report format.
 	ErrJSONFormatNotSupported = errors.New("JSON format not supported")
+
+	// ErrReportIDMismatch indicates that we cannot reopen a report because
+	// its ID does not match the given report template.
+	ErrReportIDMismatch = errors.New("report ID does not match the report template")
 )
 
 // NewReportTemplate creates a new ReportTemplate from a Measurement.
@@ -58,7 +63,7 @@ func (c Client) OpenReport(ctx context.Context, rt model.OOAPIReportTemplate) (R
 		return nil, ErrUnsupportedFormat
 	}
 	var cor model.OOAPICollectorOpenResponse
-	if err := c.APIClientTemplate.WithBodyLogging().Build().PostJSON(ctx, "/report", rt, &cor); err != nil {
+	if err := c.withBaseURL(c.CollectorBaseURL).WithBodyLogging().Build().PostJSON(ctx, "/report", rt, &cor); err != nil {
 		return nil, err
 	}
 	for _, format := range cor.SupportedFormats {
@@ -69,6 +74,37 @@ func (c Client) OpenReport(ctx context.Context, rt model.OOAPIReportTemplate) (R
 	return nil, ErrJSONFormatNotSupported
 }
 
+// ReopenReport returns a ReportChannel for submitting measurements to a report
+// we have previously opened using OpenReport, which is useful to keep submitting
+// to the same report when resuming an interrupted run. Because the report ID
+// contains the test name, the probe CC, and the probe ASN, we refuse to reopen
+// a report whose ID does not match the given template (e.g., because the probe
+// is now using another network). This function does not perform any I/O.
+func (c Client) ReopenReport(rt model.OOAPIReportTemplate, reportID string) (ReportChannel, error) {
+	if rt.DataFormatVersion != model.OOAPIReportDefaultDataFormatVersion {
+		return nil, ErrUnsupportedDataFormatVersion
+	}
+	if rt.Format != model.OOAPIReportDefaultFormat {
+		return nil, ErrUnsupportedFormat
+	}
+	if !reportIDMatches(reportID, rt) {
+		return nil, ErrReportIDMismatch
+	}
+	return &reportChan{ID: reportID, client: c, tmpl: rt}, nil
+}
+
+// reportIDMatches returns whether the given report ID, which has the
+// <time>_<test name>_<probe CC>_<probe ASN>_n<version>_<random> format
+// where the test name does not contain underscores, matches rt.
+func reportIDMatches(reportID string, rt model.OOAPIReportTemplate) bool {
+	v := strings.Split(reportID, "_")
+	if len(v) != 6 {
+		return false
+	}
+	return v[1] == strings.ReplaceAll(rt.TestName, "_", "") &&
+		v[2] == rt.ProbeCC && "AS"+v[3] == rt.ProbeASN
+}
+
 // CanSubmit returns true whether the provided measurement belongs to
 // this report, false otherwise. We say that a given measurement belongs
 // to this report if its report template matches the report's one.
@@ -84,7 +120,7 @@ func (r reportChan) CanSubmit(m *model.Measurement) bool {
 func (r reportChan) SubmitMeasurement(ctx context.Context, m *model.Measurement) error {
 	var updateResponse model.OOAPICollectorUpdateResponse
 	m.ReportID = r.ID
-	err := r.client.APIClientTemplate.WithBodyLogging().Build().PostJSON(
+	err := r.client.withBaseURL(r.client.CollectorBaseURL).WithBodyLogging().Build().PostJSON(
 		ctx, fmt.Sprintf("/report/%s", r.ID), model.OOAPICollectorUpdateRequest{
 			Format:  "json",
 			Content: m,
@@ -125,11 +161,16 @@ var _ ReportOpener = Client{}
 // reports when needed as well as of closing reports when needed. Nonetheless
 // you need to remember to call its Close method when done, because there is
 // likely an open report that has not been closed yet.
+//
+// We keep a report open for each test name, such that we can submit
+// measurements of different experiments (e.g., when using the run
+// experiment) without opening a new report every time the test name
+// of the measurement to submit changes.
 type Submitter struct {
-	channel ReportChannel
-	logger  model.Logger
-	mu      sync.Mutex
-	opener  ReportOpener
+	channels map[string]ReportChannel
+	logger   model.Logger
+	mu       sync.Mutex
+	opener   ReportOpener
 }
 
 // NewSubmitter creates a new Submitter instance.
@@ -140,15 +181,20 @@ func NewSubmitter(opener ReportOpener, logger model.Logger) *Submitter {
 // Submit submits the current measurement to the OONI backend created using
 // the ReportOpener passed to the constructor.
 func (sub *Submitter) Submit(ctx context.Context, m *model.Measurement) error {
-	var err error
 	sub.mu.Lock()
 	defer sub.mu.Unlock()
-	if sub.channel == nil || !sub.channel.CanSubmit(m) {
-		sub.channel, err = sub.opener.OpenReport(ctx, NewReportTemplate(m))
+	channel := sub.channels[m.TestName]
+	if channel == nil || !channel.CanSubmit(m) {
+		var err error
+		channel, err = sub.opener.OpenReport(ctx, NewReportTemplate(m))
 		if err != nil {
 			return err
 		}
-		sub.logger.Infof("New reportID: %s", sub.channel.ReportID())
+		sub.logger.Infof("New reportID: %s", channel.ReportID())
+		if sub.channels == nil {
+			sub.channels = make(map[string]ReportChannel)
+		}
+		sub.channels[m.TestName] = channel
 	}
-	return sub.channel.SubmitMeasurement(ctx, m)
+	return channel.SubmitMeasurement(ctx, m)
 }
@@ -171,6 +171,70 @@ func TestOpenReportInvalidFormat(t *testing.T) {
 	}
 }
 
+func TestReopenReport(t *testing.T) {
+	template := model.OOAPIReportTemplate{
+		DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
+		Format:            model.OOAPIReportDefaultFormat,
+		ProbeASN:          "AS30722",
+		ProbeCC:           "IT",
+		SoftwareName:      "ooniprobe-engine",
+		SoftwareVersion:   "0.1.0",
+		TestName:          "web_connectivity",
+		TestStartTime:     "2019-10-28 12:51:06",
+		TestVersion:       "0.1.0",
+	}
+	const reportID = "20230101T000000Z_webconnectivity_IT_30722_n1_xx"
+
+	t.Run("with a matching report ID", func(t *testing.T) {
+		report, err := newclient().ReopenReport(template, reportID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.ReportID() != reportID || !report.CanSubmit(&model.Measurement{
+			DataFormatVersion: template.DataFormatVersion,
+			ProbeASN:          template.ProbeASN,
+			ProbeCC:           template.ProbeCC,
+			SoftwareName:      template.SoftwareName,
+			SoftwareVersion:   template.SoftwareVersion,
+			TestName:          template.TestName,
+			TestStartTime:     template.TestStartTime,
+			TestVersion:       template.TestVersion,
+		}) {
+			t.Fatal("unexpected report")
+		}
+	})
+
+	for _, reportID := range []string{
+		"",
+		"20230101T000000Z_webconnectivity_IT_30722_n1",
+		"20230101T000000Z_dnscheck_IT_30722_n1_xx",
+		"20230101T000000Z_webconnectivity_DE_30722_n1_xx",
+		"20230101T000000Z_webconnectivity_IT_3269_n1_xx",
+	} {
+		t.Run("with a mismatching report ID", func(t *testing.T) {
+			report, err := newclient().ReopenReport(template, reportID)
+			if !errors.Is(err, ErrReportIDMismatch) {
+				t.Fatal("unexpected err", err)
+			}
+			if report != nil {
+				t.Fatal("expected a nil report here")
+			}
+		})
+	}
+
+	t.Run("with an invalid format", func(t *testing.T) {
+		template := template
+		template.Format = "yaml"
+		report, err := newclient().ReopenReport(template, reportID)
+		if !errors.Is(err, ErrUnsupportedFormat) {
+			t.Fatal("unexpected err", err)
+		}
+		if report != nil {
+			t.Fatal("expected a nil report here")
+		}
+	})
+}
+
 func TestJSONAPIClientCreateFailure(t *testing.T) {
 	ctx := context.Background()
 	template := model.OOAPIReportTemplate{
@@ -436,6 +500,27 @@ func TestSubmitterLifecyle(t *testing.T) {
 	}
 }
 
+func TestSubmitterWithInterleavedTestNames(t *testing.T) {
+	rro := &RecordingReportOpener{}
+	submitter := NewSubmitter(rro, log.Log)
+	ctx := context.Background()
+	for _, testName := range []string{"example", "example_extended", "example"} {
+		m := makeMeasurementWithoutTemplate("antani", testName)
+		if err := submitter.Submit(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rro.channels) != 2 {
+		t.Fatal("unexpected number of channels")
+	}
+	if len(rro.channels[0].m) != 2 {
+		t.Fatal("unexpected number of measurements in first channel")
+	}
+	if len(rro.channels[1].m) != 1 {
+		t.Fatal("unexpected number of measurements in second channel")
+	}
+}
+
 func TestSubmitterCannotOpenNewChannel(t *testing.T) {
 	rro := &RecordingReportOpener{}
 	submitter := NewSubmitter(rro, log.Log)
@@ -63,12 +63,25 @@ type Session interface {
 	UserAgent() string
 }
 
+// sessionWithBackendConfig is a Session using a self-hosted backend.
+type sessionWithBackendConfig interface {
+	BackendConfig() *model.BackendConfig
+}
+
 // Client is a client for the OONI probe services API.
 type Client struct {
 	httpx.APIClientTemplate
 	LoginCalls    *atomic.Int64
 	RegisterCalls *atomic.Int64
 	StateFile     StateFile
+
+	// CheckInBaseURL is the OPTIONAL base URL for the check-in
+	// API. When empty, we use the BaseURL.
+	CheckInBaseURL string
+
+	// CollectorBaseURL is the OPTIONAL base URL for the collector
+	// API. When empty, we use the BaseURL.
+	CollectorBaseURL string
 }
 
 // GetCredsAndAuth is an utility function that returns the credentials with
@@ -88,7 +101,9 @@ func (c Client) GetCredsAndAuth() (*model.OOAPILoginCredentials, *model.OOAPILog
 }
 
 // NewClient creates a new client for the specified probe services endpoint. This
-// function fails, e.g., we don't support the specified endpoint.
+// function fails, e.g., we don't support the specified endpoint. If the session
+// has a BackendConfig method returning a non-nil config, we use the collector
+// and check-in base URLs configured therein.
 func NewClient(sess Session, endpoint model.OOAPIService) (*Client, error) {
 	client := &Client{
 		APIClientTemplate: httpx.APIClientTemplate{
@@ -101,6 +116,12 @@ func NewClient(sess Session, endpoint model.OOAPIService) (*Client, error) {
 		RegisterCalls: &atomic.Int64{},
 		StateFile:     NewStateFile(sess.KeyValueStore()),
 	}
+	if swbc, ok := sess.(sessionWithBackendConfig); ok {
+		if config := swbc.BackendConfig(); config != nil {
+			client.CheckInBaseURL = config.CheckInURL
+			client.CollectorBaseURL = config.CollectorURL
+		}
+	}
 	switch endpoint.Type {
 	case "https":
 		return client, nil
@@ -127,6 +148,17 @@ func NewClient(sess Session, endpoint model.OOAPIService) (*Client, error) {
 	}
 }
 
+// withBaseURL returns a copy of the APIClientTemplate using the given
+// baseURL, if not empty, and otherwise the original template.
+func (c Client) withBaseURL(baseURL string) *httpx.APIClientTemplate {
+	tmpl := c.APIClientTemplate // copy
+	if baseURL != "" {
+		tmpl.BaseURL = baseURL
+		tmpl.Host = "" // the Host is only meaningful for the original URL
+	}
+	return &tmpl
+}
+
 // newHTTPAPIEndpoint is a convenience function for constructing a new
 // instance of *httpapi.Endpoint based on the content of Client
 func (c Client) newHTTPAPIEndpoint() *httpapi.Endpoint {
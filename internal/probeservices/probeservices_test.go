@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -589,3 +590,71 @@ func TestGetCredsAndAuthNotLoggedIn(t *testing.T) {
 		t.Fatal("expected nil auth here")
 	}
 }
+
+// sessionWithBackend is a mockable.Session using a self-hosted backend.
+type sessionWithBackend struct {
+	*mockable.Session
+	config *model.BackendConfig
+}
+
+func (sess *sessionWithBackend) BackendConfig() *model.BackendConfig {
+	return sess.config
+}
+
+func TestNewClientWithBackendConfig(t *testing.T) {
+	var paths []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "collector"+r.URL.Path)
+		switch r.URL.Path {
+		case "/report":
+			w.Write([]byte(`{"report_id":"xx","supported_formats":["json"]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer collector.Close()
+	checkIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "checkin"+r.URL.Path)
+		w.Write([]byte(`{"v":1,"tests":{}}`))
+	}))
+	defer checkIn.Close()
+	sess := &sessionWithBackend{
+		Session: &mockable.Session{
+			MockableHTTPClient: http.DefaultClient,
+			MockableLogger:     log.Log,
+		},
+		config: &model.BackendConfig{
+			CollectorURL: collector.URL,
+			CheckInURL:   checkIn.URL,
+		},
+	}
+	client, err := NewClient(sess, model.OOAPIService{
+		Address: "https://x.org",
+		Type:    "cloudfront",
+		Front:   "google.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.CollectorBaseURL != collector.URL || client.CheckInBaseURL != checkIn.URL {
+		t.Fatal("did not honour the backend config")
+	}
+	ctx := context.Background()
+	if _, err := client.CheckIn(ctx, model.OOAPICheckInConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := client.OpenReport(ctx, model.OOAPIReportTemplate{
+		DataFormatVersion: model.OOAPIReportDefaultDataFormatVersion,
+		Format:            model.OOAPIReportDefaultFormat,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := report.SubmitMeasurement(ctx, &model.Measurement{}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"checkin/api/v1/check-in", "collector/report", "collector/report/xx"}
+	if diff := cmp.Diff(expect, paths); diff != "" {
+		t.Fatal(diff)
+	}
+}
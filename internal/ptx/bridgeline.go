@@ -0,0 +1,169 @@
+package ptx
+
+//
+// Parsing tor bridge lines
+//
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BridgeLine is a parsed tor bridge line such as:
+//
+//	obfs4 192.95.36.142:443 CDF2E852BF539B82BD10E27E9115A31734E378C2 cert=qUVQ0srL1JI/vO6V6m/24anYXiJD3QP2HgzUKQtQ7GRqqUvs7P+tG43RtAqdhLOALP7DJQ iat-mode=1
+//
+// We also accept bridge lines prefixed by the "Bridge" keyword
+// as they appear inside the torrc configuration file.
+type BridgeLine struct {
+	// Transport is the pluggable transport name (e.g., "obfs4").
+	Transport string
+
+	// Address is the bridge endpoint (e.g., "192.95.36.142:443").
+	Address string
+
+	// Fingerprint is the OPTIONAL bridge fingerprint.
+	Fingerprint string
+
+	// Args contains the key=value arguments (e.g., "cert").
+	Args map[string]string
+}
+
+// ErrInvalidBridgeLine indicates that we cannot parse a bridge line.
+var ErrInvalidBridgeLine = errors.New("ptx: invalid bridge line")
+
+// ParseBridgeLine parses a tor bridge line.
+func ParseBridgeLine(line string) (*BridgeLine, error) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "Bridge") {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBridgeLine, line)
+	}
+	bl := &BridgeLine{
+		Transport: fields[0],
+		Address:   fields[1],
+		Args:      map[string]string{},
+	}
+	for _, field := range fields[2:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			if bl.Fingerprint != "" {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidBridgeLine, line)
+			}
+			bl.Fingerprint = field
+			continue
+		}
+		bl.Args[key] = value
+	}
+	return bl, nil
+}
+
+// ErrUnsupportedTransport indicates that we don't support a transport.
+var ErrUnsupportedTransport = errors.New("ptx: unsupported transport")
+
+// NewOBFS4DialerFromBridgeLine creates a new OBFS4Dialer from a bridge line
+// using the given dataDir as the MANDATORY directory where to store obfs4 data.
+func NewOBFS4DialerFromBridgeLine(line string, dataDir string) (*OBFS4Dialer, error) {
+	bl, err := ParseBridgeLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if bl.Transport != "obfs4" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedTransport, bl.Transport)
+	}
+	if bl.Args["cert"] == "" {
+		return nil, fmt.Errorf("%w: missing cert", ErrInvalidBridgeLine)
+	}
+	iatMode := bl.Args["iat-mode"]
+	if iatMode == "" {
+		iatMode = "0"
+	}
+	return &OBFS4Dialer{
+		Address:     bl.Address,
+		Cert:        bl.Args["cert"],
+		DataDir:     dataDir,
+		Fingerprint: bl.Fingerprint,
+		IATMode:     iatMode,
+	}, nil
+}
+
+// NewPTDialerFromBridgeLine creates a new PTDialer from a bridge line using
+// the given dataDir as the directory where to store the transports data, if
+// needed. We support the obfs4, meek_lite, snowflake, and webtunnel transports.
+func NewPTDialerFromBridgeLine(line string, dataDir string) (PTDialer, error) {
+	bl, err := ParseBridgeLine(line)
+	if err != nil {
+		return nil, err
+	}
+	switch bl.Transport {
+	case "obfs4":
+		return NewOBFS4DialerFromBridgeLine(line, dataDir)
+	case "meek_lite", "meek":
+		if bl.Args["url"] == "" {
+			return nil, fmt.Errorf("%w: missing url", ErrInvalidBridgeLine)
+		}
+		return &MeekDialer{
+			Address:     bl.Address,
+			Fingerprint: bl.Fingerprint,
+			Front:       bl.Args["front"],
+			URL:         bl.Args["url"],
+		}, nil
+	case "snowflake":
+		if bl.Args["url"] == "" {
+			return NewSnowflakeDialer(), nil
+		}
+		return NewSnowflakeDialerWithRendezvousMethod(newSnowflakeRendezvousMethodBridgeLine(bl)), nil
+	case "webtunnel":
+		if bl.Args["url"] == "" {
+			return nil, fmt.Errorf("%w: missing url", ErrInvalidBridgeLine)
+		}
+		return &WebTunnelDialer{
+			Address:     bl.Address,
+			Fingerprint: bl.Fingerprint,
+			URL:         bl.Args["url"],
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedTransport, bl.Transport)
+	}
+}
+
+// newSnowflakeRendezvousMethodBridgeLine creates a snowflake rendezvous
+// method using the url, front (or fronts), and ampcache arguments.
+func newSnowflakeRendezvousMethodBridgeLine(bl *BridgeLine) SnowflakeRendezvousMethod {
+	front := bl.Args["front"]
+	if fronts := bl.Args["fronts"]; front == "" && fronts != "" {
+		front = strings.Split(fronts, ",")[0]
+	}
+	return &snowflakeRendezvousMethodBridgeLine{
+		ampCacheURL: bl.Args["ampcache"],
+		brokerURL:   bl.Args["url"],
+		frontDomain: front,
+	}
+}
+
+// snowflakeRendezvousMethodBridgeLine is a rendezvous
+// method configured by a snowflake bridge line.
+type snowflakeRendezvousMethodBridgeLine struct {
+	ampCacheURL string
+	brokerURL   string
+	frontDomain string
+}
+
+func (d *snowflakeRendezvousMethodBridgeLine) Name() string {
+	return "bridge_line"
+}
+
+func (d *snowflakeRendezvousMethodBridgeLine) AMPCacheURL() string {
+	return d.ampCacheURL
+}
+
+func (d *snowflakeRendezvousMethodBridgeLine) BrokerURL() string {
+	return d.brokerURL
+}
+
+func (d *snowflakeRendezvousMethodBridgeLine) FrontDomain() string {
+	return d.frontDomain
+}
@@ -0,0 +1,152 @@
+package ptx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseBridgeLine(t *testing.T) {
+	t.Run("with a valid obfs4 bridge line", func(t *testing.T) {
+		line := "Bridge obfs4 209.148.46.65:443 74FAD13168806246602538555B5521A0383A1875 cert=ssH+9rP8dG2NLDN2XuFw63hIO/9MNNinLmxQDpVa+7kTOa9/m+tGWT1SmSYpQ9uTBGa6Hw iat-mode=0"
+		bl, err := ParseBridgeLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &BridgeLine{
+			Transport:   "obfs4",
+			Address:     "209.148.46.65:443",
+			Fingerprint: "74FAD13168806246602538555B5521A0383A1875",
+			Args: map[string]string{
+				"cert":     "ssH+9rP8dG2NLDN2XuFw63hIO/9MNNinLmxQDpVa+7kTOa9/m+tGWT1SmSYpQ9uTBGa6Hw",
+				"iat-mode": "0",
+			},
+		}
+		if diff := cmp.Diff(expected, bl); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with a snowflake bridge line without fingerprint", func(t *testing.T) {
+		bl, err := ParseBridgeLine("snowflake 192.0.2.3:80 url=https://snowflake-broker.torproject.net/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bl.Transport != "snowflake" || bl.Fingerprint != "" || bl.Args["url"] == "" {
+			t.Fatal("unexpected bridge line", bl)
+		}
+	})
+
+	t.Run("with too few fields", func(t *testing.T) {
+		if _, err := ParseBridgeLine("obfs4"); !errors.Is(err, ErrInvalidBridgeLine) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("with two fingerprints", func(t *testing.T) {
+		if _, err := ParseBridgeLine("obfs4 1.1.1.1:443 AAAA BBBB"); !errors.Is(err, ErrInvalidBridgeLine) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+}
+
+func TestNewOBFS4DialerFromBridgeLine(t *testing.T) {
+	t.Run("on success", func(t *testing.T) {
+		expected := DefaultTestingOBFS4Bridge()
+		dialer, err := NewOBFS4DialerFromBridgeLine(expected.AsBridgeArgument(), expected.DataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, dialer); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("with a non-obfs4 bridge line", func(t *testing.T) {
+		_, err := NewOBFS4DialerFromBridgeLine("snowflake 192.0.2.3:80", "testdata")
+		if !errors.Is(err, ErrUnsupportedTransport) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("without cert", func(t *testing.T) {
+		_, err := NewOBFS4DialerFromBridgeLine("obfs4 1.1.1.1:443 AAAA", "testdata")
+		if !errors.Is(err, ErrInvalidBridgeLine) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+}
+
+func TestNewPTDialerFromBridgeLine(t *testing.T) {
+	type testcase struct {
+		name      string
+		line      string
+		transport string
+		err       error
+	}
+	testcases := []testcase{{
+		name:      "with obfs4",
+		line:      DefaultTestingOBFS4Bridge().AsBridgeArgument(),
+		transport: "obfs4",
+	}, {
+		name:      "with meek_lite",
+		line:      "meek_lite 192.0.2.18:80 BE776A53492E1E044A26F17306E1BC46A55A1625 url=https://meek.azureedge.net/ front=ajax.aspnetcdn.com",
+		transport: "meek_lite",
+	}, {
+		name: "with meek_lite without url",
+		line: "meek_lite 192.0.2.18:80 BE776A53492E1E044A26F17306E1BC46A55A1625",
+		err:  ErrInvalidBridgeLine,
+	}, {
+		name:      "with snowflake without url",
+		line:      "snowflake 192.0.2.3:80 2B280B23E1107BB62ABFC40DDCC8824814F80A72",
+		transport: "snowflake",
+	}, {
+		name:      "with snowflake with url",
+		line:      "snowflake 192.0.2.3:80 2B280B23E1107BB62ABFC40DDCC8824814F80A72 url=https://1098762253.rsc.cdn77.org/ fronts=www.cdn77.com,www.phpmyadmin.net",
+		transport: "snowflake",
+	}, {
+		name:      "with webtunnel",
+		line:      "webtunnel [2001:db8::1]:443 2B280B23E1107BB62ABFC40DDCC8824814F80A72 url=https://example.com/antani",
+		transport: "webtunnel",
+	}, {
+		name: "with webtunnel without url",
+		line: "webtunnel [2001:db8::1]:443 2B280B23E1107BB62ABFC40DDCC8824814F80A72",
+		err:  ErrInvalidBridgeLine,
+	}, {
+		name: "with an unsupported transport",
+		line: "obfs3 192.0.2.1:443",
+		err:  ErrUnsupportedTransport,
+	}, {
+		name: "with an invalid bridge line",
+		line: "",
+		err:  ErrInvalidBridgeLine,
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dialer, err := NewPTDialerFromBridgeLine(tc.line, "testdata")
+			if !errors.Is(err, tc.err) {
+				t.Fatal("unexpected err", err)
+			}
+			if err != nil {
+				return
+			}
+			if dialer.Name() != tc.transport {
+				t.Fatal("unexpected transport", dialer.Name())
+			}
+		})
+	}
+
+	t.Run("the snowflake rendezvous method uses the bridge line", func(t *testing.T) {
+		dialer, err := NewPTDialerFromBridgeLine(
+			"snowflake 192.0.2.3:80 url=https://broker.example.com/ fronts=a.example.com,b.example.com", "testdata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rm := dialer.(*SnowflakeDialer).RendezvousMethod
+		if rm.Name() != "bridge_line" || rm.BrokerURL() != "https://broker.example.com/" ||
+			rm.FrontDomain() != "a.example.com" || rm.AMPCacheURL() != "" {
+			t.Fatal("unexpected rendezvous method", rm)
+		}
+	})
+}
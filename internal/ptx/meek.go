@@ -0,0 +1,157 @@
+package ptx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	pt "git.torproject.org/pluggable-transports/goptlib.git"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/runtimex"
+	"gitlab.com/yawning/obfs4.git/transports/base"
+	"gitlab.com/yawning/obfs4.git/transports/meeklite"
+)
+
+// MeekDialer is a dialer for meek. Make sure you fill all
+// the fields marked as mandatory before using.
+//
+// Note that meek creates connections lazily, i.e., DialContext
+// succeeds without performing any network activity and we only
+// contact the meek server when we read from or write to the
+// connection. Hence, a successful DialContext does not imply
+// that the meek server is reachable. Use Poll to check whether
+// the meek server is actually reachable.
+type MeekDialer struct {
+	// Address contains the MANDATORY bridge address.
+	Address string
+
+	// Fingerprint is the OPTIONAL bridge fingerprint.
+	Fingerprint string
+
+	// Front is the OPTIONAL domain to use for domain fronting.
+	Front string
+
+	// URL is the MANDATORY URL of the meek server.
+	URL string
+
+	// UnderlyingDialer is the optional underlying dialer to
+	// use. If not set, we will use &net.Dialer{}.
+	UnderlyingDialer model.SimpleDialer
+}
+
+var _ PTDialer = &MeekDialer{}
+
+// DialContext establishes a connection with the given meek server. The context
+// argument allows to interrupt this operation midway.
+func (d *MeekDialer) DialContext(ctx context.Context) (net.Conn, error) {
+	factory := d.newFactory()
+	parsedargs, err := d.parseargs(factory)
+	if err != nil {
+		return nil, err
+	}
+	cd := &obfs4CancellableDialer{
+		done:       make(chan interface{}),
+		ud:         d.underlyingDialer(),
+		factory:    factory,
+		parsedargs: parsedargs,
+	}
+	return cd.dial(ctx, "tcp", d.Address)
+}
+
+// errMeekUnexpectedStatusCode indicates that the meek server
+// replied to our poll with a status code other than 200.
+var errMeekUnexpectedStatusCode = errors.New("ptx: meek: unexpected status code")
+
+// Poll performs a single empty meek poll using a fresh session ID, which
+// is what the meek client periodically does when it has nothing to send. This
+// operation performs a real round trip with the meek server, which a
+// successful DialContext does not imply. The context argument allows
+// to interrupt this operation midway.
+func (d *MeekDialer) Poll(ctx context.Context) error {
+	// The following code mirrors meeklite's meekConn.roundTrip
+	URL, err := url.Parse(d.URL)
+	if err != nil {
+		return err
+	}
+	host := URL.Host
+	if d.Front != "" {
+		URL.Host = d.Front
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if d.Front != "" {
+		req.Host = host
+	}
+	sessionID := make([]byte, 16)
+	if _, err := rand.Read(sessionID); err != nil {
+		return err
+	}
+	req.Header.Set("X-Session-Id", hex.EncodeToString(sessionID))
+	req.Header.Set("User-Agent", "")
+	ud := d.underlyingDialer()
+	txp := &http.Transport{DialContext: ud.DialContext}
+	defer txp.CloseIdleConnections()
+	resp, err := txp.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d", errMeekUnexpectedStatusCode, resp.StatusCode)
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// newFactory creates a meek factory instance.
+func (d *MeekDialer) newFactory() base.ClientFactory {
+	mf := &meeklite.Transport{}
+	cf, err := mf.ClientFactory("")
+	// the source code for this transport always returns a nil error
+	runtimex.PanicOnError(err, "unexpected mf.ClientFactory failure")
+	return cf
+}
+
+// parseargs parses the meek arguments.
+func (d *MeekDialer) parseargs(factory base.ClientFactory) (interface{}, error) {
+	args := &pt.Args{"url": []string{d.URL}}
+	if d.Front != "" {
+		args.Add("front", d.Front)
+	}
+	return factory.ParseArgs(args)
+}
+
+// underlyingDialer returns a suitable SimpleDialer.
+func (d *MeekDialer) underlyingDialer() model.SimpleDialer {
+	if d.UnderlyingDialer != nil {
+		return d.UnderlyingDialer
+	}
+	return (&OBFS4Dialer{}).underlyingDialer()
+}
+
+// AsBridgeArgument returns the argument to be passed to
+// the tor command line to declare this bridge.
+func (d *MeekDialer) AsBridgeArgument() string {
+	out := fmt.Sprintf("meek_lite %s", d.Address)
+	if d.Fingerprint != "" {
+		out += " " + d.Fingerprint
+	}
+	out += " url=" + d.URL
+	if d.Front != "" {
+		out += " front=" + d.Front
+	}
+	return out
+}
+
+// Name returns the pluggable transport name.
+func (d *MeekDialer) Name() string {
+	return "meek_lite"
+}
@@ -0,0 +1,93 @@
+package ptx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMeekDialer(t *testing.T) {
+	t.Run("AsBridgeArgument", func(t *testing.T) {
+		d := &MeekDialer{
+			Address:     "192.0.2.18:80",
+			Fingerprint: "BE776A53492E1E044A26F17306E1BC46A55A1625",
+			Front:       "ajax.aspnetcdn.com",
+			URL:         "https://meek.azureedge.net/",
+		}
+		expected := "meek_lite 192.0.2.18:80 BE776A53492E1E044A26F17306E1BC46A55A1625 url=https://meek.azureedge.net/ front=ajax.aspnetcdn.com"
+		if v := d.AsBridgeArgument(); v != expected {
+			t.Fatal("unexpected bridge argument", v)
+		}
+		if d.Name() != "meek_lite" {
+			t.Fatal("unexpected name")
+		}
+	})
+
+	t.Run("DialContext fails with an invalid URL", func(t *testing.T) {
+		d := &MeekDialer{Address: "192.0.2.18:80", URL: "ftp://meek.example.com/"}
+		conn, err := d.DialContext(context.Background())
+		if err == nil {
+			t.Fatal("expected an error here")
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+
+	t.Run("DialContext is lazy", func(t *testing.T) {
+		d := &MeekDialer{Address: "192.0.2.18:80", URL: "https://meek.example.com/"}
+		conn, err := d.DialContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	t.Run("Poll", func(t *testing.T) {
+		t.Run("on success", func(t *testing.T) {
+			var host, sessionID string
+			srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				host, sessionID = r.Host, r.Header.Get("X-Session-Id")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srvr.Close()
+			d := &MeekDialer{
+				Address: "192.0.2.18:80",
+				Front:   srvr.Listener.Addr().String(),
+				URL:     "http://meek.example.com/",
+			}
+			if err := d.Poll(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if host != "meek.example.com" || len(sessionID) != 32 {
+				t.Fatal("unexpected request", host, sessionID)
+			}
+		})
+
+		t.Run("with an unexpected status code", func(t *testing.T) {
+			srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer srvr.Close()
+			d := &MeekDialer{Address: "192.0.2.18:80", URL: srvr.URL}
+			if err := d.Poll(context.Background()); !errors.Is(err, errMeekUnexpectedStatusCode) {
+				t.Fatal("unexpected err", err)
+			}
+		})
+
+		t.Run("when the server is unreachable", func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			address := listener.Addr().String()
+			listener.Close() // nobody is listening here now
+			d := &MeekDialer{Address: "192.0.2.18:80", URL: "http://" + address + "/"}
+			if err := d.Poll(context.Background()); err == nil {
+				t.Fatal("expected an error here")
+			}
+		})
+	})
+}
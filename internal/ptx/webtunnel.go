@@ -0,0 +1,169 @@
+package ptx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+// WebTunnelDialer is a dialer for webtunnel. Make sure you fill all
+// the fields marked as mandatory before using.
+//
+// Webtunnel hides tor traffic inside a connection that looks like
+// an HTTP connection upgraded to use WebSocket.
+type WebTunnelDialer struct {
+	// Address contains the MANDATORY bridge address. Note that we do
+	// not use this address for dialing, because the bridge endpoint
+	// is the one described by the URL field.
+	Address string
+
+	// Fingerprint is the OPTIONAL bridge fingerprint.
+	Fingerprint string
+
+	// URL is the MANDATORY URL of the webtunnel server.
+	URL string
+
+	// UnderlyingDialer is the optional underlying dialer to
+	// use. If not set, we will use &net.Dialer{}.
+	UnderlyingDialer model.SimpleDialer
+
+	// TLSConfig is the OPTIONAL TLS config to use. If not set, we
+	// use the URL hostname as the SNI and the bundled CA store.
+	TLSConfig *tls.Config
+}
+
+var _ PTDialer = &WebTunnelDialer{}
+
+// ErrWebTunnelUpgradeFailed indicates that the webtunnel
+// server did not switch protocols as we requested.
+var ErrWebTunnelUpgradeFailed = errors.New("ptx: webtunnel upgrade failed")
+
+// DialContext establishes a connection with the given webtunnel server.
+func (d *WebTunnelDialer) DialContext(ctx context.Context) (net.Conn, error) {
+	URL, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, err
+	}
+	address, err := d.endpoint(URL)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.underlyingDialer().DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if URL.Scheme == "https" {
+		thx := netxlite.NewTLSHandshakerStdlib(model.DiscardLogger)
+		tlsConn, _, err := thx.Handshake(ctx, conn, d.tlsConfig(URL))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	req := &http.Request{
+		Method:     "GET",
+		URL:        &url.URL{Path: URL.Path, RawQuery: URL.RawQuery},
+		Host:       URL.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"websocket"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrWebTunnelUpgradeFailed, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &webtunnelConn{Conn: conn, reader: reader}, nil
+}
+
+// endpoint returns the TCP endpoint to connect to.
+func (d *WebTunnelDialer) endpoint(URL *url.URL) (string, error) {
+	switch URL.Scheme {
+	case "https":
+		if URL.Port() == "" {
+			return net.JoinHostPort(URL.Hostname(), "443"), nil
+		}
+	case "http":
+		if URL.Port() == "" {
+			return net.JoinHostPort(URL.Hostname(), "80"), nil
+		}
+	default:
+		return "", fmt.Errorf("ptx: webtunnel: unsupported URL scheme: %s", URL.Scheme)
+	}
+	return URL.Host, nil
+}
+
+// tlsConfig returns the TLS config to use.
+func (d *WebTunnelDialer) tlsConfig(URL *url.URL) *tls.Config {
+	if d.TLSConfig != nil {
+		return d.TLSConfig
+	}
+	return &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		RootCAs:    netxlite.NewDefaultCertPool(),
+		ServerName: URL.Hostname(),
+	}
+}
+
+// underlyingDialer returns a suitable SimpleDialer.
+func (d *WebTunnelDialer) underlyingDialer() model.SimpleDialer {
+	if d.UnderlyingDialer != nil {
+		return d.UnderlyingDialer
+	}
+	return (&OBFS4Dialer{}).underlyingDialer()
+}
+
+// AsBridgeArgument returns the argument to be passed to
+// the tor command line to declare this bridge.
+func (d *WebTunnelDialer) AsBridgeArgument() string {
+	out := fmt.Sprintf("webtunnel %s", d.Address)
+	if d.Fingerprint != "" {
+		out += " " + d.Fingerprint
+	}
+	return out + " url=" + d.URL
+}
+
+// Name returns the pluggable transport name.
+func (d *WebTunnelDialer) Name() string {
+	return "webtunnel"
+}
+
+// webtunnelConn is a net.Conn reading from a bufio.Reader, which
+// may contain data the server sent along with the response.
+type webtunnelConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read implements net.Conn.Read.
+func (c *webtunnelConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,110 @@
+package ptx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+func TestWebTunnelDialer(t *testing.T) {
+	t.Run("AsBridgeArgument", func(t *testing.T) {
+		d := &WebTunnelDialer{
+			Address:     "[2001:db8::1]:443",
+			Fingerprint: "2B280B23E1107BB62ABFC40DDCC8824814F80A72",
+			URL:         "https://example.com/antani",
+		}
+		expected := "webtunnel [2001:db8::1]:443 2B280B23E1107BB62ABFC40DDCC8824814F80A72 url=https://example.com/antani"
+		if v := d.AsBridgeArgument(); v != expected {
+			t.Fatal("unexpected bridge argument", v)
+		}
+		if d.Name() != "webtunnel" {
+			t.Fatal("unexpected name")
+		}
+	})
+
+	t.Run("with an unsupported URL scheme", func(t *testing.T) {
+		d := &WebTunnelDialer{URL: "ftp://example.com/antani"}
+		if _, err := d.DialContext(context.Background()); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+
+	t.Run("when the server does not upgrade", func(t *testing.T) {
+		srvr := httptest.NewServer(http.NotFoundHandler())
+		defer srvr.Close()
+		d := &WebTunnelDialer{URL: srvr.URL + "/antani"}
+		conn, err := d.DialContext(context.Background())
+		if !errors.Is(err, ErrWebTunnelUpgradeFailed) {
+			t.Fatal("unexpected err", err)
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+
+	t.Run("we use the bundled CA store and wrap errors", func(t *testing.T) {
+		srvr := httptest.NewTLSServer(http.NotFoundHandler())
+		defer srvr.Close()
+		d := &WebTunnelDialer{URL: srvr.URL + "/antani"}
+		conn, err := d.DialContext(context.Background())
+		if err == nil || err.Error() != netxlite.FailureSSLUnknownAuthority {
+			t.Fatal("unexpected err", err)
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+
+	t.Run("on success", func(t *testing.T) {
+		srvr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/antani" || r.Header.Get("Upgrade") != "websocket" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			conn, bufrw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			bufrw.WriteString("hello")
+			bufrw.Flush()
+			io.Copy(conn, bufrw) // echo
+		}))
+		defer srvr.Close()
+		tlsConfig := srvr.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		tlsConfig.ServerName = "example.com" // the httptest certificate is valid for it
+		d := &WebTunnelDialer{
+			URL:       srvr.URL + "/antani",
+			TLSConfig: tlsConfig,
+		}
+		conn, err := d.DialContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		buffer := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buffer); err != nil {
+			t.Fatal(err)
+		}
+		if string(buffer) != "hello" {
+			t.Fatal("unexpected greeting", string(buffer))
+		}
+		if _, err := conn.Write([]byte("antani")); err != nil {
+			t.Fatal(err)
+		}
+		buffer = make([]byte, 6)
+		if _, err := io.ReadFull(conn, buffer); err != nil {
+			t.Fatal(err)
+		}
+		if string(buffer) != "antani" {
+			t.Fatal("unexpected echo", string(buffer))
+		}
+	})
+}
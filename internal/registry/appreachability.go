@@ -0,0 +1,22 @@
+package registry
+
+//
+// Registers the `appreachability' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/appreachability"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	AllExperiments["appreachability"] = &Factory{
+		build: func(config interface{}) model.ExperimentMeasurer {
+			return appreachability.NewExperimentMeasurer(
+				*config.(*appreachability.Config),
+			)
+		},
+		config:      &appreachability.Config{},
+		inputPolicy: model.InputNone,
+	}
+}
@@ -0,0 +1,22 @@
+package registry
+
+//
+// Registers the `dnsconsistency' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/dnsconsistency"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	AllExperiments["dnsconsistency"] = &Factory{
+		build: func(config interface{}) model.ExperimentMeasurer {
+			return dnsconsistency.NewExperimentMeasurer(
+				*config.(*dnsconsistency.Config),
+			)
+		},
+		config:      &dnsconsistency.Config{},
+		inputPolicy: model.InputNone,
+	}
+}
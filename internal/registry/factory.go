@@ -5,10 +5,15 @@ package registry
 //
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/iancoleman/strcase"
 	"github.com/ooni/probe-cli/v3/internal/model"
@@ -59,30 +64,78 @@ var (
 	// ErrCannotSetStringOption means SetOptionAny couldn't set a string option.
 	ErrCannotSetStringOption = errors.New("cannot set string option")
 
+	// ErrCannotSetFloatOption means SetOptionAny couldn't set a float option.
+	ErrCannotSetFloatOption = errors.New("cannot set float option")
+
+	// ErrCannotSetDurationOption means SetOptionAny couldn't set a duration option.
+	ErrCannotSetDurationOption = errors.New("cannot set duration option")
+
+	// ErrCannotSetStringSliceOption means SetOptionAny couldn't set a string slice option.
+	ErrCannotSetStringSliceOption = errors.New("cannot set string slice option")
+
+	// ErrCannotSetStringMapOption means SetOptionAny couldn't set a string map option.
+	ErrCannotSetStringMapOption = errors.New("cannot set string map option")
+
+	// ErrCannotSetStructOption means SetOptionAny couldn't set a struct option.
+	ErrCannotSetStructOption = errors.New("cannot set struct option")
+
+	// ErrInvalidEnumValue indicates that a string option value is not
+	// among the values allowed by the field's `ooni_enum` tag.
+	ErrInvalidEnumValue = errors.New("invalid enum value")
+
 	// ErrUnsupportedOptionType means we don't support the type passed to
 	// the SetOptionAny method as an opaque any type.
 	ErrUnsupportedOptionType = errors.New("unsupported option type")
 )
 
+// durationType is the reflect.Type of time.Duration.
+var durationType = reflect.TypeOf(time.Duration(0))
+
 // Options returns the options exposed by this experiment.
 func (b *Factory) Options() (map[string]model.ExperimentOptionInfo, error) {
-	result := make(map[string]model.ExperimentOptionInfo)
 	ptrinfo := reflect.ValueOf(b.config)
 	if ptrinfo.Kind() != reflect.Ptr {
 		return nil, ErrConfigIsNotAStructPointer
 	}
-	structinfo := ptrinfo.Elem().Type()
+	structinfo := ptrinfo.Elem()
 	if structinfo.Kind() != reflect.Struct {
 		return nil, ErrConfigIsNotAStructPointer
 	}
+	return b.options(structinfo), nil
+}
+
+// options returns the options exposed by the given struct value.
+func (b *Factory) options(structinfo reflect.Value) map[string]model.ExperimentOptionInfo {
+	result := make(map[string]model.ExperimentOptionInfo)
 	for i := 0; i < structinfo.NumField(); i++ {
-		field := structinfo.Field(i)
-		result[field.Name] = model.ExperimentOptionInfo{
-			Doc:  field.Tag.Get("ooni"),
-			Type: field.Type.String(),
+		field := structinfo.Type().Field(i)
+		info := model.ExperimentOptionInfo{
+			Doc:     field.Tag.Get("ooni"),
+			Type:    field.Type.String(),
+			Default: field.Tag.Get("ooni_default"),
+			Enum:    fieldEnum(field),
+		}
+		// Implementation note: we don't expose the value of `Safe` options
+		// because they typically contain secrets (e.g., credentials).
+		if field.IsExported() && !strings.HasPrefix(field.Name, "Safe") {
+			value := structinfo.Field(i)
+			info.Value = value.Interface()
+			if value.Kind() == reflect.Struct {
+				info.Fields = b.options(value)
+			}
 		}
+		result[field.Name] = info
+	}
+	return result
+}
+
+// fieldEnum returns the values listed by the field's `ooni_enum` tag, if any.
+func fieldEnum(field reflect.StructField) []string {
+	tag := field.Tag.Get("ooni_enum")
+	if tag == "" {
+		return nil
 	}
-	return result, nil
+	return strings.Split(tag, ",")
 }
 
 // setOptionBool sets a bool option.
@@ -120,6 +173,16 @@ func (b *Factory) setOptionInt(field reflect.Value, value any) error {
 	case int:
 		field.SetInt(int64(v))
 		return nil
+	case float64:
+		// Numbers decoded from JSON (e.g., the options of an
+		// OONI Run v2 descriptor) are always float64.
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return fmt.Errorf("%w: not an integer: %v", ErrCannotSetIntegerOption, v)
+		}
+		field.SetInt(int64(v))
+		return nil
+	case json.Number:
+		return b.setOptionInt(field, string(v))
 	case string:
 		number, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
@@ -132,10 +195,65 @@ func (b *Factory) setOptionInt(field reflect.Value, value any) error {
 	}
 }
 
+// setOptionFloat sets a float option.
+func (b *Factory) setOptionFloat(field reflect.Value, value any) error {
+	switch v := value.(type) {
+	case float64:
+		field.SetFloat(v)
+		return nil
+	case float32:
+		field.SetFloat(float64(v))
+		return nil
+	case int64:
+		field.SetFloat(float64(v))
+		return nil
+	case int:
+		field.SetFloat(float64(v))
+		return nil
+	case json.Number:
+		return b.setOptionFloat(field, string(v))
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCannotSetFloatOption, err.Error())
+		}
+		field.SetFloat(number)
+		return nil
+	default:
+		return fmt.Errorf("%w from a value of type %T", ErrCannotSetFloatOption, value)
+	}
+}
+
+// setOptionDuration sets a time.Duration option. Strings use the format
+// accepted by time.ParseDuration (e.g., "1500ms"). Like encoding/json does
+// for time.Duration, we interpret numbers as nanoseconds.
+func (b *Factory) setOptionDuration(field reflect.Value, value any) error {
+	switch v := value.(type) {
+	case time.Duration:
+		field.SetInt(int64(v))
+		return nil
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCannotSetDurationOption, err.Error())
+		}
+		field.SetInt(int64(duration))
+		return nil
+	default:
+		if err := b.setOptionInt(field, value); err != nil {
+			return fmt.Errorf("%w: %s", ErrCannotSetDurationOption, err.Error())
+		}
+		return nil
+	}
+}
+
 // setOptionString sets a string option
-func (b *Factory) setOptionString(field reflect.Value, value any) error {
+func (b *Factory) setOptionString(field reflect.Value, enum []string, value any) error {
 	switch v := value.(type) {
 	case string:
+		if err := checkEnum(enum, v); err != nil {
+			return err
+		}
 		field.SetString(v)
 		return nil
 	default:
@@ -143,22 +261,156 @@ func (b *Factory) setOptionString(field reflect.Value, value any) error {
 	}
 }
 
-// SetOptionAny sets an option given any value.
-func (b *Factory) SetOptionAny(key string, value any) error {
-	field, err := b.fieldbyname(b.config, key)
-	if err != nil {
-		return err
+// checkEnum returns an error if enum is not empty and does not contain value. We
+// always accept the empty string, which experiments use to select the default.
+func checkEnum(enum []string, value string) error {
+	if len(enum) <= 0 || value == "" {
+		return nil
+	}
+	for _, entry := range enum {
+		if entry == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (allowed: %s)", ErrInvalidEnumValue, value, strings.Join(enum, ", "))
+}
+
+// setOptionStringSlice sets a []string option. Strings either contain a
+// JSON array (e.g., `["h2","http/1.1"]`) or values separated by commas or
+// spaces, such that the space-separated lists that experiments used to
+// take as string options (e.g., `h2 http/1.1`) keep working.
+func (b *Factory) setOptionStringSlice(field reflect.Value, enum []string, value any) error {
+	var out []string
+	switch v := value.(type) {
+	case []string:
+		out = append(out, v...)
+	case []any:
+		for _, entry := range v {
+			s, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("%w from an entry of type %T", ErrCannotSetStringSliceOption, entry)
+			}
+			out = append(out, s)
+		}
+	case string:
+		if strings.HasPrefix(v, "[") {
+			if err := json.Unmarshal([]byte(v), &out); err != nil {
+				return fmt.Errorf("%w: %s", ErrCannotSetStringSliceOption, err.Error())
+			}
+			break
+		}
+		out = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+	default:
+		return fmt.Errorf("%w from a value of type %T", ErrCannotSetStringSliceOption, value)
+	}
+	for _, entry := range out {
+		if err := checkEnum(enum, entry); err != nil {
+			return err
+		}
+	}
+	field.Set(reflect.ValueOf(out))
+	return nil
+}
+
+// setOptionStringMap sets a map[string]string option. Strings must
+// contain a JSON object (e.g., `{"User-Agent":"miniooni/0.1.0"}`).
+func (b *Factory) setOptionStringMap(field reflect.Value, value any) error {
+	out := make(map[string]string)
+	switch v := value.(type) {
+	case map[string]string:
+		for key, entry := range v {
+			out[key] = entry
+		}
+	case map[string]any:
+		for key, entry := range v {
+			s, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("%w from an entry of type %T", ErrCannotSetStringMapOption, entry)
+			}
+			out[key] = s
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &out); err != nil {
+			return fmt.Errorf("%w: %s", ErrCannotSetStringMapOption, err.Error())
+		}
+	default:
+		return fmt.Errorf("%w from a value of type %T", ErrCannotSetStringMapOption, value)
+	}
+	field.Set(reflect.ValueOf(out))
+	return nil
+}
+
+// setOptionStruct sets a nested struct option. The value is either a
+// map[string]any (e.g., decoded from JSON) or a string containing a JSON
+// object. We set each field using the same rules used for top-level
+// options and we only modify the field if we can set all the values.
+func (b *Factory) setOptionStruct(field reflect.Value, value any) error {
+	var options map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		options = v
+	case string:
+		decoder := json.NewDecoder(strings.NewReader(v))
+		decoder.UseNumber() // preserve large integers
+		if err := decoder.Decode(&options); err != nil {
+			return fmt.Errorf("%w: %s", ErrCannotSetStructOption, err.Error())
+		}
+	default:
+		return fmt.Errorf("%w from a value of type %T", ErrCannotSetStructOption, value)
+	}
+	config := reflect.New(field.Type())
+	config.Elem().Set(field)
+	for key, entry := range options {
+		nested, enum, err := b.fieldbyname(config.Interface(), key)
+		if err != nil {
+			return err
+		}
+		if err := b.setOptionValue(nested, enum, entry); err != nil {
+			return err
+		}
+	}
+	field.Set(config.Elem())
+	return nil
+}
+
+// setOptionValue sets the given field to the given value, where enum
+// contains the OPTIONAL values allowed for string options.
+func (b *Factory) setOptionValue(field reflect.Value, enum []string, value any) error {
+	if field.Type() == durationType {
+		return b.setOptionDuration(field, value)
 	}
 	switch field.Kind() {
 	case reflect.Int64:
 		return b.setOptionInt(field, value)
+	case reflect.Float64:
+		return b.setOptionFloat(field, value)
 	case reflect.Bool:
 		return b.setOptionBool(field, value)
 	case reflect.String:
-		return b.setOptionString(field, value)
-	default:
-		return fmt.Errorf("%w: %T", ErrUnsupportedOptionType, value)
+		return b.setOptionString(field, enum, value)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String {
+			return b.setOptionStringSlice(field, enum, value)
+		}
+	case reflect.Map:
+		if field.Type().Key().Kind() == reflect.String && field.Type().Elem().Kind() == reflect.String {
+			return b.setOptionStringMap(field, value)
+		}
+	case reflect.Struct:
+		return b.setOptionStruct(field, value)
 	}
+	return fmt.Errorf("%w: %T", ErrUnsupportedOptionType, value)
+}
+
+// SetOptionAny sets an option given any value.
+func (b *Factory) SetOptionAny(key string, value any) error {
+	field, enum, err := b.fieldbyname(b.config, key)
+	if err != nil {
+		return err
+	}
+	return b.setOptionValue(field, enum, value)
 }
 
 // SetOptionsAny calls SetOptionAny for each entry inside [options].
@@ -171,22 +423,24 @@ func (b *Factory) SetOptionsAny(options map[string]any) error {
 	return nil
 }
 
-// fieldbyname return v's field whose name is equal to the given key.
-func (b *Factory) fieldbyname(v interface{}, key string) (reflect.Value, error) {
+// fieldbyname return v's field whose name is equal to the given key
+// along with the values allowed by the field's `ooni_enum` tag.
+func (b *Factory) fieldbyname(v interface{}, key string) (reflect.Value, []string, error) {
 	// See https://stackoverflow.com/a/6396678/4354461
 	ptrinfo := reflect.ValueOf(v)
 	if ptrinfo.Kind() != reflect.Ptr {
-		return reflect.Value{}, fmt.Errorf("%w but a %T", ErrConfigIsNotAStructPointer, v)
+		return reflect.Value{}, nil, fmt.Errorf("%w but a %T", ErrConfigIsNotAStructPointer, v)
 	}
 	structinfo := ptrinfo.Elem()
 	if structinfo.Kind() != reflect.Struct {
-		return reflect.Value{}, fmt.Errorf("%w but a %T", ErrConfigIsNotAStructPointer, v)
+		return reflect.Value{}, nil, fmt.Errorf("%w but a %T", ErrConfigIsNotAStructPointer, v)
 	}
 	field := structinfo.FieldByName(key)
 	if !field.IsValid() || !field.CanSet() {
-		return reflect.Value{}, fmt.Errorf("%w: %s", ErrNoSuchField, key)
+		return reflect.Value{}, nil, fmt.Errorf("%w: %s", ErrNoSuchField, key)
 	}
-	return field, nil
+	fieldinfo, _ := structinfo.Type().FieldByName(key) // must exist given the above check
+	return field, fieldEnum(fieldinfo), nil
 }
 
 // NewExperimentMeasurer creates the experiment
@@ -194,6 +448,21 @@ func (b *Factory) NewExperimentMeasurer() model.ExperimentMeasurer {
 	return b.build(b.config)
 }
 
+// clone returns a copy of the factory using a shallow copy of the
+// config, such that setting options on the copy does not modify the
+// config of the original factory. This works for slice, map and struct
+// options because we replace rather than modify their values.
+func (b *Factory) clone() *Factory {
+	config := reflect.New(reflect.TypeOf(b.config).Elem())
+	config.Elem().Set(reflect.ValueOf(b.config).Elem())
+	return &Factory{
+		build:         b.build,
+		config:        config.Interface(),
+		inputPolicy:   b.inputPolicy,
+		interruptible: b.interruptible,
+	}
+}
+
 // CanonicalizeExperimentName allows code to provide experiment names
 // in a more flexible way, where we have aliases.
 //
@@ -1,17 +1,33 @@
 package registry
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 type fakeExperimentConfig struct {
-	Chan   chan any `ooni:"we cannot set this"`
-	String string   `ooni:"a string"`
-	Truth  bool     `ooni:"something that no-one knows"`
-	Value  int64    `ooni:"a number"`
+	Chan     chan any          `ooni:"we cannot set this"`
+	Duration time.Duration     `ooni:"a duration" ooni_default:"1s"`
+	Enum     string            `ooni:"an enum" ooni_enum:"udp,tcp"`
+	Float    float64           `ooni:"a float"`
+	Headers  map[string]string `ooni:"a map"`
+	List     []string          `ooni:"a list"`
+	Nested   fakeNestedConfig  `ooni:"a struct"`
+	Protos   []string          `ooni:"a list of enums" ooni_enum:"h2,http/1.1"`
+	SafeKey  string            `ooni:"a secret"`
+	String   string            `ooni:"a string"`
+	Truth    bool              `ooni:"something that no-one knows"`
+	Value    int64             `ooni:"a number"`
+}
+
+type fakeNestedConfig struct {
+	Count  int64  `ooni:"a nested number"`
+	Name   string `ooni:"a nested string"`
+	hidden bool
 }
 
 func TestExperimentBuilderOptions(t *testing.T) {
@@ -43,7 +59,7 @@ func TestExperimentBuilderOptions(t *testing.T) {
 	})
 
 	t.Run("when config is a pointer to struct", func(t *testing.T) {
-		config := &fakeExperimentConfig{}
+		config := &fakeExperimentConfig{Nested: fakeNestedConfig{Name: "antani"}, SafeKey: "xo"}
 		b := &Factory{
 			config: config,
 		}
@@ -60,6 +76,10 @@ func TestExperimentBuilderOptions(t *testing.T) {
 				if value.Type != "chan interface {}" {
 					t.Fatal("invalid type", value.Type)
 				}
+			case "SafeKey":
+				if value.Type != "string" || value.Value != nil {
+					t.Fatal("we should not expose the value of Safe options", value.Value)
+				}
 			case "String":
 				if value.Doc != "a string" {
 					t.Fatal("invalid doc")
@@ -81,6 +101,42 @@ func TestExperimentBuilderOptions(t *testing.T) {
 				if value.Type != "int64" {
 					t.Fatal("invalid type", value.Type)
 				}
+			case "Duration":
+				if value.Type != "time.Duration" {
+					t.Fatal("invalid type", value.Type)
+				}
+				if value.Default != "1s" {
+					t.Fatal("invalid default", value.Default)
+				}
+			case "Enum":
+				if diff := cmp.Diff([]string{"udp", "tcp"}, value.Enum); diff != "" {
+					t.Fatal(diff)
+				}
+			case "Float":
+				if value.Type != "float64" || value.Value != float64(0) {
+					t.Fatal("invalid type or value", value.Type, value.Value)
+				}
+			case "Headers":
+				if value.Type != "map[string]string" {
+					t.Fatal("invalid type", value.Type)
+				}
+			case "List", "Protos":
+				if value.Type != "[]string" {
+					t.Fatal("invalid type", value.Type)
+				}
+			case "Nested":
+				if value.Type != "registry.fakeNestedConfig" {
+					t.Fatal("invalid type", value.Type)
+				}
+				if len(value.Fields) != 3 {
+					t.Fatal("invalid number of fields", len(value.Fields))
+				}
+				if value.Fields["Name"].Doc != "a nested string" || value.Fields["Name"].Value != "antani" {
+					t.Fatal("invalid nested field", value.Fields["Name"])
+				}
+				if value.Fields["hidden"].Value != nil {
+					t.Fatal("expected no value for unexported field")
+				}
 			default:
 				t.Fatal("unknown name", name)
 			}
@@ -279,6 +335,280 @@ func TestExperimentBuilderSetOptionAny(t *testing.T) {
 		FieldValue:    make(chan any),
 		ExpectErr:     ErrCannotSetStringOption,
 		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[int] for float64 representing an integer",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Value",
+		FieldValue:    float64(17),
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Value: 17,
+		},
+	}, {
+		TestCaseName:  "[int] for float64 not representing an integer",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Value",
+		FieldValue:    17.5,
+		ExpectErr:     ErrCannotSetIntegerOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[int] for json.Number",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Value",
+		FieldValue:    json.Number("9007199254740993"),
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Value: 9007199254740993,
+		},
+	}, {
+		TestCaseName:  "[float] for float64",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Float",
+		FieldValue:    0.25,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Float: 0.25,
+		},
+	}, {
+		TestCaseName:  "[float] for int",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Float",
+		FieldValue:    4,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Float: 4,
+		},
+	}, {
+		TestCaseName:  "[float] for string representation of float",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Float",
+		FieldValue:    "0.5",
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Float: 0.5,
+		},
+	}, {
+		TestCaseName:  "[float] for invalid string representation of float",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Float",
+		FieldValue:    "xx",
+		ExpectErr:     ErrCannotSetFloatOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[float] for type we don't know how to convert to float",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Float",
+		FieldValue:    true,
+		ExpectErr:     ErrCannotSetFloatOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[duration] for time.Duration",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Duration",
+		FieldValue:    1500 * time.Millisecond,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Duration: 1500 * time.Millisecond,
+		},
+	}, {
+		TestCaseName:  "[duration] for string representation of duration",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Duration",
+		FieldValue:    "1m30s",
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Duration: 90 * time.Second,
+		},
+	}, {
+		TestCaseName:  "[duration] for number of nanoseconds from JSON",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Duration",
+		FieldValue:    float64(1e09),
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Duration: time.Second,
+		},
+	}, {
+		TestCaseName:  "[duration] for invalid string representation of duration",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Duration",
+		FieldValue:    "17",
+		ExpectErr:     ErrCannotSetDurationOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[duration] for type we don't know how to convert to duration",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Duration",
+		FieldValue:    true,
+		ExpectErr:     ErrCannotSetDurationOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[enum] for allowed value",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Enum",
+		FieldValue:    "tcp",
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Enum: "tcp",
+		},
+	}, {
+		TestCaseName:  "[enum] for value that is not allowed",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Enum",
+		FieldValue:    "quic",
+		ExpectErr:     ErrInvalidEnumValue,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[[]string] for []string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    []string{"a", "b"},
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			List: []string{"a", "b"},
+		},
+	}, {
+		TestCaseName:  "[[]string] for []any from JSON",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    []any{"a", "b"},
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			List: []string{"a", "b"},
+		},
+	}, {
+		TestCaseName:  "[[]string] for []any containing a non-string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    []any{"a", 17.0},
+		ExpectErr:     ErrCannotSetStringSliceOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[[]string] for comma-separated string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    "a,b",
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			List: []string{"a", "b"},
+		},
+	}, {
+		TestCaseName:  "[[]string] for space-separated string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    "a  b, c",
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			List: []string{"a", "b", "c"},
+		},
+	}, {
+		TestCaseName:  "[[]string] for string containing a JSON array",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    `["a,b","c"]`,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			List: []string{"a,b", "c"},
+		},
+	}, {
+		TestCaseName:  "[[]string] for string containing an invalid JSON array",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    `["a",`,
+		ExpectErr:     ErrCannotSetStringSliceOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[[]string] for value that is not allowed",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Protos",
+		FieldValue:    "h2,h3",
+		ExpectErr:     ErrInvalidEnumValue,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[[]string] for type we don't know how to convert to []string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "List",
+		FieldValue:    17,
+		ExpectErr:     ErrCannotSetStringSliceOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[map] for map[string]any from JSON",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Headers",
+		FieldValue:    map[string]any{"Accept": "*/*"},
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Headers: map[string]string{"Accept": "*/*"},
+		},
+	}, {
+		TestCaseName:  "[map] for map[string]any containing a non-string",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Headers",
+		FieldValue:    map[string]any{"Accept": true},
+		ExpectErr:     ErrCannotSetStringMapOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[map] for string containing a JSON object",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Headers",
+		FieldValue:    `{"Accept":"*/*"}`,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Headers: map[string]string{"Accept": "*/*"},
+		},
+	}, {
+		TestCaseName:  "[map] for type we don't know how to convert to map",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Headers",
+		FieldValue:    17,
+		ExpectErr:     ErrCannotSetStringMapOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[struct] for map[string]any from JSON",
+		InitialConfig: &fakeExperimentConfig{Nested: fakeNestedConfig{Name: "antani"}},
+		FieldName:     "Nested",
+		FieldValue:    map[string]any{"Count": float64(4)},
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Nested: fakeNestedConfig{Count: 4, Name: "antani"},
+		},
+	}, {
+		TestCaseName:  "[struct] for string containing a JSON object",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Nested",
+		FieldValue:    `{"Count":4,"Name":"mascetti"}`,
+		ExpectErr:     nil,
+		ExpectConfig: &fakeExperimentConfig{
+			Nested: fakeNestedConfig{Count: 4, Name: "mascetti"},
+		},
+	}, {
+		TestCaseName:  "[struct] we do not modify the struct on failure",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Nested",
+		FieldValue:    map[string]any{"Name": "mascetti", "Count": "xx"},
+		ExpectErr:     ErrCannotSetIntegerOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[struct] for missing nested field",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Nested",
+		FieldValue:    map[string]any{"hidden": true},
+		ExpectErr:     ErrNoSuchField,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[struct] for string containing an invalid JSON object",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Nested",
+		FieldValue:    `{`,
+		ExpectErr:     ErrCannotSetStructOption,
+		ExpectConfig:  &fakeExperimentConfig{},
+	}, {
+		TestCaseName:  "[struct] for type we don't know how to convert to struct",
+		InitialConfig: &fakeExperimentConfig{},
+		FieldName:     "Nested",
+		FieldValue:    17,
+		ExpectErr:     ErrCannotSetStructOption,
+		ExpectConfig:  &fakeExperimentConfig{},
 	}, {
 		TestCaseName:  "for a field that we don't know how to set",
 		InitialConfig: &fakeExperimentConfig{},
@@ -296,7 +626,7 @@ func TestExperimentBuilderSetOptionAny(t *testing.T) {
 			if !errors.Is(err, input.ExpectErr) {
 				t.Fatal(err)
 			}
-			if diff := cmp.Diff(input.ExpectConfig, ec); diff != "" {
+			if diff := cmp.Diff(input.ExpectConfig, ec, cmp.AllowUnexported(fakeNestedConfig{})); diff != "" {
 				t.Fatal(diff)
 			}
 		})
@@ -0,0 +1,22 @@
+package registry
+
+//
+// Registers the `ptreachability' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/ptreachability"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	AllExperiments["ptreachability"] = &Factory{
+		build: func(config interface{}) model.ExperimentMeasurer {
+			return ptreachability.NewExperimentMeasurer(
+				*config.(*ptreachability.Config),
+			)
+		},
+		config:      &ptreachability.Config{},
+		inputPolicy: model.InputStrictlyRequired,
+	}
+}
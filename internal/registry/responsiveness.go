@@ -0,0 +1,22 @@
+package registry
+
+//
+// Registers the `responsiveness' experiment.
+//
+
+import (
+	"github.com/ooni/probe-cli/v3/internal/experiment/responsiveness"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func init() {
+	AllExperiments["responsiveness"] = &Factory{
+		build: func(config interface{}) model.ExperimentMeasurer {
+			return responsiveness.NewExperimentMeasurer(
+				*config.(*responsiveness.Config),
+			)
+		},
+		config:      &responsiveness.Config{},
+		inputPolicy: model.InputNone,
+	}
+}
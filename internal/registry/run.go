@@ -5,6 +5,8 @@ package registry
 //
 
 import (
+	"errors"
+
 	"github.com/ooni/probe-cli/v3/internal/experiment/run"
 	"github.com/ooni/probe-cli/v3/internal/model"
 )
@@ -12,11 +14,33 @@ import (
 func init() {
 	AllExperiments["run"] = &Factory{
 		build: func(config interface{}) model.ExperimentMeasurer {
-			return run.NewExperimentMeasurer(
+			return run.NewExperimentMeasurerWithFactory(
 				*config.(*run.Config),
+				newMeasurerForRun,
 			)
 		},
 		config:      &run.Config{},
 		inputPolicy: model.InputStrictlyRequired,
 	}
 }
+
+// errRunInsideRun indicates that the run experiment tried to run itself.
+var errRunInsideRun = errors.New("cannot use run to run the run experiment")
+
+// newMeasurerForRun is the run.MeasurerFactory allowing the run experiment
+// to run any registered experiment. We set the options on a copy of the
+// experiment's config, so we don't modify the config shared by all users.
+func newMeasurerForRun(name string, options map[string]any) (model.ExperimentMeasurer, error) {
+	factory, err := NewFactory(name)
+	if err != nil {
+		return nil, err
+	}
+	if CanonicalizeExperimentName(name) == "run" {
+		return nil, errRunInsideRun
+	}
+	factory = factory.clone()
+	if err := factory.SetOptionsAny(options); err != nil {
+		return nil, err
+	}
+	return factory.NewExperimentMeasurer(), nil
+}
@@ -0,0 +1,42 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/experiment/example"
+)
+
+func TestNewMeasurerForRun(t *testing.T) {
+	t.Run("we set the options on a copy of the config", func(t *testing.T) {
+		measurer, err := newMeasurerForRun("example", map[string]any{"Message": "antani"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measurer.ExperimentName() != "example" {
+			t.Fatal("unexpected measurer")
+		}
+		config := AllExperiments["example"].config.(*example.Config)
+		if config.Message == "antani" {
+			t.Fatal("we modified the shared config")
+		}
+	})
+
+	t.Run("we fail with an unknown experiment", func(t *testing.T) {
+		if _, err := newMeasurerForRun("antani", nil); !errors.Is(err, ErrNoSuchExperiment) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we fail with an invalid option", func(t *testing.T) {
+		if _, err := newMeasurerForRun("example", map[string]any{"Antani": 1}); !errors.Is(err, ErrNoSuchField) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we cannot run run inside run", func(t *testing.T) {
+		if _, err := newMeasurerForRun("run", nil); !errors.Is(err, errRunInsideRun) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package sessionresolver
+
+//
+// Custom resolvers, pinning, and inspecting the state
+//
+
+import (
+	"errors"
+	"net/url"
+)
+
+// configkey is the key used by the key value store to store
+// the configuration set by the user (see resolverconfig).
+const configkey = "sessionresolver.config"
+
+// resolverconfig contains the configuration set by the user.
+type resolverconfig struct {
+	// Custom contains the URLs of the custom resolvers.
+	Custom []string
+
+	// Pinned is the URL of the pinned resolver or empty.
+	Pinned string
+}
+
+// readconfig reads the configuration from the kvstore. This function
+// returns an empty configuration if there's no configuration.
+func (r *Resolver) readconfig() *resolverconfig {
+	config := &resolverconfig{}
+	if r.KVStore == nil {
+		return config
+	}
+	data, err := r.KVStore.Get(configkey)
+	if err != nil {
+		return config
+	}
+	if err := r.codec().Decode(data, config); err != nil {
+		return &resolverconfig{}
+	}
+	return config
+}
+
+// writeconfig writes the configuration to the kvstore.
+func (r *Resolver) writeconfig(config *resolverconfig) error {
+	if r.KVStore == nil {
+		return ErrNilKVStore
+	}
+	data, err := r.codec().Encode(config)
+	if err != nil {
+		return err
+	}
+	return r.KVStore.Set(configkey, data)
+}
+
+// pinned returns the entry of the pinned resolver inside the given
+// state or nil if there's no pinned resolver. We ignore the pinned
+// resolver when we cannot find it, which happens when we're using
+// a different list of resolvers since the user pinned it.
+func (r *Resolver) pinned(state []*resolverinfo) *resolverinfo {
+	URL := r.readconfig().Pinned
+	if URL == "" {
+		return nil
+	}
+	for _, e := range state {
+		if e.URL == URL {
+			return e
+		}
+	}
+	r.logger().Warnf("sessionresolver: ignoring unknown pinned resolver: %s", URL)
+	return nil
+}
+
+// ErrInvalidResolverURL indicates that a custom resolver URL is invalid.
+var ErrInvalidResolverURL = errors.New("sessionresolver: invalid resolver URL")
+
+// ErrUnknownResolver indicates that we don't know a given resolver URL.
+var ErrUnknownResolver = errors.New("sessionresolver: unknown resolver")
+
+// validateCustomURL returns an error if we cannot use the given
+// URL as the URL of a custom resolver.
+func validateCustomURL(URL string) error {
+	parsed, err := url.Parse(URL)
+	if err != nil || parsed.Host == "" {
+		return ErrInvalidResolverURL
+	}
+	switch parsed.Scheme {
+	case "https", "http3", "dot", "tcp", "udp":
+		return nil
+	default:
+		return ErrInvalidResolverURL
+	}
+}
+
+// ResolverState is the state of a child resolver.
+type ResolverState struct {
+	// URL is the resolver URL.
+	URL string
+
+	// Score is the resolver score between 0 and 1.
+	Score float64
+
+	// Custom indicates that the user added this resolver.
+	Custom bool
+
+	// Pinned indicates that we're only using this resolver.
+	Pinned bool
+
+	// RecentFailures contains the most recent failures.
+	RecentFailures []ResolverFailure
+}
+
+// State returns the state of the child resolvers sorted by
+// descending score, i.e., in the order in which we'll use them.
+func (r *Resolver) State() []ResolverState {
+	config := r.readconfig()
+	custom := make(map[string]bool)
+	for _, URL := range config.Custom {
+		custom[URL] = true
+	}
+	var out []ResolverState
+	for _, e := range r.readstatedefault() {
+		out = append(out, ResolverState{
+			URL:            e.URL,
+			Score:          e.Score,
+			Custom:         custom[e.URL],
+			Pinned:         e.URL == config.Pinned,
+			RecentFailures: e.RecentFailures,
+		})
+	}
+	return out
+}
+
+// AddCustom adds a custom resolver. The URL scheme must be one of https
+// (DoH), http3 (DoH3), dot (DoT), tcp and udp. When using dot, tcp and
+// udp, the URL host contains the server endpoint (e.g., dot://dns.google
+// or udp://8.8.8.8:53) and we use the default port if it's missing.
+func (r *Resolver) AddCustom(URL string) error {
+	if err := validateCustomURL(URL); err != nil {
+		return err
+	}
+	config := r.readconfig()
+	for _, e := range config.Custom {
+		if e == URL {
+			return nil // already added
+		}
+	}
+	config.Custom = append(config.Custom, URL)
+	return r.writeconfig(config)
+}
+
+// RemoveCustom removes a custom resolver and unpins it if needed.
+func (r *Resolver) RemoveCustom(URL string) error {
+	config := r.readconfig()
+	var custom []string
+	for _, e := range config.Custom {
+		if e != URL {
+			custom = append(custom, e)
+		}
+	}
+	if len(custom) == len(config.Custom) {
+		return ErrUnknownResolver
+	}
+	config.Custom = custom
+	if config.Pinned == URL {
+		config.Pinned = ""
+	}
+	return r.writeconfig(config)
+}
+
+// Reset resets the score and the recent failures of the resolver
+// with the given URL or of all resolvers if the URL is empty.
+func (r *Resolver) Reset(URL string) error {
+	if URL != "" && !r.supports(URL) {
+		return ErrUnknownResolver
+	}
+	// Note: readstatedefault will add back the removed entries
+	// using their initial score and no recent failures.
+	var out []*resolverinfo
+	if URL != "" {
+		state, _ := r.readstate()
+		for _, e := range state {
+			if e.URL != URL {
+				out = append(out, e)
+			}
+		}
+	}
+	return r.writestate(out)
+}
+
+// Pin ensures we only use the resolver with the given URL.
+func (r *Resolver) Pin(URL string) error {
+	if !r.supports(URL) {
+		return ErrUnknownResolver
+	}
+	config := r.readconfig()
+	config.Pinned = URL
+	return r.writeconfig(config)
+}
+
+// Unpin undoes the effect of Pin.
+func (r *Resolver) Unpin() error {
+	config := r.readconfig()
+	config.Pinned = ""
+	return r.writeconfig(config)
+}
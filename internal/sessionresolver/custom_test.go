@@ -0,0 +1,189 @@
+package sessionresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/kvstore"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/model/mocks"
+)
+
+func TestAddCustom(t *testing.T) {
+	t.Run("we reject invalid URLs", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		for _, URL := range []string{"\t", "system:///", "ftp://8.8.8.8/", "udp:///"} {
+			if err := reso.AddCustom(URL); !errors.Is(err, ErrInvalidResolverURL) {
+				t.Fatal("unexpected error", URL, err)
+			}
+		}
+	})
+
+	t.Run("we add and remove custom resolvers", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		const URL = "udp://8.8.8.8:53"
+		for i := 0; i < 2; i++ { // adding twice should not duplicate
+			if err := reso.AddCustom(URL); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := reso.Pin(URL); err != nil {
+			t.Fatal(err)
+		}
+		var found int
+		for _, e := range reso.State() {
+			if e.URL == URL {
+				if !e.Custom || !e.Pinned || e.Score != customResolverScore {
+					t.Fatal("unexpected state", e)
+				}
+				found++
+			}
+		}
+		if found != 1 {
+			t.Fatal("expected to find the custom resolver once")
+		}
+		if err := reso.RemoveCustom(URL); err != nil {
+			t.Fatal(err)
+		}
+		if reso.supports(URL) || reso.readconfig().Pinned != "" {
+			t.Fatal("expected the custom resolver to be gone")
+		}
+		if err := reso.RemoveCustom(URL); !errors.Is(err, ErrUnknownResolver) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we cannot add without a kvstore", func(t *testing.T) {
+		reso := &Resolver{}
+		if err := reso.AddCustom("dot://dns.google"); !errors.Is(err, ErrNilKVStore) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+}
+
+func TestPin(t *testing.T) {
+	t.Run("we cannot pin an unknown resolver", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.Pin("dot://dns.google"); !errors.Is(err, ErrUnknownResolver) {
+			t.Fatal("unexpected error", err)
+		}
+	})
+
+	t.Run("we only use the pinned resolver", func(t *testing.T) {
+		var used []string
+		reso := &Resolver{
+			KVStore: &kvstore.Memory{},
+			newChildResolverFn: func(h3 bool, URL string) (model.Resolver, error) {
+				reso := &mocks.Resolver{
+					MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+						used = append(used, URL)
+						return nil, errors.New("mocked error")
+					},
+				}
+				return reso, nil
+			},
+		}
+		const URL = "https://dns.quad9.net/dns-query"
+		if err := reso.Pin(URL); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			if _, err := reso.LookupHost(context.Background(), "dns.google"); !errors.Is(err, ErrLookupHost) {
+				t.Fatal("unexpected error", err)
+			}
+		}
+		if len(used) != 4 {
+			t.Fatal("expected to only use the pinned resolver", used)
+		}
+		for _, e := range used {
+			if e != URL {
+				t.Fatal("expected to only use the pinned resolver", used)
+			}
+		}
+		if err := reso.Unpin(); err != nil {
+			t.Fatal(err)
+		}
+		used = nil
+		if _, err := reso.LookupHost(context.Background(), "dns.google"); !errors.Is(err, ErrLookupHost) {
+			t.Fatal("unexpected error", err)
+		}
+		if len(used) != len(allmakers) {
+			t.Fatal("expected to use all the resolvers", used)
+		}
+	})
+
+	t.Run("we ignore an unknown pinned resolver", func(t *testing.T) {
+		reso := &Resolver{KVStore: &kvstore.Memory{}}
+		if err := reso.writeconfig(&resolverconfig{Pinned: "dot://dns.google"}); err != nil {
+			t.Fatal(err)
+		}
+		if reso.pinned(reso.readstatedefault()) != nil {
+			t.Fatal("expected nil")
+		}
+	})
+}
+
+func TestRecentFailuresAndReset(t *testing.T) {
+	reso := &Resolver{
+		KVStore: &kvstore.Memory{},
+		newChildResolverFn: func(h3 bool, URL string) (model.Resolver, error) {
+			reso := &mocks.Resolver{
+				MockLookupHost: func(ctx context.Context, domain string) ([]string, error) {
+					return nil, errors.New("mocked error")
+				},
+			}
+			return reso, nil
+		},
+	}
+	const URL = "https://dns.google/dns-query"
+	if err := reso.Pin(URL); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxRecentFailures+2; i++ {
+		reso.LookupHost(context.Background(), "dns.google")
+	}
+
+	// getstate returns the state of the resolver with URL
+	getstate := func() ResolverState {
+		for _, e := range reso.State() {
+			if e.URL == URL {
+				return e
+			}
+		}
+		t.Fatal("cannot find", URL)
+		return ResolverState{}
+	}
+
+	state := getstate()
+	if len(state.RecentFailures) != maxRecentFailures {
+		t.Fatal("unexpected number of failures", len(state.RecentFailures))
+	}
+	for _, e := range state.RecentFailures {
+		if e.Failure != "mocked error" || e.Time.IsZero() {
+			t.Fatal("unexpected failure", e)
+		}
+	}
+	if state.Score >= 0.01 {
+		t.Fatal("unexpected score", state.Score)
+	}
+
+	if err := reso.Reset("dot://dns.google"); !errors.Is(err, ErrUnknownResolver) {
+		t.Fatal("unexpected error", err)
+	}
+	if err := reso.Reset(URL); err != nil {
+		t.Fatal(err)
+	}
+	state = getstate()
+	if len(state.RecentFailures) != 0 || state.Score != allbyurl[URL].score {
+		t.Fatal("expected the state to be reset", state)
+	}
+
+	reso.LookupHost(context.Background(), "dns.google")
+	if err := reso.Reset(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(getstate().RecentFailures) != 0 {
+		t.Fatal("expected the state to be reset")
+	}
+}
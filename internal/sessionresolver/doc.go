@@ -21,4 +21,8 @@
 // We also support a socks5 proxy. When such a proxy is configured,
 // the code WILL skip http3 resolvers AS WELL AS the system
 // resolver, in an attempt to avoid leaking your queries.
+//
+// Users can add custom DoH, DoH3, DoT, TCP and UDP resolvers, inspect
+// the score and the recent failures of each resolver, reset scores,
+// and pin a resolver, which is useful to debug reachability issues.
 package sessionresolver
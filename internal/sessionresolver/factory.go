@@ -1,7 +1,9 @@
 package sessionresolver
 
 import (
+	"context"
 	"errors"
+	"net"
 	"net/url"
 
 	"github.com/ooni/probe-cli/v3/internal/bytecounter"
@@ -14,8 +16,12 @@ import (
 // child resolver using HTTP/3 with a proxy URL.
 var errCannotUseHTTP3WithAProxyURL = errors.New("cannot use HTTP/3 with a proxy URL")
 
-// errUnsupportedResolverScheme means we don't support the
-// given resolver scheme. We only support https, http and system.
+// errCannotUseUDPWithAProxyURL means we cannot construct a new
+// child resolver using DNS over UDP with a proxy URL.
+var errCannotUseUDPWithAProxyURL = errors.New("cannot use DNS over UDP with a proxy URL")
+
+// errUnsupportedResolverScheme means we don't support the given
+// resolver scheme. We only support https, http, dot, tcp, udp and system.
 var errUnsupportedResolverScheme = errors.New("unsupported resolver scheme")
 
 // newChildResolver constructs a new child resolver.
@@ -24,7 +30,8 @@ var errUnsupportedResolverScheme = errors.New("unsupported resolver scheme")
 //
 // - logger is the MANDATORY logger;
 //
-// - URL is the MANDATORY URL to use (a DoH URL or system:///);
+// - URL is the MANDATORY URL to use (a DoH URL, a dot://, tcp:// or
+// udp:// URL containing the server endpoint, or system:///);
 //
 // - http3Enabled indicates whether to use HTTP/3;
 //
@@ -32,8 +39,8 @@ var errUnsupportedResolverScheme = errors.New("unsupported resolver scheme")
 //
 // - proxyURL is the OPTIONAL proxy URL.
 //
-// Using a proxy URL is incompatible with using HTTP/3 and this
-// factory will return an error if that happens.
+// Using a proxy URL is incompatible with using HTTP/3 or DNS over UDP
+// and this factory will return an error if that happens.
 //
 // This function returns a model.Resolver or an error.
 func newChildResolver(
@@ -56,6 +63,11 @@ func newChildResolver(
 	switch parsed.Scheme {
 	case "http", "https": // http is here for testing
 		reso = newChildResolverHTTPS(logger, URL, http3Enabled, counter, proxyURL)
+	case "dot", "tcp", "udp":
+		if parsed.Scheme == "udp" && proxyURL != nil {
+			return nil, errCannotUseUDPWithAProxyURL
+		}
+		reso = newChildResolverDNS(logger, parsed.Scheme, parsed.Host, counter, proxyURL)
 	case "system":
 		reso = bytecounter.MaybeWrapSystemResolver(
 			netxlite.NewStdlibResolver(logger),
@@ -96,3 +108,62 @@ func newChildResolverHTTPS(
 	wrapped := netxlite.WrapResolver(logger, underlying)
 	return wrapped
 }
+
+// defaultPortByScheme maps a DNS URL scheme to its default port.
+var defaultPortByScheme = map[string]string{
+	"dot": "853",
+	"tcp": "53",
+	"udp": "53",
+}
+
+// newChildResolverDNS is like newChildResolver but assumes that we
+// already know that the URL scheme is dot, tcp, or udp. The address
+// argument is the server endpoint, possibly without the port.
+func newChildResolverDNS(
+	logger model.Logger,
+	scheme string,
+	address string,
+	counter *bytecounter.Counter,
+	proxyURL *url.URL,
+) model.Resolver {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPortByScheme[scheme])
+	}
+	var dialer model.Dialer = &byteCounterDialer{
+		Dialer: netxlite.MaybeWrapWithProxyDialer(
+			netxlite.NewDialerWithStdlibResolver(logger),
+			proxyURL, // handles correctly the case where proxyURL is nil
+		),
+		Counter: counter,
+	}
+	var dnstxp model.DNSTransport
+	switch scheme {
+	case "dot":
+		thx := netxlite.NewTLSHandshakerStdlib(logger)
+		tlsDialer := netxlite.NewTLSDialer(dialer, thx)
+		dnstxp = netxlite.NewUnwrappedDNSOverTLSTransport(tlsDialer.DialTLSContext, address)
+	case "tcp":
+		dnstxp = netxlite.NewUnwrappedDNSOverTCPTransport(dialer.DialContext, address)
+	default:
+		dnstxp = netxlite.NewUnwrappedDNSOverUDPTransport(dialer, address)
+	}
+	underlying := netxlite.NewUnwrappedParallelResolver(dnstxp)
+	wrapped := netxlite.WrapResolver(logger, underlying)
+	return wrapped
+}
+
+// byteCounterDialer is a model.Dialer counting the bytes
+// sent and received using the OPTIONAL Counter.
+type byteCounterDialer struct {
+	model.Dialer
+	Counter *bytecounter.Counter
+}
+
+// DialContext implements model.Dialer.DialContext.
+func (d *byteCounterDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return bytecounter.MaybeWrapConn(conn, d.Counter), nil
+}
@@ -104,7 +104,7 @@ func Test_newChildResolver(t *testing.T) {
 	t.Run("we return an error when we don't support the URL scheme", func(t *testing.T) {
 		reso, err := newChildResolver(
 			model.DiscardLogger,
-			"dot://8.8.8.8:853/",
+			"ftp://8.8.8.8:853/",
 			true,
 			bytecounter.New(),
 			nil,
@@ -247,6 +247,116 @@ func Test_newChildResolver(t *testing.T) {
 		})
 	})
 
+	t.Run("for DNS over TCP and UDP resolvers", func(t *testing.T) {
+		// startServer starts a DNS server returning 8.8.8.8
+		startServer := func(t *testing.T, network string) (string, func()) {
+			handler := dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+				resp := &dns.Msg{}
+				resp.SetReply(query)
+				if query.Question[0].Qtype == dns.TypeA {
+					resp.Answer = append(resp.Answer, &dns.A{
+						Hdr: dns.RR_Header{
+							Name:   query.Question[0].Name,
+							Rrtype: dns.TypeA,
+							Class:  dns.ClassINET,
+						},
+						A: net.IPv4(8, 8, 8, 8),
+					})
+				}
+				w.WriteMsg(resp)
+			})
+			srvr := &dns.Server{Handler: handler}
+			switch network {
+			case "udp":
+				pconn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				srvr.PacketConn = pconn
+				go srvr.ActivateAndServe()
+				return pconn.LocalAddr().String(), func() { srvr.Shutdown() }
+			default:
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				srvr.Listener = listener
+				go srvr.ActivateAndServe()
+				return listener.Addr().String(), func() { srvr.Shutdown() }
+			}
+		}
+
+		for _, network := range []string{"tcp", "udp"} {
+			t.Run("what we get is a working "+network+" resolver", func(t *testing.T) {
+				address, stop := startServer(t, network)
+				defer stop()
+				counter := bytecounter.New()
+				reso, err := newChildResolver(
+					model.DiscardLogger,
+					network+"://"+address,
+					false,
+					counter,
+					nil,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				addrs, err := reso.LookupHost(context.Background(), "dns.google")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(addrs) != 1 || addrs[0] != "8.8.8.8" {
+					t.Fatal("unexpected addrs", addrs)
+				}
+				if counter.BytesReceived() <= 0 || counter.BytesSent() <= 0 {
+					t.Fatal("expected to count bytes")
+				}
+			})
+		}
+
+		t.Run("we use the default port when it's missing", func(t *testing.T) {
+			for scheme, expect := range map[string]string{
+				"dot": "dns.google:853",
+				"tcp": "8.8.8.8:53",
+				"udp": "8.8.8.8:53",
+			} {
+				host := "8.8.8.8"
+				if scheme == "dot" {
+					host = "dns.google"
+				}
+				reso, err := newChildResolver(
+					model.DiscardLogger,
+					scheme+"://"+host,
+					false,
+					nil,
+					nil,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reso.Address() != expect {
+					t.Fatal("unexpected address", reso.Address())
+				}
+			}
+		})
+
+		t.Run("we cannot use DNS over UDP with a proxy URL", func(t *testing.T) {
+			reso, err := newChildResolver(
+				model.DiscardLogger,
+				"udp://8.8.8.8:53",
+				false,
+				nil,
+				&url.URL{},
+			)
+			if !errors.Is(err, errCannotUseUDPWithAProxyURL) {
+				t.Fatal("unexpected error", err)
+			}
+			if reso != nil {
+				t.Fatal("expected nil resolver here")
+			}
+		})
+	})
+
 	t.Run("for the system resolver", func(t *testing.T) {
 
 		t.Run("the returned resolver wraps errors", func(t *testing.T) {
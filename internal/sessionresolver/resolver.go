@@ -17,6 +17,7 @@ import (
 	"github.com/ooni/probe-cli/v3/internal/measurexlite"
 	"github.com/ooni/probe-cli/v3/internal/model"
 	"github.com/ooni/probe-cli/v3/internal/multierror"
+	"github.com/ooni/probe-cli/v3/internal/probemetrics"
 )
 
 // Resolver is the session resolver. Resolver will try to use
@@ -26,6 +27,10 @@ import (
 // and therefore we can generally give preference to underlying
 // DoT/DoH resolvers that work better.
 //
+// Users may add custom resolvers, inspect the resolvers state,
+// reset the score of a resolver, and pin a resolver such that
+// we only use it (see custom.go).
+//
 // Make sure you fill the mandatory fields (indicated below)
 // before using this data structure.
 //
@@ -53,6 +58,11 @@ type Resolver struct {
 	// based resolvers and we WON'T use the system resolver.
 	ProxyURL *url.URL
 
+	// URLs is the OPTIONAL list of resolver URLs to use instead
+	// of the default list (e.g., when using a self-hosted backend). We
+	// give higher initial priority to the URLs that come first.
+	URLs []string
+
 	// jsonCodec is the OPTIONAL JSON Codec to use. If not set,
 	// we will construct a default codec.
 	jsonCodec jsonCodec
@@ -101,10 +111,15 @@ var ErrLookupHost = errors.New("sessionresolver: LookupHost failed")
 // and get a better picture of what's been going wrong.
 func (r *Resolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
 	state := r.readstatedefault()
-	r.maybeConfusion(state, time.Now().UnixNano())
 	defer r.writestate(state)
+	candidates := state
+	if pinned := r.pinned(state); pinned != nil {
+		candidates = []*resolverinfo{pinned}
+	} else {
+		r.maybeConfusion(state, time.Now().UnixNano())
+	}
 	me := multierror.New(ErrLookupHost)
-	for _, e := range state {
+	for _, e := range candidates {
 		if r.ProxyURL != nil && r.shouldSkipWithProxy(e) {
 			r.logger().Infof("sessionresolver: skipping with proxy: %+v", e)
 			continue // we cannot proxy this URL so ignore it
@@ -136,6 +151,8 @@ func (r *Resolver) lookupHost(ctx context.Context, ri *resolverinfo, hostname st
 	re, err := r.getresolver(ri.URL)
 	if err != nil {
 		r.logger().Warnf("sessionresolver: getresolver: %s", err.Error())
+		probemetrics.ResolverLookupsCount.WithLabelValues(ri.URL, probemetrics.Result(err)).Inc()
+		ri.addFailure(time.Now(), err)
 		ri.Score = 0 // this is a hard error
 		return nil, err
 	}
@@ -143,10 +160,12 @@ func (r *Resolver) lookupHost(ctx context.Context, ri *resolverinfo, hostname st
 		r.logger(), "sessionresolver: lookup %s using %s", hostname, ri.URL)
 	addrs, err := timeLimitedLookup(ctx, re, hostname)
 	op.Stop(err)
+	probemetrics.ResolverLookupsCount.WithLabelValues(ri.URL, probemetrics.Result(err)).Inc()
 	if err == nil {
 		ri.Score = ewma*1.0 + (1-ewma)*ri.Score // increase score
 		return addrs, nil
 	}
+	ri.addFailure(time.Now(), err)
 	ri.Score = ewma*0.0 + (1-ewma)*ri.Score // decrease score
 	return nil, err
 }
@@ -60,6 +60,52 @@ func init() {
 	}
 }
 
+// customResolverScore is the initial score of custom resolvers, which
+// is high because the user explicitly asked us to use them.
+const customResolverScore = 1
+
+// makers returns the resolvermakers to use. When the URLs field is set we
+// create makers for the configured URLs with decreasing initial scores, so
+// that we try the resolvers in order, and otherwise we use allmakers. In
+// both cases, we also include the custom resolvers added by the user.
+func (r *Resolver) makers() []*resolvermaker {
+	var out []*resolvermaker
+	if len(r.URLs) <= 0 {
+		out = append(out, allmakers...)
+	}
+	for idx, URL := range r.URLs {
+		out = append(out, &resolvermaker{
+			url:   URL,
+			score: 1 - float64(idx)/float64(len(r.URLs)),
+		})
+	}
+	here := make(map[string]bool)
+	for _, e := range out {
+		here[e.url] = true
+	}
+	for _, URL := range r.readconfig().Custom {
+		if here[URL] {
+			continue // already here so no need to add
+		}
+		here[URL] = true
+		out = append(out, &resolvermaker{
+			url:   URL,
+			score: customResolverScore,
+		})
+	}
+	return out
+}
+
+// supports returns whether we should use the resolver with the given URL.
+func (r *Resolver) supports(URL string) bool {
+	for _, e := range r.makers() {
+		if e.url == URL {
+			return true
+		}
+	}
+	return false
+}
+
 // logger returns the configured logger or a default
 func (r *Resolver) logger() model.Logger {
 	return model.ValidLoggerOrDefault(r.Logger)
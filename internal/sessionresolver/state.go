@@ -7,6 +7,7 @@ package sessionresolver
 import (
 	"errors"
 	"sort"
+	"time"
 )
 
 // storekey is the key used by the key value store to store
@@ -20,6 +21,32 @@ type resolverinfo struct {
 
 	// Score is the score of a resolver.
 	Score float64
+
+	// RecentFailures contains the most recent failures of a resolver.
+	RecentFailures []ResolverFailure `json:",omitempty"`
+}
+
+// ResolverFailure is a recent failure of a resolver.
+type ResolverFailure struct {
+	// Time is the time when the failure occurred.
+	Time time.Time
+
+	// Failure is the failure string.
+	Failure string
+}
+
+// maxRecentFailures is the maximum number of recent failures we keep.
+const maxRecentFailures = 5
+
+// addFailure records a recent failure of the resolver.
+func (ri *resolverinfo) addFailure(t time.Time, err error) {
+	ri.RecentFailures = append(ri.RecentFailures, ResolverFailure{
+		Time:    t,
+		Failure: err.Error(),
+	})
+	if n := len(ri.RecentFailures); n > maxRecentFailures {
+		ri.RecentFailures = ri.RecentFailures[n-maxRecentFailures:]
+	}
 }
 
 // ErrNilKVStore indicates that the KVStore is nil.
@@ -52,9 +79,13 @@ func (r *Resolver) readstateandprune() ([]*resolverinfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	supported := make(map[string]bool)
+	for _, e := range r.makers() {
+		supported[e.url] = true
+	}
 	var out []*resolverinfo
 	for _, e := range ri {
-		if _, found := allbyurl[e.URL]; !found {
+		if !supported[e.URL] {
 			continue // we don't support this specific entry
 		}
 		out = append(out, e)
@@ -80,7 +111,7 @@ func (r *Resolver) readstatedefault() []*resolverinfo {
 	for _, e := range ri {
 		here[e.URL] = true // record what we already have
 	}
-	for _, e := range allmakers {
+	for _, e := range r.makers() {
 		if _, found := here[e.url]; found {
 			continue // already here so no need to add
 		}
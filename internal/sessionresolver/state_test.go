@@ -113,6 +113,34 @@ func TestReadStateDefaultWithMissingEntries(t *testing.T) {
 	}
 }
 
+func TestReadStateDefaultWithCustomURLs(t *testing.T) {
+	reso := &Resolver{
+		KVStore: &kvstore.Memory{},
+		URLs: []string{
+			"https://dns.example.com/dns-query",
+			"system:///",
+		},
+	}
+	// let us simulate that we have a default entry in the store
+	var in []*resolverinfo
+	in = append(in, &resolverinfo{
+		URL:   "https://dns.google/dns-query",
+		Score: 0.88,
+	})
+	if err := reso.writestate(in); err != nil {
+		t.Fatal(err)
+	}
+	out := reso.readstatedefault()
+	if len(out) != 2 {
+		t.Fatal("expected two entries")
+	}
+	for idx, e := range out {
+		if e.URL != reso.URLs[idx] {
+			t.Fatal("unexpected entry", idx, e.URL)
+		}
+	}
+}
+
 func TestWriteStateNoKVStore(t *testing.T) {
 	reso := &Resolver{}
 	existingURL := "https://dns.google/dns-query"
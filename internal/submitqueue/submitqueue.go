@@ -0,0 +1,446 @@
+// Package submitqueue implements a persistent queue of measurements that
+// we could not submit to the OONI collector. We store each measurement as
+// a file inside a directory, such that different sessions, and also different
+// frontends, sharing the same directory can retry submitting them later.
+//
+// The engine uses this queue when SessionConfig.SubmitQueueDir is set, which
+// is the case for miniooni and for the mobile apps using pkg/oonimkall. The
+// ooniprobe CLI does not use this queue because it records the measurements it
+// could not submit in its database and resubmits them with `ooniprobe upload`.
+package submitqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/randx"
+	"github.com/rogpeppe/go-internal/lockedfile"
+)
+
+const (
+	// DefaultMaxEntries is the default maximum number of queued measurements.
+	DefaultMaxEntries = 1000
+
+	// DefaultMaxBytes is the default maximum size of the queue in bytes.
+	DefaultMaxBytes = 64 << 20
+
+	// DefaultMinBackoff is the default delay before the first retry.
+	DefaultMinBackoff = 5 * time.Minute
+
+	// DefaultMaxBackoff is the default maximum delay between retries.
+	DefaultMaxBackoff = 24 * time.Hour
+
+	// DefaultMaxAttempts is the default maximum number of failed
+	// submission attempts after which we drop a measurement.
+	DefaultMaxAttempts = 10
+)
+
+// ErrNoSuchEntry indicates that there is no entry with the given ID.
+var ErrNoSuchEntry = errors.New("submitqueue: no such entry")
+
+// ErrEntryTooLarge indicates that a measurement is larger than the queue.
+var ErrEntryTooLarge = errors.New("submitqueue: entry too large")
+
+// entrySuffix is the suffix of the files containing entries.
+const entrySuffix = ".json"
+
+// Entry is the metadata of a queued measurement.
+type Entry struct {
+	// ID uniquely identifies the entry.
+	ID string `json:"id"`
+
+	// TestName is the name of the experiment.
+	TestName string `json:"test_name"`
+
+	// Input is the measurement input.
+	Input model.MeasurementTarget `json:"input"`
+
+	// EnqueuedAt is when we enqueued the measurement.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+
+	// Attempts is the number of failed submission attempts.
+	Attempts int `json:"attempts"`
+
+	// NextAttempt is when we should try submitting again.
+	NextAttempt time.Time `json:"next_attempt"`
+
+	// LastFailure is the error that occurred during the last attempt.
+	LastFailure string `json:"last_failure"`
+
+	// Size is the size in bytes of the entry on disk.
+	Size int64 `json:"-"`
+}
+
+// entryFile is the content of an entry file.
+type entryFile struct {
+	Entry       *Entry          `json:"entry"`
+	Measurement json.RawMessage `json:"measurement"`
+}
+
+// FlushResult contains the results of Flush.
+type FlushResult struct {
+	// Submitted is the number of measurements we submitted.
+	Submitted int
+
+	// Failed is the number of measurements we could not submit.
+	Failed int
+
+	// Deferred is the number of measurements we did not try to
+	// submit because their backoff has not expired yet or because
+	// we reached the limit passed to FlushAtMost.
+	Deferred int
+}
+
+// Queue is a persistent submission queue. Use New to construct.
+type Queue struct {
+	// MaxEntries is the maximum number of entries. When we exceed this
+	// limit, we drop the oldest entries to make room for new ones.
+	MaxEntries int
+
+	// MaxBytes is the maximum size of the queue in bytes. When we exceed
+	// this limit, we drop the oldest entries to make room for new ones.
+	MaxBytes int64
+
+	// MinBackoff is the delay before the first retry, which we double
+	// after each failed attempt up to MaxBackoff.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between retries.
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the maximum number of failed submission attempts
+	// after which we drop a measurement, which is useful because the
+	// collector may permanently reject a measurement. A zero or negative
+	// value means that we retry forever.
+	MaxAttempts int
+
+	// dir is the directory containing the queue.
+	dir string
+
+	// logger is the logger to use.
+	logger model.Logger
+
+	// mu provides mutual exclusion inside this process.
+	mu sync.Mutex
+
+	// timeNow allows to mock time.Now in tests.
+	timeNow func() time.Time
+}
+
+// New creates a new queue using the given directory, which
+// we create if needed, and the given logger.
+func New(dir string, logger model.Logger) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		MaxEntries:  DefaultMaxEntries,
+		MaxBytes:    DefaultMaxBytes,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		MaxAttempts: DefaultMaxAttempts,
+		dir:         dir,
+		logger:      model.ValidLoggerOrDefault(logger),
+		timeNow:     time.Now,
+	}
+	return q, nil
+}
+
+// lock locks the queue directory. Because other processes may be using
+// the same directory, we also lock a file inside the directory.
+func (q *Queue) lock(name string) (func(), error) {
+	q.mu.Lock()
+	unlock, err := lockedfile.MutexAt(filepath.Join(q.dir, name)).Lock()
+	if err != nil {
+		q.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		q.mu.Unlock()
+	}, nil
+}
+
+// stateLockFile is the lock protecting the entries.
+const stateLockFile = ".lock"
+
+// flushLockFile is the lock ensuring we only run a flush at a time.
+const flushLockFile = ".flush"
+
+// Enqueue adds a measurement to the queue. The failure argument is the
+// OPTIONAL error that prevented us from submitting the measurement.
+func (q *Queue) Enqueue(m *model.Measurement, failure error) (*Entry, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	now := q.timeNow()
+	entry := &Entry{
+		ID:          fmt.Sprintf("%d-%s", now.UnixNano(), randx.Letters(8)),
+		TestName:    m.TestName,
+		Input:       m.Input,
+		EnqueuedAt:  now,
+		Attempts:    0,
+		NextAttempt: now, // we can retry as soon as the next flush
+	}
+	if failure != nil {
+		entry.LastFailure = failure.Error()
+	}
+	unlock, err := q.lock(stateLockFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := q.writeLocked(entry, data); err != nil {
+		return nil, err
+	}
+	return entry, q.enforceCapsLocked()
+}
+
+// List returns the queued entries sorted by enqueue time.
+func (q *Queue) List() ([]*Entry, error) {
+	unlock, err := q.lock(stateLockFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return q.listLocked()
+}
+
+// Remove removes the entry with the given ID.
+func (q *Queue) Remove(ID string) error {
+	unlock, err := q.lock(stateLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return q.removeLocked(ID)
+}
+
+// Clear removes all the entries.
+func (q *Queue) Clear() error {
+	unlock, err := q.lock(stateLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := q.listLocked()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := q.removeLocked(e.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush tries to submit the queued measurements whose backoff has expired
+// using the given submitter. When force is true, we try to submit all the
+// measurements regardless of their backoff. We stop flushing when the context
+// is done, in which case we also return the context error.
+func (q *Queue) Flush(ctx context.Context, submitter model.Submitter, force bool) (*FlushResult, error) {
+	return q.FlushAtMost(ctx, submitter, force, 0)
+}
+
+// FlushAtMost is like Flush but tries to submit at most limit measurements,
+// such that the caller can bound the time spent flushing a large queue. A
+// zero or negative limit means that there is no limit.
+func (q *Queue) FlushAtMost(
+	ctx context.Context, submitter model.Submitter, force bool, limit int) (*FlushResult, error) {
+	// Ensure that a single flush runs at any given time, so we don't submit
+	// the same measurement twice, without holding the state lock while we
+	// submit, so that other sessions can enqueue in the meanwhile.
+	unlockFlush, err := lockedfile.MutexAt(filepath.Join(q.dir, flushLockFile)).Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlockFlush()
+	entries, err := q.List()
+	if err != nil {
+		return nil, err
+	}
+	result := &FlushResult{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if !force && q.timeNow().Before(entry.NextAttempt) {
+			result.Deferred++
+			continue
+		}
+		if limit > 0 && result.Submitted+result.Failed >= limit {
+			result.Deferred++
+			continue
+		}
+		if err := q.submit(ctx, submitter, entry); err != nil {
+			q.logger.Warnf("submitqueue: cannot submit %s: %s", entry.ID, err.Error())
+			result.Failed++
+			continue
+		}
+		q.logger.Infof("submitqueue: submitted %s", entry.ID)
+		result.Submitted++
+	}
+	return result, nil
+}
+
+// submit submits a single entry and updates the queue accordingly.
+func (q *Queue) submit(ctx context.Context, submitter model.Submitter, entry *Entry) error {
+	ef, err := q.read(entry.ID)
+	if err != nil {
+		return err
+	}
+	var m model.Measurement
+	if err := json.Unmarshal(ef.Measurement, &m); err != nil {
+		// the entry is corrupt and we'll never be able to submit it
+		q.logger.Warnf("submitqueue: removing corrupt entry %s", entry.ID)
+		return q.Remove(entry.ID)
+	}
+	submitErr := submitter.Submit(ctx, &m)
+	unlock, err := q.lock(stateLockFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if submitErr == nil {
+		return q.removeLocked(entry.ID)
+	}
+	entry = ef.Entry
+	entry.Attempts++
+	if q.MaxAttempts > 0 && entry.Attempts >= q.MaxAttempts {
+		q.logger.Warnf("submitqueue: dropping %s after %d attempts", entry.ID, entry.Attempts)
+		if err := q.removeLocked(entry.ID); err != nil {
+			return err
+		}
+		return submitErr
+	}
+	entry.LastFailure = submitErr.Error()
+	entry.NextAttempt = q.timeNow().Add(q.backoff(entry.Attempts))
+	if err := q.writeLocked(entry, ef.Measurement); err != nil {
+		return err
+	}
+	return submitErr
+}
+
+// backoff returns the backoff after the given number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.MinBackoff
+	for i := 1; i < attempts && delay < q.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.MaxBackoff {
+		delay = q.MaxBackoff
+	}
+	return delay
+}
+
+// filename returns the file name of the given entry.
+func (q *Queue) filename(ID string) string {
+	return filepath.Join(q.dir, ID+entrySuffix)
+}
+
+// read reads the file of the given entry.
+func (q *Queue) read(ID string) (*entryFile, error) {
+	data, err := lockedfile.Read(q.filename(ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchEntry, ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ef entryFile
+	if err := json.Unmarshal(data, &ef); err != nil {
+		return nil, err
+	}
+	if ef.Entry == nil {
+		return nil, fmt.Errorf("submitqueue: missing metadata in %s", ID)
+	}
+	ef.Entry.Size = int64(len(data))
+	return &ef, nil
+}
+
+// writeLocked writes the file of the given entry.
+func (q *Queue) writeLocked(entry *Entry, measurement json.RawMessage) error {
+	data, err := json.Marshal(&entryFile{Entry: entry, Measurement: measurement})
+	if err != nil {
+		return err
+	}
+	if q.MaxBytes > 0 && int64(len(data)) > q.MaxBytes {
+		return ErrEntryTooLarge
+	}
+	entry.Size = int64(len(data))
+	return lockedfile.Write(q.filename(entry.ID), bytes.NewReader(data), 0600)
+}
+
+// removeLocked removes the file of the given entry.
+func (q *Queue) removeLocked(ID string) error {
+	err := os.Remove(q.filename(ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNoSuchEntry, ID)
+	}
+	return err
+}
+
+// listLocked lists the entries sorted by enqueue time.
+func (q *Queue) listLocked() ([]*Entry, error) {
+	dirents, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Entry
+	for _, dirent := range dirents {
+		name := dirent.Name()
+		if dirent.IsDir() || !strings.HasSuffix(name, entrySuffix) {
+			continue
+		}
+		ef, err := q.read(strings.TrimSuffix(name, entrySuffix))
+		if err != nil {
+			q.logger.Warnf("submitqueue: skipping %s: %s", name, err.Error())
+			continue
+		}
+		out = append(out, ef.Entry)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].EnqueuedAt.Before(out[j].EnqueuedAt)
+	})
+	return out, nil
+}
+
+// enforceCapsLocked drops the oldest entries until the queue
+// honours the MaxEntries and MaxBytes limits.
+func (q *Queue) enforceCapsLocked() error {
+	entries, err := q.listLocked()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	for len(entries) > 0 {
+		tooMany := q.MaxEntries > 0 && len(entries) > q.MaxEntries
+		tooLarge := q.MaxBytes > 0 && total > q.MaxBytes
+		if !tooMany && !tooLarge {
+			break
+		}
+		oldest := entries[0]
+		q.logger.Warnf("submitqueue: queue is full; dropping %s", oldest.ID)
+		if err := q.removeLocked(oldest.ID); err != nil {
+			return err
+		}
+		total -= oldest.Size
+		entries = entries[1:]
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package submitqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// submitterFunc adapts a func to model.Submitter.
+type submitterFunc func(ctx context.Context, m *model.Measurement) error
+
+func (f submitterFunc) Submit(ctx context.Context, m *model.Measurement) error {
+	return f(ctx, m)
+}
+
+func newQueue(t *testing.T) *Queue {
+	q, err := New(filepath.Join(t.TempDir(), "queue"), log.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("Enqueue and List", func(t *testing.T) {
+		q := newQueue(t)
+		for _, input := range []string{"https://a.org/", "https://b.org/"} {
+			m := &model.Measurement{TestName: "web_connectivity", Input: model.MeasurementTarget(input)}
+			if _, err := q.Enqueue(m, errors.New("mocked error")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Input != "https://a.org/" || entries[1].Input != "https://b.org/" {
+			t.Fatal("unexpected entries", entries)
+		}
+		if entries[0].LastFailure != "mocked error" || entries[0].Size <= 0 {
+			t.Fatal("unexpected entry", entries[0])
+		}
+	})
+
+	t.Run("a queue can be shared", func(t *testing.T) {
+		q := newQueue(t)
+		if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		other, err := New(q.dir, log.Log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := other.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatal("expected to see the entry from the other queue")
+		}
+	})
+
+	t.Run("we enforce MaxEntries", func(t *testing.T) {
+		q := newQueue(t)
+		q.MaxEntries = 2
+		for _, name := range []string{"a", "b", "c"} {
+			if _, err := q.Enqueue(&model.Measurement{TestName: name}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].TestName != "b" || entries[1].TestName != "c" {
+			t.Fatal("expected to drop the oldest entry", entries)
+		}
+	})
+
+	t.Run("we enforce MaxBytes", func(t *testing.T) {
+		q := newQueue(t)
+		entry, err := q.Enqueue(&model.Measurement{TestName: "a"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.MaxBytes = entry.Size + 10 // room for a single entry
+		if _, err := q.Enqueue(&model.Measurement{TestName: "b"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].TestName != "b" {
+			t.Fatal("expected to drop the oldest entry", entries)
+		}
+		q.MaxBytes = 10
+		if _, err := q.Enqueue(&model.Measurement{TestName: "c"}, nil); !errors.Is(err, ErrEntryTooLarge) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("Remove and Clear", func(t *testing.T) {
+		q := newQueue(t)
+		entry, err := q.Enqueue(&model.Measurement{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Remove(entry.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Remove(entry.ID); !errors.Is(err, ErrNoSuchEntry) {
+			t.Fatal("unexpected err", err)
+		}
+		if err := q.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatal("expected no entries")
+		}
+	})
+
+	t.Run("we skip corrupt files when listing", func(t *testing.T) {
+		q := newQueue(t)
+		if err := os.WriteFile(q.filename("antani"), []byte("{"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatal("expected no entries")
+		}
+	})
+}
+
+func TestQueueFlush(t *testing.T) {
+	t.Run("we submit and remove the measurements", func(t *testing.T) {
+		q := newQueue(t)
+		if _, err := q.Enqueue(&model.Measurement{TestName: "dnscheck"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		var submitted []string
+		submitter := submitterFunc(func(ctx context.Context, m *model.Measurement) error {
+			submitted = append(submitted, m.TestName)
+			return nil
+		})
+		result, err := q.Flush(context.Background(), submitter, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Submitted != 1 || result.Failed != 0 || result.Deferred != 0 {
+			t.Fatal("unexpected result", result)
+		}
+		if len(submitted) != 1 || submitted[0] != "dnscheck" {
+			t.Fatal("unexpected submissions", submitted)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatal("expected no entries")
+		}
+	})
+
+	t.Run("we retry with backoff", func(t *testing.T) {
+		q := newQueue(t)
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		q.timeNow = func() time.Time { return now }
+		if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		var attempts int
+		failing := submitterFunc(func(ctx context.Context, m *model.Measurement) error {
+			attempts++
+			return errors.New("mocked error")
+		})
+		ctx := context.Background()
+		for _, expectDelay := range []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute} {
+			result, err := q.Flush(ctx, failing, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Failed != 1 {
+				t.Fatal("unexpected result", result)
+			}
+			entries, err := q.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !entries[0].NextAttempt.Equal(now.Add(expectDelay)) || entries[0].LastFailure != "mocked error" {
+				t.Fatal("unexpected entry", entries[0])
+			}
+			// before the backoff expires we should not retry
+			result, err = q.Flush(ctx, failing, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Deferred != 1 {
+				t.Fatal("unexpected result", result)
+			}
+			now = now.Add(expectDelay)
+		}
+		if attempts != 3 {
+			t.Fatal("unexpected number of attempts", attempts)
+		}
+		// with force we should retry regardless of the backoff
+		result, err := q.Flush(ctx, failing, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Failed != 1 || attempts != 4 {
+			t.Fatal("unexpected result", result)
+		}
+	})
+
+	t.Run("we drop a measurement after MaxAttempts", func(t *testing.T) {
+		q := newQueue(t)
+		q.MaxAttempts = 3
+		if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		var attempts int
+		failing := submitterFunc(func(ctx context.Context, m *model.Measurement) error {
+			attempts++
+			return errors.New("mocked error")
+		})
+		for idx := 0; idx < 5; idx++ {
+			if _, err := q.Flush(context.Background(), failing, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if attempts != 3 {
+			t.Fatal("unexpected number of attempts", attempts)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatal("expected the measurement to be dropped")
+		}
+	})
+
+	t.Run("we honour the limit", func(t *testing.T) {
+		q := newQueue(t)
+		for idx := 0; idx < 3; idx++ {
+			if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var attempts int
+		submitter := submitterFunc(func(ctx context.Context, m *model.Measurement) error {
+			attempts++
+			return nil
+		})
+		result, err := q.FlushAtMost(context.Background(), submitter, false, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Submitted != 2 || result.Deferred != 1 || attempts != 2 {
+			t.Fatal("unexpected result", result)
+		}
+		entries, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatal("unexpected number of entries", len(entries))
+		}
+	})
+
+	t.Run("we cap the backoff", func(t *testing.T) {
+		q := newQueue(t)
+		if q.backoff(100) != DefaultMaxBackoff {
+			t.Fatal("unexpected backoff")
+		}
+	})
+
+	t.Run("we stop when the context is done", func(t *testing.T) {
+		q := newQueue(t)
+		if _, err := q.Enqueue(&model.Measurement{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		submitter := submitterFunc(func(ctx context.Context, m *model.Measurement) error {
+			t.Fatal("should not be called")
+			return nil
+		})
+		result, err := q.Flush(ctx, submitter, true)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("unexpected err", err)
+		}
+		if result.Submitted != 0 {
+			t.Fatal("unexpected result", result)
+		}
+	})
+}
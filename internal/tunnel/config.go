@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net"
+	"net/url"
 	"os"
 
 	"github.com/armon/go-socks5"
@@ -19,11 +20,23 @@ import (
 // structure while in use, because that may lead to data races.
 type Config struct {
 	// Name is the MANDATORY name of the tunnel. We support
-	// "tor", "psiphon", and "fake" tunnels. You SHOULD
+	// "tor", "torsf", "tor+obfs4", "psiphon", "socks5",
+	// "http-connect", and "fake" tunnels. You SHOULD
 	// use "fake" tunnels only for testing: they don't provide
 	// any real tunneling, just a socks5 proxy.
 	Name string
 
+	// BridgeLines contains the tor bridge lines to use with the
+	// "tor+obfs4" tunnel. This field is MANDATORY for such a
+	// tunnel and ignored by all the other tunnels.
+	BridgeLines []string
+
+	// BootstrapProgress is the OPTIONAL function called to report
+	// the bootstrap progress. The "tor" based tunnels report tor's
+	// bootstrap phase and the "psiphon" tunnel reports psiphon's
+	// notices. Other tunnels do not report any progress.
+	BootstrapProgress func(ev *BootstrapEvent)
+
 	// Session is the MANDATORY measurement session, or a suitable
 	// mock of the required functionality. That is, the possibility
 	// of obtaining a valid psiphon configuration.
@@ -42,6 +55,11 @@ type Config struct {
 	// implementation that does not emit any output.
 	Logger model.Logger
 
+	// HealthCheckEndpoint is the OPTIONAL TCP endpoint to which a
+	// Monitor connects through the tunnel to check whether the tunnel
+	// works. When empty, we use "api.ooni.io:443".
+	HealthCheckEndpoint string
+
 	// TorArgs contains the optional arguments that you want us to pass
 	// to the tor binary when invoking it. By default we do not
 	// pass any extra argument. This flag might be useful to
@@ -52,6 +70,11 @@ type Config struct {
 	// executing. When not set, we execute `tor`.
 	TorBinary string
 
+	// UpstreamProxyURL is the URL of the upstream proxy to use with
+	// the "socks5" and "http-connect" tunnels. This field is MANDATORY
+	// for such tunnels and ignored by all the other tunnels.
+	UpstreamProxyURL *url.URL
+
 	// testExecabsLookPath allows us to mock exeabs.LookPath
 	testExecabsLookPath func(name string) (string, error)
 
@@ -89,6 +112,12 @@ type Config struct {
 	// testTorGetInfo allows us to fake a failure when
 	// getting info from the tor control port.
 	testTorGetInfo func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error)
+
+	// testTorAddEventListener allows us to mock subscribing to tor events.
+	testTorAddEventListener func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error
+
+	// testTorRemoveEventListener allows us to mock unsubscribing from tor events.
+	testTorRemoveEventListener func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error
 }
 
 // snowflakeRendezvousMethod returns the rendezvous method that snowflake should use
@@ -99,6 +128,14 @@ func (c *Config) snowflakeRendezvousMethod() string {
 	return "domain_fronting"
 }
 
+// healthCheckEndpoint returns the endpoint to use for health checks.
+func (c *Config) healthCheckEndpoint() string {
+	if c.HealthCheckEndpoint != "" {
+		return c.HealthCheckEndpoint
+	}
+	return defaultHealthCheckEndpoint
+}
+
 // logger returns the logger to use.
 func (c *Config) logger() model.Logger {
 	if c.Logger != nil {
@@ -200,6 +237,22 @@ func (c *Config) torEnableNetwork(ctx context.Context, tor *tor.Tor, wait bool)
 	return tor.EnableNetwork(ctx, wait)
 }
 
+// torAddEventListener calls either testTorAddEventListener or ctrl.AddEventListener.
+func (c *Config) torAddEventListener(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+	if c.testTorAddEventListener != nil {
+		return c.testTorAddEventListener(ctrl, ch, codes...)
+	}
+	return ctrl.AddEventListener(ch, codes...)
+}
+
+// torRemoveEventListener calls either testTorRemoveEventListener or ctrl.RemoveEventListener.
+func (c *Config) torRemoveEventListener(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+	if c.testTorRemoveEventListener != nil {
+		return c.testTorRemoveEventListener(ctrl, ch, codes...)
+	}
+	return ctrl.RemoveEventListener(ch, codes...)
+}
+
 // torGetInfo calls either testTorGetInfo or ctrl.GetInfo.
 func (c *Config) torGetInfo(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
 	if c.testTorGetInfo != nil {
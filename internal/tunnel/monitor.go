@@ -0,0 +1,231 @@
+package tunnel
+
+//
+// Monitoring the health of a tunnel
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ErrTunnelDead indicates that a tunnel is not working anymore.
+var ErrTunnelDead = errors.New("tunnel: the tunnel is dead")
+
+// ErrMonitorStopped indicates that the Monitor has been stopped.
+var ErrMonitorStopped = errors.New("tunnel: the monitor has been stopped")
+
+// healthCheckTimeout is the timeout of a single health check. We use a
+// generous timeout because tor may need to build a new circuit.
+const healthCheckTimeout = 15 * time.Second
+
+// defaultHealthCheckEndpoint is the default endpoint we connect to
+// through the tunnel when checking whether the tunnel works.
+const defaultHealthCheckEndpoint = "api.ooni.io:443"
+
+// Monitor is a Tunnel that allows to check whether the underlying
+// tunnel is still working and optionally restarts it when it has died.
+//
+// When restarting is enabled, the monitor exposes a local SOCKS5 proxy URL
+// relaying connections to the current tunnel, such that the value returned by
+// SOCKS5ProxyURL does not change across restarts.
+type Monitor struct {
+	autoRestart bool
+	cancel      context.CancelFunc
+	config      *Config
+	ctx         context.Context
+	mu          sync.Mutex
+	relay       net.Listener
+	restartMu   sync.Mutex
+	restarts    int
+	start       func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) // for testing
+	stopped     bool
+	torArgs     []string
+	tunnel      Tunnel
+}
+
+var _ Tunnel = &Monitor{}
+
+// StartMonitor starts a tunnel like Start does and wraps it into a Monitor. When
+// autoRestart is true, CheckHealth restarts the tunnel when it has died.
+func StartMonitor(ctx context.Context, config *Config, autoRestart bool) (*Monitor, DebugInfo, error) {
+	torArgs := append([]string{}, config.TorArgs...)
+	tun, debugInfo, err := Start(ctx, config)
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	// Implementation note: the restarted tunnels must outlive the context passed
+	// to CheckHealth because, e.g., tor is killed when its context is done.
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	m := &Monitor{
+		autoRestart: autoRestart,
+		cancel:      cancel,
+		config:      config,
+		ctx:         monitorCtx,
+		start:       Start,
+		torArgs:     torArgs,
+		tunnel:      tun,
+	}
+	if autoRestart {
+		listener, err := config.netListen("tcp", "127.0.0.1:0")
+		if err != nil {
+			cancel()
+			tun.Stop()
+			return nil, debugInfo, err
+		}
+		m.relay = listener
+		go m.serveRelay(listener)
+	}
+	return m, debugInfo, nil
+}
+
+// BootstrapTime implements Tunnel.BootstrapTime.
+func (m *Monitor) BootstrapTime() time.Duration {
+	return m.current().BootstrapTime()
+}
+
+// SOCKS5ProxyURL implements Tunnel.SOCKS5ProxyURL.
+func (m *Monitor) SOCKS5ProxyURL() *url.URL {
+	if m.relay != nil {
+		return &url.URL{Scheme: "socks5", Host: m.relay.Addr().String()}
+	}
+	return m.current().SOCKS5ProxyURL()
+}
+
+// Stop implements Tunnel.Stop.
+func (m *Monitor) Stop() {
+	m.cancel() // interrupt a pending restart before locking
+	defer m.mu.Unlock()
+	m.mu.Lock()
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	if m.relay != nil {
+		m.relay.Close()
+	}
+	m.tunnel.Stop()
+}
+
+// Restarts returns the number of times we restarted the tunnel.
+func (m *Monitor) Restarts() int {
+	defer m.mu.Unlock()
+	m.mu.Lock()
+	return m.restarts
+}
+
+// current returns the current tunnel.
+func (m *Monitor) current() Tunnel {
+	defer m.mu.Unlock()
+	m.mu.Lock()
+	return m.tunnel
+}
+
+// CheckHealth returns nil if the tunnel is working. Otherwise, if restarting
+// is enabled, it attempts to restart the tunnel and returns nil on success. In
+// all the other cases, this function returns an error. The ctx only bounds
+// the health check, while a restarted tunnel lives until Stop is called.
+//
+// We only hold the mutex protecting the current tunnel while swapping
+// tunnels, such that the relay, BootstrapTime, and SOCKS5ProxyURL keep
+// working while we're checking or restarting the tunnel.
+func (m *Monitor) CheckHealth(ctx context.Context) error {
+	defer m.restartMu.Unlock()
+	m.restartMu.Lock() // we don't want concurrent restarts
+	m.mu.Lock()
+	stopped, current := m.stopped, m.tunnel
+	m.mu.Unlock()
+	if stopped {
+		return ErrMonitorStopped
+	}
+	err := checkTunnel(ctx, current.SOCKS5ProxyURL(), m.config.healthCheckEndpoint())
+	if err == nil {
+		return nil
+	}
+	logger := m.config.logger()
+	logger.Warnf("tunnel: %s tunnel is dead: %s", m.config.Name, err.Error())
+	if !m.autoRestart {
+		return fmt.Errorf("%w: %s", ErrTunnelDead, err.Error())
+	}
+	current.Stop()
+	// Implementation note: some tunnels append to TorArgs, so we
+	// must restore the original arguments before restarting.
+	m.config.TorArgs = append([]string{}, m.torArgs...)
+	logger.Infof("tunnel: restarting %s tunnel; please be patient...", m.config.Name)
+	tun, _, err := m.start(m.ctx, m.config)
+	if err != nil {
+		// Keep using a stopped tunnel so that the next check fails again
+		return fmt.Errorf("%w: cannot restart: %s", ErrTunnelDead, err.Error())
+	}
+	defer m.mu.Unlock()
+	m.mu.Lock()
+	if m.stopped {
+		// Stop was called while we were restarting
+		tun.Stop()
+		return ErrMonitorStopped
+	}
+	m.tunnel = tun
+	m.restarts++
+	return nil
+}
+
+// checkTunnel checks whether the tunnel whose SOCKS5 proxy is at the given
+// URL works by connecting to endpoint through the tunnel. Just checking whether
+// the proxy is alive is not enough, because, e.g., tor may be running and
+// yet it may have lost connectivity with the tor network.
+func checkTunnel(ctx context.Context, URL *url.URL, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	// Implementation note: proxy.SOCKS5 always returns a ContextDialer
+	dialer, err := proxy.SOCKS5("tcp", URL.Host, nil, &net.Dialer{})
+	if err != nil {
+		return err
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// serveRelay relays the connections accepted by listener to the current tunnel.
+func (m *Monitor) serveRelay(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.relayConn(conn)
+	}
+}
+
+// relayConn relays a single connection to the current tunnel.
+func (m *Monitor) relayConn(conn net.Conn) {
+	defer conn.Close()
+	proxy, err := net.Dial("tcp", m.current().SOCKS5ProxyURL().Host)
+	if err != nil {
+		return
+	}
+	defer proxy.Close()
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		io.Copy(proxy, conn)
+		// tell the proxy we're done writing
+		if tc, ok := proxy.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}()
+	io.Copy(conn, proxy)
+	conn.Close()
+	<-done
+}
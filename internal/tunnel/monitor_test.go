@@ -0,0 +1,214 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+func TestMonitor(t *testing.T) {
+	// the health checks connect to this server through the tunnel
+	endpoint := httptest.NewServer(http.NotFoundHandler())
+	defer endpoint.Close()
+
+	newConfig := func(t *testing.T) *Config {
+		return &Config{
+			Name:                "fake",
+			Session:             &MockableSession{},
+			TunnelDir:           t.TempDir(),
+			Logger:              model.DiscardLogger,
+			HealthCheckEndpoint: endpoint.Listener.Addr().String(),
+		}
+	}
+
+	t.Run("when Start fails", func(t *testing.T) {
+		config := newConfig(t)
+		config.Name = "antani"
+		m, _, err := StartMonitor(context.Background(), config, true)
+		if !errors.Is(err, ErrUnsupportedTunnelName) {
+			t.Fatal("unexpected err", err)
+		}
+		if m != nil {
+			t.Fatal("expected nil monitor")
+		}
+	})
+
+	t.Run("with a working tunnel", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Stop()
+		if err := m.CheckHealth(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if m.SOCKS5ProxyURL().String() != m.current().SOCKS5ProxyURL().String() {
+			t.Fatal("without restarting, we should not use a relay")
+		}
+	})
+
+	t.Run("with a dead tunnel and without restarting", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Stop()
+		m.current().Stop() // simulate the tunnel dying
+		if err := m.CheckHealth(context.Background()); !errors.Is(err, ErrTunnelDead) {
+			t.Fatal("unexpected err", err)
+		}
+		if m.Restarts() != 0 {
+			t.Fatal("should not have restarted")
+		}
+	})
+
+	t.Run("with a tunnel that cannot reach the endpoint", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener.Close() // nobody is listening here anymore
+		config := newConfig(t)
+		config.HealthCheckEndpoint = listener.Addr().String()
+		m, _, err := StartMonitor(context.Background(), config, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Stop()
+		if err := m.CheckHealth(context.Background()); !errors.Is(err, ErrTunnelDead) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("the restarted tunnel outlives the context of CheckHealth", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tunnelCtx context.Context
+		m.start = func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+			tunnelCtx = ctx
+			return Start(ctx, config)
+		}
+		m.current().Stop() // simulate the tunnel dying
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := m.CheckHealth(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if tunnelCtx.Err() != nil {
+			t.Fatal("the tunnel context should still be alive")
+		}
+		m.Stop()
+		if tunnelCtx.Err() == nil {
+			t.Fatal("Stop should cancel the tunnel context")
+		}
+	})
+
+	t.Run("with a dead tunnel and restarting", func(t *testing.T) {
+		srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("antani"))
+		}))
+		defer srvr.Close()
+		m, _, err := StartMonitor(context.Background(), newConfig(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Stop()
+		proxyURL := m.SOCKS5ProxyURL().String()
+		m.current().Stop() // simulate the tunnel dying
+		if err := m.CheckHealth(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if m.Restarts() != 1 {
+			t.Fatal("should have restarted once")
+		}
+		if m.SOCKS5ProxyURL().String() != proxyURL {
+			t.Fatal("the proxy URL should not change across restarts")
+		}
+		body, err := fetchUsingTunnel(m, srvr.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "antani" {
+			t.Fatal("unexpected body", body)
+		}
+	})
+
+	t.Run("we do not hold the lock while restarting", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Stop()
+		restarting, unblock := make(chan bool), make(chan bool)
+		m.start = func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+			close(restarting)
+			<-unblock
+			return Start(ctx, config)
+		}
+		m.current().Stop() // simulate the tunnel dying
+		errch := make(chan error)
+		go func() {
+			errch <- m.CheckHealth(context.Background())
+		}()
+		<-restarting
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			m.BootstrapTime()
+			m.Restarts()
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("the monitor is locked while restarting")
+		}
+		close(unblock)
+		if err := <-errch; err != nil {
+			t.Fatal(err)
+		}
+		if m.Restarts() != 1 {
+			t.Fatal("should have restarted once")
+		}
+	})
+
+	t.Run("when Stop is called while restarting", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var restarted Tunnel
+		m.start = func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+			m.Stop()
+			tun, debugInfo, err := Start(context.Background(), config)
+			restarted = tun
+			return tun, debugInfo, err
+		}
+		m.current().Stop() // simulate the tunnel dying
+		if err := m.CheckHealth(context.Background()); !errors.Is(err, ErrMonitorStopped) {
+			t.Fatal("unexpected err", err)
+		}
+		if m.current() == restarted {
+			t.Fatal("should not have swapped the tunnel")
+		}
+	})
+
+	t.Run("after Stop", func(t *testing.T) {
+		m, _, err := StartMonitor(context.Background(), newConfig(t), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Stop()
+		m.Stop() // should be idempotent
+		if err := m.CheckHealth(context.Background()); !errors.Is(err, ErrMonitorStopped) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package tunnel
+
+//
+// Reporting the bootstrap progress
+//
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Psiphon-Labs/psiphon-tunnel-core/ClientLibrary/clientlib"
+)
+
+// BootstrapEvent is emitted while a tunnel is bootstrapping.
+type BootstrapEvent struct {
+	// Name is the name of the tunnel.
+	Name string
+
+	// Progress is the bootstrap progress as a percentage.
+	Progress int
+
+	// Summary is a human readable description of the current phase.
+	Summary string
+}
+
+// emitBootstrapProgress calls BootstrapProgress, if configured.
+func (c *Config) emitBootstrapProgress(name string, progress int, summary string) {
+	if c.BootstrapProgress != nil {
+		c.BootstrapProgress(&BootstrapEvent{
+			Name:     name,
+			Progress: progress,
+			Summary:  summary,
+		})
+	}
+}
+
+// parseTorBootstrapPhase parses tor's BOOTSTRAP status events, e.g.:
+//
+//	NOTICE BOOTSTRAP PROGRESS=50 TAG=loading_descriptors SUMMARY="Loading relay descriptors"
+//
+// and returns the progress and the summary. The boolean return value is
+// false when we cannot parse the progress.
+func parseTorBootstrapPhase(value string) (int, string, bool) {
+	var (
+		progress = -1
+		summary  string
+	)
+	for _, field := range strings.Fields(value) {
+		if strings.HasPrefix(field, "PROGRESS=") {
+			p, err := strconv.Atoi(strings.TrimPrefix(field, "PROGRESS="))
+			if err != nil || p < 0 || p > 100 {
+				return 0, "", false
+			}
+			progress = p
+		}
+	}
+	if idx := strings.Index(value, `SUMMARY="`); idx >= 0 {
+		rest := value[idx+len(`SUMMARY="`):]
+		if end := strings.Index(rest, `"`); end >= 0 {
+			summary = rest[:end]
+		}
+	}
+	if progress < 0 {
+		return 0, "", false
+	}
+	return progress, summary, true
+}
+
+// psiphonNoticesProgress maps the psiphon notices we're interested
+// into to their approximate bootstrap progress. Note that psiphon emits
+// some of these notices only when diagnostic notices are enabled.
+var psiphonNoticesProgress = map[string]int{
+	"CandidateServers": 10,
+	"ConnectingServer": 30,
+	"ConnectedServer":  60,
+	"ActiveTunnel":     90,
+}
+
+// psiphonNoticeReceiver returns a function that receives psiphon
+// notices and emits the corresponding bootstrap events.
+func psiphonNoticeReceiver(config *Config) func(clientlib.NoticeEvent) {
+	return func(notice clientlib.NoticeEvent) {
+		if notice.Type == "Tunnels" {
+			if count, _ := notice.Data["count"].(float64); count > 0 {
+				config.emitBootstrapProgress("psiphon", 100, "Tunnels")
+			}
+			return
+		}
+		if progress, found := psiphonNoticesProgress[notice.Type]; found {
+			config.emitBootstrapProgress("psiphon", progress, notice.Type)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package tunnel
+
+import (
+	"testing"
+
+	"github.com/Psiphon-Labs/psiphon-tunnel-core/ClientLibrary/clientlib"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTorBootstrapPhase(t *testing.T) {
+	type testcase struct {
+		name     string
+		input    string
+		progress int
+		summary  string
+		good     bool
+	}
+	testcases := []testcase{{
+		name:     "with a valid bootstrap phase",
+		input:    `NOTICE BOOTSTRAP PROGRESS=50 TAG=loading_descriptors SUMMARY="Loading relay descriptors"`,
+		progress: 50,
+		summary:  "Loading relay descriptors",
+		good:     true,
+	}, {
+		name:     "without summary",
+		input:    `NOTICE BOOTSTRAP PROGRESS=100 TAG=done`,
+		progress: 100,
+		good:     true,
+	}, {
+		name:  "without progress",
+		input: `NOTICE BOOTSTRAP TAG=done SUMMARY="Done"`,
+	}, {
+		name:  "with invalid progress",
+		input: `NOTICE BOOTSTRAP PROGRESS=antani`,
+	}, {
+		name:  "with out of range progress",
+		input: `NOTICE BOOTSTRAP PROGRESS=117`,
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			progress, summary, good := parseTorBootstrapPhase(tc.input)
+			if progress != tc.progress || summary != tc.summary || good != tc.good {
+				t.Fatal("unexpected result", progress, summary, good)
+			}
+		})
+	}
+}
+
+func TestPsiphonNoticeReceiver(t *testing.T) {
+	var events []*BootstrapEvent
+	config := &Config{
+		BootstrapProgress: func(ev *BootstrapEvent) {
+			events = append(events, ev)
+		},
+	}
+	receiver := psiphonNoticeReceiver(config)
+	receiver(clientlib.NoticeEvent{Type: "ConnectingServer"})
+	receiver(clientlib.NoticeEvent{Type: "Info"})
+	receiver(clientlib.NoticeEvent{Type: "Tunnels", Data: map[string]any{"count": float64(0)}})
+	receiver(clientlib.NoticeEvent{Type: "Tunnels", Data: map[string]any{"count": float64(1)}})
+	expected := []*BootstrapEvent{{
+		Name:     "psiphon",
+		Progress: 30,
+		Summary:  "ConnectingServer",
+	}, {
+		Name:     "psiphon",
+		Progress: 100,
+		Summary:  "Tunnels",
+	}}
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -12,10 +12,10 @@ import (
 )
 
 // mockableStartPsiphon allows us to test for psiphon startup failures.
-var mockableStartPsiphon = func(
-	ctx context.Context, config []byte, workdir string) (*clientlib.PsiphonTunnel, error) {
+var mockableStartPsiphon = func(ctx context.Context, config []byte, workdir string,
+	noticeReceiver func(clientlib.NoticeEvent)) (*clientlib.PsiphonTunnel, error) {
 	return clientlib.StartTunnel(ctx, config, "", clientlib.Parameters{
-		DataRootDirectory: &workdir}, nil, nil)
+		DataRootDirectory: &workdir}, nil, noticeReceiver)
 }
 
 // psiphonTunnel is a psiphon tunnel
@@ -60,7 +60,7 @@ func psiphonStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error
 		return nil, debugInfo, err
 	}
 	start := time.Now()
-	tunnel, err := mockableStartPsiphon(ctx, configJSON, workdir)
+	tunnel, err := mockableStartPsiphon(ctx, configJSON, workdir, psiphonNoticeReceiver(config))
 	if err != nil {
 		return nil, debugInfo, err
 	}
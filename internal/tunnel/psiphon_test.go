@@ -86,8 +86,8 @@ func TestPsiphonStartFailure(t *testing.T) {
 	defer func() {
 		mockableStartPsiphon = oldStartPsiphon
 	}()
-	mockableStartPsiphon = func(ctx context.Context, config []byte,
-		workdir string) (*clientlib.PsiphonTunnel, error) {
+	mockableStartPsiphon = func(ctx context.Context, config []byte, workdir string,
+		noticeReceiver func(clientlib.NoticeEvent)) (*clientlib.PsiphonTunnel, error) {
 		return nil, expected
 	}
 	tunnel, _, err := psiphonStart(context.Background(), &Config{
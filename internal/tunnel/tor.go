@@ -10,6 +10,9 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/cretz/bine/control"
+	"github.com/cretz/bine/tor"
 )
 
 // torProcess is a running tor process.
@@ -93,11 +96,18 @@ func torStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 	debugInfo.Version = protoInfo.TorVersion
 	instance.StopProcessOnClose = true
 	start := time.Now()
-	if err := config.torEnableNetwork(ctx, instance, true); err != nil {
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	watchDone := make(chan bool)
+	go torWatchBootstrap(watchCtx, config, instance, watchDone)
+	err = config.torEnableNetwork(ctx, instance, true)
+	cancelWatch()
+	<-watchDone
+	if err != nil {
 		instance.Close()
 		return nil, debugInfo, err
 	}
 	stop := time.Now()
+	config.emitBootstrapProgress(config.Name, 100, "Done")
 	// Adapted from <https://git.io/Jfc7N>
 	info, err := config.torGetInfo(instance.Control, "net/listeners/socks")
 	if err != nil {
@@ -120,6 +130,61 @@ func torStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 	}, debugInfo, nil
 }
 
+// torWatchBootstrap subscribes to tor's STATUS_CLIENT events and reports the
+// bootstrap progress using config.BootstrapProgress until ctx is done. This
+// function closes the done channel when it's finished.
+func torWatchBootstrap(ctx context.Context, config *Config, instance *tor.Tor, done chan<- bool) {
+	defer close(done)
+	if config.BootstrapProgress == nil || instance.Control == nil {
+		return
+	}
+	// Implementation note: we don't need to call HandleEvents because
+	// tor.EnableNetwork does that while waiting for the bootstrap.
+	events := make(chan control.Event, 16)
+	if err := config.torAddEventListener(instance.Control, events, control.EventCodeStatusClient); err != nil {
+		return
+	}
+	defer func() {
+		// Implementation note: bine blocks when sending events to a listener
+		// so we keep draining until we have removed our listener.
+		removed := make(chan bool)
+		go func() {
+			defer close(removed)
+			config.torRemoveEventListener(instance.Control, events, control.EventCodeStatusClient)
+		}()
+		for {
+			select {
+			case <-events:
+			case <-removed:
+				return
+			}
+		}
+	}()
+	torRelayBootstrapEvents(ctx, config, events)
+}
+
+// torRelayBootstrapEvents reports the BOOTSTRAP status events read from
+// events using config.BootstrapProgress until ctx is done.
+func torRelayBootstrapEvents(ctx context.Context, config *Config, events <-chan control.Event) {
+	lastProgress := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			status, ok := ev.(*control.StatusEvent)
+			if !ok || status.Action != "BOOTSTRAP" {
+				continue
+			}
+			progress, summary, good := parseTorBootstrapPhase(status.Raw)
+			if good && progress != lastProgress {
+				config.emitBootstrapProgress(config.Name, progress, summary)
+				lastProgress = progress
+			}
+		}
+	}
+}
+
 // maybeCleanupTunnelDir removes stale files inside
 // of the tunnel directory.
 func maybeCleanupTunnelDir(dir, logfile string) {
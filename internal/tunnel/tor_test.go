@@ -309,6 +309,65 @@ func TestTorUnsupportedProxy(t *testing.T) {
 	}
 }
 
+func TestTorBootstrapProgress(t *testing.T) {
+	ctx := context.Background()
+	var events []*BootstrapEvent
+	first := make(chan bool)
+	tun, _, err := torStart(ctx, &Config{
+		Name:      "tor",
+		Session:   &MockableSession{},
+		TunnelDir: "testdata",
+		BootstrapProgress: func(ev *BootstrapEvent) {
+			events = append(events, ev)
+			if len(events) == 1 {
+				close(first)
+			}
+		},
+		testExecabsLookPath: func(name string) (string, error) {
+			return "/usr/local/bin/tor", nil
+		},
+		testTorStart: func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error) {
+			return &tor.Tor{Control: &control.Conn{}}, nil
+		},
+		testTorProtocolInfo: func(tor *tor.Tor) (*control.ProtocolInfo, error) {
+			return &control.ProtocolInfo{}, nil
+		},
+		testTorEnableNetwork: func(ctx context.Context, tor *tor.Tor, wait bool) error {
+			<-first // wait for the watcher to emit the first event
+			return nil
+		},
+		testTorAddEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			go func() {
+				ch <- control.ParseStatusEvent(control.EventCodeStatusClient, `NOTICE CIRCUIT_ESTABLISHED`)
+				ch <- control.ParseStatusEvent(control.EventCodeStatusClient,
+					`NOTICE BOOTSTRAP PROGRESS=50 TAG=loading_descriptors SUMMARY="Loading relay descriptors"`)
+			}()
+			return nil
+		},
+		testTorRemoveEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			return nil
+		},
+		testTorGetInfo: func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
+			return []*control.KeyVal{{Key: "net/listeners/socks", Val: "127.0.0.1:9050"}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tun == nil {
+		t.Fatal("expected non-nil tunnel here")
+	}
+	if len(events) != 2 {
+		t.Fatal("unexpected number of events", len(events))
+	}
+	if events[0].Progress != 50 || events[0].Summary != "Loading relay descriptors" {
+		t.Fatal("unexpected first event", events[0])
+	}
+	if events[1].Progress != 100 || events[1].Name != "tor" {
+		t.Fatal("unexpected last event", events[1])
+	}
+}
+
 func TestMaybeCleanupTunnelDir(t *testing.T) {
 	fakeTunDir := filepath.Join("testdata", "fake-tun-dir")
 	if err := os.RemoveAll(fakeTunDir); err != nil {
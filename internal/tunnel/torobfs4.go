@@ -0,0 +1,86 @@
+package tunnel
+
+//
+// tor+obfs4: Tor+obfs4 tunnel
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ooni/probe-cli/v3/internal/ptx"
+)
+
+// ErrNoBridgeLines indicates that config.BridgeLines is empty.
+var ErrNoBridgeLines = errors.New("tunnel: no bridge lines")
+
+// torobfs4Start starts the tor+obfs4 tunnel. We try each bridge line
+// in config.BridgeLines in order and stop at the first one working.
+func torobfs4Start(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+	debugInfo := DebugInfo{Name: "tor+obfs4"}
+	if err := ctx.Err(); err != nil {
+		return nil, debugInfo, err
+	}
+	if config.TunnelDir == "" {
+		return nil, debugInfo, ErrEmptyTunnelDir
+	}
+	if len(config.BridgeLines) <= 0 {
+		return nil, debugInfo, ErrNoBridgeLines
+	}
+	var errs []error
+	for _, line := range config.BridgeLines {
+		tun, di, err := torobfs4StartWithBridgeLine(ctx, config, line)
+		if err == nil {
+			return tun, di, nil
+		}
+		config.logger().Warnf("tunnel: tor+obfs4 failed with %s: %s", line, err.Error())
+		debugInfo = di
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break // no point in trying the next bridge
+		}
+	}
+	return nil, debugInfo, fmt.Errorf("tunnel: all tor+obfs4 bridges failed: %w", errs[len(errs)-1])
+}
+
+// torobfs4StartWithBridgeLine starts the tor+obfs4 tunnel using a single bridge line.
+func torobfs4StartWithBridgeLine(ctx context.Context, config *Config, line string) (Tunnel, DebugInfo, error) {
+	debugInfo := DebugInfo{Name: "tor+obfs4"}
+
+	// 1. start a listener using obfs4
+	dialer, err := ptx.NewOBFS4DialerFromBridgeLine(line, config.TunnelDir)
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	ptl := config.sfNewPTXListener(ctx, dialer)
+	if err := ptl.Start(); err != nil {
+		return nil, debugInfo, err
+	}
+
+	// 2. append arguments to a copy of the configuration, since we
+	// must not modify the config and each attempt needs the original
+	// arguments plus the arguments for its own bridge line
+	extraArguments := []string{
+		"UseBridges", "1",
+		"ClientTransportPlugin", ptl.AsClientTransportPluginArgument(),
+		"Bridge", dialer.AsBridgeArgument(),
+	}
+	torConfig := *config
+	torConfig.TorArgs = append(append([]string{}, config.TorArgs...), extraArguments...)
+
+	// 3. start tor as we would normally do
+	torTunnel, debugInfo, err := config.sfTorStart(ctx, &torConfig)
+	debugInfo.Name = "tor+obfs4"
+	if err != nil {
+		ptl.Stop()
+		return nil, debugInfo, err
+	}
+
+	// 4. wrap the tunnel and the listener
+	tt := &torsfTunnel{
+		torTunnel:  torTunnel,
+		sfListener: ptl,
+	}
+	return tt, debugInfo, nil
+}
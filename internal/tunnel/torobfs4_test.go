@@ -0,0 +1,166 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-cli/v3/internal/model"
+	"github.com/ooni/probe-cli/v3/internal/model/mocks"
+	"github.com/ooni/probe-cli/v3/internal/ptx"
+)
+
+func Test_torobfs4Start(t *testing.T) {
+	bridgeLine := ptx.DefaultTestingOBFS4Bridge().AsBridgeArgument()
+
+	t.Run("without bridge lines", func(t *testing.T) {
+		config := &Config{
+			Name:      "tor+obfs4",
+			Session:   &MockableSession{},
+			TunnelDir: t.TempDir(),
+			Logger:    model.DiscardLogger,
+		}
+		tun, _, err := torobfs4Start(context.Background(), config)
+		if !errors.Is(err, ErrNoBridgeLines) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("without a tunnel dir", func(t *testing.T) {
+		config := &Config{
+			Name:        "tor+obfs4",
+			Session:     &MockableSession{},
+			BridgeLines: []string{bridgeLine},
+			Logger:      model.DiscardLogger,
+		}
+		tun, _, err := torobfs4Start(context.Background(), config)
+		if !errors.Is(err, ErrEmptyTunnelDir) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("with an invalid bridge line", func(t *testing.T) {
+		config := &Config{
+			Name:        "tor+obfs4",
+			Session:     &MockableSession{},
+			BridgeLines: []string{"obfs4"},
+			TunnelDir:   t.TempDir(),
+			Logger:      model.DiscardLogger,
+		}
+		tun, _, err := torobfs4Start(context.Background(), config)
+		if !errors.Is(err, ptx.ErrInvalidBridgeLine) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("we try the next bridge line when torStart fails", func(t *testing.T) {
+		stopCounter := &atomic.Int64{}
+		expected := errors.New("mocked error")
+		var attempts [][]string
+		config := &Config{
+			Name:        "tor+obfs4",
+			Session:     &MockableSession{},
+			BridgeLines: []string{bridgeLine, bridgeLine},
+			TunnelDir:   t.TempDir(),
+			Logger:      model.DiscardLogger,
+			TorArgs:     []string{"--antani"},
+			testSfWrapPTXListener: func(tp torsfPTXListener) torsfPTXListener {
+				return &torsfPTXListenerWrapper{
+					torsfPTXListener: tp,
+					counter:          stopCounter,
+				}
+			},
+			testSfTorStart: func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+				attempts = append(attempts, config.TorArgs)
+				return nil, DebugInfo{}, expected
+			},
+		}
+		tun, debugInfo, err := torobfs4Start(context.Background(), config)
+		if !errors.Is(err, expected) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+		if debugInfo.Name != "tor+obfs4" {
+			t.Fatal("unexpected debug info name", debugInfo.Name)
+		}
+		if stopCounter.Load() != 2 {
+			t.Fatal("did not call stop for each attempt")
+		}
+		if len(attempts) != 2 {
+			t.Fatal("unexpected number of attempts", len(attempts))
+		}
+		for _, args := range attempts {
+			if len(args) != 7 || args[0] != "--antani" || args[6] != bridgeLine {
+				t.Fatal("unexpected tor args", args)
+			}
+		}
+		if diff := cmp.Diff([]string{"--antani"}, config.TorArgs); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("on success", func(t *testing.T) {
+		expectDebugInfo := DebugInfo{
+			Name: "tor+obfs4",
+		}
+		config := &Config{
+			Name:        "tor+obfs4",
+			Session:     &MockableSession{},
+			BridgeLines: []string{bridgeLine},
+			TunnelDir:   t.TempDir(),
+			Logger:      model.DiscardLogger,
+			TorArgs:     []string{"--antani"},
+			testSfTorStart: func(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+				if len(config.TorArgs) != 7 {
+					t.Fatal("unexpected tor args", config.TorArgs)
+				}
+				tun := &fakeTunnel{
+					addr: &mocks.Addr{
+						MockString: func() string {
+							return "127.0.0.1:5555"
+						},
+					},
+					bootstrapTime: 123,
+					listener: &mocks.Listener{
+						MockClose: func() error {
+							return nil
+						},
+					},
+					once: sync.Once{},
+				}
+				return tun, DebugInfo{}, nil
+			},
+		}
+		tun, debugInfo, err := torobfs4Start(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expectDebugInfo, debugInfo); diff != "" {
+			t.Fatal(diff)
+		}
+		if tun.BootstrapTime() != 123 {
+			t.Fatal("invalid bootstrap time")
+		}
+		if tun.SOCKS5ProxyURL().String() != "socks5://127.0.0.1:5555" {
+			t.Fatal("invalid socks5 proxy URL")
+		}
+		if diff := cmp.Diff([]string{"--antani"}, config.TorArgs); diff != "" {
+			t.Fatal(diff)
+		}
+		tun.Stop()
+	})
+}
@@ -107,6 +107,20 @@ type DebugInfo struct {
 // case, fetching the Psiphon configuration from the backend may
 // fail when the backend is not reachable.
 //
+// The "torsf" tunnel uses tor with the snowflake pluggable transport
+// and config.SnowflakeRendezvous as the rendezvous method.
+//
+// The "tor+obfs4" tunnel uses tor with the obfs4 pluggable transport
+// and the bridges in config.BridgeLines. We try each bridge line in
+// order and use the first one that allows tor to bootstrap.
+//
+// The "socks5" tunnel uses the SOCKS5 proxy in config.UpstreamProxyURL
+// after checking that we can connect to it.
+//
+// The "http-connect" tunnel exposes a local SOCKS5 proxy that creates
+// connections using HTTP CONNECT through config.UpstreamProxyURL, which
+// must be an "http" URL optionally containing user and password.
+//
 // The "fake" tunnel is a fake tunnel that just exposes a
 // SOCKS5 proxy and then connects directly to server. We use
 // this special kind of tunnel to implement tests.
@@ -128,6 +142,12 @@ func Start(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
 		return torsfStart(ctx, config)
 	case "tor":
 		return torStart(ctx, config)
+	case "tor+obfs4":
+		return torobfs4Start(ctx, config)
+	case "socks5":
+		return socks5Start(ctx, config)
+	case "http-connect":
+		return httpConnectStart(ctx, config)
 	default:
 		di := DebugInfo{}
 		return nil, di, fmt.Errorf("%w: %s", ErrUnsupportedTunnelName, config.Name)
@@ -0,0 +1,232 @@
+package tunnel
+
+//
+// socks5 and http-connect: tunnels using an upstream proxy
+//
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/armon/go-socks5"
+	"github.com/ooni/probe-cli/v3/internal/netxlite"
+)
+
+// ErrNoUpstreamProxy indicates that config.UpstreamProxyURL is nil.
+var ErrNoUpstreamProxy = errors.New("tunnel: no upstream proxy URL")
+
+// ErrUnsupportedUpstreamProxy indicates that config.UpstreamProxyURL has
+// a scheme that is not compatible with the selected tunnel.
+var ErrUnsupportedUpstreamProxy = errors.New("tunnel: unsupported upstream proxy URL")
+
+// upstreamDialTimeout is the timeout used for connecting to the upstream proxy.
+const upstreamDialTimeout = 15 * time.Second
+
+// upstreamDial connects to the upstream proxy endpoint.
+func upstreamDial(ctx context.Context, config *Config, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamDialTimeout)
+	defer cancel()
+	dialer := netxlite.NewDialerWithStdlibResolver(config.logger())
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// socks5Tunnel is a tunnel using an upstream SOCKS5 proxy.
+type socks5Tunnel struct {
+	bootstrapTime time.Duration
+	proxyURL      *url.URL
+}
+
+// BootstrapTime implements Tunnel.BootstrapTime.
+func (t *socks5Tunnel) BootstrapTime() time.Duration {
+	return t.bootstrapTime
+}
+
+// SOCKS5ProxyURL implements Tunnel.SOCKS5ProxyURL.
+func (t *socks5Tunnel) SOCKS5ProxyURL() *url.URL {
+	return t.proxyURL
+}
+
+// Stop implements Tunnel.Stop.
+func (t *socks5Tunnel) Stop() {
+	// nothing to do
+}
+
+// socks5Start starts the socks5 tunnel. Because the upstream is already
+// a SOCKS5 proxy, we just check whether it's reachable.
+func socks5Start(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+	debugInfo := DebugInfo{Name: "socks5"}
+	if err := ctx.Err(); err != nil {
+		return nil, debugInfo, err
+	}
+	URL := config.UpstreamProxyURL
+	if URL == nil {
+		return nil, debugInfo, ErrNoUpstreamProxy
+	}
+	if URL.Scheme != "socks5" {
+		return nil, debugInfo, fmt.Errorf("%w: %s", ErrUnsupportedUpstreamProxy, URL.Scheme)
+	}
+	start := time.Now()
+	conn, err := upstreamDial(ctx, config, URL.Host)
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	conn.Close()
+	return &socks5Tunnel{
+		bootstrapTime: time.Since(start),
+		proxyURL:      URL,
+	}, debugInfo, nil
+}
+
+// httpConnectTunnel is a tunnel exposing a local SOCKS5 proxy
+// that forwards connections using an upstream HTTP proxy.
+type httpConnectTunnel struct {
+	addr          net.Addr
+	bootstrapTime time.Duration
+	listener      net.Listener
+	once          sync.Once
+}
+
+// BootstrapTime implements Tunnel.BootstrapTime.
+func (t *httpConnectTunnel) BootstrapTime() time.Duration {
+	return t.bootstrapTime
+}
+
+// SOCKS5ProxyURL implements Tunnel.SOCKS5ProxyURL.
+func (t *httpConnectTunnel) SOCKS5ProxyURL() *url.URL {
+	return &url.URL{
+		Scheme: "socks5",
+		Host:   t.addr.String(),
+	}
+}
+
+// Stop implements Tunnel.Stop.
+func (t *httpConnectTunnel) Stop() {
+	t.once.Do(func() { t.listener.Close() })
+}
+
+// httpConnectStart starts the http-connect tunnel.
+func httpConnectStart(ctx context.Context, config *Config) (Tunnel, DebugInfo, error) {
+	debugInfo := DebugInfo{Name: "http-connect"}
+	if err := ctx.Err(); err != nil {
+		return nil, debugInfo, err
+	}
+	URL := config.UpstreamProxyURL
+	if URL == nil {
+		return nil, debugInfo, ErrNoUpstreamProxy
+	}
+	if URL.Scheme != "http" {
+		return nil, debugInfo, fmt.Errorf("%w: %s", ErrUnsupportedUpstreamProxy, URL.Scheme)
+	}
+	start := time.Now()
+	conn, err := upstreamDial(ctx, config, URL.Host)
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	conn.Close()
+	dialer := &httpConnectDialer{config: config, proxyURL: URL}
+	server, err := config.socks5New(&socks5.Config{Dial: dialer.DialContext})
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	listener, err := config.netListen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, debugInfo, err
+	}
+	go server.Serve(listener)
+	return &httpConnectTunnel{
+		addr:          listener.Addr(),
+		bootstrapTime: time.Since(start),
+		listener:      listener,
+	}, debugInfo, nil
+}
+
+// ErrHTTPConnectFailed indicates that the upstream HTTP proxy
+// did not accept our CONNECT request.
+var ErrHTTPConnectFailed = errors.New("tunnel: HTTP CONNECT failed")
+
+// httpConnectHandshakeTimeout is the default timeout for sending the
+// CONNECT request and reading the upstream proxy response.
+const httpConnectHandshakeTimeout = 15 * time.Second
+
+// httpConnectDialer dials connections using HTTP CONNECT.
+type httpConnectDialer struct {
+	config   *Config
+	proxyURL *url.URL
+
+	// handshakeTimeout is the OPTIONAL CONNECT handshake timeout. If
+	// zero, we use the httpConnectHandshakeTimeout default.
+	handshakeTimeout time.Duration
+}
+
+// timeout returns the CONNECT handshake timeout.
+func (d *httpConnectDialer) timeout() time.Duration {
+	if d.handshakeTimeout > 0 {
+		return d.handshakeTimeout
+	}
+	return httpConnectHandshakeTimeout
+}
+
+// DialContext creates a connection to address through the upstream proxy.
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := upstreamDial(ctx, d.config, d.proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	// Implementation note: go-socks5 calls us with a context without
+	// deadline, so we need a timeout to avoid hanging forever when the
+	// upstream proxy does not answer our CONNECT request.
+	deadline := time.Now().Add(d.timeout())
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrHTTPConnectFailed, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	// Implementation note: the reader may have buffered data the
+	// server sent right after the response, so we must use it.
+	return &httpConnectConn{Conn: conn, reader: reader}, nil
+}
+
+// httpConnectConn is a net.Conn reading from a bufio.Reader.
+type httpConnectConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read implements net.Conn.Read.
+func (c *httpConnectConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
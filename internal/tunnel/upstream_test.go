@@ -0,0 +1,233 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-cli/v3/internal/model"
+)
+
+// startConnectProxy starts an HTTP CONNECT proxy requiring the given
+// Proxy-Authorization header (if not empty) and returns its URL.
+func startConnectProxy(t *testing.T, auth string) *url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				req, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				if req.Method != "CONNECT" {
+					io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+					return
+				}
+				if auth != "" && req.Header.Get("Proxy-Authorization") != auth {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, reader)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return &url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+// fetchUsingTunnel fetches the given URL using the tunnel proxy.
+func fetchUsingTunnel(tun Tunnel, URL string) (string, error) {
+	clnt := &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyURL(tun.SOCKS5ProxyURL()),
+	}}
+	defer clnt.CloseIdleConnections()
+	resp, err := clnt.Get(URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func Test_httpConnectStart(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("antani"))
+	}))
+	defer srvr.Close()
+
+	t.Run("without upstream proxy URL", func(t *testing.T) {
+		config := &Config{Name: "http-connect", Logger: model.DiscardLogger}
+		tun, _, err := httpConnectStart(context.Background(), config)
+		if !errors.Is(err, ErrNoUpstreamProxy) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("with a non-http upstream proxy URL", func(t *testing.T) {
+		config := &Config{
+			Name:             "http-connect",
+			Logger:           model.DiscardLogger,
+			UpstreamProxyURL: &url.URL{Scheme: "socks5", Host: "127.0.0.1:1080"},
+		}
+		tun, _, err := httpConnectStart(context.Background(), config)
+		if !errors.Is(err, ErrUnsupportedUpstreamProxy) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("on success", func(t *testing.T) {
+		config := &Config{
+			Name:             "http-connect",
+			Logger:           model.DiscardLogger,
+			UpstreamProxyURL: startConnectProxy(t, ""),
+		}
+		tun, debugInfo, err := httpConnectStart(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tun.Stop()
+		if debugInfo.Name != "http-connect" {
+			t.Fatal("unexpected debug info name", debugInfo.Name)
+		}
+		body, err := fetchUsingTunnel(tun, srvr.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "antani" {
+			t.Fatal("unexpected body", body)
+		}
+	})
+
+	t.Run("with proxy authentication", func(t *testing.T) {
+		URL := startConnectProxy(t, "Basic dXNlcjpwYXNz") // user:pass
+		URL.User = url.UserPassword("user", "pass")
+		config := &Config{
+			Name:             "http-connect",
+			Logger:           model.DiscardLogger,
+			UpstreamProxyURL: URL,
+		}
+		tun, _, err := httpConnectStart(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tun.Stop()
+		body, err := fetchUsingTunnel(tun, srvr.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "antani" {
+			t.Fatal("unexpected body", body)
+		}
+	})
+
+	t.Run("when the proxy refuses CONNECT", func(t *testing.T) {
+		URL := startConnectProxy(t, "Basic dXNlcjpwYXNz")
+		dialer := &httpConnectDialer{
+			config:   &Config{Logger: model.DiscardLogger},
+			proxyURL: URL,
+		}
+		conn, err := dialer.DialContext(context.Background(), "tcp", srvr.Listener.Addr().String())
+		if !errors.Is(err, ErrHTTPConnectFailed) {
+			t.Fatal("unexpected err", err)
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+
+	t.Run("when the proxy does not answer CONNECT", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close() // keep the connection open but silent
+			}
+		}()
+		dialer := &httpConnectDialer{
+			config:           &Config{Logger: model.DiscardLogger},
+			proxyURL:         &url.URL{Scheme: "http", Host: listener.Addr().String()},
+			handshakeTimeout: 100 * time.Millisecond,
+		}
+		conn, err := dialer.DialContext(context.Background(), "tcp", srvr.Listener.Addr().String())
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatal("unexpected err", err)
+		}
+		if conn != nil {
+			t.Fatal("expected nil conn")
+		}
+	})
+}
+
+func Test_socks5Start(t *testing.T) {
+	t.Run("without upstream proxy URL", func(t *testing.T) {
+		config := &Config{Name: "socks5", Logger: model.DiscardLogger}
+		tun, _, err := socks5Start(context.Background(), config)
+		if !errors.Is(err, ErrNoUpstreamProxy) {
+			t.Fatal("unexpected err", err)
+		}
+		if tun != nil {
+			t.Fatal("expected nil tun")
+		}
+	})
+
+	t.Run("on success", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		URL := &url.URL{Scheme: "socks5", Host: listener.Addr().String()}
+		config := &Config{
+			Name:             "socks5",
+			Logger:           model.DiscardLogger,
+			UpstreamProxyURL: URL,
+		}
+		tun, _, err := socks5Start(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tun.Stop()
+		if tun.SOCKS5ProxyURL().String() != URL.String() {
+			t.Fatal("unexpected proxy URL", tun.SOCKS5ProxyURL())
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"runtime"
 	"sync"
@@ -97,6 +98,12 @@ type SessionConfig struct {
 	// information required by a Session.
 	StateDir string
 
+	// SubmitQueueDir is the optional directory where the Session
+	// shall queue the measurements that Submit could not submit, so
+	// that FlushSubmitQueue can retry later, possibly from another
+	// Session. If empty, the Session does not queue measurements.
+	SubmitQueueDir string
+
 	// TempDir is the mandatory directory where the Session shall
 	// store temporary files. Among other tasks, Session.Close will
 	// remove any temporary file created within this Session.
@@ -187,6 +194,7 @@ func newSessionWithContext(ctx context.Context, config *SessionConfig) (*Session
 		ProxyURL:               proxyURL,
 		SoftwareName:           config.SoftwareName,
 		SoftwareVersion:        config.SoftwareVersion,
+		SubmitQueueDir:         config.SubmitQueueDir,
 		TempDir:                config.TempDir,
 		TunnelDir:              config.TunnelDir,
 	}
@@ -307,23 +315,26 @@ type SubmitMeasurementResults struct {
 
 // Submit submits the given measurement and returns the results.
 //
+// If the SessionConfig contained a SubmitQueueDir and we cannot submit the
+// measurement, we add the measurement to the submission queue and we return
+// an error. You can retry submitting queued measurements by calling
+// FlushSubmitQueue, therefore you should not resubmit them yourself.
+//
 // This function locks the session until it's done. That is, no other operation
 // can be performed as long as this function is pending.
 func (sess *Session) Submit(ctx *Context, measurement string) (*SubmitMeasurementResults, error) {
 	sess.mtx.Lock()
 	defer sess.mtx.Unlock()
-	if sess.submitter == nil {
-		psc, err := sess.sessp.NewProbeServicesClient(ctx.ctx)
-		if err != nil {
-			return nil, err
-		}
-		sess.submitter = probeservices.NewSubmitter(psc, sess.sessp.Logger())
-	}
 	var mm model.Measurement
 	if err := json.Unmarshal([]byte(measurement), &mm); err != nil {
 		return nil, err
 	}
-	if err := sess.submitter.Submit(ctx.ctx, &mm); err != nil {
+	if err := sess.submitLocked(ctx.ctx, &mm); err != nil {
+		if queue := sess.sessp.SubmitQueue(); queue != nil {
+			if _, qerr := queue.Enqueue(&mm, err); qerr == nil {
+				return nil, fmt.Errorf("%w: %s", engine.ErrMeasurementQueued, err.Error())
+			}
+		}
 		return nil, err
 	}
 	data, err := json.Marshal(mm)
@@ -334,6 +345,79 @@ func (sess *Session) Submit(ctx *Context, measurement string) (*SubmitMeasuremen
 	}, nil
 }
 
+// submitLocked submits the given measurement. This function assumes
+// that the caller has already locked the session.
+func (sess *Session) submitLocked(ctx context.Context, mm *model.Measurement) error {
+	if sess.submitter == nil {
+		psc, err := sess.sessp.NewProbeServicesClient(ctx)
+		if err != nil {
+			return err
+		}
+		sess.submitter = probeservices.NewSubmitter(psc, sess.sessp.Logger())
+	}
+	return sess.submitter.Submit(ctx, mm)
+}
+
+// FlushSubmitQueueResults contains the results of FlushSubmitQueue.
+type FlushSubmitQueueResults struct {
+	// Submitted is the number of measurements we submitted.
+	Submitted int64
+
+	// Failed is the number of measurements we could not submit.
+	Failed int64
+
+	// Deferred is the number of measurements we did not try to
+	// submit because their retry backoff has not expired yet.
+	Deferred int64
+}
+
+// FlushSubmitQueue submits the measurements in the submission queue. When
+// force is true, we submit all of them regardless of their retry backoff. This
+// function fails if the SessionConfig did not contain a SubmitQueueDir.
+//
+// This function locks the session until it's done. That is, no other operation
+// can be performed as long as this function is pending.
+func (sess *Session) FlushSubmitQueue(ctx *Context, force bool) (*FlushSubmitQueueResults, error) {
+	sess.mtx.Lock()
+	defer sess.mtx.Unlock()
+	queue := sess.sessp.SubmitQueue()
+	if queue == nil {
+		return nil, engine.ErrNoSubmitQueue
+	}
+	result, err := queue.Flush(ctx.ctx, submitterFunc(sess.submitLocked), force)
+	if err != nil {
+		return nil, err
+	}
+	return &FlushSubmitQueueResults{
+		Submitted: int64(result.Submitted),
+		Failed:    int64(result.Failed),
+		Deferred:  int64(result.Deferred),
+	}, nil
+}
+
+// SubmitQueueLength returns the number of measurements in the submission
+// queue. This function fails if the SessionConfig did not contain
+// a SubmitQueueDir.
+func (sess *Session) SubmitQueueLength() (int64, error) {
+	queue := sess.sessp.SubmitQueue()
+	if queue == nil {
+		return 0, engine.ErrNoSubmitQueue
+	}
+	entries, err := queue.List()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(entries)), nil
+}
+
+// submitterFunc adapts a func to model.Submitter.
+type submitterFunc func(ctx context.Context, mm *model.Measurement) error
+
+// Submit implements model.Submitter.
+func (fn submitterFunc) Submit(ctx context.Context, mm *model.Measurement) error {
+	return fn(ctx, mm)
+}
+
 // CheckInConfigWebConnectivity contains WebConnectivity
 // configuration for the check-in API.
 type CheckInConfigWebConnectivity struct {
@@ -1,6 +1,12 @@
 package oonimkall
 
-import "testing"
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ooni/probe-cli/v3/internal/engine"
+)
 
 func TestNewCheckInInfoWebConnectivityNilPointer(t *testing.T) {
 	out := newCheckInInfoWebConnectivity(nil)
@@ -8,3 +14,50 @@ func TestNewCheckInInfoWebConnectivityNilPointer(t *testing.T) {
 		t.Fatal("expected nil pointer")
 	}
 }
+
+func TestSessionSubmitQueue(t *testing.T) {
+	newSession := func(t *testing.T, queueDir string) *Session {
+		dir := t.TempDir()
+		sess, err := NewSession(&SessionConfig{
+			SoftwareName:    "oonimkall-test",
+			SoftwareVersion: "0.1.0",
+			StateDir:        filepath.Join(dir, "state"),
+			SubmitQueueDir:  queueDir,
+			TempDir:         dir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sess
+	}
+
+	t.Run("without a queue", func(t *testing.T) {
+		sess := newSession(t, "")
+		if _, err := sess.SubmitQueueLength(); !errors.Is(err, engine.ErrNoSubmitQueue) {
+			t.Fatal("unexpected err", err)
+		}
+		if _, err := sess.FlushSubmitQueue(sess.NewContext(), false); !errors.Is(err, engine.ErrNoSubmitQueue) {
+			t.Fatal("unexpected err", err)
+		}
+	})
+
+	t.Run("we queue measurements we cannot submit", func(t *testing.T) {
+		queueDir := filepath.Join(t.TempDir(), "queue")
+		sess := newSession(t, queueDir)
+		ctx := sess.NewContext()
+		ctx.Cancel() // so that we cannot submit
+		_, err := sess.Submit(ctx, `{"test_name":"example"}`)
+		if !errors.Is(err, engine.ErrMeasurementQueued) {
+			t.Fatal("unexpected err", err)
+		}
+		// a different session using the same directory sees the measurement
+		other := newSession(t, queueDir)
+		count, err := other.SubmitQueueLength()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatal("unexpected queue length", count)
+		}
+	})
+}